@@ -0,0 +1,123 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BlockStep is a single step in a path given to BodyAtPath, selecting a
+// nested block by its type and labels.
+type BlockStep struct {
+	Type string
+
+	// Labels are the labels of the block to select. The step selects only
+	// a block with exactly these labels, so this must have as many elements
+	// as blocks of this type expect labels, and is empty for a block type
+	// that expects no labels.
+	Labels []string
+}
+
+// String returns a representation of the step in the style of a block
+// header in the native syntax, such as resource "a" "b".
+func (s BlockStep) String() string {
+	var buf strings.Builder
+	buf.WriteString(s.Type)
+	for _, label := range s.Labels {
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.Quote(label))
+	}
+	return buf.String()
+}
+
+// BodyAtPath navigates from the given root body through a sequence of
+// nested blocks, returning the body of the block selected by the final step.
+// This allows decoding a single known section of a configuration without
+// decoding the content that surrounds it. If the path is empty then the
+// result is the root body itself.
+//
+// Each step is resolved using a minimal schema containing only the block
+// type of that step, so the remaining content of each body along the path is
+// not checked. It is an error for a step to match no blocks, or to match more
+// than one block, in which case the result is nil and the diagnostics refer
+// to the nearest block that was successfully resolved.
+func BodyAtPath(root Body, path []BlockStep) (Body, Diagnostics) {
+	var diags Diagnostics
+
+	body := root
+	var parent *Block
+	for _, step := range path {
+		labelNames := make([]string, len(step.Labels))
+		for i := range labelNames {
+			labelNames[i] = fmt.Sprintf("label%d", i)
+		}
+		content, _, moreDiags := body.PartialContent(&BodySchema{
+			Blocks: []BlockHeaderSchema{
+				{
+					Type:       step.Type,
+					LabelNames: labelNames,
+				},
+			},
+		})
+		diags = append(diags, moreDiags...)
+		if content == nil || moreDiags.HasErrors() {
+			return nil, diags
+		}
+
+		var matches Blocks
+		for _, block := range content.Blocks.OfType(step.Type) {
+			if blockLabelsEqual(block.Labels, step.Labels) {
+				matches = append(matches, block)
+			}
+		}
+
+		var subject *Range
+		var where string
+		if parent != nil {
+			subject = parent.DefRange.Ptr()
+			where = fmt.Sprintf("the %s block", BlockStep{Type: parent.Type, Labels: parent.Labels})
+		} else {
+			subject = body.MissingItemRange().Ptr()
+			where = "the root body"
+		}
+
+		switch len(matches) {
+		case 1:
+			parent = matches[0]
+			body = parent.Body
+		case 0:
+			diags = append(diags, &Diagnostic{
+				Severity: DiagError,
+				Summary:  "Block not found",
+				Detail:   fmt.Sprintf("There is no %s block in %s.", step, where),
+				Subject:  subject,
+			})
+			return nil, diags
+		default:
+			diags = append(diags, &Diagnostic{
+				Severity: DiagError,
+				Summary:  "Ambiguous block path",
+				Detail:   fmt.Sprintf("There are %d %s blocks in %s, so the path does not select a single block. The first is defined at %s.", len(matches), step, where, matches[0].DefRange),
+				Subject:  subject,
+			})
+			return nil, diags
+		}
+	}
+
+	return body, diags
+}
+
+func blockLabelsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"testing"
+)
+
+func TestBodyAtPath(t *testing.T) {
+	target := &testBlocksBody{Name: "target"}
+	root := &testBlocksBody{
+		Name: "root",
+		Blocks: Blocks{
+			{
+				Type:     "module",
+				Labels:   []string{"network"},
+				DefRange: Range{Filename: "module.network"},
+				Body: &testBlocksBody{
+					Name: "module.network",
+					Blocks: Blocks{
+						{
+							Type:     "settings",
+							DefRange: Range{Filename: "settings"},
+							Body:     target,
+						},
+						{
+							Type:     "subnet",
+							Labels:   []string{"a"},
+							DefRange: Range{Filename: "subnet.a.1"},
+							Body:     &testBlocksBody{Name: "subnet.a.1"},
+						},
+						{
+							Type:     "subnet",
+							Labels:   []string{"a"},
+							DefRange: Range{Filename: "subnet.a.2"},
+							Body:     &testBlocksBody{Name: "subnet.a.2"},
+						},
+					},
+				},
+			},
+			{
+				Type:     "module",
+				Labels:   []string{"other"},
+				DefRange: Range{Filename: "module.other"},
+				Body:     &testBlocksBody{Name: "module.other"},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		Path        []BlockStep
+		Want        Body
+		WantDetail  string
+		WantSubject string
+	}{
+		"empty path": {
+			nil,
+			root,
+			"",
+			"",
+		},
+		"nested block": {
+			[]BlockStep{
+				{Type: "module", Labels: []string{"network"}},
+				{Type: "settings"},
+			},
+			target,
+			"",
+			"",
+		},
+		"missing at root": {
+			[]BlockStep{
+				{Type: "module", Labels: []string{"nope"}},
+			},
+			nil,
+			`There is no module "nope" block in the root body.`,
+			"root",
+		},
+		"missing nested": {
+			[]BlockStep{
+				{Type: "module", Labels: []string{"other"}},
+				{Type: "settings"},
+			},
+			nil,
+			`There is no settings block in the module "other" block.`,
+			"module.other",
+		},
+		"ambiguous": {
+			[]BlockStep{
+				{Type: "module", Labels: []string{"network"}},
+				{Type: "subnet", Labels: []string{"a"}},
+			},
+			nil,
+			`There are 2 subnet "a" blocks in the module "network" block, so the path does not select a single block. The first is defined at subnet.a.1:0,0-0.`,
+			"module.network",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, diags := BodyAtPath(root, test.Path)
+			if got != test.Want {
+				t.Errorf("wrong body %#v; want %#v", got, test.Want)
+			}
+			if test.WantDetail == "" {
+				if len(diags) != 0 {
+					t.Errorf("unexpected diagnostics: %s", diags.Error())
+				}
+				return
+			}
+			if len(diags) != 1 {
+				t.Fatalf("wrong number of diagnostics %d; want 1\n%s", len(diags), diags.Error())
+			}
+			if got := diags[0].Detail; got != test.WantDetail {
+				t.Errorf("wrong detail\ngot:  %s\nwant: %s", got, test.WantDetail)
+			}
+			if got := diags[0].Subject.Filename; got != test.WantSubject {
+				t.Errorf("wrong subject %q; want %q", got, test.WantSubject)
+			}
+		})
+	}
+}
+
+// testBlocksBody is a minimal Body containing only blocks, for testing
+// functions that navigate nested blocks.
+type testBlocksBody struct {
+	Name   string
+	Blocks Blocks
+}
+
+func (b *testBlocksBody) Content(schema *BodySchema) (*BodyContent, Diagnostics) {
+	content, _, diags := b.PartialContent(schema)
+	return content, diags
+}
+
+func (b *testBlocksBody) PartialContent(schema *BodySchema) (*BodyContent, Body, Diagnostics) {
+	content := &BodyContent{
+		Attributes: Attributes{},
+	}
+	for _, blockS := range schema.Blocks {
+		for _, block := range b.Blocks {
+			if block.Type == blockS.Type && len(block.Labels) == len(blockS.LabelNames) {
+				content.Blocks = append(content.Blocks, block)
+			}
+		}
+	}
+	return content, b, nil
+}
+
+func (b *testBlocksBody) JustAttributes() (Attributes, Diagnostics) {
+	return Attributes{}, nil
+}
+
+func (b *testBlocksBody) MissingItemRange() Range {
+	return Range{
+		Filename: b.Name,
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+// CountBlocksOfType returns the number of blocks of the given type in the
+// given body, using a minimal schema so that the blocks and any other body
+// content don't need to be fully decoded.
+//
+// This is a convenience for validation rules such as "exactly one backend
+// block", and works uniformly for all body implementations, including
+// bodies produced by MergeBodies.
+//
+// The label names for the block type must be provided if blocks of that type
+// expect labels, because some syntaxes (such as JSON) cannot distinguish
+// labels from nested content without that information. Only the number of
+// labels matters; the names are used only in error messages.
+//
+// The returned diagnostics are those produced by decoding the body with the
+// minimal schema, such as errors about blocks with the wrong number of labels.
+// The count includes only successfully-decoded blocks.
+func CountBlocksOfType(body Body, typeName string, labelNames ...string) (int, Diagnostics) {
+	content, _, diags := body.PartialContent(&BodySchema{
+		Blocks: []BlockHeaderSchema{
+			{
+				Type:       typeName,
+				LabelNames: labelNames,
+			},
+		},
+	})
+	if content == nil {
+		return 0, diags
+	}
+	return len(content.Blocks.OfType(typeName)), diags
+}
@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"testing"
+)
+
+func TestCountBlocksOfType(t *testing.T) {
+	tests := map[string]struct {
+		Body      Body
+		Want      int
+		DiagCount int
+	}{
+		"none": {
+			&testMergedBodiesVictim{
+				HasBlocks: map[string]int{"other": 2},
+			},
+			0,
+			0,
+		},
+		"single body": {
+			&testMergedBodiesVictim{
+				HasAttributes: []string{"backend"},
+				HasBlocks:     map[string]int{"backend": 1, "other": 1},
+			},
+			1,
+			0,
+		},
+		"merged bodies": {
+			MergeBodies([]Body{
+				&testMergedBodiesVictim{
+					HasBlocks: map[string]int{"backend": 1},
+				},
+				&testMergedBodiesVictim{
+					HasBlocks: map[string]int{"other": 3},
+				},
+				&testMergedBodiesVictim{
+					HasBlocks: map[string]int{"backend": 2},
+				},
+			}),
+			3,
+			0,
+		},
+		"with diagnostics": {
+			&testMergedBodiesVictim{
+				HasBlocks: map[string]int{"backend": 1},
+				DiagCount: 1,
+			},
+			1,
+			1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, diags := CountBlocksOfType(test.Body, "backend")
+			if len(diags) != test.DiagCount {
+				t.Errorf("wrong number of diagnostics %d; want %d", len(diags), test.DiagCount)
+				for _, diag := range diags {
+					t.Logf(" - %s", diag.Error())
+				}
+			}
+			if got != test.Want {
+				t.Errorf("wrong count %d; want %d", got, test.Want)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ package hcl
 
 import (
 	"fmt"
+	"sort"
 )
 
 // DiagnosticSeverity represents the severity of a diagnostic.
@@ -161,6 +162,54 @@ func (d Diagnostics) Errs() []error {
 	return errs
 }
 
+// SortDiagnostics returns a copy of the given diagnostics sorted into a
+// deterministic order, which is useful when the diagnostics were collected
+// from concurrent work and so would otherwise be in an unpredictable order.
+//
+// Diagnostics with a subject range are sorted by filename and then by the
+// byte offset of the start of the range. Diagnostics without a subject
+// range are sorted after all of those that have one. Any ties are broken by
+// severity, with errors before warnings, and then by summary and detail.
+// Diagnostics that are equal in all of these respects retain their relative
+// order from the input.
+func SortDiagnostics(diags Diagnostics) Diagnostics {
+	if len(diags) == 0 {
+		return diags
+	}
+
+	ret := make(Diagnostics, len(diags))
+	copy(ret, diags)
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		switch {
+		case (a.Subject == nil) != (b.Subject == nil):
+			return b.Subject == nil
+		case a.Subject != nil && a.Subject.Filename != b.Subject.Filename:
+			return a.Subject.Filename < b.Subject.Filename
+		case a.Subject != nil && a.Subject.Start.Byte != b.Subject.Start.Byte:
+			return a.Subject.Start.Byte < b.Subject.Start.Byte
+		case a.Severity != b.Severity:
+			return diagSeverityOrder(a.Severity) < diagSeverityOrder(b.Severity)
+		case a.Summary != b.Summary:
+			return a.Summary < b.Summary
+		default:
+			return a.Detail < b.Detail
+		}
+	})
+	return ret
+}
+
+func diagSeverityOrder(sev DiagnosticSeverity) int {
+	switch sev {
+	case DiagError:
+		return 0
+	case DiagWarning:
+		return 1
+	default:
+		return 2
+	}
+}
+
 // A DiagnosticWriter emits diagnostics somehow.
 type DiagnosticWriter interface {
 	WriteDiagnostic(*Diagnostic) error
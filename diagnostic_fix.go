@@ -0,0 +1,67 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+// DiagnosticFix is a machine-applicable suggestion for resolving the problem
+// described by a diagnostic, replacing the source bytes in a particular range
+// with some new text. A fix with an empty range inserts text, and a fix with
+// empty new text deletes the range.
+//
+// Fixes are advisory metadata intended for editor integrations that offer
+// "quick fix" actions. Diagnostic producers can attach them using
+// WithDiagnosticFixes, and recipients can retrieve them using
+// DiagnosticFixes.
+type DiagnosticFix struct {
+	Range   Range
+	NewText string
+}
+
+// WithDiagnosticFixes returns a value to use as the Extra field of a
+// diagnostic which carries the given fixes, while also wrapping the given
+// existing extra value, which may be nil. The result implements
+// DiagnosticExtraUnwrapper, so any other extra information already in the
+// diagnostic remains available through DiagnosticExtra.
+//
+// For example:
+//
+//	diag.Extra = hcl.WithDiagnosticFixes(diag.Extra, fix)
+func WithDiagnosticFixes(extra interface{}, fixes ...DiagnosticFix) interface{} {
+	return &diagnosticFixesExtra{
+		fixes:   fixes,
+		wrapped: extra,
+	}
+}
+
+// DiagnosticFixes returns all of the fixes attached to the given diagnostic,
+// whether directly as the value of its Extra field, or by WithDiagnosticFixes
+// at any level of a chain of wrapped extra values. The result is nil if the
+// diagnostic has no fixes.
+func DiagnosticFixes(diag *Diagnostic) []DiagnosticFix {
+	var ret []DiagnosticFix
+	extra := diag.Extra
+	for extra != nil {
+		switch extra := extra.(type) {
+		case DiagnosticFix:
+			ret = append(ret, extra)
+		case *diagnosticFixesExtra:
+			ret = append(ret, extra.fixes...)
+		}
+
+		unwrap, ok := extra.(DiagnosticExtraUnwrapper)
+		if !ok {
+			break
+		}
+		extra = unwrap.UnwrapDiagnosticExtra()
+	}
+	return ret
+}
+
+type diagnosticFixesExtra struct {
+	fixes   []DiagnosticFix
+	wrapped interface{}
+}
+
+func (e *diagnosticFixesExtra) UnwrapDiagnosticExtra() interface{} {
+	return e.wrapped
+}
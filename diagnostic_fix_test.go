@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiagnosticFixes(t *testing.T) {
+	fixA := DiagnosticFix{
+		Range:   Range{Filename: "a.hcl", Start: Pos{Line: 1, Column: 1, Byte: 0}, End: Pos{Line: 1, Column: 9, Byte: 8}},
+		NewText: "a ?? b",
+	}
+	fixB := DiagnosticFix{
+		Range:   Range{Filename: "a.hcl", Start: Pos{Line: 2, Column: 1, Byte: 10}, End: Pos{Line: 2, Column: 1, Byte: 10}},
+		NewText: "# ",
+	}
+
+	tests := map[string]struct {
+		Extra interface{}
+		Want  []DiagnosticFix
+	}{
+		"no extra": {
+			nil,
+			nil,
+		},
+		"unrelated extra": {
+			"hello",
+			nil,
+		},
+		"direct fix": {
+			fixA,
+			[]DiagnosticFix{fixA},
+		},
+		"attached fixes": {
+			WithDiagnosticFixes(nil, fixA, fixB),
+			[]DiagnosticFix{fixA, fixB},
+		},
+		"attached at multiple levels": {
+			WithDiagnosticFixes(WithDiagnosticFixes(nil, fixB), fixA),
+			[]DiagnosticFix{fixA, fixB},
+		},
+		"wrapped by another extra": {
+			diagnosticExtraWrapper{WithDiagnosticFixes(nil, fixA)},
+			[]DiagnosticFix{fixA},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := DiagnosticFixes(&Diagnostic{Extra: test.Extra})
+			if !reflect.DeepEqual(got, test.Want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.Want)
+			}
+		})
+	}
+
+	t.Run("preserves wrapped extra", func(t *testing.T) {
+		diag := &Diagnostic{
+			Extra: diagnosticExtraMarker("marker"),
+		}
+		diag.Extra = WithDiagnosticFixes(diag.Extra, fixA)
+
+		got, ok := DiagnosticExtra[diagnosticExtraMarker](diag)
+		if !ok || got != "marker" {
+			t.Errorf("wrapped extra value is not available; got %#v", got)
+		}
+	})
+}
+
+type diagnosticExtraMarker string
+
+type diagnosticExtraWrapper struct {
+	wrapped interface{}
+}
+
+func (w diagnosticExtraWrapper) UnwrapDiagnosticExtra() interface{} {
+	return w.wrapped
+}
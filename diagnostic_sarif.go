@@ -0,0 +1,191 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+)
+
+// SARIFDiagnosticWriter is a DiagnosticWriter that produces a log in the
+// Static Analysis Results Interchange Format (SARIF), version 2.1.0, for
+// integration with tools that consume that format, such as code scanning
+// dashboards.
+//
+// A SARIF log is a single JSON document, so the diagnostics are accumulated
+// and the log is written only when Close is called.
+type SARIFDiagnosticWriter struct {
+	wr       io.Writer
+	toolName string
+	results  []sarifResult
+	closed   bool
+}
+
+var _ DiagnosticWriter = (*SARIFDiagnosticWriter)(nil)
+
+// NewSARIFDiagnosticWriter creates a SARIFDiagnosticWriter that writes a
+// SARIF log to the given writer when it is closed, attributing the results
+// to a tool of the given name.
+//
+// Each diagnostic becomes a result whose level corresponds to its severity
+// and whose message is its summary followed by its detail. A diagnostic
+// with a subject range has a location recording both the line and column and
+// the byte offset of that range. Columns are counted in the same way as in
+// Pos, which the log declares as counting Unicode code points.
+func NewSARIFDiagnosticWriter(wr io.Writer, toolName string) *SARIFDiagnosticWriter {
+	return &SARIFDiagnosticWriter{
+		wr:       wr,
+		toolName: toolName,
+	}
+}
+
+// WriteDiagnostic adds the given diagnostic to the log.
+func (w *SARIFDiagnosticWriter) WriteDiagnostic(diag *Diagnostic) error {
+	if diag == nil {
+		return errors.New("nil diagnostic")
+	}
+	if w.closed {
+		return errors.New("SARIF diagnostic writer is closed")
+	}
+
+	result := sarifResult{
+		Message: sarifMessage{
+			Text: diag.Summary,
+		},
+	}
+	if diag.Detail != "" {
+		result.Message.Text += ": " + diag.Detail
+	}
+	switch diag.Severity {
+	case DiagError:
+		result.Level = "error"
+	case DiagWarning:
+		result.Level = "warning"
+	default:
+		result.Level = "note"
+	}
+	if rng := diag.Subject; rng != nil {
+		result.Locations = []sarifLocation{
+			{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{
+						URI: filepath.ToSlash(rng.Filename),
+					},
+					Region: sarifRegion{
+						StartLine:   rng.Start.Line,
+						StartColumn: rng.Start.Column,
+						EndLine:     rng.End.Line,
+						EndColumn:   rng.End.Column,
+						ByteOffset:  rng.Start.Byte,
+						ByteLength:  rng.End.Byte - rng.Start.Byte,
+					},
+				},
+			},
+		}
+	}
+
+	w.results = append(w.results, result)
+	return nil
+}
+
+// WriteDiagnostics adds all of the given diagnostics to the log.
+func (w *SARIFDiagnosticWriter) WriteDiagnostics(diags Diagnostics) error {
+	for _, diag := range diags {
+		err := w.WriteDiagnostic(diag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the SARIF log containing all of the diagnostics written so
+// far to the underlying writer. The writer cannot be used after it has been
+// closed. Close does not close the underlying writer.
+func (w *SARIFDiagnosticWriter) Close() error {
+	if w.closed {
+		return errors.New("SARIF diagnostic writer is already closed")
+	}
+	w.closed = true
+
+	results := w.results
+	if results == nil {
+		// SARIF distinguishes an empty list of results, meaning that the
+		// tool found no problems, from an absent one.
+		results = []sarifResult{}
+	}
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{
+					Driver: sarifToolComponent{
+						Name: w.toolName,
+					},
+				},
+				ColumnKind: "unicodeCodePoints",
+				Results:    results,
+			},
+		},
+	}
+
+	enc := json.NewEncoder(w.wr)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifToolComponent `json:"driver"`
+}
+
+type sarifToolComponent struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+	ByteOffset  int `json:"byteOffset"`
+	ByteLength  int `json:"byteLength"`
+}
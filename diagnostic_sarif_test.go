@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSARIFDiagnosticWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewSARIFDiagnosticWriter(&buf, "hclscan")
+	err := w.WriteDiagnostics(Diagnostics{
+		{
+			Severity: DiagError,
+			Summary:  "Splines not reticulated",
+			Detail:   "All splines must be pre-reticulated.",
+			Subject: &Range{
+				Filename: "dir/splines.hcl",
+				Start:    Pos{Line: 2, Column: 3, Byte: 10},
+				End:      Pos{Line: 2, Column: 8, Byte: 15},
+			},
+		},
+		{
+			Severity: DiagWarning,
+			Summary:  "No location",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("log written before close:\n%s", buf.String())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `{
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "hclscan"
+        }
+      },
+      "columnKind": "unicodeCodePoints",
+      "results": [
+        {
+          "level": "error",
+          "message": {
+            "text": "Splines not reticulated: All splines must be pre-reticulated."
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "dir/splines.hcl"
+                },
+                "region": {
+                  "startLine": 2,
+                  "startColumn": 3,
+                  "endLine": 2,
+                  "endColumn": 8,
+                  "byteOffset": 10,
+                  "byteLength": 5
+                }
+              }
+            }
+          ]
+        },
+        {
+          "level": "warning",
+          "message": {
+            "text": "No location"
+          }
+        }
+      ]
+    }
+  ]
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := w.WriteDiagnostic(&Diagnostic{Summary: "late"}); err == nil {
+		t.Errorf("no error writing after close")
+	}
+}
+
+func TestSARIFDiagnosticWriter_empty(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewSARIFDiagnosticWriter(&buf, "hclscan")
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"results": []`)) {
+		t.Errorf("log does not record an empty list of results:\n%s", buf.String())
+	}
+}
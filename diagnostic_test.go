@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"testing"
+)
+
+func TestSortDiagnostics(t *testing.T) {
+	rng := func(filename string, start int) *Range {
+		return &Range{
+			Filename: filename,
+			Start:    Pos{Byte: start, Line: 1, Column: start + 1},
+			End:      Pos{Byte: start + 1, Line: 1, Column: start + 2},
+		}
+	}
+	diag := func(summary string, sev DiagnosticSeverity, subject *Range) *Diagnostic {
+		return &Diagnostic{
+			Severity: sev,
+			Summary:  summary,
+			Subject:  subject,
+		}
+	}
+
+	input := Diagnostics{
+		diag("no range warning", DiagWarning, nil),
+		diag("b.hcl 0", DiagError, rng("b.hcl", 0)),
+		diag("no range error", DiagError, nil),
+		diag("a.hcl 5 warning", DiagWarning, rng("a.hcl", 5)),
+		diag("a.hcl 5 error", DiagError, rng("a.hcl", 5)),
+		diag("a.hcl 0", DiagWarning, rng("a.hcl", 0)),
+		diag("a.hcl 5 error", DiagError, rng("a.hcl", 5)),
+	}
+	want := []string{
+		"a.hcl 0",
+		"a.hcl 5 error",
+		"a.hcl 5 error",
+		"a.hcl 5 warning",
+		"b.hcl 0",
+		"no range error",
+		"no range warning",
+	}
+
+	got := SortDiagnostics(input)
+	if len(got) != len(want) {
+		t.Fatalf("wrong number of diagnostics %d; want %d", len(got), len(want))
+	}
+	for i, diag := range got {
+		if diag.Summary != want[i] {
+			t.Errorf("wrong diagnostic at index %d: %q; want %q", i, diag.Summary, want[i])
+		}
+	}
+
+	// Equal diagnostics keep their relative order.
+	if got[1] != input[4] || got[2] != input[6] {
+		t.Errorf("equal diagnostics were reordered")
+	}
+
+	// The input is not modified.
+	if input[0].Summary != "no range warning" {
+		t.Errorf("input was modified")
+	}
+}
@@ -4,6 +4,8 @@
 package hcl
 
 import (
+	"sync/atomic"
+
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
 )
@@ -13,12 +15,207 @@ import (
 type EvalContext struct {
 	Variables map[string]cty.Value
 	Functions map[string]function.Function
-	parent    *EvalContext
+
+	// FunctionResolver, if set, is called to lazily find the implementation
+	// of a function whose name doesn't appear in the Functions map of this
+	// context or any of its ancestors. It returns false if there is no
+	// function of the given name.
+	//
+	// When resolvers are set at multiple levels of the context tree, they
+	// are consulted from the innermost context outwards.
+	FunctionResolver func(name string) (function.Function, bool)
+
+	// VariableResolver, if set, is called to lazily resolve a reference to
+	// a variable whose root name doesn't appear in the Variables map of this
+	// context or any of its ancestors. It is given the full traversal so
+	// that it can fetch only the data the reference actually needs, and it
+	// returns the result of the entire traversal rather than just the value
+	// of the root variable. It returns false if there is no variable of the
+	// given root name, in which case the usual error is reported.
+	//
+	// As with FunctionResolver, resolvers at multiple levels of the context
+	// tree are consulted from the innermost context outwards.
+	VariableResolver func(traversal Traversal) (cty.Value, Diagnostics, bool)
+
+	// FunctionCallValidator, if set, is called for each function call after
+	// its arguments have been evaluated but before the function itself is
+	// called, with the name of the function as written in the call and the
+	// argument values that will be passed to it. If the returned diagnostics
+	// include any errors then the function is not called and the result of
+	// the call is unknown. This allows applications to enforce policies on
+	// the use of particular functions.
+	//
+	// Diagnostics returned without a subject are reported at the function
+	// call. When validators are set at multiple levels of the context tree,
+	// all of them are consulted, from the innermost context outwards.
+	FunctionCallValidator func(name string, args []cty.Value) Diagnostics
+
+	// UnknownFunctions selects how a call to a function that is not defined
+	// in this context or any of its ancestors is handled. By default such a
+	// call is an error, but applications doing partial evaluation before all
+	// of their functions are available can instead ask for the call to
+	// return an unknown value of unknown type.
+	//
+	// Setting this to anything other than UnknownFunctionsError also allows
+	// function calls in a context that would otherwise not allow them. The
+	// innermost context with a non-default setting takes priority.
+	UnknownFunctions UnknownFunctionsMode
+
+	// MaxEvalSteps, if greater than zero, limits the total number of
+	// evaluation steps that may be taken by all evaluations using this
+	// context or any of its descendents. Each expression node evaluated
+	// counts as a step, as does each iteration of a for expression or splat.
+	// Once the budget is exhausted, evaluation fails with an error. This
+	// allows applications to bound the cost of evaluating untrusted input.
+	//
+	// The innermost context with this set governs. A context created by
+	// NewChild is governed by the budget of its parent only if the parent, or
+	// one of its own ancestors, already had this set when the child was
+	// created. The count of steps taken is shared by all evaluations using
+	// the governing context and its descendents, including concurrent ones,
+	// and is never reset automatically; call ResetEvalSteps to start
+	// counting again before reusing the context.
+	MaxEvalSteps int
+
+	// NonFiniteNumbers selects how an arithmetic operation whose result is
+	// infinite is handled when neither of its operands was, such as a
+	// division by zero. By default the infinite value is returned, but
+	// applications for which that would be surprising can instead ask for an
+	// error at the operation. The innermost context with a non-default
+	// setting takes priority.
+	//
+	// Operations whose result would not be a number at all, such as dividing
+	// zero by zero, are always errors.
+	NonFiniteNumbers NonFiniteNumbersMode
+
+	// CompareStrings, if set, is used by the relational operators <, <=, >
+	// and >= when both of their operands are strings, and returns a negative
+	// number, zero, or a positive number if the first string sorts before,
+	// the same as, or after the second, as with strings.Compare. This allows
+	// applications to compare strings using locale-aware collation rules.
+	//
+	// By default, and for operands of any other types, the operands of these
+	// operators are converted to numbers. The innermost context with this set
+	// takes priority.
+	CompareStrings func(a, b string) int
+
+	// evalSteps is the number of steps taken against MaxEvalSteps, updated
+	// atomically. budgetParent is the nearest ancestor with MaxEvalSteps set
+	// when the receiver was created, so that evaluations needn't search for
+	// it at each step.
+	evalSteps    int64
+	budgetParent *EvalContext
+
+	parent *EvalContext
+}
+
+// UnknownFunctionsMode is the type of EvalContext.UnknownFunctions.
+type UnknownFunctionsMode int
+
+const (
+	// UnknownFunctionsError reports an error for calls to unknown functions.
+	// This is the default.
+	UnknownFunctionsError UnknownFunctionsMode = iota
+
+	// UnknownFunctionsWarn makes calls to unknown functions return
+	// cty.DynamicVal, along with a warning for each such call.
+	UnknownFunctionsWarn
+
+	// UnknownFunctionsIgnore makes calls to unknown functions silently
+	// return cty.DynamicVal.
+	UnknownFunctionsIgnore
+)
+
+// UnknownFunctionsMode returns the effective UnknownFunctions setting for
+// the receiver, taking into account its ancestors. It is safe to call on
+// a nil context, which uses the default.
+func (ctx *EvalContext) UnknownFunctionsMode() UnknownFunctionsMode {
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		if thisCtx.UnknownFunctions != UnknownFunctionsError {
+			return thisCtx.UnknownFunctions
+		}
+	}
+	return UnknownFunctionsError
+}
+
+// NonFiniteNumbersMode is the type of EvalContext.NonFiniteNumbers.
+type NonFiniteNumbersMode int
+
+const (
+	// NonFiniteNumbersAllow allows arithmetic operations to produce infinite
+	// results. This is the default.
+	NonFiniteNumbersAllow NonFiniteNumbersMode = iota
+
+	// NonFiniteNumbersError reports an error for arithmetic operations that
+	// produce an infinite result from finite operands.
+	NonFiniteNumbersError
+)
+
+// NonFiniteNumbersMode returns the effective NonFiniteNumbers setting for
+// the receiver, taking into account its ancestors. It is safe to call on
+// a nil context, which uses the default.
+func (ctx *EvalContext) NonFiniteNumbersMode() NonFiniteNumbersMode {
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		if thisCtx.NonFiniteNumbers != NonFiniteNumbersAllow {
+			return thisCtx.NonFiniteNumbers
+		}
+	}
+	return NonFiniteNumbersAllow
+}
+
+// StringComparer returns the effective CompareStrings function for the
+// receiver, taking into account its ancestors, or nil if there is none. It is
+// safe to call on a nil context.
+func (ctx *EvalContext) StringComparer() func(a, b string) int {
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		if thisCtx.CompareStrings != nil {
+			return thisCtx.CompareStrings
+		}
+	}
+	return nil
+}
+
+// ConsumeEvalStep records one evaluation step against the budget set by
+// MaxEvalSteps in the context that governs the receiver, and returns false if
+// that budget has now been exceeded. It always returns true if there is no
+// budget, including when called on a nil context. It is safe for concurrent
+// use.
+//
+// Expression implementations call this to participate in enforcement of
+// MaxEvalSteps.
+func (ctx *EvalContext) ConsumeEvalStep() bool {
+	budget := ctx.evalBudget()
+	if budget == nil {
+		return true
+	}
+	return atomic.AddInt64(&budget.evalSteps, 1) <= int64(budget.MaxEvalSteps)
+}
+
+// ResetEvalSteps resets the count of steps taken against the budget set by
+// MaxEvalSteps in the context that governs the receiver, so that a context
+// can be reused for further evaluations with a full budget. It does nothing
+// if there is no budget.
+func (ctx *EvalContext) ResetEvalSteps() {
+	if budget := ctx.evalBudget(); budget != nil {
+		atomic.StoreInt64(&budget.evalSteps, 0)
+	}
+}
+
+// evalBudget returns the context whose MaxEvalSteps governs the receiver, or
+// nil if there is none.
+func (ctx *EvalContext) evalBudget() *EvalContext {
+	if ctx == nil || ctx.MaxEvalSteps > 0 {
+		return ctx
+	}
+	return ctx.budgetParent
 }
 
 // NewChild returns a new EvalContext that is a child of the receiver.
 func (ctx *EvalContext) NewChild() *EvalContext {
-	return &EvalContext{parent: ctx}
+	return &EvalContext{
+		parent:       ctx,
+		budgetParent: ctx.evalBudget(),
+	}
 }
 
 // Parent returns the parent of the receiver, or nil if the receiver has
@@ -26,3 +223,170 @@ func (ctx *EvalContext) NewChild() *EvalContext {
 func (ctx *EvalContext) Parent() *EvalContext {
 	return ctx.parent
 }
+
+// SubsetContext returns a new EvalContext containing only the variables and
+// functions from the given context, or any of its ancestors, that the given
+// expression refers to. This is useful for capturing the minimal scope needed
+// to reproduce a particular evaluation, such as for serialization.
+//
+// Variables are selected by their root names, as reported by the expression's
+// Variables method, so a reference to any attribute or element of a variable
+// brings along the whole value of that variable. Variables that are not
+// statically defined are fetched from any VariableResolver in the context,
+// using a traversal of only the root name.
+//
+// Functions can be selected only for expressions that also have a method
+// Functions() []string returning the names of all of the functions they
+// call, as the native syntax expressions do. For other expressions the
+// result includes all of the statically-defined functions from the given
+// context, along with any function resolvers.
+//
+// The result has no parent context, but has the same settings as the given
+// context, including its function call validators and its MaxEvalSteps
+// budget. The result counts its own steps against that budget, starting
+// from zero. If the given context is nil then the result is also nil.
+func SubsetContext(ctx *EvalContext, expr Expression) *EvalContext {
+	if ctx == nil {
+		return nil
+	}
+
+	ret := &EvalContext{
+		UnknownFunctions:      ctx.UnknownFunctionsMode(),
+		NonFiniteNumbers:      ctx.NonFiniteNumbersMode(),
+		CompareStrings:        ctx.StringComparer(),
+		FunctionCallValidator: ctx.functionCallValidator(),
+	}
+	if budget := ctx.evalBudget(); budget != nil {
+		ret.MaxEvalSteps = budget.MaxEvalSteps
+	}
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		// We preserve whether variables and functions are allowed at all,
+		// so that evaluating in the subset context produces the same
+		// errors as evaluating in the original.
+		if thisCtx.Variables != nil || thisCtx.VariableResolver != nil {
+			ret.Variables = map[string]cty.Value{}
+		}
+		if thisCtx.Functions != nil || thisCtx.FunctionResolver != nil {
+			ret.Functions = map[string]function.Function{}
+		}
+	}
+
+	if ret.Variables != nil {
+		for _, traversal := range expr.Variables() {
+			name := traversal.RootName()
+			if _, exists := ret.Variables[name]; exists {
+				continue
+			}
+			if val, exists := ctx.lookupVariable(traversal); exists {
+				ret.Variables[name] = val
+			}
+		}
+	}
+
+	if ret.Functions != nil {
+		fe, ok := expr.(interface{ Functions() []string })
+		if !ok {
+			for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+				for name, f := range thisCtx.Functions {
+					if _, exists := ret.Functions[name]; !exists {
+						ret.Functions[name] = f
+					}
+				}
+			}
+			ret.FunctionResolver = ctx.resolveFunction
+			return ret
+		}
+		for _, name := range fe.Functions() {
+			if f, exists := ctx.lookupFunction(name); exists {
+				ret.Functions[name] = f
+			}
+		}
+	}
+
+	return ret
+}
+
+// functionCallValidator returns a function that calls each of the function
+// call validators of the receiver and its ancestors in the same order as
+// during evaluation, stopping at the first one that returns errors, or nil
+// if there are none.
+func (ctx *EvalContext) functionCallValidator() func(name string, args []cty.Value) Diagnostics {
+	var validators []func(name string, args []cty.Value) Diagnostics
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		if thisCtx.FunctionCallValidator != nil {
+			validators = append(validators, thisCtx.FunctionCallValidator)
+		}
+	}
+	switch len(validators) {
+	case 0:
+		return nil
+	case 1:
+		return validators[0]
+	}
+	return func(name string, args []cty.Value) Diagnostics {
+		var diags Diagnostics
+		for _, validator := range validators {
+			diags = append(diags, validator(name, args)...)
+			if diags.HasErrors() {
+				break
+			}
+		}
+		return diags
+	}
+}
+
+// lookupVariable finds the value of the root variable of the given
+// traversal, searching in the same order as during evaluation.
+func (ctx *EvalContext) lookupVariable(traversal Traversal) (cty.Value, bool) {
+	val, diags, exists := ctx.lookupRootVariable(traversal)
+	return val, exists && !diags.HasErrors()
+}
+
+// lookupRootVariable is like lookupVariable, but also returns any
+// diagnostics from a VariableResolver, and whether the variable exists even
+// if there were errors.
+func (ctx *EvalContext) lookupRootVariable(traversal Traversal) (cty.Value, Diagnostics, bool) {
+	name := traversal.RootName()
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		if val, exists := thisCtx.Variables[name]; exists {
+			return val, nil, true
+		}
+	}
+
+	root := Traversal{traversal[0]}
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		if thisCtx.VariableResolver == nil {
+			continue
+		}
+		val, diags, exists := thisCtx.VariableResolver(root)
+		if exists {
+			return val, diags, true
+		}
+	}
+	return cty.NilVal, nil, false
+}
+
+// lookupFunction finds the function of the given name, searching in the
+// same order as during evaluation.
+func (ctx *EvalContext) lookupFunction(name string) (function.Function, bool) {
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		if f, exists := thisCtx.Functions[name]; exists {
+			return f, true
+		}
+	}
+	return ctx.resolveFunction(name)
+}
+
+// resolveFunction consults the function resolvers of the receiver and its
+// ancestors, innermost first.
+func (ctx *EvalContext) resolveFunction(name string) (function.Function, bool) {
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		if thisCtx.FunctionResolver == nil {
+			continue
+		}
+		if f, exists := thisCtx.FunctionResolver(name); exists {
+			return f, true
+		}
+	}
+	return function.Function{}, false
+}
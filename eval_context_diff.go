@@ -0,0 +1,152 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"sort"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ContextDiff describes a single difference between two evaluation
+// contexts, as returned by DiffEvalContexts.
+type ContextDiff struct {
+	// Name is the name of the variable or function that differs.
+	Name string
+
+	// Function is true if the difference is in the functions of the
+	// contexts, rather than in their variables.
+	Function bool
+
+	// A and B are the values of the variable in the first and second
+	// context respectively, or cty.NilVal if it isn't defined in that
+	// context. They are always cty.NilVal for functions.
+	A, B cty.Value
+
+	// ALevel and BLevel are the levels of the first and second context at
+	// which the variable or function is defined, counting 0 for the given
+	// context itself, 1 for its parent, and so on, or -1 if it isn't
+	// defined in that context.
+	ALevel, BLevel int
+}
+
+// DiffEvalContexts compares the variables and functions available in the
+// two given contexts, including those inherited from their ancestors, and
+// returns a description of each difference between them. This is intended
+// for debugging why the same expression evaluates differently in two
+// contexts.
+//
+// A variable differs if it is defined in only one of the contexts or if its
+// values in the two contexts are not equal as defined by cty.Value.RawEquals,
+// which also compares marks and refinements. A function differs only if it
+// is defined in just one of the contexts, since functions can't be compared.
+// Where a context and one of its ancestors both define the same name, only
+// the innermost definition is considered, as for evaluation. Variables and
+// functions provided lazily by VariableResolver and FunctionResolver are
+// not considered.
+//
+// The variable differences are returned first, followed by the function
+// differences, each in lexical order by name. The result is empty if the
+// contexts don't differ, and a nil context has no variables or functions.
+func DiffEvalContexts(a, b *EvalContext) []ContextDiff {
+	var ret []ContextDiff
+
+	aVars, aVarLevels := a.flattenVariables()
+	bVars, bVarLevels := b.flattenVariables()
+	for _, name := range unionNames(aVarLevels, bVarLevels) {
+		aLevel, aOk := aVarLevels[name]
+		bLevel, bOk := bVarLevels[name]
+		if aOk && bOk && aVars[name].RawEquals(bVars[name]) {
+			continue
+		}
+		diff := ContextDiff{
+			Name:   name,
+			ALevel: -1,
+			BLevel: -1,
+		}
+		if aOk {
+			diff.A, diff.ALevel = aVars[name], aLevel
+		}
+		if bOk {
+			diff.B, diff.BLevel = bVars[name], bLevel
+		}
+		ret = append(ret, diff)
+	}
+
+	aFuncLevels := a.functionLevels()
+	bFuncLevels := b.functionLevels()
+	for _, name := range unionNames(aFuncLevels, bFuncLevels) {
+		aLevel, aOk := aFuncLevels[name]
+		bLevel, bOk := bFuncLevels[name]
+		if aOk && bOk {
+			continue
+		}
+		diff := ContextDiff{
+			Name:     name,
+			Function: true,
+			ALevel:   -1,
+			BLevel:   -1,
+		}
+		if aOk {
+			diff.ALevel = aLevel
+		}
+		if bOk {
+			diff.BLevel = bLevel
+		}
+		ret = append(ret, diff)
+	}
+
+	return ret
+}
+
+// flattenVariables returns the innermost definition of each of the variables
+// in the receiver and its ancestors, along with the level at which each one
+// is defined.
+func (ctx *EvalContext) flattenVariables() (map[string]cty.Value, map[string]int) {
+	vals := make(map[string]cty.Value)
+	levels := make(map[string]int)
+	level := 0
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		for name, val := range thisCtx.Variables {
+			if _, exists := levels[name]; !exists {
+				vals[name] = val
+				levels[name] = level
+			}
+		}
+		level++
+	}
+	return vals, levels
+}
+
+// functionLevels returns the level at which the innermost definition of each
+// of the functions in the receiver and its ancestors is defined.
+func (ctx *EvalContext) functionLevels() map[string]int {
+	levels := make(map[string]int)
+	level := 0
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		for name := range thisCtx.Functions {
+			if _, exists := levels[name]; !exists {
+				levels[name] = level
+			}
+		}
+		level++
+	}
+	return levels
+}
+
+// unionNames returns the keys of both of the given maps, sorted and without
+// duplicates.
+func unionNames(a, b map[string]int) []string {
+	names := make([]string, 0, len(a)+len(b))
+	for name := range a {
+		names = append(names, name)
+	}
+	for name := range b {
+		if _, exists := a[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty-debug/ctydebug"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+func TestDiffEvalContexts(t *testing.T) {
+	root := &EvalContext{
+		Variables: map[string]cty.Value{
+			"region": cty.StringVal("us-east-1"),
+			"count":  cty.NumberIntVal(1),
+		},
+		Functions: map[string]function.Function{
+			"upper": stdlib.UpperFunc,
+		},
+	}
+
+	tests := map[string]struct {
+		a, b *EvalContext
+		want []ContextDiff
+	}{
+		"both nil": {
+			nil,
+			nil,
+			nil,
+		},
+		"same": {
+			root.NewChild(),
+			root,
+			nil,
+		},
+		"nil and non-nil": {
+			nil,
+			root,
+			[]ContextDiff{
+				{Name: "count", B: cty.NumberIntVal(1), ALevel: -1, BLevel: 0},
+				{Name: "region", B: cty.StringVal("us-east-1"), ALevel: -1, BLevel: 0},
+				{Name: "upper", Function: true, ALevel: -1, BLevel: 0},
+			},
+		},
+		"shadowed in child": {
+			func() *EvalContext {
+				child := root.NewChild()
+				child.Variables = map[string]cty.Value{
+					"region": cty.StringVal("eu-west-1"),
+					"extra":  cty.True,
+				}
+				child.Functions = map[string]function.Function{
+					"lower": stdlib.LowerFunc,
+				}
+				return child.NewChild()
+			}(),
+			root,
+			[]ContextDiff{
+				{Name: "extra", A: cty.True, ALevel: 1, BLevel: -1},
+				{Name: "region", A: cty.StringVal("eu-west-1"), B: cty.StringVal("us-east-1"), ALevel: 1, BLevel: 0},
+				{Name: "lower", Function: true, ALevel: 1, BLevel: -1},
+			},
+		},
+		"marks": {
+			&EvalContext{
+				Variables: map[string]cty.Value{
+					"secret": cty.StringVal("a").Mark("sensitive"),
+				},
+			},
+			&EvalContext{
+				Variables: map[string]cty.Value{
+					"secret": cty.StringVal("a"),
+				},
+			},
+			[]ContextDiff{
+				{Name: "secret", A: cty.StringVal("a").Mark("sensitive"), B: cty.StringVal("a"), ALevel: 0, BLevel: 0},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := DiffEvalContexts(test.a, test.b)
+			if diff := cmp.Diff(test.want, got, ctydebug.CmpOptions); diff != "" {
+				t.Errorf("wrong result\n%s", diff)
+			}
+		})
+	}
+}
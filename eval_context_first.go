@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ValueInFirstContext evaluates the given expression in each of the given
+// contexts in turn, returning the result from the first context that
+// defines all of the variables the expression refers to. This is intended
+// for layered scopes, such as trying a local scope before falling back to a
+// global one.
+//
+// Evaluation in a context is considered to have failed because a variable
+// was not found only if the evaluation returns error diagnostics and at least
+// one of the traversals returned by expr.Variables has a root name that isn't
+// defined in that context, either in the Variables map of the context or one
+// of its ancestors or by one of their VariableResolver functions. In that
+// case the diagnostics are discarded and the next context is tried. Any other
+// evaluation result, including a result with errors that don't relate to
+// missing variables, such as a type error, is returned immediately without
+// trying any further contexts.
+//
+// Missing attributes or elements within a variable that is defined, as in
+// a reference to var.foo where var has no attribute foo, are genuine
+// evaluation errors rather than "not found" failures, so they don't cause
+// later contexts to be tried.
+//
+// If none of the contexts define all of the variables then the result and
+// diagnostics from evaluating in the last context are returned. If no
+// contexts are given at all then the expression is evaluated with a nil
+// context.
+func ValueInFirstContext(expr Expression, ctxs ...*EvalContext) (cty.Value, Diagnostics) {
+	if len(ctxs) == 0 {
+		return expr.Value(nil)
+	}
+
+	var val cty.Value
+	var diags Diagnostics
+	for _, ctx := range ctxs {
+		val, diags = expr.Value(ctx)
+		if !diags.HasErrors() || ctx.definesAllVariables(expr.Variables()) {
+			return val, diags
+		}
+	}
+	return val, diags
+}
+
+// definesAllVariables returns true if the root names of all of the given
+// traversals are defined in the receiver, either statically or by a
+// variable resolver.
+func (ctx *EvalContext) definesAllVariables(traversals []Traversal) bool {
+Traversals:
+	for _, traversal := range traversals {
+		name := traversal.RootName()
+		for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+			if _, exists := thisCtx.Variables[name]; exists {
+				continue Traversals
+			}
+		}
+		for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+			if thisCtx.VariableResolver == nil {
+				continue
+			}
+			if _, _, exists := thisCtx.VariableResolver(traversal); exists {
+				continue Traversals
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,156 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// firstContextExpr is a mock expression whose value is the result of
+// traversing each of its variable traversals, which is enough to exercise
+// the variable lookup behavior of ValueInFirstContext.
+type firstContextExpr struct {
+	staticExpr
+	vars []Traversal
+}
+
+func (e firstContextExpr) Value(ctx *EvalContext) (cty.Value, Diagnostics) {
+	var diags Diagnostics
+	var vals []cty.Value
+	for _, traversal := range e.vars {
+		val, moreDiags := traversal.TraverseAbs(ctx)
+		diags = append(diags, moreDiags...)
+		vals = append(vals, val)
+	}
+	if diags.HasErrors() {
+		return cty.DynamicVal, diags
+	}
+	return cty.TupleVal(vals), diags
+}
+
+func (e firstContextExpr) Variables() []Traversal {
+	return e.vars
+}
+
+func TestValueInFirstContext(t *testing.T) {
+	local := &EvalContext{
+		Variables: map[string]cty.Value{
+			"a": cty.StringVal("local a"),
+			"obj": cty.ObjectVal(map[string]cty.Value{
+				"attr": cty.StringVal("local obj.attr"),
+			}),
+		},
+	}
+	global := &EvalContext{
+		Variables: map[string]cty.Value{
+			"a": cty.StringVal("global a"),
+			"b": cty.StringVal("global b"),
+			"obj": cty.ObjectVal(map[string]cty.Value{
+				"other": cty.StringVal("global obj.other"),
+			}),
+		},
+		VariableResolver: func(traversal Traversal) (cty.Value, Diagnostics, bool) {
+			if traversal.RootName() != "lazy" {
+				return cty.NilVal, nil, false
+			}
+			return cty.StringVal("global lazy"), nil, true
+		},
+	}
+	child := global.NewChild()
+	child.Variables = map[string]cty.Value{
+		"c": cty.StringVal("child c"),
+	}
+
+	traversal := func(names ...string) Traversal {
+		ret := Traversal{TraverseRoot{Name: names[0]}}
+		for _, name := range names[1:] {
+			ret = append(ret, TraverseAttr{Name: name})
+		}
+		return ret
+	}
+
+	tests := map[string]struct {
+		Vars      []Traversal
+		Ctxs      []*EvalContext
+		Want      cty.Value
+		WantError string
+	}{
+		"found in first": {
+			[]Traversal{traversal("a")},
+			[]*EvalContext{local, global},
+			cty.TupleVal([]cty.Value{cty.StringVal("local a")}),
+			"",
+		},
+		"found in second": {
+			[]Traversal{traversal("b")},
+			[]*EvalContext{local, global},
+			cty.TupleVal([]cty.Value{cty.StringVal("global b")}),
+			"",
+		},
+		"split across contexts": {
+			[]Traversal{traversal("a"), traversal("b")},
+			[]*EvalContext{local, global},
+			cty.TupleVal([]cty.Value{cty.StringVal("global a"), cty.StringVal("global b")}),
+			"",
+		},
+		"found by resolver": {
+			[]Traversal{traversal("lazy")},
+			[]*EvalContext{local, global},
+			cty.TupleVal([]cty.Value{cty.StringVal("global lazy")}),
+			"",
+		},
+		"found in parent": {
+			[]Traversal{traversal("b"), traversal("c")},
+			[]*EvalContext{local, child},
+			cty.TupleVal([]cty.Value{cty.StringVal("global b"), cty.StringVal("child c")}),
+			"",
+		},
+		"nil context skipped": {
+			[]Traversal{traversal("b")},
+			[]*EvalContext{nil, global},
+			cty.TupleVal([]cty.Value{cty.StringVal("global b")}),
+			"",
+		},
+		"not found anywhere": {
+			[]Traversal{traversal("nope")},
+			[]*EvalContext{local, global},
+			cty.DynamicVal,
+			"Unknown variable",
+		},
+		"genuine error stops": {
+			[]Traversal{traversal("obj", "other")},
+			[]*EvalContext{local, global},
+			cty.DynamicVal,
+			"Unsupported attribute",
+		},
+		"no contexts": {
+			[]Traversal{traversal("a")},
+			nil,
+			cty.DynamicVal,
+			"Variables not allowed",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr := firstContextExpr{vars: test.Vars}
+			got, diags := ValueInFirstContext(expr, test.Ctxs...)
+
+			if test.WantError == "" {
+				if diags.HasErrors() {
+					t.Fatalf("unexpected errors: %s", diags.Error())
+				}
+			} else {
+				if len(diags) != 1 || diags[0].Summary != test.WantError {
+					t.Fatalf("wrong diagnostics\ngot:  %s\nwant: %s", diags.Error(), test.WantError)
+				}
+			}
+			if !got.RawEquals(test.Want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.Want)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// EvalRecording is a record of the external inputs used by evaluations in
+// a context returned by RecordEvalContext, which can be replayed by
+// ReplayEvalContext. This allows golden tests of evaluation to run without
+// depending on the environment that originally provided the variables and
+// functions, including functions whose results vary between calls.
+//
+// A recording can be serialized as JSON using the standard encoding/json
+// package, except that it is an error for it to contain any unknown or
+// marked values.
+type EvalRecording struct {
+	// Variables has the value of each root variable that was referenced,
+	// indexed by name.
+	Variables map[string]cty.Value
+
+	// Calls has an entry for each function call, in the order they were
+	// made.
+	Calls []RecordedCall
+}
+
+// RecordedCall describes a single function call in an EvalRecording.
+type RecordedCall struct {
+	// Name is the name of the function as written in the call.
+	Name string
+
+	// Args are the argument values passed in the call, before any
+	// conversion to the types of the function's parameters.
+	Args []cty.Value
+
+	// Result is the value returned by the function, or cty.NilVal if it
+	// returned an error, in which case Error is the error message.
+	Result cty.Value
+	Error  string
+}
+
+// RecordEvalContext returns a context that resolves variables and functions
+// in the same way as the given context, and an EvalRecording to which each
+// variable lookup and function call made while evaluating in the returned
+// context is added. Variables are recorded by their root names, so a
+// reference to any attribute or element of a variable records the whole
+// value of that variable.
+//
+// The returned context has no parent, and so the given context is consulted
+// only through the recording. It preserves the UnknownFunctions,
+// NonFiniteNumbers and CompareStrings settings of the given context, but
+// unlike SubsetContext it has no FunctionCallValidator or MaxEvalSteps. The
+// recording is not safe for concurrent use by multiple evaluations.
+func RecordEvalContext(ctx *EvalContext) (*EvalContext, *EvalRecording) {
+	rec := &EvalRecording{
+		Variables: map[string]cty.Value{},
+	}
+	ret := &EvalContext{
+		UnknownFunctions: ctx.UnknownFunctionsMode(),
+		NonFiniteNumbers: ctx.NonFiniteNumbersMode(),
+		CompareStrings:   ctx.StringComparer(),
+	}
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		// We preserve whether variables and functions are allowed at all,
+		// so that evaluating in the recording context produces the same
+		// errors as evaluating in the original.
+		if thisCtx.Variables != nil || thisCtx.VariableResolver != nil {
+			ret.Variables = map[string]cty.Value{}
+		}
+		if thisCtx.Functions != nil || thisCtx.FunctionResolver != nil {
+			ret.Functions = map[string]function.Function{}
+		}
+	}
+
+	if ret.Variables != nil {
+		ret.VariableResolver = func(traversal Traversal) (cty.Value, Diagnostics, bool) {
+			name := traversal.RootName()
+			val, exists := rec.Variables[name]
+			if !exists {
+				var diags Diagnostics
+				val, diags, exists = ctx.lookupRootVariable(traversal)
+				if !exists || diags.HasErrors() {
+					return val, diags, exists
+				}
+				rec.Variables[name] = val
+			}
+			val, diags := traversal.SimpleSplit().Rel.TraverseRel(val)
+			return val, diags, true
+		}
+	}
+	if ret.Functions != nil {
+		ret.FunctionResolver = func(name string) (function.Function, bool) {
+			f, exists := ctx.lookupFunction(name)
+			if !exists {
+				return function.Function{}, false
+			}
+			return recordingFunction(name, f, rec), true
+		}
+	}
+	return ret, rec
+}
+
+// EvaluateRecorded evaluates the given expression in a context returned by
+// RecordEvalContext for the given context, returning the result along with
+// the recording of the variables and function results it used.
+//
+// Evaluating the same expression using the recording's Evaluate method then
+// produces the same result without access to the original context, even for
+// functions whose results vary between calls. The recording can be
+// serialized as JSON in a deterministic form, so that it can be stored as an
+// audit record of exactly which inputs produced the result.
+func EvaluateRecorded(expr Expression, ctx *EvalContext) (cty.Value, *EvalRecording, Diagnostics) {
+	recCtx, rec := RecordEvalContext(ctx)
+	val, diags := expr.Value(recCtx)
+	return val, rec, diags
+}
+
+// Evaluate evaluates the given expression using only the variables and
+// function results in the receiver, as with ReplayEvalContext. Referring to
+// a variable or calling a function in a way the receiver has no record of
+// produces an error diagnostic, so the result is reproduced exactly or not at
+// all.
+func (r *EvalRecording) Evaluate(expr Expression) (cty.Value, Diagnostics) {
+	return expr.Value(ReplayEvalContext(r))
+}
+
+// recordingFunction returns a function that calls the given function and
+// adds each call to the given recording.
+//
+// The function accepts any arguments, leaving the given function to check
+// and convert them, so that the recorded arguments are exactly those given
+// in the call and can be matched by the function from replayFunction.
+func recordingFunction(name string, f function.Function, rec *EvalRecording) function.Function {
+	return function.New(&function.Spec{
+		Description: f.Description(),
+		VarParam:    anyArgsParam(),
+		Type: func(args []cty.Value) (cty.Type, error) {
+			return f.ReturnTypeForValues(args)
+		},
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			result, err := f.Call(args)
+			call := RecordedCall{
+				Name:   name,
+				Args:   append([]cty.Value(nil), args...),
+				Result: result,
+			}
+			if err != nil {
+				call.Result = cty.NilVal
+				call.Error = err.Error()
+			}
+			rec.Calls = append(rec.Calls, call)
+			return result, err
+		},
+	})
+}
+
+// ReplayEvalContext returns a context that provides only the variables and
+// function results in the given recording, as produced by RecordEvalContext.
+// A reference to a variable that was not recorded is an error, as is a call
+// to a function with arguments that were not recorded for it. A function
+// called more than once with the same arguments returns the result recorded
+// for the first such call.
+func ReplayEvalContext(rec *EvalRecording) *EvalContext {
+	ret := &EvalContext{
+		Variables: make(map[string]cty.Value, len(rec.Variables)),
+		Functions: map[string]function.Function{},
+	}
+	for name, val := range rec.Variables {
+		ret.Variables[name] = val
+	}
+	for _, call := range rec.Calls {
+		if _, exists := ret.Functions[call.Name]; !exists {
+			ret.Functions[call.Name] = replayFunction(call.Name, rec)
+		}
+	}
+	return ret
+}
+
+// replayFunction returns a function that returns the results recorded for
+// calls to the function of the given name in the given recording.
+func replayFunction(name string, rec *EvalRecording) function.Function {
+	find := func(args []cty.Value) (RecordedCall, error) {
+	Calls:
+		for _, call := range rec.Calls {
+			if call.Name != name || len(call.Args) != len(args) {
+				continue
+			}
+			for i, arg := range args {
+				if !arg.RawEquals(call.Args[i]) {
+					continue Calls
+				}
+			}
+			if call.Error != "" {
+				return call, errors.New(call.Error)
+			}
+			return call, nil
+		}
+		return RecordedCall{}, fmt.Errorf("no result was recorded for a call to %s with these arguments", name)
+	}
+	return function.New(&function.Spec{
+		VarParam: anyArgsParam(),
+		Type: func(args []cty.Value) (cty.Type, error) {
+			call, err := find(args)
+			if err != nil {
+				return cty.NilType, err
+			}
+			return call.Result.Type(), nil
+		},
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			call, err := find(args)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			return call.Result, nil
+		},
+	})
+}
+
+// anyArgsParam returns a variadic parameter that accepts any argument values
+// unchanged.
+func anyArgsParam() *function.Parameter {
+	return &function.Parameter{
+		Name:             "args",
+		Type:             cty.DynamicPseudoType,
+		AllowNull:        true,
+		AllowUnknown:     true,
+		AllowDynamicType: true,
+		AllowMarked:      true,
+	}
+}
+
+// recordedValueJSON is the JSON serialization of a value in an
+// EvalRecording, which includes its type so that it can be decoded exactly.
+type recordedValueJSON struct {
+	Type  json.RawMessage `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+type recordedCallJSON struct {
+	Name   string              `json:"name"`
+	Args   []recordedValueJSON `json:"args"`
+	Result *recordedValueJSON  `json:"result,omitempty"`
+	Error  string              `json:"error,omitempty"`
+}
+
+type evalRecordingJSON struct {
+	Variables map[string]recordedValueJSON `json:"variables"`
+	Calls     []recordedCallJSON           `json:"calls"`
+}
+
+// MarshalJSON returns a JSON serialization of the receiver, which can be
+// decoded by UnmarshalJSON.
+func (r *EvalRecording) MarshalJSON() ([]byte, error) {
+	raw := evalRecordingJSON{
+		Variables: make(map[string]recordedValueJSON, len(r.Variables)),
+		Calls:     make([]recordedCallJSON, 0, len(r.Calls)),
+	}
+
+	names := make([]string, 0, len(r.Variables))
+	for name := range r.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		val, err := marshalRecordedValue(r.Variables[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for variable %q: %w", name, err)
+		}
+		raw.Variables[name] = val
+	}
+
+	for i, call := range r.Calls {
+		rawCall := recordedCallJSON{
+			Name:  call.Name,
+			Args:  make([]recordedValueJSON, len(call.Args)),
+			Error: call.Error,
+		}
+		for j, arg := range call.Args {
+			val, err := marshalRecordedValue(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid argument %d for call %d to %s: %w", j, i, call.Name, err)
+			}
+			rawCall.Args[j] = val
+		}
+		if call.Error == "" {
+			val, err := marshalRecordedValue(call.Result)
+			if err != nil {
+				return nil, fmt.Errorf("invalid result for call %d to %s: %w", i, call.Name, err)
+			}
+			rawCall.Result = &val
+		}
+		raw.Calls = append(raw.Calls, rawCall)
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON decodes a JSON serialization produced by MarshalJSON,
+// replacing the content of the receiver.
+func (r *EvalRecording) UnmarshalJSON(src []byte) error {
+	var raw evalRecordingJSON
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return err
+	}
+
+	*r = EvalRecording{
+		Variables: make(map[string]cty.Value, len(raw.Variables)),
+	}
+	for name, rawVal := range raw.Variables {
+		val, err := unmarshalRecordedValue(rawVal)
+		if err != nil {
+			return fmt.Errorf("invalid value for variable %q: %w", name, err)
+		}
+		r.Variables[name] = val
+	}
+	for i, rawCall := range raw.Calls {
+		call := RecordedCall{
+			Name:  rawCall.Name,
+			Args:  make([]cty.Value, len(rawCall.Args)),
+			Error: rawCall.Error,
+		}
+		for j, rawArg := range rawCall.Args {
+			val, err := unmarshalRecordedValue(rawArg)
+			if err != nil {
+				return fmt.Errorf("invalid argument %d for call %d to %s: %w", j, i, call.Name, err)
+			}
+			call.Args[j] = val
+		}
+		if rawCall.Result != nil {
+			val, err := unmarshalRecordedValue(*rawCall.Result)
+			if err != nil {
+				return fmt.Errorf("invalid result for call %d to %s: %w", i, call.Name, err)
+			}
+			call.Result = val
+		}
+		r.Calls = append(r.Calls, call)
+	}
+	return nil
+}
+
+func marshalRecordedValue(val cty.Value) (recordedValueJSON, error) {
+	if val.ContainsMarked() {
+		return recordedValueJSON{}, errors.New("value is marked")
+	}
+	ty, err := ctyjson.MarshalType(val.Type())
+	if err != nil {
+		return recordedValueJSON{}, err
+	}
+	v, err := ctyjson.Marshal(val, val.Type())
+	if err != nil {
+		return recordedValueJSON{}, err
+	}
+	return recordedValueJSON{Type: ty, Value: v}, nil
+}
+
+func unmarshalRecordedValue(raw recordedValueJSON) (cty.Value, error) {
+	ty, err := ctyjson.UnmarshalType(raw.Type)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(raw.Value, ty)
+}
@@ -0,0 +1,264 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+func TestRecordEvalContext(t *testing.T) {
+	calls := 0
+	counter := function.New(&function.Spec{
+		Type: function.StaticReturnType(cty.Number),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			calls++
+			return cty.NumberIntVal(int64(calls)), nil
+		},
+	})
+	parent := &EvalContext{
+		Variables: map[string]cty.Value{
+			"app": cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"tags": cty.ListVal([]cty.Value{cty.StringVal("a")}),
+			}),
+			"unused": cty.True,
+		},
+		Functions: map[string]function.Function{
+			"upper": stdlib.UpperFunc,
+		},
+	}
+	original := parent.NewChild()
+	original.VariableResolver = func(traversal Traversal) (cty.Value, Diagnostics, bool) {
+		if traversal.RootName() != "region" {
+			return cty.NilVal, nil, false
+		}
+		val, diags := traversal.SimpleSplit().Rel.TraverseRel(cty.StringVal("eu-west-1"))
+		return val, diags, true
+	}
+	original.FunctionResolver = func(name string) (function.Function, bool) {
+		return counter, name == "counter"
+	}
+
+	// evaluate exercises the given context in the same way as evaluating
+	// some references and function calls, returning the results.
+	evaluate := func(t *testing.T, ctx *EvalContext) []cty.Value {
+		t.Helper()
+		var ret []cty.Value
+		for _, traversal := range []Traversal{
+			{TraverseRoot{Name: "app"}, TraverseAttr{Name: "name"}},
+			{TraverseRoot{Name: "app"}, TraverseAttr{Name: "tags"}, TraverseIndex{Key: cty.NumberIntVal(0)}},
+			{TraverseRoot{Name: "region"}},
+		} {
+			val, diags := traversal.TraverseAbs(ctx)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Error())
+			}
+			ret = append(ret, val)
+		}
+		for _, call := range []struct {
+			name string
+			args []cty.Value
+		}{
+			{"upper", []cty.Value{cty.StringVal("a")}},
+			{"counter", nil},
+			{"counter", nil},
+		} {
+			f, exists := ctx.lookupFunction(call.name)
+			if !exists {
+				t.Fatalf("no function %q", call.name)
+			}
+			val, err := f.Call(call.args)
+			if err != nil {
+				t.Fatalf("unexpected error calling %s: %s", call.name, err)
+			}
+			ret = append(ret, val)
+		}
+		return ret
+	}
+
+	recCtx, rec := RecordEvalContext(original)
+	recorded := evaluate(t, recCtx)
+	want := []cty.Value{
+		cty.StringVal("web"),
+		cty.StringVal("a"),
+		cty.StringVal("eu-west-1"),
+		cty.StringVal("A"),
+		cty.NumberIntVal(1),
+		cty.NumberIntVal(2),
+	}
+	for i := range want {
+		if !recorded[i].RawEquals(want[i]) {
+			t.Errorf("wrong result %d while recording\ngot:  %#v\nwant: %#v", i, recorded[i], want[i])
+		}
+	}
+	if _, exists := rec.Variables["unused"]; exists {
+		t.Errorf("recorded a variable that was not referenced")
+	}
+	if got, want := len(rec.Calls), 3; got != want {
+		t.Errorf("recorded %d calls; want %d", got, want)
+	}
+
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatalf("failed to serialize recording: %s", err)
+	}
+	var decoded EvalRecording
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Fatalf("failed to decode recording: %s", err)
+	}
+
+	replayCtx := ReplayEvalContext(&decoded)
+	replayed := evaluate(t, replayCtx)
+	// The counter was called with the same arguments both times, and so
+	// the replay gives the result of the first call for both.
+	want[len(want)-1] = cty.NumberIntVal(1)
+	for i := range want {
+		if !replayed[i].RawEquals(want[i]) {
+			t.Errorf("wrong result %d while replaying\ngot:  %#v\nwant: %#v", i, replayed[i], want[i])
+		}
+	}
+
+	t.Run("unrecorded variable", func(t *testing.T) {
+		_, diags := Traversal{TraverseRoot{Name: "unused"}}.TraverseAbs(replayCtx)
+		if !diags.HasErrors() {
+			t.Fatalf("unexpected success")
+		}
+		if got, want := diags[0].Summary, "Unknown variable"; got != want {
+			t.Errorf("wrong summary %q; want %q", got, want)
+		}
+	})
+
+	t.Run("unrecorded arguments", func(t *testing.T) {
+		f, exists := replayCtx.lookupFunction("upper")
+		if !exists {
+			t.Fatalf("no function upper")
+		}
+		_, err := f.Call([]cty.Value{cty.StringVal("b")})
+		if err == nil || !strings.Contains(err.Error(), "no result was recorded for a call to upper") {
+			t.Errorf("wrong error %v", err)
+		}
+	})
+
+	t.Run("unrecorded function", func(t *testing.T) {
+		if _, exists := replayCtx.lookupFunction("lower"); exists {
+			t.Errorf("unexpected function lower")
+		}
+	})
+
+	t.Run("marked value", func(t *testing.T) {
+		rec := &EvalRecording{
+			Variables: map[string]cty.Value{"secret": cty.StringVal("x").Mark("sensitive")},
+		}
+		if _, err := json.Marshal(rec); err == nil {
+			t.Errorf("unexpected success")
+		}
+	})
+}
+
+// recordTestExpr is an expression that reads a variable and passes it to a
+// function, for testing EvaluateRecorded.
+type recordTestExpr struct {
+	staticExpr
+	traversal Traversal
+	function  string
+}
+
+func (e recordTestExpr) Value(ctx *EvalContext) (cty.Value, Diagnostics) {
+	val, diags := e.traversal.TraverseAbs(ctx)
+	if diags.HasErrors() {
+		return cty.DynamicVal, diags
+	}
+	f, exists := ctx.lookupFunction(e.function)
+	if !exists {
+		return cty.DynamicVal, diags.Append(&Diagnostic{
+			Severity: DiagError,
+			Summary:  "Call to unknown function",
+		})
+	}
+	result, err := f.Call([]cty.Value{val})
+	if err != nil {
+		return cty.DynamicVal, diags.Append(&Diagnostic{
+			Severity: DiagError,
+			Summary:  "Error in function call",
+			Detail:   err.Error(),
+		})
+	}
+	return result, diags
+}
+
+func TestEvaluateRecorded(t *testing.T) {
+	calls := 0
+	stamp := function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "prefix", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			calls++
+			return cty.StringVal(fmt.Sprintf("%s-%d", args[0].AsString(), calls)), nil
+		},
+	})
+	ctx := &EvalContext{
+		Variables: map[string]cty.Value{
+			"app":    cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("web")}),
+			"secret": cty.StringVal("unused"),
+		},
+		Functions: map[string]function.Function{
+			"stamp": stamp,
+		},
+	}
+	expr := recordTestExpr{
+		traversal: Traversal{TraverseRoot{Name: "app"}, TraverseAttr{Name: "name"}},
+		function:  "stamp",
+	}
+
+	got, rec, diags := EvaluateRecorded(expr, ctx)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	if want := cty.StringVal("web-1"); !got.RawEquals(want) {
+		t.Fatalf("wrong result %#v; want %#v", got, want)
+	}
+
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatalf("failed to serialize recording: %s", err)
+	}
+	if again, _ := json.Marshal(rec); string(again) != string(buf) {
+		t.Errorf("serialization is not deterministic\nfirst:  %s\nsecond: %s", buf, again)
+	}
+	if strings.Contains(string(buf), "unused") {
+		t.Errorf("recording includes a variable that was not read: %s", buf)
+	}
+
+	var decoded EvalRecording
+	if err := json.Unmarshal(buf, &decoded); err != nil {
+		t.Fatalf("failed to decode recording: %s", err)
+	}
+	replayed, diags := decoded.Evaluate(expr)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors replaying: %s", diags.Error())
+	}
+	if !replayed.RawEquals(got) {
+		t.Errorf("wrong replayed result %#v; want %#v", replayed, got)
+	}
+	if calls != 1 {
+		t.Errorf("function was called %d times; want 1", calls)
+	}
+
+	t.Run("missing input", func(t *testing.T) {
+		other := recordTestExpr{
+			traversal: Traversal{TraverseRoot{Name: "secret"}},
+			function:  "stamp",
+		}
+		if _, diags := decoded.Evaluate(other); !diags.HasErrors() {
+			t.Errorf("unexpected success")
+		}
+	})
+}
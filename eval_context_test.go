@@ -0,0 +1,256 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+type subsetContextExpr struct {
+	staticExpr
+	vars  []Traversal
+	funcs []string
+}
+
+func (e subsetContextExpr) Variables() []Traversal {
+	return e.vars
+}
+
+func (e subsetContextExpr) Functions() []string {
+	return e.funcs
+}
+
+func TestSubsetContext(t *testing.T) {
+	upper := function.New(&function.Spec{})
+	lower := function.New(&function.Spec{})
+	parent := &EvalContext{
+		Variables: map[string]cty.Value{
+			"a": cty.ObjectVal(map[string]cty.Value{
+				"b": cty.StringVal("a.b"),
+				"c": cty.StringVal("a.c"),
+			}),
+			"shadowed": cty.StringVal("parent"),
+			"unused":   cty.StringVal("unused"),
+		},
+		Functions: map[string]function.Function{
+			"upper": upper,
+			"lower": lower,
+		},
+		VariableResolver: func(traversal Traversal) (cty.Value, Diagnostics, bool) {
+			if traversal.RootName() != "lazy" {
+				return cty.NilVal, nil, false
+			}
+			return cty.StringVal("lazy"), nil, true
+		},
+	}
+	ctx := parent.NewChild()
+	ctx.Variables = map[string]cty.Value{
+		"shadowed": cty.StringVal("child"),
+	}
+
+	traversal := func(names ...string) Traversal {
+		ret := Traversal{TraverseRoot{Name: names[0]}}
+		for _, name := range names[1:] {
+			ret = append(ret, TraverseAttr{Name: name})
+		}
+		return ret
+	}
+
+	tests := map[string]struct {
+		expr      Expression
+		wantVars  map[string]cty.Value
+		wantFuncs []string
+	}{
+		"nothing": {
+			subsetContextExpr{},
+			map[string]cty.Value{},
+			[]string{},
+		},
+		"nested traversal": {
+			subsetContextExpr{
+				vars: []Traversal{traversal("a", "b")},
+			},
+			map[string]cty.Value{
+				"a": parent.Variables["a"],
+			},
+			[]string{},
+		},
+		"shadowed, lazy, and undefined": {
+			subsetContextExpr{
+				vars: []Traversal{
+					traversal("shadowed"),
+					traversal("lazy", "foo"),
+					traversal("undefined"),
+				},
+			},
+			map[string]cty.Value{
+				"shadowed": cty.StringVal("child"),
+				"lazy":     cty.StringVal("lazy"),
+			},
+			[]string{},
+		},
+		"functions": {
+			subsetContextExpr{
+				funcs: []string{"upper", "undefined"},
+			},
+			map[string]cty.Value{},
+			[]string{"upper"},
+		},
+		"functions unknown": {
+			StaticExpr(cty.True, Range{}),
+			map[string]cty.Value{},
+			[]string{"lower", "upper"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := SubsetContext(ctx, test.expr)
+			if got.Parent() != nil {
+				t.Errorf("result has a parent context")
+			}
+
+			if len(got.Variables) != len(test.wantVars) {
+				t.Errorf("wrong variables %#v; want %#v", got.Variables, test.wantVars)
+			}
+			for name, want := range test.wantVars {
+				if got, ok := got.Variables[name]; !ok || !got.RawEquals(want) {
+					t.Errorf("wrong value for %q: %#v; want %#v", name, got, want)
+				}
+			}
+
+			gotFuncs := make([]string, 0, len(got.Functions))
+			for name := range got.Functions {
+				gotFuncs = append(gotFuncs, name)
+			}
+			sort.Strings(gotFuncs)
+			if len(gotFuncs) != len(test.wantFuncs) {
+				t.Fatalf("wrong functions %#v; want %#v", gotFuncs, test.wantFuncs)
+			}
+			for i := range gotFuncs {
+				if gotFuncs[i] != test.wantFuncs[i] {
+					t.Fatalf("wrong functions %#v; want %#v", gotFuncs, test.wantFuncs)
+				}
+			}
+		})
+	}
+
+	t.Run("nil context", func(t *testing.T) {
+		if got := SubsetContext(nil, subsetContextExpr{}); got != nil {
+			t.Errorf("wrong result %#v; want nil", got)
+		}
+	})
+
+	t.Run("no variables allowed", func(t *testing.T) {
+		got := SubsetContext(&EvalContext{}, subsetContextExpr{
+			vars: []Traversal{traversal("a")},
+		})
+		if got.Variables != nil || got.Functions != nil {
+			t.Errorf("result allows variables or functions, but original did not")
+		}
+	})
+	t.Run("settings", func(t *testing.T) {
+		var calls []string
+		parent := &EvalContext{
+			MaxEvalSteps: 10,
+			FunctionCallValidator: func(name string, args []cty.Value) Diagnostics {
+				calls = append(calls, "parent")
+				return nil
+			},
+		}
+		parent.ConsumeEvalStep()
+		ctx := parent.NewChild()
+		ctx.FunctionCallValidator = func(name string, args []cty.Value) Diagnostics {
+			calls = append(calls, "child")
+			return nil
+		}
+
+		got := SubsetContext(ctx, subsetContextExpr{})
+		if got.MaxEvalSteps != 10 {
+			t.Errorf("wrong MaxEvalSteps %d; want 10", got.MaxEvalSteps)
+		}
+		if got.evalSteps != 0 {
+			t.Errorf("wrong count of steps %d; want 0", got.evalSteps)
+		}
+		if got.FunctionCallValidator == nil {
+			t.Fatalf("result has no FunctionCallValidator")
+		}
+		got.FunctionCallValidator("f", nil)
+		if want := []string{"child", "parent"}; len(calls) != 2 || calls[0] != want[0] || calls[1] != want[1] {
+			t.Errorf("wrong validator calls %#v; want %#v", calls, want)
+		}
+	})
+}
+
+func TestEvalContext_ConsumeEvalStep(t *testing.T) {
+	t.Run("no budget", func(t *testing.T) {
+		var nilCtx *EvalContext
+		ctx := (&EvalContext{}).NewChild()
+		for i := 0; i < 10; i++ {
+			if !nilCtx.ConsumeEvalStep() || !ctx.ConsumeEvalStep() {
+				t.Fatalf("budget exceeded without a budget")
+			}
+		}
+	})
+
+	t.Run("inherited", func(t *testing.T) {
+		parent := &EvalContext{
+			MaxEvalSteps: 2,
+		}
+		child := parent.NewChild().NewChild()
+		if !child.ConsumeEvalStep() || !parent.ConsumeEvalStep() {
+			t.Fatalf("budget exceeded too early")
+		}
+		if child.ConsumeEvalStep() {
+			t.Fatalf("budget not exceeded")
+		}
+
+		parent.ResetEvalSteps()
+		if !child.ConsumeEvalStep() {
+			t.Fatalf("budget not reset")
+		}
+	})
+
+	t.Run("innermost governs", func(t *testing.T) {
+		parent := &EvalContext{
+			MaxEvalSteps: 1,
+		}
+		child := parent.NewChild()
+		child.MaxEvalSteps = 3
+		for i := 0; i < 3; i++ {
+			if !child.ConsumeEvalStep() {
+				t.Fatalf("budget exceeded after %d steps", i)
+			}
+		}
+		if !parent.ConsumeEvalStep() {
+			t.Fatalf("child's steps were counted against parent's budget")
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		ctx := &EvalContext{
+			MaxEvalSteps: 1000,
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				child := ctx.NewChild()
+				for j := 0; j < 100; j++ {
+					child.ConsumeEvalStep()
+				}
+			}()
+		}
+		wg.Wait()
+		if ctx.ConsumeEvalStep() {
+			t.Fatalf("budget not exceeded after %d steps", ctx.evalSteps)
+		}
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hcltest"
 	"github.com/zclconf/go-cty-debug/ctydebug"
 	"github.com/zclconf/go-cty/cty"
@@ -851,3 +852,75 @@ func TestExpandInvalidIteratorError(t *testing.T) {
 	})
 
 }
+
+func TestExpandNestedIteratorNames(t *testing.T) {
+	// When a dynamic block is nested inside the content of another dynamic
+	// block of the same type, the default iterator name of the inner block
+	// would shadow the outer one. Naming the inner iterator explicitly
+	// allows the inner content to refer to both.
+	src := `
+dynamic "group" {
+  for_each = ["a", "b"]
+  content {
+    name = group.value
+
+    dynamic "group" {
+      for_each = ["x", "y"]
+      iterator = inner
+      content {
+        name = "${group.value}-${inner.value}"
+      }
+    }
+  }
+}
+`
+	f, diags := hclsyntax.ParseConfig([]byte(src), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected parse errors: %s", diags)
+	}
+
+	decSpec := &hcldec.BlockListSpec{
+		TypeName: "group",
+		Nested: &hcldec.ObjectSpec{
+			"name": &hcldec.AttrSpec{
+				Name: "name",
+				Type: cty.String,
+			},
+			"group": &hcldec.BlockListSpec{
+				TypeName: "group",
+				Nested: &hcldec.ObjectSpec{
+					"name": &hcldec.AttrSpec{
+						Name: "name",
+						Type: cty.String,
+					},
+				},
+			},
+		},
+	}
+
+	got, diags := hcldec.Decode(Expand(f.Body, nil), decSpec, nil)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags)
+	}
+
+	group := func(outer string) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal(outer),
+			"group": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal(outer + "-x"),
+				}),
+				cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal(outer + "-y"),
+				}),
+			}),
+		})
+	}
+	want := cty.ListVal([]cty.Value{
+		group("a"),
+		group("b"),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
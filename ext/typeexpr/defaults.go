@@ -4,16 +4,30 @@
 package typeexpr
 
 import (
+	"context"
+	"fmt"
+	"math/big"
+	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/convert"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
 )
 
 // Defaults represents a type tree which may contain default values for
 // optional object attributes at any level. This is used to apply nested
 // defaults to a given cty.Value before converting it to a concrete type.
+//
+// A nil *Defaults represents the absence of any defaults, as returned by
+// TypeConstraintWithDefaults for a type constraint without any. All of the
+// methods that apply defaults accept a nil receiver and return the given value
+// unchanged, without any conversion because there is no type to convert to.
 type Defaults struct {
 	// Type of the node for which these defaults apply. This is necessary in
 	// order to determine how to inspect the Defaults and Children collections.
@@ -21,8 +35,19 @@ type Defaults struct {
 
 	// DefaultValues contains the default values for each object attribute,
 	// indexed by attribute name.
+	//
+	// The value at key WildcardAttribute, if any, is the default for every
+	// attribute of the object type that has no default of its own, either in
+	// DefaultValues or, for ApplyWithContext, in DefaultExprs.
 	DefaultValues map[string]cty.Value
 
+	// DefaultExprs contains expressions to evaluate to produce default
+	// values for object attributes, indexed by attribute name, for defaults
+	// that depend on other values. These are used only by ApplyWithContext,
+	// which prefers them over any static default for the same attribute in
+	// DefaultValues. Other methods use only DefaultValues.
+	DefaultExprs map[string]hcl.Expression
+
 	// Children is a map of Defaults for elements contained in this type. This
 	// only applies to structural and collection types.
 	//
@@ -30,9 +55,27 @@ type Defaults struct {
 	// instances are non-comparable, due to embedding a *big.Float.
 	//
 	// Collections have a single element type, which is stored at key "".
+	//
+	// For an object type, the child at key WildcardAttribute, if any, applies
+	// to each attribute that has the same type as the child and no child of
+	// its own.
 	Children map[string]*Defaults
 }
 
+// WildcardAttribute is the key in the DefaultValues and Children of a
+// Defaults for an object type whose entries apply to any attribute without
+// an entry of its own, so that defaults can be given for many attributes at
+// once. An entry under an attribute's own name always takes precedence.
+//
+// As with the entries for particular attributes, the wildcard applies to
+// required attributes as well as optional ones, so a required attribute that
+// is missing or null also gets the wildcard default. Defaults trees built by
+// TypeConstraintWithDefaults never have a wildcard.
+//
+// If the object type has an attribute named "*" then the key refers only to
+// that attribute, as usual, and there is no wildcard.
+const WildcardAttribute = "*"
+
 // Apply walks the given value, applying specified defaults wherever optional
 // attributes are missing. The input and output values may have different
 // types, and the result may still require type conversion to the final desired
@@ -41,36 +84,754 @@ type Defaults struct {
 // This function is permissive and does not report errors, assuming that the
 // caller will have better context to report useful type conversion failure
 // diagnostics.
+//
+// Marks on the given value are preserved at every level. A default value
+// substituted for a null attribute has both its own marks and any marks of
+// the null value it replaces.
+//
+// Defaults are never applied to capsule-typed values, which are opaque, so
+// such values are passed through unchanged at any level of nesting,
+// regardless of any defaults given for them.
+//
+// To protect against exhausting the stack, defaults are applied only to
+// values nested no more than DefaultMaxApplyDepth levels deep. Any more
+// deeply-nested parts of the value are returned unchanged, without their
+// defaults, and there is no indication that this happened. Callers that might
+// be given such values should use ApplyWithOptions instead, which reports an
+// error in that case.
 func (d *Defaults) Apply(val cty.Value) cty.Value {
-	return d.apply(val)
+	return d.apply(val, &applyState{}, nil)
 }
 
-func (d *Defaults) apply(v cty.Value) cty.Value {
-	// We don't apply defaults to null values or unknown values. To be clear,
-	// we will overwrite children values with defaults if they are null but not
-	// if the actual value is null.
-	if !v.IsKnown() || v.IsNull() {
+// ApplyContext is a variant of Apply which can be cancelled using the given
+// context, for applying defaults to very large values. The context is checked
+// periodically while visiting the elements of collections and the
+// attributes of objects.
+//
+// If the context is cancelled before all defaults have been applied, the
+// result is the given value unchanged, along with an error diagnostic.
+// Otherwise the result is the same as for Apply, and there is an error
+// diagnostic only if some parts of the value were left unchanged because they
+// are nested more deeply than DefaultMaxApplyDepth.
+func (d *Defaults) ApplyContext(ctx context.Context, val cty.Value) (cty.Value, hcl.Diagnostics) {
+	s := &applyState{
+		ctx: ctx,
+	}
+	if err := ctx.Err(); err != nil {
+		s.ctxErr = err
+	}
+	ret := d.apply(val, s, nil)
+	if s.ctxErr != nil {
+		return val, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Applying defaults cancelled",
+				Detail:   fmt.Sprintf("Default values were not applied because the operation was cancelled: %s.", s.ctxErr),
+			},
+		}
+	}
+	return ret, s.depthDiags()
+}
+
+// ApplyFillingNulls is a variant of Apply which also applies defaults within
+// null objects, rather than leaving them null.
+//
+// A null value, or a missing object attribute, for which the defaults
+// describe an object type with defaults somewhere inside it is replaced by
+// an object with those defaults applied, at any level of nesting. Null
+// collections and primitive values are still left null, as with Apply.
+//
+// The resulting objects contain only the attributes that have defaults, so
+// converting the result to the final type still fails if any of the nested
+// objects have required attributes.
+//
+// As with Apply, values nested more deeply than DefaultMaxApplyDepth are
+// silently left unchanged.
+func (d *Defaults) ApplyFillingNulls(val cty.Value) cty.Value {
+	return d.apply(val, &applyState{fillNulls: true}, nil)
+}
+
+// ApplyWithContext is a variant of Apply which also evaluates the expressions
+// in DefaultExprs to produce default values, in the given evaluation context.
+//
+// An expression is evaluated only when its attribute is missing or null. In
+// addition to the variables from the given context, the expression can refer
+// to the other attributes of the object it is a default for, by name. Only
+// attributes that were given in the value or that have static defaults are
+// available in this way, so expression defaults cannot refer to each other.
+// The static default in DefaultValues is used for any attribute that has no
+// expression.
+//
+// The returned diagnostics are those from evaluating the expressions. An
+// attribute whose expression produces errors is left missing or null. There
+// is also an error diagnostic if some parts of the value were left unchanged
+// because they are nested more deeply than DefaultMaxApplyDepth.
+func (d *Defaults) ApplyWithContext(val cty.Value, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	s := &applyState{
+		withExprs: true,
+		evalCtx:   ctx,
+	}
+	ret := d.apply(val, s, nil)
+	return ret, append(s.diags, s.depthDiags()...)
+}
+
+// ApplyAndConvert is a variant of Apply which also converts the result to
+// the receiver's type.
+//
+// Defaults are applied using the same walk as Apply. Any optional attributes
+// that are then still absent or null become null values of their declared
+// types, rather than untyped nulls. If the result cannot be converted then
+// the error is an *ApplyError describing the problem and the returned value
+// is the result of Apply, without conversion.
+func (d *Defaults) ApplyAndConvert(val cty.Value) (cty.Value, error) {
+	return d.applyAndConvert(val, &applyState{})
+}
+
+func (d *Defaults) applyAndConvert(val cty.Value, s *applyState) (cty.Value, error) {
+	if d == nil {
+		return val, nil
+	}
+
+	val = d.apply(val, s, nil)
+	if s.depthExceeded != nil {
+		return val, s.depthExceeded
+	}
+
+	// Conversion walks the whole value, which is wasted effort for a large
+	// value that already has exactly the type we'd convert it to.
+	if val.Type().Equals(d.Type.WithoutOptionalAttributesDeep()) {
+		return val, nil
+	}
+
+	ret, err := s.convertValue(val, d.Type)
+	if err != nil {
+		// The conversion error only describes the first problem found, so
+		// we prefer the more complete message describing the whole type
+		// mismatch when the types are not convertible at all, unless the
+		// caller's own conversion function gave the error.
+		if s.convert == nil && convert.GetConversionUnsafe(val.Type(), d.Type) == nil {
+			unmarked, _ := val.UnmarkDeep()
+			path, source, target := mismatchAt(unmarked, d.Type, nil)
+			return val, &ApplyError{
+				Path:   path,
+				Source: source,
+				Target: target,
+				Msg:    convert.MismatchMessage(source, target),
+			}
+		}
+
+		applyErr := &ApplyError{
+			Source: val.Type(),
+			Target: d.Type,
+			Msg:    err.Error(),
+		}
+		if pathErr, ok := err.(cty.PathError); ok && len(pathErr.Path) > 0 {
+			unmarked, _ := val.UnmarkDeep()
+			source, sourceErr := pathErr.Path.Apply(unmarked)
+			target, targetOk := typeAtPath(d.Type, pathErr.Path)
+			if sourceErr == nil && targetOk {
+				applyErr.Path = pathErr.Path
+				applyErr.Source = source.Type()
+				applyErr.Target = target
+			}
+		}
+		return val, applyErr
+	}
+	return ret, nil
+}
+
+// ApplyWithDiagnostics is a variant of ApplyAndConvert which returns error
+// diagnostics describing any mismatch, rather than an error. The Extra field
+// of the diagnostic is the *ApplyError that ApplyAndConvert would return.
+//
+// Applying defaults to the elements of a set can make some of them equal, in
+// which case they merge into a single element of the resulting set. This is
+// not an error, but ApplyWithDiagnostics returns a warning for each set
+// where it happens, because it's often a sign that the set elements differ
+// only in attributes that ought to be required.
+func (d *Defaults) ApplyWithDiagnostics(val cty.Value) (cty.Value, hcl.Diagnostics) {
+	return d.ApplyWithOptions(val, ApplyOptions{})
+}
+
+// ApplyOptions are the options for ApplyWithOptions.
+type ApplyOptions struct {
+	// MaxDepth is the maximum depth of nested values to which defaults are
+	// applied, protecting against very deeply-nested types exhausting the
+	// stack. If a value is nested more deeply than this among values that
+	// have defaults, the result is an error. If zero, the limit is
+	// DefaultMaxApplyDepth.
+	MaxDepth int
+
+	// OnDefault, if set, is called each time a value from DefaultValues is
+	// substituted for a missing or null attribute, with the path where the
+	// default was placed and the value placed there. This is intended for
+	// explaining where values came from, and does not affect the result.
+	//
+	// The calls are made in a predictable order: the attributes of an object
+	// and the elements of a map are visited in lexical order of their keys,
+	// and the elements of a list or tuple in order of their indices. Defaults
+	// applied within a default value are reported before the default value
+	// itself, because they are applied first.
+	OnDefault func(path cty.Path, value cty.Value)
+
+	// RejectUnknownAttributes makes it an error for an object in the given
+	// value to have an attribute that is not declared in the corresponding
+	// object type, including within the elements of collections. Conversion
+	// to the type would otherwise silently discard such attributes, hiding
+	// typos in attribute names. If there are any, defaults are not applied
+	// and the given value is returned along with an error for each.
+	RejectUnknownAttributes bool
+
+	// TreatEmptyAsMissing makes an empty string, or an empty collection,
+	// tuple, or object, count as missing at an attribute that has a value in
+	// DefaultValues, so that it is replaced by that default just as a null
+	// attribute would be. Empty values elsewhere are left unchanged, as are
+	// attributes whose defaults come from DefaultExprs.
+	TreatEmptyAsMissing bool
+
+	// Unify, if set, replaces convert.UnifyUnsafe for finding a common
+	// element type for the elements of a list, set, or map after defaults
+	// have been applied to them, which may have made their types differ.
+	// If it returns cty.NilType then the collection's elements are instead
+	// left as the elements of a tuple or object, for the final conversion to
+	// deal with.
+	//
+	// Convert, if set, replaces convert.Convert for the final conversion to
+	// the type of the defaults. It isn't called if the value already has
+	// exactly that type.
+	//
+	// These allow stricter or domain-specific conversion rules, such as
+	// disallowing the conversion of numbers to strings.
+	Unify   func(types []cty.Type) (cty.Type, []convert.Conversion)
+	Convert func(val cty.Value, ty cty.Type) (cty.Value, error)
+}
+
+// DefaultMaxApplyDepth is the maximum depth of nested values to which the
+// Apply family of methods will apply defaults, unless overridden by
+// ApplyOptions.MaxDepth. All of the methods leave any more deeply nested
+// values unchanged, but only Apply and ApplyFillingNulls do so silently,
+// while the others return an error.
+const DefaultMaxApplyDepth = 1000
+
+// ApplyWithOptions is a variant of ApplyWithDiagnostics which accepts
+// additional options to modify its behavior.
+func (d *Defaults) ApplyWithOptions(val cty.Value, opts ApplyOptions) (cty.Value, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	if opts.RejectUnknownAttributes && d != nil {
+		diags = unknownAttributeDiags(val, d.Type, nil)
+		if diags.HasErrors() {
+			return val, diags
+		}
+	}
+
+	s := &applyState{
+		maxDepth:            opts.MaxDepth,
+		onDefault:           opts.OnDefault,
+		treatEmptyAsMissing: opts.TreatEmptyAsMissing,
+		unify:               opts.Unify,
+		convert:             opts.Convert,
+	}
+	ret, err := d.applyAndConvert(val, s)
+	for _, collapsed := range s.collapsedSets {
+		where := "the set"
+		if len(collapsed.path) > 0 {
+			where = fmt.Sprintf("the set at %s", formatApplyPath(collapsed.path))
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Set elements merged by defaults",
+			Detail:   fmt.Sprintf("Applying default values reduced the number of elements in %s from %d to %d, because some of its elements became equal and so were merged.", where, collapsed.before, collapsed.after),
+		})
+	}
+	if err != nil {
+		applyErr := err.(*ApplyError)
+		detail := fmt.Sprintf("Unsuitable value: %s.", applyErr.Msg)
+		if len(applyErr.Path) > 0 {
+			detail = fmt.Sprintf("Unsuitable value at %s: %s.", formatApplyPath(applyErr.Path), applyErr.Msg)
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsuitable value type",
+			Detail:   detail,
+			Extra:    applyErr,
+		})
+	}
+	return ret, diags
+}
+
+// unknownAttributeDiags returns an error diagnostic for each attribute of an
+// object within the given value, which is at the given path, that is not
+// declared in the corresponding object type within the given type.
+func unknownAttributeDiags(val cty.Value, ty cty.Type, path cty.Path) hcl.Diagnostics {
+	val, _ = val.Unmark()
+	if !val.IsKnown() || val.IsNull() {
+		return nil
+	}
+
+	var diags hcl.Diagnostics
+	vty := val.Type()
+	var elems map[string]cty.Value
+	var keys []string
+	if vty.IsObjectType() || vty.IsMapType() {
+		elems = val.AsValueMap()
+		keys = make([]string, 0, len(elems))
+		for key := range elems {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	switch {
+	case ty.IsObjectType() && elems != nil:
+		for _, key := range keys {
+			keyPath := applyPathStep(vty, path, key)
+			if !ty.HasAttribute(key) {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Unsupported attribute",
+					Detail:   fmt.Sprintf("The attribute at %s is not declared in the type constraint, and so would be discarded.", formatApplyPath(keyPath)),
+				})
+				continue
+			}
+			diags = append(diags, unknownAttributeDiags(elems[key], ty.AttributeType(key), keyPath)...)
+		}
+	case ty.IsMapType() && elems != nil:
+		for _, key := range keys {
+			diags = append(diags, unknownAttributeDiags(elems[key], ty.ElementType(), applyPathStep(vty, path, key))...)
+		}
+	case (ty.IsListType() || ty.IsSetType()) && (vty.IsListType() || vty.IsSetType() || vty.IsTupleType()):
+		for ix, elem := range val.AsValueSlice() {
+			diags = append(diags, unknownAttributeDiags(elem, ty.ElementType(), path.Index(cty.NumberIntVal(int64(ix))))...)
+		}
+	case ty.IsTupleType() && (vty.IsListType() || vty.IsTupleType()):
+		etys := ty.TupleElementTypes()
+		if val.LengthInt() != len(etys) {
+			// Conversion will report the mismatched length.
+			return nil
+		}
+		for ix, elem := range val.AsValueSlice() {
+			diags = append(diags, unknownAttributeDiags(elem, etys[ix], path.Index(cty.NumberIntVal(int64(ix))))...)
+		}
+	}
+	return diags
+}
+
+// ApplyError is the type of error returned by ApplyAndConvert when the
+// result of applying defaults cannot be converted to the required type.
+type ApplyError struct {
+	// Path is the location of the problem within the value, which is empty
+	// if the problem is with the value as a whole.
+	Path cty.Path
+
+	// Source is the type of the value at Path, after applying defaults, and
+	// Target is the type that is required there.
+	Source, Target cty.Type
+
+	// Msg describes the problem.
+	Msg string
+}
+
+func (e *ApplyError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", formatApplyPath(e.Path), e.Msg)
+}
+
+// formatApplyPath renders the given path in a compact form for use in an
+// ApplyError message, such as .a[0]["b"].
+func formatApplyPath(path cty.Path) string {
+	var buf strings.Builder
+	for _, step := range path {
+		switch step := step.(type) {
+		case cty.GetAttrStep:
+			buf.WriteString("." + step.Name)
+		case cty.IndexStep:
+			key, _ := step.Key.Unmark()
+			switch {
+			case !key.IsKnown() || key.IsNull():
+				buf.WriteString("[...]")
+			case key.Type() == cty.String:
+				fmt.Fprintf(&buf, "[%q]", key.AsString())
+			case key.Type() == cty.Number:
+				fmt.Fprintf(&buf, "[%s]", key.AsBigFloat().Text('f', -1))
+			default:
+				buf.WriteString("[...]")
+			}
+		}
+	}
+	return buf.String()
+}
+
+// typeAtPath returns the type found at the given path within a value of the
+// given type, or false if the path doesn't refer to anything in that type.
+func typeAtPath(ty cty.Type, path cty.Path) (cty.Type, bool) {
+	for _, step := range path {
+		switch step := step.(type) {
+		case cty.GetAttrStep:
+			switch {
+			case ty.IsObjectType() && ty.HasAttribute(step.Name):
+				ty = ty.AttributeType(step.Name)
+			case ty.IsMapType():
+				ty = ty.ElementType()
+			default:
+				return cty.NilType, false
+			}
+		case cty.IndexStep:
+			key, _ := step.Key.Unmark()
+			switch {
+			case ty.IsCollectionType():
+				ty = ty.ElementType()
+			case ty.IsObjectType() && key.Type() == cty.String && key.IsKnown() && !key.IsNull() && ty.HasAttribute(key.AsString()):
+				ty = ty.AttributeType(key.AsString())
+			case ty.IsTupleType() && key.Type() == cty.Number && key.IsKnown() && !key.IsNull():
+				ix, accuracy := key.AsBigFloat().Int64()
+				if accuracy != big.Exact || ix < 0 || int(ix) >= len(ty.TupleElementTypes()) {
+					return cty.NilType, false
+				}
+				ty = ty.TupleElementType(int(ix))
+			default:
+				return cty.NilType, false
+			}
+		default:
+			return cty.NilType, false
+		}
+	}
+	return ty, true
+}
+
+// mismatchAt finds the most deeply nested part of the given value that
+// cannot be converted to the corresponding part of the given type, returning
+// its path along with the source and target types found there. The caller
+// must already know that val cannot be converted to ty.
+func mismatchAt(val cty.Value, ty cty.Type, path cty.Path) (cty.Path, cty.Type, cty.Type) {
+	if val.IsNull() || !val.IsKnown() {
+		return path, val.Type(), ty
+	}
+
+	vt := val.Type()
+	var children []cty.PathStep
+	var childTypes []cty.Type
+	switch {
+	case ty.IsObjectType() && (vt.IsObjectType() || vt.IsMapType()):
+		names := make([]string, 0, len(ty.AttributeTypes()))
+		for name := range ty.AttributeTypes() {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if vt.IsObjectType() && !vt.HasAttribute(name) {
+				if ty.AttributeOptional(name) {
+					continue
+				}
+				// A missing required attribute is a problem with the
+				// object itself, not with any of its attributes.
+				return path, vt, ty
+			}
+			if vt.IsMapType() {
+				children = append(children, cty.IndexStep{Key: cty.StringVal(name)})
+			} else {
+				children = append(children, cty.GetAttrStep{Name: name})
+			}
+			childTypes = append(childTypes, ty.AttributeType(name))
+		}
+	case ty.IsTupleType() && (vt.IsTupleType() || vt.IsListType()):
+		if val.LengthInt() != len(ty.TupleElementTypes()) {
+			return path, vt, ty
+		}
+		for i, ety := range ty.TupleElementTypes() {
+			children = append(children, cty.IndexStep{Key: cty.NumberIntVal(int64(i))})
+			childTypes = append(childTypes, ety)
+		}
+	case ty.IsCollectionType() && (vt.IsCollectionType() || vt.IsObjectType() || vt.IsTupleType()):
+		for it := val.ElementIterator(); it.Next(); {
+			k, _ := it.Element()
+			if vt.IsObjectType() {
+				children = append(children, cty.GetAttrStep{Name: k.AsString()})
+			} else {
+				children = append(children, cty.IndexStep{Key: k})
+			}
+			childTypes = append(childTypes, ty.ElementType())
+		}
+	}
+
+	for i, step := range children {
+		child, err := step.Apply(val)
+		if err != nil || child.Type().Equals(childTypes[i]) || convert.GetConversionUnsafe(child.Type(), childTypes[i]) != nil {
+			continue
+		}
+		return mismatchAt(child, childTypes[i], append(path.Copy(), step))
+	}
+	return path, vt, ty
+}
+
+// ApplyIter is a variant of Apply for large lists, sets, and tuples, which
+// returns an iterator that applies defaults to one element at a time rather
+// than building the whole result in memory. Each call to the iterator returns
+// the next element, in the same order as the elements of the given value, or
+// false once there are no more elements.
+//
+// Each element has the same defaults applied as in the result of Apply, and
+// carries any marks from the given collection. Apply also unifies the element
+// types of a resulting list or set, which ApplyIter cannot do without visiting
+// every element, so the elements may still require type conversion.
+//
+// If the given value is not a known, non-null list, set, or tuple then the
+// iterator returns no elements.
+func (d *Defaults) ApplyIter(val cty.Value) func() (cty.Value, bool) {
+	ty := val.Type()
+	if !val.IsKnown() || val.IsNull() || !(ty.IsListType() || ty.IsSetType() || ty.IsTupleType()) {
+		return func() (cty.Value, bool) {
+			return cty.NilVal, false
+		}
+	}
+
+	val, marks := val.Unmark()
+	it := val.ElementIterator()
+	ix := 0
+	return func() (cty.Value, bool) {
+		if !it.Next() {
+			return cty.NilVal, false
+		}
+		_, element := it.Element()
+		if childDefaults := d.getChild(ix); childDefaults != nil {
+			element = childDefaults.apply(element, &applyState{}, nil)
+		}
+		ix++
+		return element.WithMarks(marks), true
+	}
+}
+
+// applyState is the state shared by all of the levels of a single walk of a
+// value by apply.
+type applyState struct {
+	// fillNulls is set to also apply defaults within null objects, as for
+	// ApplyFillingNulls.
+	fillNulls bool
+
+	// withExprs is set to also evaluate DefaultExprs, in evalCtx, as for
+	// ApplyWithContext. Any diagnostics from doing so are added to diags.
+	withExprs bool
+	evalCtx   *hcl.EvalContext
+	diags     hcl.Diagnostics
+
+	// collapsedSets records each set whose elements were merged because
+	// they became equal after defaults were applied.
+	collapsedSets []collapsedSet
+
+	// maxDepth overrides DefaultMaxApplyDepth if greater than zero. If the
+	// limit is reached then depthExceeded describes where.
+	maxDepth      int
+	depthExceeded *ApplyError
+
+	// ctx, if set, is checked periodically for cancellation, as for
+	// ApplyContext. Once it has been cancelled, ctxErr is its error and no
+	// further defaults are applied.
+	ctx    context.Context
+	ctxErr error
+	steps  int
+
+	// onDefault, if set, is called for each default value substituted, as
+	// for ApplyOptions.OnDefault.
+	onDefault func(path cty.Path, value cty.Value)
+
+	// treatEmptyAsMissing is set to also replace empty values at attributes
+	// with a default value, as for ApplyOptions.TreatEmptyAsMissing.
+	treatEmptyAsMissing bool
+
+	// unify and convert, if set, replace the go-cty functions of the same
+	// names, as for ApplyOptions.Unify and ApplyOptions.Convert.
+	unify   func([]cty.Type) (cty.Type, []convert.Conversion)
+	convert func(cty.Value, cty.Type) (cty.Value, error)
+
+	// hasDefaultsCache memoizes the result of hasDefaults for each node in
+	// the tree, since it is otherwise recalculated for every element of a
+	// collection.
+	hasDefaultsCache map[*Defaults]bool
+}
+
+// hasDefaults returns true if applying the receiver using the given state
+// could change a value, because it or one of its descendents has a default
+// that the state would apply. Values are returned unchanged by any subtree
+// for which this returns false, so it need not be walked at all.
+func (d *Defaults) hasDefaults(s *applyState) bool {
+	if ret, ok := s.hasDefaultsCache[d]; ok {
+		return ret
+	}
+	if s.hasDefaultsCache == nil {
+		s.hasDefaultsCache = make(map[*Defaults]bool)
+	}
+	// A node that is its own descendent, which Validate reports, is treated
+	// as having no defaults while we're still deciding, so that we don't
+	// recurse forever.
+	s.hasDefaultsCache[d] = false
+
+	ret := len(d.DefaultValues) > 0 || (s.withExprs && len(d.DefaultExprs) > 0)
+	if !ret && s.fillNulls && d.Type.IsObjectType() && len(d.Children) > 0 {
+		// Filling a null object replaces it with an object of nulls, even if
+		// there are no defaults to put in it.
+		ret = true
+	}
+	for _, child := range d.Children {
+		if ret {
+			break
+		}
+		ret = child != nil && child.hasDefaults(s)
+	}
+
+	s.hasDefaultsCache[d] = ret
+	return ret
+}
+
+// unifyTypes returns the type that all of the given types can be converted
+// to, and the conversions to it, using the caller's unification function if
+// they gave one.
+func (s *applyState) unifyTypes(types []cty.Type) (cty.Type, []convert.Conversion) {
+	if s.unify != nil {
+		return s.unify(types)
+	}
+	return convert.UnifyUnsafe(types)
+}
+
+// convertValue converts the given value to the given type, using the
+// caller's conversion function if they gave one.
+func (s *applyState) convertValue(val cty.Value, ty cty.Type) (cty.Value, error) {
+	if s.convert != nil {
+		return s.convert(val, ty)
+	}
+	return convert.Convert(val, ty)
+}
+
+// defaultApplied reports that the given default value was placed at the
+// given path, if the caller asked to be told.
+func (s *applyState) defaultApplied(path cty.Path, value cty.Value) {
+	if s.onDefault != nil {
+		s.onDefault(path, value)
+	}
+}
+
+// applyContextCheckInterval is the number of elements that apply visits
+// between checks for whether the context has been cancelled.
+const applyContextCheckInterval = 256
+
+// cancelled returns true if the context for the walk has been cancelled,
+// checking it only once for every applyContextCheckInterval calls.
+func (s *applyState) cancelled() bool {
+	if s.ctx == nil || s.ctxErr != nil {
+		return s.ctxErr != nil
+	}
+	s.steps++
+	if s.steps%applyContextCheckInterval == 0 {
+		s.ctxErr = s.ctx.Err()
+	}
+	return s.ctxErr != nil
+}
+
+// depthDiags returns an error diagnostic if some parts of the value were left
+// unchanged because they are nested too deeply, for the methods that return
+// diagnostics rather than an *ApplyError.
+func (s *applyState) depthDiags() hcl.Diagnostics {
+	if s.depthExceeded == nil {
+		return nil
+	}
+	return hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Value nested too deeply",
+			Detail:   fmt.Sprintf("Default values were not applied at %s: %s.", formatApplyPath(s.depthExceeded.Path), s.depthExceeded.Msg),
+			Extra:    s.depthExceeded,
+		},
+	}
+}
+
+// collapsedSet describes a set whose number of elements was reduced from
+// before to after by applying defaults to its elements.
+type collapsedSet struct {
+	path          cty.Path
+	before, after int
+}
+
+func (d *Defaults) apply(v cty.Value, s *applyState, path cty.Path) cty.Value {
+	if d == nil {
+		return v
+	}
+
+	maxDepth := s.maxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxApplyDepth
+	}
+	if len(path) > maxDepth {
+		if s.depthExceeded == nil {
+			s.depthExceeded = &ApplyError{
+				Path:   path,
+				Source: v.Type(),
+				Target: d.Type,
+				Msg:    fmt.Sprintf("value is nested more deeply than the maximum of %d levels", maxDepth),
+			}
+		}
+		return v
+	}
+
+	// Capsule types are opaque, so there's nothing inside them to apply
+	// defaults to, even if the defaults claim otherwise.
+	if v.Type().IsCapsuleType() || d.Type.IsCapsuleType() {
+		return v
+	}
+
+	// Do nothing if we have no defaults to apply, here or anywhere below.
+	if !d.hasDefaults(s) {
 		return v
 	}
 
-	// Also, do nothing if we have no defaults to apply.
-	if len(d.DefaultValues) == 0 && len(d.Children) == 0 {
+	// We don't apply defaults to null values or unknown values. To be clear,
+	// we will overwrite children values with defaults if they are null but not
+	// if the actual value is null, unless the caller asked us to fill in null
+	// objects too.
+	if !v.IsKnown() {
 		return v
 	}
+	if v.IsNull() {
+		if !s.fillNulls || !d.Type.IsObjectType() {
+			return v
+		}
+		var marks cty.ValueMarks
+		v, marks = v.Unmark()
+		switch {
+		case v.Type().IsObjectType():
+			attrs := make(map[string]cty.Value)
+			for name, aty := range v.Type().AttributeTypes() {
+				attrs[name] = cty.NullVal(aty)
+			}
+			v = cty.ObjectVal(attrs)
+		case v.Type() == cty.DynamicPseudoType:
+			v = cty.EmptyObjectVal
+		default:
+			return v.WithMarks(marks)
+		}
+		v = v.WithMarks(marks)
+	}
 
 	v, marks := v.Unmark()
 
 	switch {
 	case v.Type().IsSetType(), v.Type().IsListType(), v.Type().IsTupleType():
-		values := d.applyAsSlice(v)
+		values := d.applyAsSlice(v, s, path)
 
 		if v.Type().IsSetType() {
 			if len(values) == 0 {
 				v = cty.SetValEmpty(v.Type().ElementType())
 				break
 			}
-			if converts := d.unifyAsSlice(values); len(converts) > 0 {
+			if converts := d.unifyAsSlice(values, s); len(converts) > 0 {
 				v = cty.SetVal(converts).WithMarks(marks)
+				if v.IsWhollyKnown() && v.LengthInt() < len(values) {
+					s.collapsedSets = append(s.collapsedSets, collapsedSet{
+						path:   path,
+						before: len(values),
+						after:  v.LengthInt(),
+					})
+				}
 				break
 			}
 		} else if v.Type().IsListType() {
@@ -78,34 +839,79 @@ func (d *Defaults) apply(v cty.Value) cty.Value {
 				v = cty.ListValEmpty(v.Type().ElementType())
 				break
 			}
-			if converts := d.unifyAsSlice(values); len(converts) > 0 {
+			if converts := d.unifyAsSlice(values, s); len(converts) > 0 {
 				v = cty.ListVal(converts)
 				break
 			}
 		}
 		v = cty.TupleVal(values)
 	case v.Type().IsObjectType(), v.Type().IsMapType():
-		values := d.applyAsMap(v)
+		values := d.applyAsMap(v, s, path)
+
+		defaultValues := d.attrDefaults(s)
+		if s.fillNulls && d.Type.IsObjectType() {
+			// Missing attributes are equivalent to null ones, so we give
+			// them the same treatment as explicit nulls.
+			keys := d.childAttrNames()
+			for _, key := range keys {
+				defaults := d.attrChild(key)
+				if _, ok := values[key]; ok {
+					continue
+				}
+				if _, ok := defaultValues[key]; ok {
+					continue
+				}
+				if _, ok := d.DefaultExprs[key]; ok && s.withExprs {
+					continue
+				}
+				if filled := defaults.apply(cty.NullVal(cty.DynamicPseudoType), s, path.GetAttr(key)); !filled.IsNull() {
+					values[key] = filled
+				}
+			}
+		}
 
-		for key, defaultValue := range d.DefaultValues {
-			if value, ok := values[key]; !ok || value.IsNull() {
-				if defaults, ok := d.Children[key]; ok {
-					values[key] = defaults.apply(defaultValue)
+		// We substitute defaults in a predictable order so that OnDefault
+		// callbacks and diagnostics are the same each time.
+		keys := make([]string, 0, len(defaultValues))
+		for key := range defaultValues {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			defaultValue := defaultValues[key]
+			if _, ok := d.DefaultExprs[key]; ok && s.withExprs {
+				continue
+			}
+			if value, ok := values[key]; !ok || value.IsNull() || (s.treatEmptyAsMissing && isEmptyValue(value)) {
+				// Any marks on the value being replaced apply to its
+				// replacement too, along with the default's own marks.
+				_, valueMarks := value.Unmark()
+				if defaults := d.attrChild(key); defaults != nil {
+					values[key] = defaults.apply(defaultValue, s, path.GetAttr(key)).WithMarks(valueMarks)
+					s.defaultApplied(path.GetAttr(key), values[key])
 					continue
 				}
-				values[key] = defaultValue
+				values[key] = defaultValue.WithMarks(valueMarks)
+				s.defaultApplied(path.GetAttr(key), values[key])
 			}
-			if defaultRng := defaultValue.Range(); defaultRng.DefinitelyNotNull() && values[key].Type() != cty.DynamicPseudoType {
+			// Range doesn't accept marked values, but marks have no bearing
+			// on whether the default is null.
+			unmarkedDefault, _ := defaultValue.Unmark()
+			if defaultRng := unmarkedDefault.Range(); defaultRng.DefinitelyNotNull() && values[key].Type() != cty.DynamicPseudoType {
 				values[key] = values[key].RefineNotNull()
 			}
 		}
 
+		if s.withExprs && len(d.DefaultExprs) > 0 {
+			d.applyExprs(values, s, path)
+		}
+
 		if v.Type().IsMapType() {
 			if len(values) == 0 {
 				v = cty.MapValEmpty(v.Type().ElementType())
 				break
 			}
-			if converts := d.unifyAsMap(values); len(converts) > 0 {
+			if converts := d.unifyAsMap(values, s); len(converts) > 0 {
 				v = cty.MapVal(converts)
 				break
 			}
@@ -116,72 +922,261 @@ func (d *Defaults) apply(v cty.Value) cty.Value {
 	return v.WithMarks(marks)
 }
 
-func (d *Defaults) applyAsSlice(value cty.Value) []cty.Value {
-	var elements []cty.Value
-	for ix, element := range value.AsValueSlice() {
-		if childDefaults := d.getChild(ix); childDefaults != nil {
-			element = childDefaults.apply(element)
-			elements = append(elements, element)
+// attrDefaults returns the default values for the attributes of the
+// receiver's object type, indexed by attribute name, with the wildcard
+// default in place for each attribute that has no default of its own. The
+// result must not be modified, because it's the receiver's own DefaultValues
+// if there is no wildcard. If the state is for ApplyWithContext then the
+// wildcard doesn't apply to attributes with a default expression.
+func (d *Defaults) attrDefaults(s *applyState) map[string]cty.Value {
+	wildcard, ok := d.wildcardDefault()
+	if !ok {
+		return d.DefaultValues
+	}
+	ret := make(map[string]cty.Value, len(d.Type.AttributeTypes()))
+	for name, val := range d.DefaultValues {
+		if name != WildcardAttribute {
+			ret[name] = val
+		}
+	}
+	for name := range d.Type.AttributeTypes() {
+		if _, ok := ret[name]; ok {
+			continue
+		}
+		if _, ok := d.DefaultExprs[name]; ok && s.withExprs {
 			continue
 		}
-		elements = append(elements, element)
+		ret[name] = wildcard
 	}
-	return elements
+	return ret
 }
 
-func (d *Defaults) applyAsMap(value cty.Value) map[string]cty.Value {
-	elements := make(map[string]cty.Value)
-	for key, element := range value.AsValueMap() {
-		if childDefaults := d.getChild(key); childDefaults != nil {
-			elements[key] = childDefaults.apply(element)
-			continue
+// attrDefault returns the default value for the attribute of the receiver's
+// object type with the given name, which may be the wildcard default, or
+// false if there is none. Default expressions are not considered.
+func (d *Defaults) attrDefault(name string) (cty.Value, bool) {
+	if !d.Type.IsObjectType() {
+		val, ok := d.DefaultValues[name]
+		return val, ok
+	}
+	if val, ok := d.DefaultValues[name]; ok {
+		if name != WildcardAttribute || d.Type.HasAttribute(name) {
+			return val, true
 		}
-		elements[key] = element
+		return cty.NilVal, false
 	}
-	return elements
+	if !d.Type.HasAttribute(name) {
+		return cty.NilVal, false
+	}
+	return d.wildcardDefault()
 }
 
-func (d *Defaults) getChild(key interface{}) *Defaults {
-	// Children for tuples are keyed by an int.
-	// Children for objects are keyed by a string.
-	// Children for maps, lists, and sets are always keyed by the empty string.
-	//
-	// For maps and objects the supplied key type is a string type.
-	// For lists, sets, and tuples the supplied key type is an int type.
-	//
-	// The callers of the defaults package could, in theory, pass a value in
-	// where the types expected based on the defaults do not match the actual
-	// type in the value. In this case, we get a mismatch between what the
-	// defaults package expects the key to be, and which type it actually is.
-	//
-	// In the event of such a mismatch, we just won't apply defaults. Instead,
-	// relying on the user later calling go-cty.Convert to detect this same
-	// error (as  documented). In this case we'd just want to return nil to
-	// indicate either there are no defaults or we can't work out how to apply
-	// them. Both of  these outcomes are treated the same by the rest of the
-	// package.
-	//
-	// For the above reasons it isn't necessarily safe to just rely on a single
-	// metric for working out how we should retrieve the key. If the defaults
-	// type is a tuple we can't just assume the supplied key will be an int (as
-	// the concrete value actually supplied by the user could be an object or a
-	// map). Similarly, if the supplied key is an int we can't just assume we
-	// should treat the type as a tuple (as a list would also specify an int,
-	// but we should return the children keyed by the empty string rather than
-	// the index).
+// wildcardDefault returns the wildcard default value of the receiver, or
+// false if it doesn't have one.
+func (d *Defaults) wildcardDefault() (cty.Value, bool) {
+	if !d.Type.IsObjectType() || d.Type.HasAttribute(WildcardAttribute) {
+		return cty.NilVal, false
+	}
+	val, ok := d.DefaultValues[WildcardAttribute]
+	return val, ok
+}
 
-	switch concrete := key.(type) {
+// attrChild returns the child defaults for the attribute of the receiver's
+// object type with the given name, which may be the wildcard child, or nil
+// if there are none.
+func (d *Defaults) attrChild(name string) *Defaults {
+	if !d.Type.IsObjectType() {
+		return d.Children[name]
+	}
+	if child, ok := d.Children[name]; ok {
+		if name != WildcardAttribute || d.Type.HasAttribute(name) {
+			return child
+		}
+		return nil
+	}
+	if d.Type.HasAttribute(WildcardAttribute) || !d.Type.HasAttribute(name) {
+		return nil
+	}
+	child := d.Children[WildcardAttribute]
+	if child == nil || !child.Type.Equals(d.Type.AttributeType(name)) {
+		return nil
+	}
+	return child
+}
+
+// childAttrNames returns the names of the attributes of the receiver's
+// object type that have child defaults, including through the wildcard
+// child, along with any other keys of Children except the wildcard, in
+// lexical order.
+func (d *Defaults) childAttrNames() []string {
+	wildcard := d.Children[WildcardAttribute] != nil && !d.Type.HasAttribute(WildcardAttribute)
+	names := make([]string, 0, len(d.Children))
+	for key := range d.Children {
+		if key != WildcardAttribute || !wildcard {
+			names = append(names, key)
+		}
+	}
+	if wildcard {
+		for name := range d.Type.AttributeTypes() {
+			if _, ok := d.Children[name]; !ok && d.attrChild(name) != nil {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isEmptyValue returns true if the given value is a known empty string, or
+// a known collection, tuple, or object with no elements.
+func isEmptyValue(v cty.Value) bool {
+	v, _ = v.Unmark()
+	if !v.IsKnown() || v.IsNull() {
+		return false
+	}
+	ty := v.Type()
+	switch {
+	case ty == cty.String:
+		return v.AsString() == ""
+	case ty.IsCollectionType(), ty.IsTupleType(), ty.IsObjectType():
+		return v.LengthInt() == 0
+	default:
+		return false
+	}
+}
+
+// applyExprs evaluates the expressions in DefaultExprs for any attributes
+// that are missing or null in the given attribute values, which are updated
+// in place.
+func (d *Defaults) applyExprs(values map[string]cty.Value, s *applyState, path cty.Path) {
+	ctx := s.evalCtx.NewChild()
+	ctx.Variables = make(map[string]cty.Value, len(values))
+	for key, value := range values {
+		if !value.IsNull() {
+			ctx.Variables[key] = value
+		}
+	}
+
+	keys := make([]string, 0, len(d.DefaultExprs))
+	for key := range d.DefaultExprs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value, ok := values[key]; ok && !value.IsNull() {
+			continue
+		}
+		defaultValue, diags := d.DefaultExprs[key].Value(ctx)
+		s.diags = append(s.diags, diags...)
+		if diags.HasErrors() {
+			continue
+		}
+		if defaults := d.attrChild(key); defaults != nil {
+			defaultValue = defaults.apply(defaultValue, s, path.GetAttr(key))
+		}
+		values[key] = defaultValue
+	}
+}
+
+func (d *Defaults) applyAsSlice(value cty.Value, s *applyState, path cty.Path) []cty.Value {
+	if d.Type.IsTupleType() && value.LengthInt() != len(d.Type.TupleElementTypes()) {
+		// The children of a tuple are matched to elements by position, which
+		// is meaningless when the value has a different number of elements
+		// than the type. We leave the value untouched so that the later
+		// conversion reports the mismatch, rather than applying defaults
+		// intended for some other element.
+		return value.AsValueSlice()
+	}
+
+	// AsValueSlice returns a new slice, so we can update it in place.
+	elements := value.AsValueSlice()
+	for ix, element := range elements {
+		if childDefaults := d.getChild(ix); childDefaults != nil && !s.cancelled() {
+			elements[ix] = childDefaults.apply(element, s, path.Index(cty.NumberIntVal(int64(ix))))
+		}
+	}
+	return elements
+}
+
+func (d *Defaults) applyAsMap(value cty.Value, s *applyState, path cty.Path) map[string]cty.Value {
+	// AsValueMap returns a new map, so we can update it in place, but it
+	// returns nil if there are no elements and the caller may add some.
+	elements := value.AsValueMap()
+	if elements == nil {
+		elements = make(map[string]cty.Value)
+	}
+	keys := make([]string, 0, len(elements))
+	for key := range elements {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if childDefaults := d.getChild(key); childDefaults != nil && !s.cancelled() {
+			elements[key] = childDefaults.apply(elements[key], s, applyPathStep(value.Type(), path, key))
+		}
+	}
+	return elements
+}
+
+// applyPathStep returns the path to the element with the given key of a
+// value of the given object or map type at the given path.
+func applyPathStep(ty cty.Type, path cty.Path, key string) cty.Path {
+	if ty.IsMapType() {
+		return path.Index(cty.StringVal(key))
+	}
+	return path.GetAttr(key)
+}
+
+func (d *Defaults) getChild(key interface{}) *Defaults {
+	if d == nil {
+		return nil
+	}
+
+	// Children for tuples are keyed by an int.
+	// Children for objects are keyed by a string.
+	// Children for maps, lists, and sets are always keyed by the empty string.
+	//
+	// For maps and objects the supplied key type is a string type.
+	// For lists, sets, and tuples the supplied key type is an int type.
+	//
+	// The callers of the defaults package could, in theory, pass a value in
+	// where the types expected based on the defaults do not match the actual
+	// type in the value. In this case, we get a mismatch between what the
+	// defaults package expects the key to be, and which type it actually is.
+	//
+	// In the event of such a mismatch, we just won't apply defaults. Instead,
+	// relying on the user later calling go-cty.Convert to detect this same
+	// error (as  documented). In this case we'd just want to return nil to
+	// indicate either there are no defaults or we can't work out how to apply
+	// them. Both of  these outcomes are treated the same by the rest of the
+	// package.
+	//
+	// For the above reasons it isn't necessarily safe to just rely on a single
+	// metric for working out how we should retrieve the key. If the defaults
+	// type is a tuple we can't just assume the supplied key will be an int (as
+	// the concrete value actually supplied by the user could be an object or a
+	// map). Similarly, if the supplied key is an int we can't just assume we
+	// should treat the type as a tuple (as a list would also specify an int,
+	// but we should return the children keyed by the empty string rather than
+	// the index).
+
+	switch concrete := key.(type) {
 	case int:
 		if d.Type.IsTupleType() {
 			// If the type is an int, and our defaults are expecting a tuple
-			// then we return the children for the tuple at the index.
+			// then we return the children for the tuple at the index, as long
+			// as the tuple type actually has an element at that index.
+			if concrete < 0 || concrete >= len(d.Type.TupleElementTypes()) {
+				return nil
+			}
 			return d.Children[strconv.Itoa(concrete)]
 		}
 	case string:
 		if d.Type.IsObjectType() {
 			// If the type is a string, and our defaults are expecting an object
-			// then we return the children for the object at the key.
-			return d.Children[concrete]
+			// then we return the children for the object at the key, or the
+			// wildcard children if there are none for that key.
+			return d.attrChild(concrete)
 		}
 	}
 
@@ -196,12 +1191,18 @@ func (d *Defaults) getChild(key interface{}) *Defaults {
 	return d.Children[""]
 }
 
-func (d *Defaults) unifyAsSlice(values []cty.Value) []cty.Value {
+func (d *Defaults) unifyAsSlice(values []cty.Value, s *applyState) []cty.Value {
+	// Unification is quadratic in the number of values for object types,
+	// so we skip it in the common case where no conversion is needed.
+	if len(values) > 0 && sameTypes(values) {
+		return values
+	}
+
 	var types []cty.Type
 	for _, value := range values {
 		types = append(types, value.Type())
 	}
-	unify, conversions := convert.UnifyUnsafe(types)
+	unify, conversions := s.unifyTypes(types)
 	if unify == cty.NilType {
 		return nil
 	}
@@ -222,7 +1223,33 @@ func (d *Defaults) unifyAsSlice(values []cty.Value) []cty.Value {
 	return converts
 }
 
-func (d *Defaults) unifyAsMap(values map[string]cty.Value) map[string]cty.Value {
+// sameTypes returns true if all of the given values, of which there must be
+// at least one, have exactly the same type.
+func sameTypes(values []cty.Value) bool {
+	for _, value := range values[1:] {
+		if !value.Type().Equals(values[0].Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Defaults) unifyAsMap(values map[string]cty.Value, s *applyState) map[string]cty.Value {
+	// As for unifyAsSlice, we skip unification if it would do nothing.
+	first := cty.NilType
+	same := true
+	for _, value := range values {
+		if first == cty.NilType {
+			first = value.Type()
+		} else if !value.Type().Equals(first) {
+			same = false
+			break
+		}
+	}
+	if same && first != cty.NilType {
+		return values
+	}
+
 	var keys []string
 	for key := range values {
 		keys = append(keys, key)
@@ -233,7 +1260,7 @@ func (d *Defaults) unifyAsMap(values map[string]cty.Value) map[string]cty.Value
 	for _, key := range keys {
 		types = append(types, values[key].Type())
 	}
-	unify, conversions := convert.UnifyUnsafe(types)
+	unify, conversions := s.unifyTypes(types)
 	if unify == cty.NilType {
 		return nil
 	}
@@ -252,3 +1279,933 @@ func (d *Defaults) unifyAsMap(values map[string]cty.Value) map[string]cty.Value
 	}
 	return converts
 }
+
+// Equal returns true if the receiver and the given defaults describe the
+// same type and the same default values at every level of the tree.
+//
+// Types are compared using cty.Type.Equals and default values are compared
+// using cty.Value.RawEquals, so marks and refinements are significant.
+// Default expressions are equal only if they are the same expression. A
+// nil DefaultValues or Children map is considered equal to an empty one,
+// because both represent the absence of anything at that level. Two nil
+// Defaults are equal, but a nil Defaults is never equal to a non-nil one.
+func (d *Defaults) Equal(other *Defaults) bool {
+	if d == nil || other == nil {
+		return d == other
+	}
+
+	if !d.Type.Equals(other.Type) {
+		return false
+	}
+
+	if len(d.DefaultValues) != len(other.DefaultValues) {
+		return false
+	}
+	for key, value := range d.DefaultValues {
+		otherValue, ok := other.DefaultValues[key]
+		if !ok || !value.RawEquals(otherValue) {
+			return false
+		}
+	}
+
+	if len(d.DefaultExprs) != len(other.DefaultExprs) {
+		return false
+	}
+	for key, expr := range d.DefaultExprs {
+		otherExpr, ok := other.DefaultExprs[key]
+		if !ok || !sameExpression(expr, otherExpr) {
+			return false
+		}
+	}
+
+	if len(d.Children) != len(other.Children) {
+		return false
+	}
+	for key, child := range d.Children {
+		otherChild, ok := other.Children[key]
+		if !ok || !child.Equal(otherChild) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameExpression returns true if the given expressions are the same
+// expression, without panicking for expressions that can't be compared
+// using ==. Those are considered the same only if they are the same pointer,
+// so two distinct expressions of such a type are never the same.
+func sameExpression(a, b hcl.Expression) bool {
+	ty := reflect.TypeOf(a)
+	if ty != reflect.TypeOf(b) {
+		return false
+	}
+	if ty == nil {
+		return true
+	}
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if ty.Kind() == reflect.Ptr {
+		return av.Pointer() == bv.Pointer()
+	}
+	// A type can be comparable and yet panic when compared, if it holds a
+	// non-comparable value in an interface, as for the cty.Value inside
+	// hcl.StaticExpr, so we must check the dynamic values too.
+	if !ty.Comparable() || !comparableValue(av) || !comparableValue(bv) {
+		return false
+	}
+	return a == b
+}
+
+// comparableValue returns true if comparing the given value using == would
+// not panic.
+func comparableValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return true
+		}
+		return v.Elem().Type().Comparable() && comparableValue(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !comparableValue(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !comparableValue(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return v.Type().Comparable()
+	}
+}
+
+// Merge returns a new Defaults tree combining the receiver with the given
+// other tree, such as when composing a type constraint from multiple
+// fragments. Default values and default expressions from other override or
+// add to those of the receiver, by attribute name, and children with the same
+// key are merged recursively.
+//
+// Both trees must have the same type at every node they have in common,
+// or Merge returns an error describing the path to the first node where
+// they differ, in the same form as used by Validate. Neither tree is
+// modified, and the result shares no nodes or maps with either of them. If
+// either tree is nil the result is a copy of the other.
+func (d *Defaults) Merge(other *Defaults) (*Defaults, error) {
+	return d.merge(other, "root")
+}
+
+func (d *Defaults) merge(other *Defaults, path string) (*Defaults, error) {
+	if d == nil {
+		return other.Clone(), nil
+	}
+	if other == nil {
+		return d.Clone(), nil
+	}
+
+	if !d.Type.Equals(other.Type) {
+		return nil, fmt.Errorf("cannot merge defaults for %s: type %s does not match %s", path, TypeString(other.Type), TypeString(d.Type))
+	}
+
+	ret := d.Clone()
+	for name, value := range other.DefaultValues {
+		if ret.DefaultValues == nil {
+			ret.DefaultValues = make(map[string]cty.Value)
+		}
+		ret.DefaultValues[name] = value
+	}
+	for name, expr := range other.DefaultExprs {
+		if ret.DefaultExprs == nil {
+			ret.DefaultExprs = make(map[string]hcl.Expression)
+		}
+		ret.DefaultExprs[name] = expr
+	}
+
+	var keys []string
+	for key := range other.Children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		child, err := d.Children[key].merge(other.Children[key], path+defaultsPathStep(d.Type, key))
+		if err != nil {
+			return nil, err
+		}
+		if ret.Children == nil {
+			ret.Children = make(map[string]*Defaults)
+		}
+		ret.Children[key] = child
+	}
+
+	return ret, nil
+}
+
+// Clone returns a deep copy of the receiver, which can be modified without
+// affecting the original, such as to layer overrides into a cached tree.
+//
+// The DefaultValues, DefaultExprs, and Children maps are copied at every
+// level of the tree. The default values and expressions themselves are
+// shared, because cty values are immutable and expressions are not modified
+// by evaluation. The result is nil if the receiver is nil.
+func (d *Defaults) Clone() *Defaults {
+	if d == nil {
+		return nil
+	}
+
+	ret := &Defaults{
+		Type: d.Type,
+	}
+	if d.DefaultValues != nil {
+		ret.DefaultValues = make(map[string]cty.Value, len(d.DefaultValues))
+		for name, value := range d.DefaultValues {
+			ret.DefaultValues[name] = value
+		}
+	}
+	if d.DefaultExprs != nil {
+		ret.DefaultExprs = make(map[string]hcl.Expression, len(d.DefaultExprs))
+		for name, expr := range d.DefaultExprs {
+			ret.DefaultExprs[name] = expr
+		}
+	}
+	if d.Children != nil {
+		ret.Children = make(map[string]*Defaults, len(d.Children))
+		for key, child := range d.Children {
+			ret.Children[key] = child.Clone()
+		}
+	}
+	return ret
+}
+
+// Prune returns a copy of the receiver without any of the subtrees of
+// Children that have no effect when applied, because neither they nor any of
+// their descendents have any DefaultValues or DefaultExprs. This is useful
+// after merging several trees, which can leave behind such empty subtrees.
+//
+// The result gives the same results as the receiver for Apply and its
+// variants, with the exception that ApplyFillingNulls no longer replaces a
+// null object with an object of nulls when there were no defaults anywhere
+// inside it. If the receiver has no defaults at all then the result has only
+// its Type. As with Clone, the maps of the result are all copies. The result
+// is nil if the receiver is nil.
+func (d *Defaults) Prune() *Defaults {
+	if d == nil {
+		return nil
+	}
+	return d.prune(&applyState{withExprs: true})
+}
+
+func (d *Defaults) prune(s *applyState) *Defaults {
+	ret := &Defaults{
+		Type: d.Type,
+	}
+	if d.DefaultValues != nil {
+		ret.DefaultValues = make(map[string]cty.Value, len(d.DefaultValues))
+		for name, value := range d.DefaultValues {
+			ret.DefaultValues[name] = value
+		}
+	}
+	if d.DefaultExprs != nil {
+		ret.DefaultExprs = make(map[string]hcl.Expression, len(d.DefaultExprs))
+		for name, expr := range d.DefaultExprs {
+			ret.DefaultExprs[name] = expr
+		}
+	}
+	for key, child := range d.Children {
+		if child == nil || !child.hasDefaults(s) {
+			continue
+		}
+		if ret.Children == nil {
+			ret.Children = make(map[string]*Defaults)
+		}
+		ret.Children[key] = child.prune(s)
+	}
+	return ret
+}
+
+// Walk performs a pre-order traversal of the receiver and its descendents,
+// calling the given function for each node along with the path to the
+// corresponding part of a value from the root of the tree. This allows
+// inspecting a tree, such as to generate documentation or to find sensitive
+// defaults, without needing to interpret the keys of Children.
+//
+// The path to a child for an object attribute ends with a cty.GetAttrStep,
+// and the path to a child for a tuple element ends with a cty.IndexStep whose
+// key is the element index. The single child for the elements of a
+// collection applies to all of its elements, so its path ends with a
+// cty.IndexStep whose key is an unknown value of the collection's key type:
+// a number for a list, a string for a map, or the element type for a set.
+// Children are visited in lexical order of their keys.
+//
+// If the function returns an error then the walk stops immediately and Walk
+// returns that error. Walk does nothing if the receiver is nil.
+func (d *Defaults) Walk(fn func(path cty.Path, d *Defaults) error) error {
+	return d.walk(nil, fn)
+}
+
+func (d *Defaults) walk(path cty.Path, fn func(path cty.Path, d *Defaults) error) error {
+	if d == nil {
+		return nil
+	}
+	if err := fn(path, d); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(d.Children))
+	for key := range d.Children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := d.Children[key].walk(defaultsChildPath(d.Type, path, key), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultsChildPath returns the path, as reported by Walk, to the child with
+// the given key of a Defaults of the given type at the given path.
+func defaultsChildPath(ty cty.Type, path cty.Path, key string) cty.Path {
+	switch {
+	case ty.IsTupleType():
+		if ix, err := strconv.Atoi(key); err == nil {
+			return path.Index(cty.NumberIntVal(int64(ix)))
+		}
+	case ty.IsListType():
+		return path.Index(cty.UnknownVal(cty.Number))
+	case ty.IsMapType():
+		return path.Index(cty.UnknownVal(cty.String))
+	case ty.IsSetType():
+		return path.Index(cty.UnknownVal(ty.ElementType()))
+	}
+	return path.GetAttr(key)
+}
+
+// DefaultForPath returns the default value for the attribute at the given
+// path within a value that the receiver applies to, or false if there is no
+// default value in DefaultValues for that attribute, including through the
+// wildcard key. Default expressions in DefaultExprs are not considered.
+//
+// Each step of the path but the last selects a child of the receiver. A
+// cty.GetAttrStep selects an object attribute, and a cty.IndexStep selects
+// a tuple element by its index or the child for all elements of a
+// collection regardless of the key, which may be unknown. The last step must
+// select an object attribute. The paths passed to the function given to
+// Walk are therefore accepted, after adding a step for an attribute.
+func (d *Defaults) DefaultForPath(path cty.Path) (cty.Value, bool) {
+	if d == nil || len(path) == 0 {
+		return cty.NilVal, false
+	}
+
+	for _, step := range path[:len(path)-1] {
+		key, ok := defaultsPathKey(d.Type, step)
+		if !ok {
+			return cty.NilVal, false
+		}
+		d = d.attrChild(key)
+		if d == nil {
+			return cty.NilVal, false
+		}
+	}
+
+	name, ok := defaultsPathKey(d.Type, path[len(path)-1])
+	if !ok || !d.Type.IsObjectType() {
+		return cty.NilVal, false
+	}
+	return d.attrDefault(name)
+}
+
+// defaultsPathKey returns the key in Children or DefaultValues selected by
+// the given path step from a Defaults of the given type, or false if the
+// step doesn't select anything.
+func defaultsPathKey(ty cty.Type, step cty.PathStep) (string, bool) {
+	switch step := step.(type) {
+	case cty.GetAttrStep:
+		if ty.IsObjectType() {
+			return step.Name, true
+		}
+	case cty.IndexStep:
+		key, _ := step.Key.Unmark()
+		switch {
+		case ty.IsCollectionType():
+			return "", true
+		case !key.IsKnown() || key.IsNull():
+			return "", false
+		case ty.IsObjectType() && key.Type() == cty.String:
+			return key.AsString(), true
+		case ty.IsTupleType() && key.Type() == cty.Number:
+			ix, accuracy := key.AsBigFloat().Int64()
+			if accuracy != big.Exact || ix < 0 || int(ix) >= len(ty.TupleElementTypes()) {
+				return "", false
+			}
+			return strconv.FormatInt(ix, 10), true
+		}
+	}
+	return "", false
+}
+
+// DefaultObject returns the value that results from applying the receiver
+// to an empty object and converting the result to the receiver's type, for
+// showing the effective defaults of a type in documentation or previews.
+//
+// Each attribute with a default value has that value, and each attribute of
+// an object type that has defaults of its own is itself an object built in
+// the same way, as ApplyFillingNulls would produce. All other attributes are
+// null, including required attributes. If a default value can't be
+// converted to the type of its attribute then the result is not converted,
+// but still has the default values in place.
+//
+// If the receiver's type is not an object type then the result is a null
+// value of that type, since there is nowhere to place any defaults. The
+// result is cty.NilVal if the receiver is nil.
+func (d *Defaults) DefaultObject() cty.Value {
+	if d == nil {
+		return cty.NilVal
+	}
+	if !d.Type.IsObjectType() {
+		return cty.NullVal(d.Type.WithoutOptionalAttributesDeep())
+	}
+
+	val := d.defaultObject(&applyState{}, nil)
+	if ret, err := convert.Convert(val, d.Type); err == nil {
+		return ret
+	}
+	return val
+}
+
+func (d *Defaults) defaultObject(s *applyState, path cty.Path) cty.Value {
+	attrs := make(map[string]cty.Value, len(d.Type.AttributeTypes()))
+	for name, aty := range d.Type.AttributeTypes() {
+		child := d.attrChild(name)
+		if val, ok := d.attrDefault(name); ok {
+			attrs[name] = child.apply(val, s, path.GetAttr(name))
+			continue
+		}
+		if child != nil && child.Type.IsObjectType() && child.hasDefaults(s) {
+			attrs[name] = child.defaultObject(s, path.GetAttr(name))
+			continue
+		}
+		attrs[name] = cty.NullVal(aty.WithoutOptionalAttributesDeep())
+	}
+	return cty.ObjectVal(attrs)
+}
+
+// Unset is the inverse of Apply, returning a copy of the given value in which
+// each attribute whose value equals its default, according to
+// cty.Value.RawEquals, is replaced by a null value of the same type. This
+// allows showing only the settings that differ from the defaults.
+//
+// A value equals its default if they are equal after applying any nested
+// defaults to the default and converting one to the type of the other, so
+// that Unset recognizes the defaults placed by both Apply and
+// ApplyAndConvert. Marks are ignored when comparing, and an attribute that
+// is unset keeps the marks of the value it replaces. Attributes that don't
+// equal their defaults are unset recursively, using the same children as
+// Apply, and attributes whose defaults come from DefaultExprs are left
+// unchanged.
+func (d *Defaults) Unset(val cty.Value) cty.Value {
+	return d.unset(val, &applyState{})
+}
+
+func (d *Defaults) unset(v cty.Value, s *applyState) cty.Value {
+	if d == nil || !d.hasDefaults(s) {
+		return v
+	}
+	if !v.IsKnown() || v.IsNull() || v.Type().IsCapsuleType() || d.Type.IsCapsuleType() {
+		return v
+	}
+
+	v, marks := v.Unmark()
+	ty := v.Type()
+	switch {
+	case ty.IsListType(), ty.IsSetType(), ty.IsTupleType():
+		if d.Type.IsTupleType() && v.LengthInt() != len(d.Type.TupleElementTypes()) {
+			break
+		}
+		elements := v.AsValueSlice()
+		if len(elements) == 0 {
+			break
+		}
+		for ix, element := range elements {
+			elements[ix] = d.getChild(ix).unset(element, s)
+		}
+		switch {
+		case ty.IsListType():
+			v = cty.ListVal(elements)
+		case ty.IsSetType():
+			v = cty.SetVal(elements)
+		default:
+			v = cty.TupleVal(elements)
+		}
+	case ty.IsObjectType(), ty.IsMapType():
+		elements := v.AsValueMap()
+		if len(elements) == 0 {
+			break
+		}
+		for key, element := range elements {
+			if d.Type.IsObjectType() && d.isDefaultValue(key, element, s) {
+				_, elementMarks := element.Unmark()
+				elements[key] = cty.NullVal(element.Type()).WithMarks(elementMarks)
+				continue
+			}
+			elements[key] = d.getChild(key).unset(element, s)
+		}
+		if ty.IsMapType() {
+			v = cty.MapVal(elements)
+		} else {
+			v = cty.ObjectVal(elements)
+		}
+	}
+	return v.WithMarks(marks)
+}
+
+// isDefaultValue returns true if the given value of the attribute with the
+// given name is the same as the default Apply would place there.
+func (d *Defaults) isDefaultValue(name string, val cty.Value, s *applyState) bool {
+	defaultValue, ok := d.attrDefault(name)
+	if !ok {
+		return false
+	}
+	val, _ = val.UnmarkDeep()
+	if !val.IsWhollyKnown() || val.IsNull() {
+		return false
+	}
+
+	if child := d.attrChild(name); child != nil {
+		defaultValue = child.apply(defaultValue, s, nil)
+	}
+	defaultValue, _ = defaultValue.UnmarkDeep()
+	if !defaultValue.Type().Equals(val.Type()) {
+		// The default may have been converted to the attribute's type, or
+		// the value may not have been, so we try converting either way.
+		if converted, err := convert.Convert(defaultValue, val.Type()); err == nil {
+			defaultValue = converted
+		} else if converted, err := convert.Convert(val, defaultValue.Type()); err == nil {
+			val = converted
+		} else {
+			return false
+		}
+	}
+	return val.RawEquals(defaultValue)
+}
+
+// Validate checks that the receiver is well-formed, so that problems in a
+// hand-built Defaults tree can be detected before it is applied to any
+// values.
+//
+// Each default value must be convertible to the type of its attribute, and
+// each key of DefaultValues and Children must correspond to an attribute or
+// element of the receiver's type, with the child having the type of that
+// attribute or element. A wildcard default value must be convertible to the
+// type of every attribute without a default of its own, and a wildcard child
+// must apply to at least one attribute.
+//
+// The tree must also not contain any cycles, where a Defaults is one of its
+// own descendents, because the methods that walk the tree would never
+// finish. A Defaults may appear more than once in the tree as long as it
+// isn't within itself.
+//
+// Diagnostics have no source location, so each one instead describes the
+// location of the problem in the tree as a path starting at "root".
+func (d *Defaults) Validate() hcl.Diagnostics {
+	return d.validate("root", make(map[*Defaults]string))
+}
+
+// validate checks the receiver, which is at the given path, given the paths
+// of each of its ancestors.
+func (d *Defaults) validate(path string, ancestors map[*Defaults]string) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	if d == nil {
+		return diags
+	}
+	ancestors[d] = path
+	defer delete(ancestors, d)
+
+	ty := d.Type
+	if len(d.DefaultValues) > 0 && !ty.IsObjectType() {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid default values",
+			Detail:   fmt.Sprintf("The defaults for %s have default values, but its type %s has no attributes.", path, TypeString(ty)),
+		})
+	}
+
+	var names []string
+	for name := range d.DefaultValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !ty.IsObjectType() {
+			break
+		}
+		attrPath := path + defaultsPathStep(ty, name)
+		if _, ok := d.wildcardDefault(); ok && name == WildcardAttribute {
+			diags = append(diags, d.validateWildcardDefault(attrPath)...)
+			continue
+		}
+		if !ty.HasAttribute(name) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid default value for optional attribute",
+				Detail:   fmt.Sprintf("There is a default value for %s, but %s has no attribute %q.", attrPath, path, name),
+			})
+			continue
+		}
+		if _, err := convert.Convert(d.DefaultValues[name], ty.AttributeType(name)); err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid default value for optional attribute",
+				Detail:   fmt.Sprintf("The default value for %s is not compatible with the attribute's type constraint: %s.", attrPath, err),
+			})
+		}
+	}
+
+	var exprNames []string
+	for name := range d.DefaultExprs {
+		exprNames = append(exprNames, name)
+	}
+	sort.Strings(exprNames)
+	for _, name := range exprNames {
+		if !ty.IsObjectType() || !ty.HasAttribute(name) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid default expression for optional attribute",
+				Detail:   fmt.Sprintf("There is a default expression for %s, but %s has no attribute %q.", path+defaultsPathStep(ty, name), path, name),
+			})
+		}
+	}
+
+	var keys []string
+	for key := range d.Children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		childPath := path + defaultsPathStep(ty, key)
+		slotTy, ok := defaultsChildType(ty, key)
+		if !ok && ty.IsObjectType() && key == WildcardAttribute && d.Children[key] != nil {
+			var matched bool
+			slotTy, matched = d.wildcardChildType()
+			if !matched {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid child defaults",
+					Detail:   fmt.Sprintf("The wildcard child defaults for %s have type %s, but no attribute of %s without child defaults of its own has that type.", childPath, TypeString(d.Children[key].Type), path),
+				})
+				continue
+			}
+			ok = true
+		}
+		if !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid child defaults",
+				Detail:   fmt.Sprintf("There are child defaults for %s, but it is not an attribute or element of %s. %s", childPath, path, defaultsChildKeyRule(ty, key, path)),
+			})
+			continue
+		}
+		child := d.Children[key]
+		if child == nil {
+			continue
+		}
+		if ancestorPath, ok := ancestors[child]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Cyclic child defaults",
+				Detail:   fmt.Sprintf("The child defaults for %s are the same as the defaults for %s, which contain them, so the tree of defaults has no end.", childPath, ancestorPath),
+			})
+			continue
+		}
+		if !child.Type.Equals(slotTy) {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid child defaults",
+				Detail:   fmt.Sprintf("The child defaults for %s have type %s, but it has type %s.", childPath, TypeString(child.Type), TypeString(slotTy)),
+			})
+			continue
+		}
+		diags = append(diags, child.validate(childPath, ancestors)...)
+	}
+
+	return diags
+}
+
+// validateWildcardDefault checks that the receiver's wildcard default value,
+// at the given path, is convertible to the type of each attribute it applies
+// to.
+func (d *Defaults) validateWildcardDefault(path string) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	wildcard, _ := d.wildcardDefault()
+
+	var names []string
+	for name := range d.Type.AttributeTypes() {
+		if _, ok := d.DefaultValues[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := convert.Convert(wildcard, d.Type.AttributeType(name)); err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid default value for optional attribute",
+				Detail:   fmt.Sprintf("The wildcard default value at %s is not compatible with the type constraint of attribute %q, which has no default of its own: %s.", path, name, err),
+			})
+		}
+	}
+	return diags
+}
+
+// wildcardChildType returns the type of the receiver's wildcard child, or
+// false if it doesn't apply to any attribute.
+func (d *Defaults) wildcardChildType() (cty.Type, bool) {
+	child := d.Children[WildcardAttribute]
+	for name := range d.Type.AttributeTypes() {
+		if _, ok := d.Children[name]; !ok && d.attrChild(name) == child {
+			return child.Type, true
+		}
+	}
+	return cty.NilType, false
+}
+
+// defaultsChildType returns the type of the attribute or element that the
+// given Children key of a Defaults of the given type refers to, or false if
+// the key doesn't refer to anything.
+func defaultsChildType(ty cty.Type, key string) (cty.Type, bool) {
+	switch {
+	case ty.IsObjectType():
+		if !ty.HasAttribute(key) {
+			return cty.NilType, false
+		}
+		return ty.AttributeType(key), true
+	case ty.IsTupleType():
+		ix, err := strconv.Atoi(key)
+		if err != nil || ix < 0 || ix >= len(ty.TupleElementTypes()) || strconv.Itoa(ix) != key {
+			return cty.NilType, false
+		}
+		return ty.TupleElementType(ix), true
+	case ty.IsCollectionType():
+		if key != "" {
+			return cty.NilType, false
+		}
+		return ty.ElementType(), true
+	default:
+		return cty.NilType, false
+	}
+}
+
+// defaultsChildKeyRule describes which keys of Children are valid for a
+// Defaults of the given type at the given path, for explaining why the
+// given key is not.
+func defaultsChildKeyRule(ty cty.Type, key, path string) string {
+	switch {
+	case ty.IsObjectType():
+		if key == "" {
+			return fmt.Sprintf("The children of an object are keyed by attribute name, and the empty key is used only for the elements of collections, but %s is an object.", path)
+		}
+		return fmt.Sprintf("The children of an object are keyed by attribute name, and %s has no attribute %q.", path, key)
+	case ty.IsTupleType():
+		if ix, err := strconv.Atoi(key); err == nil && strconv.Itoa(ix) == key && ix >= 0 {
+			return fmt.Sprintf("The children of a tuple are keyed by element index, and %s has only %d elements.", path, len(ty.TupleElementTypes()))
+		}
+		return fmt.Sprintf("The children of a tuple are keyed by element index, written in decimal without leading zeros, such as \"0\", but %q is not an index.", key)
+	case ty.IsCollectionType():
+		return fmt.Sprintf("The child for the elements of a collection has the empty key, but %s is a collection of type %s.", path, TypeString(ty))
+	default:
+		return fmt.Sprintf("Only objects, tuples, and collections have children, but %s has type %s.", path, TypeString(ty))
+	}
+}
+
+// defaultsPathStep returns the part of a path, as reported by Validate, that
+// selects the given key from a Defaults of the given type.
+func defaultsPathStep(ty cty.Type, key string) string {
+	switch {
+	case ty.IsTupleType():
+		if _, err := strconv.Atoi(key); err == nil {
+			return "[" + key + "]"
+		}
+	case ty.IsObjectType():
+		if hclsyntax.ValidIdentifier(key) {
+			return "." + key
+		}
+	}
+	return fmt.Sprintf("[%q]", key)
+}
+
+// ZeroFillDefaults returns a Defaults tree for the given type which supplies
+// a zero value for every object attribute at every level, whether or not the
+// attribute is optional: an empty string, zero, false, or an empty
+// collection, as appropriate for the attribute's type. Applying the result
+// to an incomplete value, such as an empty object, produces a minimally-valid
+// value of the type, which is useful for scaffolding incomplete
+// configuration.
+//
+// Nested object attributes default to an empty object whose attributes are
+// then filled in by the corresponding child defaults. Elements of lists,
+// sets, and maps are filled using the child defaults stored under the key
+// "" for collection elements. Attributes of type cty.DynamicPseudoType,
+// which have no more specific zero value, default to null.
+//
+// The result is nil if there are no object attributes anywhere in the type.
+func ZeroFillDefaults(ty cty.Type) *Defaults {
+	switch {
+	case ty.IsObjectType():
+		defaultValues := make(map[string]cty.Value)
+		children := make(map[string]*Defaults)
+		for name, aty := range ty.AttributeTypes() {
+			if val, ok := zeroValue(aty); ok {
+				defaultValues[name] = val
+			}
+			if child := ZeroFillDefaults(aty); child != nil {
+				children[name] = child
+			}
+		}
+		return structuredDefaults(ty, defaultValues, children)
+	case ty.IsTupleType():
+		children := make(map[string]*Defaults)
+		for i, ety := range ty.TupleElementTypes() {
+			if child := ZeroFillDefaults(ety); child != nil {
+				children[strconv.Itoa(i)] = child
+			}
+		}
+		return structuredDefaults(ty, nil, children)
+	case ty.IsCollectionType():
+		return collectionDefaults(ty, ZeroFillDefaults(ty.ElementType()))
+	default:
+		return nil
+	}
+}
+
+// zeroValue returns the zero value used by ZeroFillDefaults for the given
+// type, or false if the type has no zero value.
+func zeroValue(ty cty.Type) (cty.Value, bool) {
+	switch {
+	case ty == cty.String:
+		return cty.StringVal(""), true
+	case ty == cty.Number:
+		return cty.Zero, true
+	case ty == cty.Bool:
+		return cty.False, true
+	case ty.IsListType():
+		return cty.ListValEmpty(ty.ElementType().WithoutOptionalAttributesDeep()), true
+	case ty.IsSetType():
+		return cty.SetValEmpty(ty.ElementType().WithoutOptionalAttributesDeep()), true
+	case ty.IsMapType():
+		return cty.MapValEmpty(ty.ElementType().WithoutOptionalAttributesDeep()), true
+	case ty == cty.DynamicPseudoType:
+		return cty.NullVal(cty.DynamicPseudoType), true
+	case ty.IsObjectType():
+		// The attributes are filled in by the child defaults, if any.
+		return cty.EmptyObjectVal, true
+	case ty.IsTupleType():
+		elems := make([]cty.Value, len(ty.TupleElementTypes()))
+		for i, ety := range ty.TupleElementTypes() {
+			val, ok := zeroValue(ety)
+			if !ok {
+				val = cty.NullVal(ety)
+			}
+			elems[i] = val
+		}
+		return cty.TupleVal(elems), true
+	default:
+		return cty.NilVal, false
+	}
+}
+
+// ToHCLWrite renders the default values described by the receiver as an
+// hclwrite object literal, which is useful for generating example
+// configuration from a type constraint.
+//
+// Attributes which have no default value, either directly or somewhere
+// within their nested children, are omitted from the result. Element defaults
+// for lists and sets are rendered as a single-element tuple, while element
+// defaults for maps are omitted because there is no key to render them under.
+//
+// If there are no defaults to render at all then the result is an empty
+// object literal.
+func (d *Defaults) ToHCLWrite() hclwrite.Tokens {
+	if toks := d.hclwriteTokens(); toks != nil {
+		return toks
+	}
+	return hclwrite.TokensForObject(nil)
+}
+
+func (d *Defaults) hclwriteTokens() hclwrite.Tokens {
+	if d == nil {
+		return nil
+	}
+
+	switch {
+	case d.Type.IsObjectType():
+		defaultValues := d.attrDefaults(&applyState{})
+		var names []string
+		for name := range defaultValues {
+			names = append(names, name)
+		}
+		for _, name := range d.childAttrNames() {
+			if _, exists := defaultValues[name]; !exists {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		var attrs []hclwrite.ObjectAttrTokens
+		for _, name := range names {
+			var value hclwrite.Tokens
+			if defaultValue, ok := defaultValues[name]; ok {
+				if child := d.attrChild(name); child != nil {
+					defaultValue = child.apply(defaultValue, &applyState{}, nil)
+				}
+				defaultValue, _ = defaultValue.UnmarkDeep()
+				if !defaultValue.IsWhollyKnown() {
+					continue
+				}
+				value = hclwrite.TokensForValue(defaultValue)
+			} else {
+				value = d.attrChild(name).hclwriteTokens()
+			}
+			if value == nil {
+				continue
+			}
+
+			nameTokens := hclwrite.TokensForIdentifier(name)
+			if !hclsyntax.ValidIdentifier(name) {
+				nameTokens = hclwrite.TokensForValue(cty.StringVal(name))
+			}
+			attrs = append(attrs, hclwrite.ObjectAttrTokens{
+				Name:  nameTokens,
+				Value: value,
+			})
+		}
+		if len(attrs) == 0 {
+			return nil
+		}
+		return hclwrite.TokensForObject(attrs)
+	case d.Type.IsListType(), d.Type.IsSetType():
+		if elem := d.Children[""].hclwriteTokens(); elem != nil {
+			return hclwrite.TokensForTuple([]hclwrite.Tokens{elem})
+		}
+	case d.Type.IsTupleType():
+		var elems []hclwrite.Tokens
+		found := false
+		for ix := range d.Type.TupleElementTypes() {
+			elem := d.Children[strconv.Itoa(ix)].hclwriteTokens()
+			if elem == nil {
+				elem = hclwrite.TokensForValue(cty.NullVal(cty.DynamicPseudoType))
+			} else {
+				found = true
+			}
+			elems = append(elems, elem)
+		}
+		if found {
+			return hclwrite.TokensForTuple(elems)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package typeexpr
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DefaultsBuilder constructs a Defaults tree for a particular type, as an
+// alternative to populating the fields of Defaults directly, which requires
+// knowing how the keys of Children correspond to the parts of the type.
+//
+// The methods that populate the tree return builders so that they can be
+// chained, and don't report errors themselves. Instead, Build checks the
+// whole tree for consistency with its type and reports any problems.
+type DefaultsBuilder struct {
+	ty       cty.Type
+	values   map[string]cty.Value
+	children map[string]*DefaultsBuilder
+}
+
+// NewDefaultsBuilder returns a builder for a Defaults tree for the given
+// type, which initially has no defaults.
+func NewDefaultsBuilder(ty cty.Type) *DefaultsBuilder {
+	return &DefaultsBuilder{ty: ty}
+}
+
+// SetAttrDefault sets the default value for the attribute of the receiver's
+// object type with the given name, replacing any value set previously, and
+// returns the receiver.
+func (b *DefaultsBuilder) SetAttrDefault(name string, v cty.Value) *DefaultsBuilder {
+	if b.values == nil {
+		b.values = make(map[string]cty.Value)
+	}
+	b.values[name] = v
+	return b
+}
+
+// Child returns the builder for the defaults within the attribute of the
+// receiver's object type with the given name, creating it if necessary. For
+// a tuple type, the name is instead the decimal index of an element.
+func (b *DefaultsBuilder) Child(name string) *DefaultsBuilder {
+	if child, ok := b.children[name]; ok {
+		return child
+	}
+	ty, ok := defaultsChildType(b.ty, name)
+	if !ok {
+		// Build will report that this child is invalid.
+		ty = cty.NilType
+	}
+	child := NewDefaultsBuilder(ty)
+	if b.children == nil {
+		b.children = make(map[string]*DefaultsBuilder)
+	}
+	b.children[name] = child
+	return child
+}
+
+// ElementChild returns the builder for the defaults within all of the
+// elements of the receiver's list, set, or map type, creating it if
+// necessary.
+func (b *DefaultsBuilder) ElementChild() *DefaultsBuilder {
+	return b.Child("")
+}
+
+// Build returns the Defaults tree described by the receiver, or an error if
+// it is not consistent with its type, as reported by Defaults.Validate.
+// Children for which no defaults were set are omitted from the result.
+//
+// The result does not share any maps with the receiver, so the receiver may
+// continue to be modified and built again.
+func (b *DefaultsBuilder) Build() (*Defaults, error) {
+	d := b.build()
+	if diags := d.Validate(); diags.HasErrors() {
+		var msgs []string
+		for _, diag := range diags {
+			msgs = append(msgs, diag.Detail)
+		}
+		return nil, errors.New(strings.Join(msgs, " "))
+	}
+	return d, nil
+}
+
+func (b *DefaultsBuilder) build() *Defaults {
+	d := &Defaults{
+		Type: b.ty,
+	}
+	if len(b.values) > 0 {
+		d.DefaultValues = make(map[string]cty.Value, len(b.values))
+		for name, v := range b.values {
+			d.DefaultValues[name] = v
+		}
+	}
+
+	for key, childBuilder := range b.children {
+		child := childBuilder.build()
+		// Children with an invalid key are retained so that Validate
+		// reports them.
+		if child.Type != cty.NilType && len(child.DefaultValues) == 0 && len(child.Children) == 0 {
+			continue
+		}
+		if d.Children == nil {
+			d.Children = make(map[string]*Defaults)
+		}
+		d.Children[key] = child
+	}
+	return d
+}
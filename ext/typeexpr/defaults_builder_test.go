@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package typeexpr
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestDefaultsBuilder(t *testing.T) {
+	wantType, want := parseTypeWithDefaults(t, `object({
+		name    = optional(string, "web")
+		servers = optional(list(object({
+			port = optional(number, 80)
+			tags = optional(map(object({
+				value = optional(string, "none")
+			})))
+		})))
+		pair    = optional(tuple([string, object({ enabled = optional(bool, true) })]))
+		other   = optional(object({ unused = optional(string) }))
+	})`)
+
+	b := NewDefaultsBuilder(wantType)
+	b.SetAttrDefault("name", cty.StringVal("web"))
+	server := b.Child("servers").ElementChild().SetAttrDefault("port", cty.NumberIntVal(80))
+	server.Child("tags").ElementChild().SetAttrDefault("value", cty.StringVal("none"))
+	b.Child("pair").Child("1").SetAttrDefault("enabled", cty.True)
+	b.Child("other")
+
+	got, err := b.Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	// Changing the builder must not change a Defaults already built.
+	b.SetAttrDefault("name", cty.StringVal("db"))
+	if !got.Equal(want) {
+		t.Errorf("result changed after modifying the builder")
+	}
+}
+
+func TestDefaultsBuilder_errors(t *testing.T) {
+	ty := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name":  cty.String,
+		"items": cty.List(cty.ObjectWithOptionalAttrs(map[string]cty.Type{"count": cty.Number}, []string{"count"})),
+	}, []string{"name", "items"})
+
+	tests := map[string]struct {
+		build func(b *DefaultsBuilder)
+		want  string
+	}{
+		"unknown attribute": {
+			func(b *DefaultsBuilder) {
+				b.SetAttrDefault("nmae", cty.StringVal("a"))
+			},
+			`There is a default value for root.nmae, but root has no attribute "nmae".`,
+		},
+		"incompatible default": {
+			func(b *DefaultsBuilder) {
+				b.Child("items").ElementChild().SetAttrDefault("count", cty.StringVal("many"))
+			},
+			`The default value for root.items[""].count is not compatible with the attribute's type constraint: a number is required.`,
+		},
+		"unknown child": {
+			func(b *DefaultsBuilder) {
+				b.Child("nope").SetAttrDefault("x", cty.True)
+			},
+			`There are child defaults for root.nope, but it is not an attribute or element of root. The children of an object are keyed by attribute name, and root has no attribute "nope".`,
+		},
+		"element of an object": {
+			func(b *DefaultsBuilder) {
+				b.ElementChild()
+			},
+			`There are child defaults for root[""], but it is not an attribute or element of root. The children of an object are keyed by attribute name, and the empty key is used only for the elements of collections, but root is an object.`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := NewDefaultsBuilder(ty)
+			test.build(b)
+			got, err := b.Build()
+			if err == nil {
+				t.Fatalf("unexpected success: %#v", got)
+			}
+			if err.Error() != test.want {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package typeexpr
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// defaultsJSON is the JSON serialization of a Defaults.
+type defaultsJSON struct {
+	Type          json.RawMessage            `json:"type"`
+	DefaultValues map[string]json.RawMessage `json:"default_values,omitempty"`
+	Children      map[string]*Defaults       `json:"children,omitempty"`
+}
+
+// MarshalJSON returns a JSON serialization of the receiver, which can be
+// decoded by UnmarshalJSON to produce a tree equal to the receiver, as
+// reported by Equal. This allows persisting a Defaults tree, such as for
+// caching or for passing it to another process.
+//
+// The type is encoded using cty's JSON type serialization, and each default
+// value is encoded using cty's JSON value serialization against the type of
+// its attribute, disregarding any optional attribute markers within that
+// type in the same way as converting a value to it does. It is an error for a default value to be marked or unknown,
+// or to not conform to the type of its attribute. Default expressions cannot
+// be serialized, so it is also an error for DefaultExprs to be non-empty.
+func (d *Defaults) MarshalJSON() ([]byte, error) {
+	if d == nil {
+		return []byte("null"), nil
+	}
+
+	if len(d.DefaultExprs) > 0 {
+		return nil, fmt.Errorf("cannot serialize default expressions")
+	}
+
+	tyJSON, err := d.Type.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	ret := defaultsJSON{
+		Type:     tyJSON,
+		Children: d.Children,
+	}
+
+	var names []string
+	for name := range d.DefaultValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		valTy, ok := defaultValueJSONType(d.Type, name)
+		if !ok {
+			return nil, fmt.Errorf("invalid default value for %q: type %s has no such attribute", name, TypeString(d.Type))
+		}
+		valJSON, err := ctyjson.Marshal(d.DefaultValues[name], valTy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default value for %q: %w", name, err)
+		}
+		if ret.DefaultValues == nil {
+			ret.DefaultValues = make(map[string]json.RawMessage, len(d.DefaultValues))
+		}
+		ret.DefaultValues[name] = valJSON
+	}
+
+	return json.Marshal(ret)
+}
+
+// UnmarshalJSON decodes a JSON serialization of a Defaults tree produced by
+// MarshalJSON, replacing the content of the receiver.
+func (d *Defaults) UnmarshalJSON(src []byte) error {
+	var raw defaultsJSON
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return err
+	}
+
+	var ty cty.Type
+	if err := ty.UnmarshalJSON(raw.Type); err != nil {
+		return fmt.Errorf("invalid type: %w", err)
+	}
+
+	var defaultValues map[string]cty.Value
+	for name, valJSON := range raw.DefaultValues {
+		valTy, ok := defaultValueJSONType(ty, name)
+		if !ok {
+			return fmt.Errorf("invalid default value for %q: type %s has no such attribute", name, TypeString(ty))
+		}
+		val, err := ctyjson.Unmarshal(valJSON, valTy)
+		if err != nil {
+			return fmt.Errorf("invalid default value for %q: %w", name, err)
+		}
+		if defaultValues == nil {
+			defaultValues = make(map[string]cty.Value, len(raw.DefaultValues))
+		}
+		defaultValues[name] = val
+	}
+
+	*d = Defaults{
+		Type:          ty,
+		DefaultValues: defaultValues,
+		Children:      raw.Children,
+	}
+	return nil
+}
+
+// defaultValueJSONType returns the type to use for serializing the default
+// value with the given name in a Defaults of the given type, or false if the
+// type has no such attribute. The wildcard default applies to attributes of
+// various types, so it's serialized along with its own type.
+func defaultValueJSONType(ty cty.Type, name string) (cty.Type, bool) {
+	if !ty.IsObjectType() {
+		return cty.NilType, false
+	}
+	if !ty.HasAttribute(name) {
+		if name == WildcardAttribute {
+			return cty.DynamicPseudoType, true
+		}
+		return cty.NilType, false
+	}
+	return ty.AttributeType(name).WithoutOptionalAttributesDeep(), true
+}
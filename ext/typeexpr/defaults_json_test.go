@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package typeexpr
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+func TestDefaults_JSON(t *testing.T) {
+	itemType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name":  cty.String,
+		"extra": cty.DynamicPseudoType,
+	}, []string{"name", "extra"})
+	rootType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"enabled": cty.Bool,
+		"tags":    cty.Map(cty.String),
+		"items":   cty.List(itemType),
+		"pair":    cty.Tuple([]cty.Type{cty.String, itemType}),
+	}, []string{"enabled", "tags", "items"})
+
+	testCases := map[string]*Defaults{
+		"nil": nil,
+		"no defaults": {
+			Type: rootType,
+		},
+		"nested": {
+			Type: rootType,
+			DefaultValues: map[string]cty.Value{
+				"enabled": cty.True,
+				"tags":    cty.MapVal(map[string]cty.Value{"env": cty.StringVal("dev")}),
+			},
+			Children: map[string]*Defaults{
+				"items": {
+					Type: cty.List(itemType),
+					Children: map[string]*Defaults{
+						"": {
+							Type: itemType,
+							DefaultValues: map[string]cty.Value{
+								"name":  cty.StringVal("unnamed"),
+								"extra": cty.NumberIntVal(5),
+							},
+						},
+					},
+				},
+				"pair": {
+					Type: cty.Tuple([]cty.Type{cty.String, itemType}),
+					Children: map[string]*Defaults{
+						"1": {
+							Type: itemType,
+							DefaultValues: map[string]cty.Value{
+								"name": cty.NullVal(cty.String),
+							},
+						},
+					},
+				},
+			},
+		},
+		"wildcard": {
+			Type: itemType,
+			DefaultValues: map[string]cty.Value{
+				"name":            cty.StringVal("unnamed"),
+				WildcardAttribute: cty.NumberIntVal(5),
+			},
+		},
+	}
+
+	for name, defaults := range testCases {
+		t.Run(name, func(t *testing.T) {
+			src, err := json.Marshal(defaults)
+			if err != nil {
+				t.Fatalf("unexpected error from marshal: %s", err)
+			}
+			var got *Defaults
+			if err := json.Unmarshal(src, &got); err != nil {
+				t.Fatalf("unexpected error from unmarshal: %s\n%s", err, src)
+			}
+			if !got.Equal(defaults) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v\njson: %s", got, defaults, src)
+			}
+		})
+	}
+
+	t.Run("parsed type constraint", func(t *testing.T) {
+		expr, diags := hclsyntax.ParseExpression([]byte(`object({
+			name  = optional(string, "default")
+			ports = optional(list(object({
+				number   = number
+				protocol = optional(string, "tcp")
+			})), [])
+		})`), "", hcl.InitialPos)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+		_, defaults, diags := TypeConstraintWithDefaults(expr)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+
+		src, err := json.Marshal(defaults)
+		if err != nil {
+			t.Fatalf("unexpected error from marshal: %s", err)
+		}
+		got := &Defaults{}
+		if err := json.Unmarshal(src, got); err != nil {
+			t.Fatalf("unexpected error from unmarshal: %s\n%s", err, src)
+		}
+		if !got.Equal(defaults) {
+			t.Errorf("wrong result\ngot:  %#v\nwant: %#v\njson: %s", got, defaults, src)
+		}
+	})
+
+	t.Run("marked default", func(t *testing.T) {
+		defaults := &Defaults{
+			Type: rootType,
+			DefaultValues: map[string]cty.Value{
+				"enabled": cty.True.Mark("sensitive"),
+			},
+		}
+		if _, err := json.Marshal(defaults); err == nil {
+			t.Fatalf("unexpected success")
+		}
+	})
+
+	t.Run("unknown attribute", func(t *testing.T) {
+		var got Defaults
+		err := json.Unmarshal([]byte(`{"type":"string","default_values":{"a":"b"}}`), &got)
+		if err == nil {
+			t.Fatalf("unexpected success")
+		}
+		if got, want := err.Error(), `invalid default value for "a": type string has no such attribute`; got != want {
+			t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+		}
+	})
+}
@@ -4,10 +4,20 @@
 package typeexpr
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
 )
 
 var (
@@ -280,6 +290,58 @@ func TestDefaults_Apply(t *testing.T) {
 				}),
 			}),
 		},
+		"tuple shorter than its type": {
+			defaults: &Defaults{
+				Type: cty.Tuple([]cty.Type{simpleObject, simpleObject}),
+				Children: map[string]*Defaults{
+					"0": {
+						Type: simpleObject,
+						DefaultValues: map[string]cty.Value{
+							"b": cty.False,
+						},
+					},
+					"1": {
+						Type: simpleObject,
+						DefaultValues: map[string]cty.Value{
+							"b": cty.True,
+						},
+					},
+				},
+			},
+			value: cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("foo"),
+				}),
+			}),
+			want: cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("foo"),
+				}),
+			}),
+		},
+		"tuple children beyond the type's elements": {
+			defaults: &Defaults{
+				Type: cty.Tuple([]cty.Type{simpleObject}),
+				Children: map[string]*Defaults{
+					"1": {
+						Type: simpleObject,
+						DefaultValues: map[string]cty.Value{
+							"b": cty.True,
+						},
+					},
+				},
+			},
+			value: cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("foo"),
+				}),
+			}),
+			want: cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("foo"),
+				}),
+			}),
+		},
 		// More complex cases with deeply nested defaults, testing the "default
 		// within a default" edges.
 		"set of nested objects, no default sub-object": {
@@ -915,6 +977,188 @@ func TestDefaults_Apply(t *testing.T) {
 				"foo": cty.DynamicVal,
 			}),
 		},
+		"marked default value": {
+			defaults: &Defaults{
+				Type: cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+					"password": cty.String,
+				}, []string{"password"}),
+				DefaultValues: map[string]cty.Value{
+					"password": cty.StringVal("hunter2").Mark("sensitive"),
+				},
+			},
+			value: cty.EmptyObjectVal,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"password": cty.StringVal("hunter2").Mark("sensitive"),
+			}),
+		},
+		"marked default values in map elements": {
+			defaults: &Defaults{
+				Type: cty.Map(simpleObject),
+				Children: map[string]*Defaults{
+					"": {
+						Type: simpleObject,
+						DefaultValues: map[string]cty.Value{
+							"b": cty.True.Mark("sensitive"),
+						},
+					},
+				},
+			},
+			value: cty.MapVal(map[string]cty.Value{
+				"x": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("foo").Mark("element"),
+					"b": cty.NullVal(cty.Bool),
+				}),
+				"y": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("bar"),
+					"b": cty.False,
+				}).Mark("element"),
+			}).Mark("container"),
+			want: cty.MapVal(map[string]cty.Value{
+				"x": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("foo").Mark("element"),
+					"b": cty.True.Mark("sensitive"),
+				}),
+				"y": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("bar"),
+					"b": cty.False,
+				}).Mark("element"),
+			}).Mark("container"),
+		},
+		"marked default map in object": {
+			defaults: &Defaults{
+				Type: cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+					"tags": cty.Map(cty.String),
+				}, []string{"tags"}),
+				DefaultValues: map[string]cty.Value{
+					"tags": cty.MapVal(map[string]cty.Value{
+						"k": cty.StringVal("v").Mark("sensitive"),
+					}),
+				},
+			},
+			value: cty.EmptyObjectVal.Mark("container"),
+			want: cty.ObjectVal(map[string]cty.Value{
+				"tags": cty.MapVal(map[string]cty.Value{
+					"k": cty.StringVal("v").Mark("sensitive"),
+				}),
+			}).Mark("container"),
+		},
+		"defaulted nested object gets its own defaults": {
+			defaults: &Defaults{
+				Type: cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+					"network": cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+						"subnet": cty.String,
+						"dns": cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+							"ttl": cty.Number,
+						}, []string{"ttl"}),
+					}, []string{"subnet", "dns"}),
+				}, []string{"network"}),
+				DefaultValues: map[string]cty.Value{
+					"network": cty.EmptyObjectVal,
+				},
+				Children: map[string]*Defaults{
+					"network": {
+						Type: cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+							"subnet": cty.String,
+							"dns": cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+								"ttl": cty.Number,
+							}, []string{"ttl"}),
+						}, []string{"subnet", "dns"}),
+						DefaultValues: map[string]cty.Value{
+							"subnet": cty.StringVal("10.0.0.0/8"),
+							"dns":    cty.EmptyObjectVal,
+						},
+						Children: map[string]*Defaults{
+							"dns": {
+								Type: cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+									"ttl": cty.Number,
+								}, []string{"ttl"}),
+								DefaultValues: map[string]cty.Value{
+									"ttl": cty.NumberIntVal(300),
+								},
+							},
+						},
+					},
+				},
+			},
+			value: cty.EmptyObjectVal,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.StringVal("10.0.0.0/8"),
+					"dns": cty.ObjectVal(map[string]cty.Value{
+						"ttl": cty.NumberIntVal(300),
+					}),
+				}),
+			}),
+		},
+		"marked existing and default attributes in marked object": {
+			defaults: &Defaults{
+				Type: cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+					"name":     cty.String,
+					"password": cty.String,
+				}, []string{"password"}),
+				DefaultValues: map[string]cty.Value{
+					"password": cty.StringVal("hunter2").Mark("sensitive"),
+				},
+			},
+			value: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("admin").Mark("personal"),
+			}).Mark("container"),
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name":     cty.StringVal("admin").Mark("personal"),
+				"password": cty.StringVal("hunter2").Mark("sensitive"),
+			}).Mark("container"),
+		},
+		"marked null attribute replaced by marked default": {
+			defaults: &Defaults{
+				Type: cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+					"name":     cty.String,
+					"password": cty.String,
+				}, []string{"password"}),
+				DefaultValues: map[string]cty.Value{
+					"password": cty.StringVal("hunter2").Mark("sensitive"),
+				},
+			},
+			value: cty.ObjectVal(map[string]cty.Value{
+				"name":     cty.StringVal("admin").Mark("personal"),
+				"password": cty.NullVal(cty.String).Mark("input"),
+			}),
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name":     cty.StringVal("admin").Mark("personal"),
+				"password": cty.StringVal("hunter2").WithMarks(cty.NewValueMarks("sensitive", "input")),
+			}),
+		},
+		"marked attributes in map elements": {
+			defaults: &Defaults{
+				Type: cty.Map(simpleObject),
+				Children: map[string]*Defaults{
+					"": {
+						Type: simpleObject,
+						DefaultValues: map[string]cty.Value{
+							"b": cty.True.Mark("sensitive"),
+						},
+					},
+				},
+			},
+			value: cty.ObjectVal(map[string]cty.Value{
+				"x": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("foo").Mark("element"),
+				}),
+				"y": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("bar"),
+					"b": cty.NullVal(cty.Bool).Mark("input"),
+				}),
+			}).Mark("container"),
+			want: cty.ObjectVal(map[string]cty.Value{
+				"x": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("foo").Mark("element"),
+					"b": cty.True.Mark("sensitive"),
+				}),
+				"y": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("bar"),
+					"b": cty.True.WithMarks(cty.NewValueMarks("sensitive", "input")),
+				}),
+			}).Mark("container"),
+		},
 	}
 
 	for name, tc := range testCases {
@@ -926,3 +1170,3387 @@ func TestDefaults_Apply(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaults_ToHCLWrite(t *testing.T) {
+	tests := map[string]struct {
+		source string
+		want   string
+	}{
+		"no defaults": {
+			source: `object({ a = string, b = optional(number) })`,
+			want:   `{}`,
+		},
+		"flat object": {
+			source: `object({ a = string, b = optional(number, 5), c = optional(string, "hello") })`,
+			want: `{
+  b = 5
+  c = "hello"
+}`,
+		},
+		"nested object": {
+			source: `object({
+				a = string
+				b = optional(object({
+					c = optional(bool, true)
+					d = string
+				}))
+				e = optional(object({
+					f = optional(string, "nested")
+				}), {})
+			})`,
+			want: `{
+  b = {
+    c = true
+  }
+  e = {
+    f = "nested"
+  }
+}`,
+		},
+		"list of objects": {
+			source: `list(object({ a = optional(string, "x") }))`,
+			want: `[{
+  a = "x"
+}]`,
+		},
+		"map of objects": {
+			source: `object({ m = map(object({ a = optional(string, "x") })) })`,
+			want:   `{}`,
+		},
+		"tuple": {
+			source: `tuple([string, object({ a = optional(number, 1) })])`,
+			want: `[null, {
+  a = 1
+}]`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(test.source), "", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatalf("failed to parse: %s", diags)
+			}
+			ty, defaults, diags := TypeConstraintWithDefaults(expr)
+			if diags.HasErrors() {
+				t.Fatalf("failed to decode type constraint: %s", diags)
+			}
+			if defaults == nil {
+				defaults = &Defaults{Type: ty}
+			}
+
+			got := string(hclwrite.Format(defaults.ToHCLWrite().Bytes()))
+			if got != test.want {
+				t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDefaults_Equal(t *testing.T) {
+	objType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"a": cty.String,
+		"b": cty.List(cty.String),
+	}, []string{"a", "b"})
+	base := func() *Defaults {
+		return &Defaults{
+			Type: cty.Map(objType),
+			Children: map[string]*Defaults{
+				"": {
+					Type: objType,
+					DefaultValues: map[string]cty.Value{
+						"a": cty.StringVal("foo"),
+						"b": cty.ListValEmpty(cty.String),
+					},
+				},
+			},
+		}
+	}
+
+	withExpr := func(expr hcl.Expression) *Defaults {
+		d := base()
+		d.Children[""].DefaultExprs = map[string]hcl.Expression{
+			"a": expr,
+		}
+		return d
+	}
+	objectExpr := hcl.StaticExpr(cty.ObjectVal(map[string]cty.Value{
+		"a": cty.ListVal([]cty.Value{cty.StringVal("foo")}),
+	}), hcl.Range{})
+	pointerExpr := &hclsyntax.LiteralValueExpr{Val: cty.StringVal("foo")}
+
+	tests := map[string]struct {
+		a, b *Defaults
+		want bool
+	}{
+		"both nil": {
+			nil,
+			nil,
+			true,
+		},
+		"nil and non-nil": {
+			nil,
+			base(),
+			false,
+		},
+		"identical": {
+			base(),
+			base(),
+			true,
+		},
+		"nil and empty maps": {
+			&Defaults{Type: cty.String},
+			&Defaults{
+				Type:          cty.String,
+				DefaultValues: map[string]cty.Value{},
+				Children:      map[string]*Defaults{},
+			},
+			true,
+		},
+		"different type": {
+			&Defaults{Type: cty.String},
+			&Defaults{Type: cty.Number},
+			false,
+		},
+		"different default value": {
+			base(),
+			func() *Defaults {
+				d := base()
+				d.Children[""].DefaultValues["a"] = cty.StringVal("bar")
+				return d
+			}(),
+			false,
+		},
+		"different default value marks": {
+			base(),
+			func() *Defaults {
+				d := base()
+				d.Children[""].DefaultValues["a"] = cty.StringVal("foo").Mark("sensitive")
+				return d
+			}(),
+			false,
+		},
+		"missing default value": {
+			base(),
+			func() *Defaults {
+				d := base()
+				delete(d.Children[""].DefaultValues, "b")
+				return d
+			}(),
+			false,
+		},
+		"extra child": {
+			base(),
+			func() *Defaults {
+				d := base()
+				d.Children["extra"] = &Defaults{Type: cty.String}
+				return d
+			}(),
+			false,
+		},
+		"same comparable default expression": {
+			withExpr(hcl.StaticExpr(cty.StringVal("foo"), hcl.Range{})),
+			withExpr(hcl.StaticExpr(cty.StringVal("foo"), hcl.Range{})),
+			true,
+		},
+		"different comparable default expressions": {
+			withExpr(hcl.StaticExpr(cty.StringVal("foo"), hcl.Range{})),
+			withExpr(hcl.StaticExpr(cty.StringVal("bar"), hcl.Range{})),
+			false,
+		},
+		"uncomparable default expressions": {
+			withExpr(objectExpr),
+			withExpr(objectExpr),
+			false,
+		},
+		"same pointer default expression": {
+			withExpr(pointerExpr),
+			withExpr(pointerExpr),
+			true,
+		},
+		"different pointer default expressions": {
+			withExpr(pointerExpr),
+			withExpr(&hclsyntax.LiteralValueExpr{Val: cty.StringVal("foo")}),
+			false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.a.Equal(test.b); got != test.want {
+				t.Errorf("wrong result %t; want %t", got, test.want)
+			}
+			if got := test.b.Equal(test.a); got != test.want {
+				t.Errorf("wrong result %t in reverse; want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestZeroFillDefaults(t *testing.T) {
+	disk := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"size":  cty.Number,
+		"label": cty.String,
+	}, []string{"label"})
+	ty := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name":    cty.String,
+		"enabled": cty.Bool,
+		"tags":    cty.Map(cty.String),
+		"ports":   cty.List(cty.Number),
+		"root":    disk,
+		"disks":   cty.List(disk),
+		"pair":    cty.Tuple([]cty.Type{cty.String, disk}),
+		"extra":   cty.DynamicPseudoType,
+	}, []string{"enabled"})
+
+	tests := map[string]struct {
+		value cty.Value
+		want  cty.Value
+	}{
+		"empty object": {
+			cty.EmptyObjectVal,
+			cty.ObjectVal(map[string]cty.Value{
+				"name":    cty.StringVal(""),
+				"enabled": cty.False,
+				"tags":    cty.MapValEmpty(cty.String),
+				"ports":   cty.ListValEmpty(cty.Number),
+				"root": cty.ObjectVal(map[string]cty.Value{
+					"size":  cty.Zero,
+					"label": cty.StringVal(""),
+				}),
+				"disks": cty.ListValEmpty(disk.WithoutOptionalAttributesDeep()),
+				"pair": cty.TupleVal([]cty.Value{
+					cty.StringVal(""),
+					cty.ObjectVal(map[string]cty.Value{
+						"size":  cty.Zero,
+						"label": cty.StringVal(""),
+					}),
+				}),
+				"extra": cty.NullVal(cty.DynamicPseudoType),
+			}),
+		},
+		"partial object": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"disks": cty.TupleVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"size": cty.NumberIntVal(10),
+					}),
+				}),
+				"extra": cty.True,
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name":    cty.StringVal("web"),
+				"enabled": cty.False,
+				"tags":    cty.MapValEmpty(cty.String),
+				"ports":   cty.ListValEmpty(cty.Number),
+				"root": cty.ObjectVal(map[string]cty.Value{
+					"size":  cty.Zero,
+					"label": cty.StringVal(""),
+				}),
+				"disks": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"size":  cty.NumberIntVal(10),
+						"label": cty.StringVal(""),
+					}),
+				}),
+				"pair": cty.TupleVal([]cty.Value{
+					cty.StringVal(""),
+					cty.ObjectVal(map[string]cty.Value{
+						"size":  cty.Zero,
+						"label": cty.StringVal(""),
+					}),
+				}),
+				"extra": cty.True,
+			}),
+		},
+	}
+
+	defaults := ZeroFillDefaults(ty)
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := convert.Convert(defaults.Apply(test.value), ty)
+			if err != nil {
+				t.Fatalf("unexpected conversion error: %s", err)
+			}
+			if !test.want.RawEquals(got) {
+				t.Errorf("wrong result\n%s", cmp.Diff(test.want, got, valueComparer))
+			}
+		})
+	}
+
+	t.Run("primitive", func(t *testing.T) {
+		if got := ZeroFillDefaults(cty.List(cty.String)); got != nil {
+			t.Errorf("unexpected defaults for type with no attributes: %#v", got)
+		}
+	})
+}
+
+func TestDefaults_ApplyIter(t *testing.T) {
+	simpleObject := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"a": cty.String,
+		"b": cty.Bool,
+	}, []string{"b"})
+	simpleDefaults := &Defaults{
+		Type: simpleObject,
+		DefaultValues: map[string]cty.Value{
+			"b": cty.True,
+		},
+	}
+	listDefaults := &Defaults{
+		Type: cty.List(simpleObject),
+		Children: map[string]*Defaults{
+			"": simpleDefaults,
+		},
+	}
+
+	testCases := map[string]struct {
+		defaults *Defaults
+		value    cty.Value
+		want     []cty.Value
+	}{
+		"list": {
+			defaults: listDefaults,
+			value: cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("foo"),
+					"b": cty.NullVal(cty.Bool),
+				}),
+				cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("bar"),
+					"b": cty.False,
+				}),
+			}),
+			want: []cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("foo"),
+					"b": cty.True,
+				}),
+				cty.ObjectVal(map[string]cty.Value{
+					"a": cty.StringVal("bar"),
+					"b": cty.False,
+				}),
+			},
+		},
+		"marked list": {
+			defaults: listDefaults,
+			value: cty.ListVal([]cty.Value{
+				cty.EmptyObjectVal,
+			}).Mark("sensitive"),
+			want: []cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"b": cty.True,
+				}).Mark("sensitive"),
+			},
+		},
+		"tuple": {
+			defaults: &Defaults{
+				Type: cty.Tuple([]cty.Type{simpleObject, simpleObject}),
+				Children: map[string]*Defaults{
+					"1": simpleDefaults,
+				},
+			},
+			value: cty.TupleVal([]cty.Value{
+				cty.EmptyObjectVal,
+				cty.EmptyObjectVal,
+			}),
+			want: []cty.Value{
+				cty.EmptyObjectVal,
+				cty.ObjectVal(map[string]cty.Value{
+					"b": cty.True,
+				}),
+			},
+		},
+		"empty list": {
+			defaults: listDefaults,
+			value:    cty.ListValEmpty(simpleObject),
+			want:     nil,
+		},
+		"unknown list": {
+			defaults: listDefaults,
+			value:    cty.UnknownVal(cty.List(simpleObject)),
+			want:     nil,
+		},
+		"not a sequence": {
+			defaults: simpleDefaults,
+			value:    cty.EmptyObjectVal,
+			want:     nil,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			next := tc.defaults.ApplyIter(tc.value)
+			var got []cty.Value
+			for {
+				val, ok := next()
+				if !ok {
+					break
+				}
+				got = append(got, val)
+			}
+
+			if !cmp.Equal(tc.want, got, valueComparer) {
+				t.Errorf("wrong result\n%s", cmp.Diff(tc.want, got, valueComparer))
+			}
+		})
+	}
+}
+
+func TestDefaults_ApplyWithDiagnostics(t *testing.T) {
+	simpleObject := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"a": cty.String,
+		"b": cty.Bool,
+	}, []string{"b"})
+	simpleDefaults := &Defaults{
+		Type: simpleObject,
+		DefaultValues: map[string]cty.Value{
+			"b": cty.True,
+		},
+	}
+	portType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"number":   cty.Number,
+		"protocol": cty.String,
+	}, []string{"protocol"})
+
+	testCases := map[string]struct {
+		defaults   *Defaults
+		value      cty.Value
+		want       cty.Value
+		wantDetail string
+	}{
+		"valid": {
+			defaults: simpleDefaults,
+			value: cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal("foo"),
+			}),
+			want: cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal("foo"),
+				"b": cty.True,
+			}),
+		},
+		"converted": {
+			defaults: simpleDefaults,
+			value: cty.ObjectVal(map[string]cty.Value{
+				"a": cty.NumberIntVal(5),
+				"b": cty.StringVal("false"),
+			}),
+			want: cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal("5"),
+				"b": cty.False,
+			}),
+		},
+		"missing required attribute": {
+			defaults: simpleDefaults,
+			value:    cty.EmptyObjectVal,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"b": cty.True,
+			}),
+			wantDetail: `Unsuitable value: attribute "a" is required.`,
+		},
+		"invalid value": {
+			defaults: simpleDefaults,
+			value: cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal("foo"),
+				"b": cty.StringVal("maybe"),
+			}),
+			want: cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal("foo"),
+				"b": cty.StringVal("maybe"),
+			}),
+			wantDetail: `Unsuitable value at .b: a bool is required.`,
+		},
+		"set elements merged": {
+			defaults: &Defaults{
+				Type: cty.Object(map[string]cty.Type{
+					"ports": cty.Set(portType),
+				}),
+				Children: map[string]*Defaults{
+					"ports": {
+						Type: cty.Set(portType),
+						Children: map[string]*Defaults{
+							"": {
+								Type: portType,
+								DefaultValues: map[string]cty.Value{
+									"protocol": cty.StringVal("tcp"),
+								},
+							},
+						},
+					},
+				},
+			},
+			value: cty.ObjectVal(map[string]cty.Value{
+				"ports": cty.SetVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"number":   cty.NumberIntVal(80),
+						"protocol": cty.NullVal(cty.String),
+					}),
+					cty.ObjectVal(map[string]cty.Value{
+						"number":   cty.NumberIntVal(80),
+						"protocol": cty.StringVal("tcp"),
+					}),
+				}),
+			}),
+			want: cty.ObjectVal(map[string]cty.Value{
+				"ports": cty.SetVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"number":   cty.NumberIntVal(80),
+						"protocol": cty.StringVal("tcp"),
+					}),
+				}),
+			}),
+			wantDetail: `Applying default values reduced the number of elements in the set at .ports from 2 to 1, because some of its elements became equal and so were merged.`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, diags := tc.defaults.ApplyWithDiagnostics(tc.value)
+
+			if tc.wantDetail == "" {
+				if diags.HasErrors() {
+					t.Fatalf("unexpected diagnostics: %s", diags.Error())
+				}
+			} else {
+				if len(diags) != 1 {
+					t.Fatalf("got %d diagnostics; want 1\n%s", len(diags), diags.Error())
+				}
+				if got, want := diags[0].Detail, tc.wantDetail; got != want {
+					t.Errorf("wrong detail\ngot:  %s\nwant: %s", got, want)
+				}
+			}
+
+			if !cmp.Equal(tc.want, got, valueComparer) {
+				t.Errorf("wrong result\n%s", cmp.Diff(tc.want, got, valueComparer))
+			}
+		})
+	}
+}
+
+func TestDefaults_ApplyFillingNulls(t *testing.T) {
+	// object({
+	//   outer = optional(object({
+	//     name  = optional(string, "outer")
+	//     inner = optional(object({
+	//       enabled = optional(bool, true)
+	//     }))
+	//   }))
+	//   tags = optional(list(string))
+	// })
+	innerType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"enabled": cty.Bool,
+	}, []string{"enabled"})
+	outerType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name":  cty.String,
+		"inner": innerType,
+	}, []string{"name", "inner"})
+	rootType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"outer": outerType,
+		"tags":  cty.List(cty.String),
+	}, []string{"outer", "tags"})
+
+	innerDefaults := &Defaults{
+		Type: innerType,
+		DefaultValues: map[string]cty.Value{
+			"enabled": cty.True,
+		},
+	}
+	outerDefaults := &Defaults{
+		Type: outerType,
+		DefaultValues: map[string]cty.Value{
+			"name": cty.StringVal("outer"),
+		},
+		Children: map[string]*Defaults{
+			"inner": innerDefaults,
+		},
+	}
+	rootDefaults := &Defaults{
+		Type: rootType,
+		Children: map[string]*Defaults{
+			"outer": outerDefaults,
+		},
+	}
+
+	testCases := map[string]struct {
+		defaults *Defaults
+		value    cty.Value
+		want     cty.Value
+	}{
+		"two levels": {
+			defaults: outerDefaults,
+			value: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal("given"),
+				"inner": cty.NullVal(innerType.WithoutOptionalAttributesDeep()),
+			}),
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("given"),
+				"inner": cty.ObjectVal(map[string]cty.Value{
+					"enabled": cty.True,
+				}),
+			}),
+		},
+		"two levels with missing attribute": {
+			defaults: outerDefaults,
+			value:    cty.EmptyObjectVal,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("outer"),
+				"inner": cty.ObjectVal(map[string]cty.Value{
+					"enabled": cty.True,
+				}),
+			}),
+		},
+		"three levels": {
+			defaults: rootDefaults,
+			value: cty.ObjectVal(map[string]cty.Value{
+				"outer": cty.NullVal(cty.DynamicPseudoType),
+				"tags":  cty.NullVal(cty.List(cty.String)),
+			}),
+			want: cty.ObjectVal(map[string]cty.Value{
+				"outer": cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal("outer"),
+					"inner": cty.ObjectVal(map[string]cty.Value{
+						"enabled": cty.True,
+					}),
+				}),
+				"tags": cty.NullVal(cty.List(cty.String)),
+			}),
+		},
+		"three levels from null root": {
+			defaults: rootDefaults,
+			value:    cty.NullVal(rootType.WithoutOptionalAttributesDeep()).Mark("sensitive"),
+			want: cty.ObjectVal(map[string]cty.Value{
+				"outer": cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal("outer"),
+					"inner": cty.ObjectVal(map[string]cty.Value{
+						"enabled": cty.True,
+					}),
+				}),
+				"tags": cty.NullVal(cty.List(cty.String)),
+			}).Mark("sensitive"),
+		},
+		"null collection is left null": {
+			defaults: &Defaults{
+				Type: cty.List(innerType),
+				Children: map[string]*Defaults{
+					"": innerDefaults,
+				},
+			},
+			value: cty.NullVal(cty.List(innerType.WithoutOptionalAttributesDeep())),
+			want:  cty.NullVal(cty.List(innerType.WithoutOptionalAttributesDeep())),
+		},
+		"null elements of collections are filled": {
+			defaults: &Defaults{
+				Type: cty.List(innerType),
+				Children: map[string]*Defaults{
+					"": innerDefaults,
+				},
+			},
+			value: cty.ListVal([]cty.Value{
+				cty.NullVal(innerType.WithoutOptionalAttributesDeep()),
+			}),
+			want: cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"enabled": cty.True,
+				}),
+			}),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got := tc.defaults.ApplyFillingNulls(tc.value)
+			if !cmp.Equal(tc.want, got, valueComparer) {
+				t.Errorf("wrong result\n%s", cmp.Diff(tc.want, got, valueComparer))
+			}
+		})
+	}
+
+	// Apply must still leave null objects alone.
+	null := cty.NullVal(rootType.WithoutOptionalAttributesDeep())
+	if got := rootDefaults.Apply(null); !got.RawEquals(null) {
+		t.Errorf("Apply changed null value to %#v", got)
+	}
+}
+
+func TestDefaults_Validate(t *testing.T) {
+	subnetType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"cidr":   cty.String,
+		"public": cty.Bool,
+	}, []string{"public"})
+	networkType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name":    cty.String,
+		"subnets": cty.List(subnetType),
+	}, []string{"name", "subnets"})
+	rootType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"network": networkType,
+		"pair":    cty.Tuple([]cty.Type{cty.String, subnetType}),
+	}, []string{"network"})
+
+	testCases := map[string]struct {
+		defaults *Defaults
+		want     []string
+	}{
+		"valid": {
+			defaults: &Defaults{
+				Type: rootType,
+				Children: map[string]*Defaults{
+					"network": {
+						Type: networkType,
+						DefaultValues: map[string]cty.Value{
+							"name": cty.StringVal("default"),
+						},
+						Children: map[string]*Defaults{
+							"subnets": {
+								Type: cty.List(subnetType),
+								Children: map[string]*Defaults{
+									"": {
+										Type: subnetType,
+										DefaultValues: map[string]cty.Value{
+											"public": cty.StringVal("true"),
+										},
+									},
+								},
+							},
+						},
+					},
+					"pair": {
+						Type: cty.Tuple([]cty.Type{cty.String, subnetType}),
+						Children: map[string]*Defaults{
+							"1": {
+								Type: subnetType,
+								DefaultValues: map[string]cty.Value{
+									"public": cty.False,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"unconvertible default": {
+			defaults: &Defaults{
+				Type: rootType,
+				Children: map[string]*Defaults{
+					"network": {
+						Type: networkType,
+						Children: map[string]*Defaults{
+							"subnets": {
+								Type: cty.List(subnetType),
+								Children: map[string]*Defaults{
+									"": {
+										Type: subnetType,
+										DefaultValues: map[string]cty.Value{
+											"public": cty.StringVal("maybe"),
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			want: []string{
+				`The default value for root.network.subnets[""].public is not compatible with the attribute's type constraint: a bool is required.`,
+			},
+		},
+		"unknown keys": {
+			defaults: &Defaults{
+				Type: rootType,
+				DefaultValues: map[string]cty.Value{
+					"nope": cty.True,
+				},
+				DefaultExprs: map[string]hcl.Expression{
+					"nope_expr": hcl.StaticExpr(cty.True, hcl.Range{}),
+				},
+				Children: map[string]*Defaults{
+					"missing": {
+						Type: cty.String,
+					},
+					"pair": {
+						Type: cty.Tuple([]cty.Type{cty.String, subnetType}),
+						Children: map[string]*Defaults{
+							"2": {
+								Type: subnetType,
+							},
+						},
+					},
+				},
+			},
+			want: []string{
+				`There is a default value for root.nope, but root has no attribute "nope".`,
+				`There is a default expression for root.nope_expr, but root has no attribute "nope_expr".`,
+				`There are child defaults for root.missing, but it is not an attribute or element of root. The children of an object are keyed by attribute name, and root has no attribute "missing".`,
+				`There are child defaults for root.pair[2], but it is not an attribute or element of root.pair. The children of a tuple are keyed by element index, and root.pair has only 2 elements.`,
+			},
+		},
+		"keys inconsistent with types": {
+			defaults: &Defaults{
+				Type: rootType,
+				Children: map[string]*Defaults{
+					"": {
+						Type: subnetType,
+					},
+					"network": {
+						Type: networkType,
+						Children: map[string]*Defaults{
+							"subnets": {
+								Type: cty.List(subnetType),
+								Children: map[string]*Defaults{
+									"0": {
+										Type: subnetType,
+									},
+								},
+							},
+						},
+					},
+					"pair": {
+						Type: cty.Tuple([]cty.Type{cty.String, subnetType}),
+						Children: map[string]*Defaults{
+							"01": {
+								Type: subnetType,
+							},
+							"cidr": {
+								Type: cty.String,
+							},
+						},
+					},
+				},
+			},
+			want: []string{
+				`There are child defaults for root[""], but it is not an attribute or element of root. The children of an object are keyed by attribute name, and the empty key is used only for the elements of collections, but root is an object.`,
+				`There are child defaults for root.network.subnets["0"], but it is not an attribute or element of root.network.subnets. The child for the elements of a collection has the empty key, but root.network.subnets is a collection of type list(object({cidr=string,public=bool})).`,
+				`There are child defaults for root.pair[01], but it is not an attribute or element of root.pair. The children of a tuple are keyed by element index, written in decimal without leading zeros, such as "0", but "01" is not an index.`,
+				`There are child defaults for root.pair["cidr"], but it is not an attribute or element of root.pair. The children of a tuple are keyed by element index, written in decimal without leading zeros, such as "0", but "cidr" is not an index.`,
+			},
+		},
+		"children for primitive type": {
+			defaults: &Defaults{
+				Type: cty.String,
+				Children: map[string]*Defaults{
+					"x": {
+						Type: cty.String,
+					},
+				},
+			},
+			want: []string{
+				`There are child defaults for root["x"], but it is not an attribute or element of root. Only objects, tuples, and collections have children, but root has type string.`,
+			},
+		},
+		"wrong child type": {
+			defaults: &Defaults{
+				Type: cty.Map(subnetType),
+				Children: map[string]*Defaults{
+					"": {
+						Type: networkType,
+					},
+				},
+			},
+			want: []string{
+				`The child defaults for root[""] have type object({name=string,subnets=list(object({cidr=string,public=bool}))}), but it has type object({cidr=string,public=bool}).`,
+			},
+		},
+		"default values for non-object": {
+			defaults: &Defaults{
+				Type: cty.List(cty.String),
+				DefaultValues: map[string]cty.Value{
+					"a": cty.StringVal("b"),
+				},
+			},
+			want: []string{
+				`The defaults for root have default values, but its type list(string) has no attributes.`,
+			},
+		},
+		"valid wildcard": {
+			defaults: &Defaults{
+				Type: networkType,
+				DefaultValues: map[string]cty.Value{
+					"name":            cty.StringVal("default"),
+					WildcardAttribute: cty.ListValEmpty(subnetType),
+				},
+				Children: map[string]*Defaults{
+					WildcardAttribute: {
+						Type: cty.List(subnetType),
+					},
+				},
+			},
+		},
+		"incompatible wildcard default": {
+			defaults: &Defaults{
+				Type: networkType,
+				DefaultValues: map[string]cty.Value{
+					WildcardAttribute: cty.StringVal("default"),
+				},
+			},
+			want: []string{
+				`The wildcard default value at root["*"] is not compatible with the type constraint of attribute "subnets", which has no default of its own: list of object required.`,
+			},
+		},
+		"unmatched wildcard child": {
+			defaults: &Defaults{
+				Type: networkType,
+				Children: map[string]*Defaults{
+					WildcardAttribute: {
+						Type: subnetType,
+					},
+				},
+			},
+			want: []string{
+				`The wildcard child defaults for root["*"] have type object({cidr=string,public=bool}), but no attribute of root without child defaults of its own has that type.`,
+			},
+		},
+	}
+
+	// Cycles can only be made by modifying a tree after constructing it.
+	cyclic := &Defaults{
+		Type: networkType,
+	}
+	cyclic.Children = map[string]*Defaults{
+		"subnets": {
+			Type: cty.List(subnetType),
+			Children: map[string]*Defaults{
+				"": cyclic,
+			},
+		},
+	}
+	testCases["cycle"] = struct {
+		defaults *Defaults
+		want     []string
+	}{
+		defaults: &Defaults{
+			Type: rootType,
+			Children: map[string]*Defaults{
+				"network": cyclic,
+			},
+		},
+		want: []string{
+			`The child defaults for root.network.subnets[""] are the same as the defaults for root.network, which contain them, so the tree of defaults has no end.`,
+		},
+	}
+	self := &Defaults{
+		Type: networkType,
+	}
+	self.Children = map[string]*Defaults{
+		"name": self,
+	}
+	testCases["self-reference"] = struct {
+		defaults *Defaults
+		want     []string
+	}{
+		defaults: self,
+		want: []string{
+			`The child defaults for root.name are the same as the defaults for root, which contain them, so the tree of defaults has no end.`,
+		},
+	}
+	shared := &Defaults{
+		Type: subnetType,
+		DefaultValues: map[string]cty.Value{
+			"public": cty.False,
+		},
+	}
+	testCases["shared child"] = struct {
+		defaults *Defaults
+		want     []string
+	}{
+		defaults: &Defaults{
+			Type: rootType,
+			Children: map[string]*Defaults{
+				"network": {
+					Type: networkType,
+					Children: map[string]*Defaults{
+						"subnets": {
+							Type: cty.List(subnetType),
+							Children: map[string]*Defaults{
+								"": shared,
+							},
+						},
+					},
+				},
+				"pair": {
+					Type: cty.Tuple([]cty.Type{cty.String, subnetType}),
+					Children: map[string]*Defaults{
+						"1": shared,
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			diags := tc.defaults.Validate()
+			var got []string
+			for _, diag := range diags {
+				got = append(got, diag.Detail)
+			}
+			if !cmp.Equal(tc.want, got) {
+				t.Errorf("wrong diagnostics\n%s", cmp.Diff(tc.want, got))
+			}
+		})
+	}
+
+	t.Run("parsed type constraint", func(t *testing.T) {
+		expr, diags := hclsyntax.ParseExpression([]byte(`object({
+			network = optional(object({
+				name    = optional(string, "default")
+				subnets = optional(list(object({
+					cidr   = string
+					public = optional(bool, false)
+				})), [])
+			}), {})
+		})`), "", hcl.InitialPos)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+		_, defaults, diags := TypeConstraintWithDefaults(expr)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+		if diags := defaults.Validate(); len(diags) != 0 {
+			t.Errorf("unexpected diagnostics: %s", diags.Error())
+		}
+	})
+}
+
+func TestDefaults_ApplyAndConvert(t *testing.T) {
+	itemType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name":  cty.String,
+		"count": cty.Number,
+	}, []string{"count"})
+	defaults := &Defaults{
+		Type: cty.Object(map[string]cty.Type{
+			"items": cty.List(itemType),
+		}),
+		Children: map[string]*Defaults{
+			"items": {
+				Type: cty.List(itemType),
+				Children: map[string]*Defaults{
+					"": {
+						Type: itemType,
+						DefaultValues: map[string]cty.Value{
+							"count": cty.NumberIntVal(1),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		got, err := defaults.ApplyAndConvert(cty.ObjectVal(map[string]cty.Value{
+			"items": cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal("a"),
+				}),
+			}),
+		}))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		want := cty.ObjectVal(map[string]cty.Value{
+			"items": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"name":  cty.StringVal("a"),
+					"count": cty.NumberIntVal(1),
+				}),
+			}),
+		})
+		if !cmp.Equal(want, got, valueComparer) {
+			t.Errorf("wrong result\n%s", cmp.Diff(want, got, valueComparer))
+		}
+	})
+
+	t.Run("object for map", func(t *testing.T) {
+		_, defaults := parseTypeWithDefaults(t, `map(object({ a = optional(string, "x") }))`)
+		got, err := defaults.ApplyAndConvert(cty.ObjectVal(map[string]cty.Value{
+			"k1": cty.EmptyObjectVal,
+			"k2": cty.EmptyObjectVal,
+		}))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		want := cty.MapVal(map[string]cty.Value{
+			"k1": cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x")}),
+			"k2": cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x")}),
+		})
+		if !cmp.Equal(want, got, valueComparer) {
+			t.Errorf("wrong result\n%s", cmp.Diff(want, got, valueComparer))
+		}
+	})
+
+	t.Run("invalid element", func(t *testing.T) {
+		_, err := defaults.ApplyAndConvert(cty.ObjectVal(map[string]cty.Value{
+			"items": cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal("a"),
+				}),
+				cty.ObjectVal(map[string]cty.Value{
+					"name":  cty.StringVal("b"),
+					"count": cty.StringVal("many"),
+				}),
+			}),
+		}))
+
+		var applyErr *ApplyError
+		if !errors.As(err, &applyErr) {
+			t.Fatalf("wrong error %#v; want *ApplyError", err)
+		}
+		wantPath := cty.GetAttrPath("items").IndexInt(1).GetAttr("count")
+		if !applyErr.Path.Equals(wantPath) {
+			t.Errorf("wrong path %#v; want %#v", applyErr.Path, wantPath)
+		}
+		if !applyErr.Source.Equals(cty.String) || !applyErr.Target.Equals(cty.Number) {
+			t.Errorf("wrong types %#v and %#v", applyErr.Source, applyErr.Target)
+		}
+		if got, want := err.Error(), `.items[1].count: a number is required`; got != want {
+			t.Errorf("wrong message\ngot:  %s\nwant: %s", got, want)
+		}
+	})
+
+	t.Run("incompatible types", func(t *testing.T) {
+		_, err := defaults.ApplyAndConvert(cty.StringVal("nope"))
+
+		var applyErr *ApplyError
+		if !errors.As(err, &applyErr) {
+			t.Fatalf("wrong error %#v; want *ApplyError", err)
+		}
+		if len(applyErr.Path) != 0 {
+			t.Errorf("unexpected path %#v", applyErr.Path)
+		}
+		if !applyErr.Source.Equals(cty.String) || !applyErr.Target.Equals(defaults.Type) {
+			t.Errorf("wrong types %#v and %#v", applyErr.Source, applyErr.Target)
+		}
+	})
+}
+
+func TestDefaults_ApplyAndConvert_nestedMismatch(t *testing.T) {
+	ty, defaults := parseTypeWithDefaults(t, `object({
+		servers = list(object({
+			name = string
+			tags = optional(list(string), [])
+		}))
+	})`)
+	server := func(name string, tags cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal(name),
+			"tags": tags,
+		})
+	}
+
+	tests := map[string]struct {
+		input      cty.Value
+		wantPath   cty.Path
+		wantSource cty.Type
+		wantTarget cty.Type
+	}{
+		"element of a tuple": {
+			cty.ObjectVal(map[string]cty.Value{
+				"servers": cty.TupleVal([]cty.Value{
+					server("a", cty.ListValEmpty(cty.String)),
+					server("b", cty.ListVal([]cty.Value{cty.StringVal("x")})),
+					server("c", cty.ObjectVal(map[string]cty.Value{
+						"env": cty.StringVal("prod"),
+					})),
+				}),
+			}),
+			cty.GetAttrPath("servers").IndexInt(2).GetAttr("tags"),
+			cty.Object(map[string]cty.Type{"env": cty.String}),
+			cty.List(cty.String),
+		},
+		"element of a list": {
+			cty.ObjectVal(map[string]cty.Value{
+				"servers": cty.ListVal([]cty.Value{
+					server("a", cty.True),
+					server("b", cty.True),
+				}),
+			}),
+			cty.GetAttrPath("servers").IndexInt(0).GetAttr("tags"),
+			cty.Bool,
+			cty.List(cty.String),
+		},
+		"missing required attribute": {
+			cty.ObjectVal(map[string]cty.Value{
+				"servers": cty.TupleVal([]cty.Value{
+					server("a", cty.ListValEmpty(cty.String)),
+					cty.ObjectVal(map[string]cty.Value{
+						"tags": cty.ListValEmpty(cty.String),
+					}),
+				}),
+			}),
+			cty.GetAttrPath("servers").IndexInt(1),
+			cty.Object(map[string]cty.Type{"tags": cty.List(cty.String)}),
+			ty.AttributeType("servers").ElementType(),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := defaults.ApplyAndConvert(test.input)
+
+			var applyErr *ApplyError
+			if !errors.As(err, &applyErr) {
+				t.Fatalf("wrong error %#v; want *ApplyError", err)
+			}
+			if !applyErr.Path.Equals(test.wantPath) {
+				t.Errorf("wrong path %q; want %q", formatApplyPath(applyErr.Path), formatApplyPath(test.wantPath))
+			}
+			if !applyErr.Source.Equals(test.wantSource) || !applyErr.Target.Equals(test.wantTarget) {
+				t.Errorf("wrong types %#v and %#v", applyErr.Source, applyErr.Target)
+			}
+			if want := formatApplyPath(test.wantPath) + ": "; !strings.HasPrefix(err.Error(), want) {
+				t.Errorf("message %q does not start with %q", err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestDefaults_Merge(t *testing.T) {
+	itemType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name":  cty.String,
+		"count": cty.Number,
+	}, []string{"name", "count"})
+	rootType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"enabled": cty.Bool,
+		"region":  cty.String,
+		"items":   cty.List(itemType),
+	}, []string{"enabled", "region", "items"})
+
+	testCases := map[string]struct {
+		defaults *Defaults
+		other    *Defaults
+		want     *Defaults
+		wantErr  string
+	}{
+		"both nil": {},
+		"nil receiver": {
+			other: &Defaults{
+				Type: rootType,
+				DefaultValues: map[string]cty.Value{
+					"enabled": cty.True,
+				},
+			},
+			want: &Defaults{
+				Type: rootType,
+				DefaultValues: map[string]cty.Value{
+					"enabled": cty.True,
+				},
+			},
+		},
+		"nil other": {
+			defaults: &Defaults{
+				Type: rootType,
+				DefaultValues: map[string]cty.Value{
+					"enabled": cty.True,
+				},
+			},
+			want: &Defaults{
+				Type: rootType,
+				DefaultValues: map[string]cty.Value{
+					"enabled": cty.True,
+				},
+			},
+		},
+		"override and add values": {
+			defaults: &Defaults{
+				Type: rootType,
+				DefaultValues: map[string]cty.Value{
+					"enabled": cty.True,
+					"region":  cty.StringVal("us-east-1"),
+				},
+			},
+			other: &Defaults{
+				Type: rootType,
+				DefaultValues: map[string]cty.Value{
+					"region": cty.StringVal("eu-west-1"),
+					"items":  cty.ListValEmpty(itemType),
+				},
+			},
+			want: &Defaults{
+				Type: rootType,
+				DefaultValues: map[string]cty.Value{
+					"enabled": cty.True,
+					"region":  cty.StringVal("eu-west-1"),
+					"items":   cty.ListValEmpty(itemType),
+				},
+			},
+		},
+		"merge children": {
+			defaults: &Defaults{
+				Type: rootType,
+				Children: map[string]*Defaults{
+					"items": {
+						Type: cty.List(itemType),
+						Children: map[string]*Defaults{
+							"": {
+								Type: itemType,
+								DefaultValues: map[string]cty.Value{
+									"name": cty.StringVal("unnamed"),
+								},
+							},
+						},
+					},
+				},
+			},
+			other: &Defaults{
+				Type: rootType,
+				Children: map[string]*Defaults{
+					"items": {
+						Type: cty.List(itemType),
+						Children: map[string]*Defaults{
+							"": {
+								Type: itemType,
+								DefaultValues: map[string]cty.Value{
+									"count": cty.NumberIntVal(1),
+								},
+							},
+						},
+					},
+				},
+			},
+			want: &Defaults{
+				Type: rootType,
+				Children: map[string]*Defaults{
+					"items": {
+						Type: cty.List(itemType),
+						Children: map[string]*Defaults{
+							"": {
+								Type: itemType,
+								DefaultValues: map[string]cty.Value{
+									"name":  cty.StringVal("unnamed"),
+									"count": cty.NumberIntVal(1),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"type mismatch": {
+			defaults: &Defaults{
+				Type: rootType,
+				Children: map[string]*Defaults{
+					"items": {
+						Type: cty.List(itemType),
+						Children: map[string]*Defaults{
+							"": {
+								Type: itemType,
+							},
+						},
+					},
+				},
+			},
+			other: &Defaults{
+				Type: rootType,
+				Children: map[string]*Defaults{
+					"items": {
+						Type: cty.List(itemType),
+						Children: map[string]*Defaults{
+							"": {
+								Type: cty.EmptyObject,
+							},
+						},
+					},
+				},
+			},
+			wantErr: `cannot merge defaults for root.items[""]: type object({}) does not match object({count=number,name=string})`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.defaults.Merge(tc.other)
+			if tc.wantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success\nwant error: %s", tc.wantErr)
+				}
+				if got := err.Error(); got != tc.wantErr {
+					t.Errorf("wrong error\ngot:  %s\nwant: %s", got, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("does not modify inputs", func(t *testing.T) {
+		defaults := &Defaults{
+			Type: rootType,
+			DefaultValues: map[string]cty.Value{
+				"enabled": cty.True,
+			},
+		}
+		other := &Defaults{
+			Type: rootType,
+			DefaultValues: map[string]cty.Value{
+				"region": cty.StringVal("eu-west-1"),
+			},
+		}
+		got, err := defaults.Merge(other)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got.DefaultValues["items"] = cty.ListValEmpty(itemType)
+		if len(defaults.DefaultValues) != 1 || len(other.DefaultValues) != 1 {
+			t.Errorf("inputs were modified\ndefaults: %#v\nother:    %#v", defaults.DefaultValues, other.DefaultValues)
+		}
+	})
+}
+
+func TestDefaults_Clone(t *testing.T) {
+	itemType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name": cty.String,
+	}, []string{"name"})
+	rootType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"region": cty.String,
+		"items":  cty.List(itemType),
+	}, []string{"region", "items"})
+
+	base := &Defaults{
+		Type: rootType,
+		DefaultValues: map[string]cty.Value{
+			"region": cty.StringVal("us-east-1"),
+		},
+		Children: map[string]*Defaults{
+			"items": {
+				Type: cty.List(itemType),
+				Children: map[string]*Defaults{
+					"": {
+						Type: itemType,
+						DefaultValues: map[string]cty.Value{
+							"name": cty.StringVal("unnamed"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var d *Defaults
+		if got := d.Clone(); got != nil {
+			t.Errorf("wrong result %#v; want nil", got)
+		}
+	})
+
+	t.Run("equal", func(t *testing.T) {
+		got := base.Clone()
+		if got == base {
+			t.Fatalf("result is the receiver")
+		}
+		if !got.Equal(base) {
+			t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, base)
+		}
+	})
+
+	t.Run("independent", func(t *testing.T) {
+		got := base.Clone()
+		got.DefaultValues["region"] = cty.StringVal("eu-west-1")
+		got.Children["items"].Children[""].DefaultValues["name"] = cty.StringVal("override")
+		got.Children["other"] = &Defaults{Type: cty.String}
+
+		if got, want := base.DefaultValues["region"], cty.StringVal("us-east-1"); !got.RawEquals(want) {
+			t.Errorf("base default was modified: %#v", got)
+		}
+		if got, want := base.Children["items"].Children[""].DefaultValues["name"], cty.StringVal("unnamed"); !got.RawEquals(want) {
+			t.Errorf("base child default was modified: %#v", got)
+		}
+		if _, ok := base.Children["other"]; ok {
+			t.Errorf("base children were modified")
+		}
+	})
+}
+
+func TestDefaults_Prune(t *testing.T) {
+	itemType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name": cty.String,
+	}, []string{"name"})
+	metaType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"labels": cty.Map(itemType),
+		"owner":  itemType,
+	}, []string{"labels", "owner"})
+	rootType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"region": cty.String,
+		"items":  cty.List(itemType),
+		"meta":   metaType,
+	}, []string{"region", "items", "meta"})
+
+	items := &Defaults{
+		Type: cty.List(itemType),
+		Children: map[string]*Defaults{
+			"": {
+				Type: itemType,
+				DefaultValues: map[string]cty.Value{
+					"name": cty.StringVal("unnamed"),
+				},
+			},
+		},
+	}
+	meta := &Defaults{
+		Type: metaType,
+		Children: map[string]*Defaults{
+			"labels": {
+				Type: cty.Map(itemType),
+				Children: map[string]*Defaults{
+					"": {
+						Type:          itemType,
+						DefaultValues: map[string]cty.Value{},
+					},
+				},
+			},
+			"owner": nil,
+		},
+	}
+	defaults := &Defaults{
+		Type: rootType,
+		DefaultValues: map[string]cty.Value{
+			"region": cty.StringVal("us-east-1"),
+		},
+		Children: map[string]*Defaults{
+			"items": items,
+			"meta":  meta,
+		},
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var d *Defaults
+		if got := d.Prune(); got != nil {
+			t.Errorf("wrong result %#v; want nil", got)
+		}
+	})
+
+	t.Run("removes subtrees without defaults", func(t *testing.T) {
+		got := defaults.Prune()
+		want := &Defaults{
+			Type: rootType,
+			DefaultValues: map[string]cty.Value{
+				"region": cty.StringVal("us-east-1"),
+			},
+			Children: map[string]*Defaults{
+				"items": items,
+			},
+		}
+		if !got.Equal(want) {
+			t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+		}
+
+		val := cty.ObjectVal(map[string]cty.Value{
+			"items": cty.ListVal([]cty.Value{cty.EmptyObjectVal}),
+			"meta": cty.ObjectVal(map[string]cty.Value{
+				"labels": cty.MapVal(map[string]cty.Value{
+					"a": cty.EmptyObjectVal,
+				}),
+			}),
+		})
+		if got, want := got.Apply(val), defaults.Apply(val); !got.RawEquals(want) {
+			t.Errorf("pruned tree gives a different result\ngot:  %#v\nwant: %#v", got, want)
+		}
+	})
+
+	t.Run("keeps default expressions", func(t *testing.T) {
+		expr, diags := hclsyntax.ParseExpression([]byte(`{}`), "", hcl.InitialPos)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+		d := &Defaults{
+			Type: rootType,
+			Children: map[string]*Defaults{
+				"meta": {
+					Type: metaType,
+					DefaultExprs: map[string]hcl.Expression{
+						"owner": expr,
+					},
+				},
+			},
+		}
+		if got := d.Prune(); !got.Equal(d) {
+			t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, d)
+		}
+	})
+
+	t.Run("no defaults", func(t *testing.T) {
+		if got, want := meta.Prune(), (&Defaults{Type: metaType}); !got.Equal(want) {
+			t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+		}
+	})
+}
+
+func TestDefaults_ApplyWithContext(t *testing.T) {
+	parseExpr := func(src string) hcl.Expression {
+		expr, diags := hclsyntax.ParseExpression([]byte(src), "", hcl.InitialPos)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors parsing %q: %s", src, diags.Error())
+		}
+		return expr
+	}
+
+	bucketType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name":    cty.String,
+		"region":  cty.String,
+		"logs":    cty.String,
+		"enabled": cty.Bool,
+	}, []string{"region", "logs", "enabled"})
+	bucketDefaults := &Defaults{
+		Type: bucketType,
+		DefaultValues: map[string]cty.Value{
+			"region":  cty.StringVal("static"),
+			"enabled": cty.True,
+		},
+		DefaultExprs: map[string]hcl.Expression{
+			"region": parseExpr(`var.region`),
+			"logs":   parseExpr(`"${name}-logs-${enabled}"`),
+		},
+	}
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{
+				"region": cty.StringVal("us-east-1"),
+			}),
+		},
+	}
+
+	testCases := map[string]struct {
+		defaults  *Defaults
+		value     cty.Value
+		ctx       *hcl.EvalContext
+		want      cty.Value
+		wantDiags int
+	}{
+		"missing attributes": {
+			defaults: bucketDefaults,
+			value: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("b"),
+			}),
+			ctx: ctx,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name":    cty.StringVal("b"),
+				"region":  cty.StringVal("us-east-1"),
+				"logs":    cty.StringVal("b-logs-true"),
+				"enabled": cty.True,
+			}),
+		},
+		"given attributes": {
+			defaults: bucketDefaults,
+			value: cty.ObjectVal(map[string]cty.Value{
+				"name":    cty.StringVal("b"),
+				"region":  cty.StringVal("eu-west-1"),
+				"logs":    cty.NullVal(cty.String),
+				"enabled": cty.False,
+			}),
+			ctx: ctx,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name":    cty.StringVal("b"),
+				"region":  cty.StringVal("eu-west-1"),
+				"logs":    cty.StringVal("b-logs-false"),
+				"enabled": cty.False,
+			}),
+		},
+		"evaluation errors": {
+			defaults: bucketDefaults,
+			value: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("b"),
+			}),
+			ctx: nil,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name":    cty.StringVal("b"),
+				"logs":    cty.StringVal("b-logs-true"),
+				"enabled": cty.True,
+			}),
+			wantDiags: 1,
+		},
+		"nested in collection": {
+			defaults: &Defaults{
+				Type: cty.List(bucketType),
+				Children: map[string]*Defaults{
+					"": bucketDefaults,
+				},
+			},
+			value: cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal("a"),
+				}),
+				cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal("b"),
+				}),
+			}),
+			ctx: ctx,
+			want: cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"name":    cty.StringVal("a"),
+					"region":  cty.StringVal("us-east-1"),
+					"logs":    cty.StringVal("a-logs-true"),
+					"enabled": cty.True,
+				}),
+				cty.ObjectVal(map[string]cty.Value{
+					"name":    cty.StringVal("b"),
+					"region":  cty.StringVal("us-east-1"),
+					"logs":    cty.StringVal("b-logs-true"),
+					"enabled": cty.True,
+				}),
+			}),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, diags := tc.defaults.ApplyWithContext(tc.value, tc.ctx)
+			if len(diags) != tc.wantDiags {
+				t.Errorf("got %d diagnostics; want %d\n%s", len(diags), tc.wantDiags, diags.Error())
+			}
+			if !cmp.Equal(tc.want, got, valueComparer) {
+				t.Errorf("wrong result\n%s", cmp.Diff(tc.want, got, valueComparer))
+			}
+		})
+	}
+
+	t.Run("ignored by Apply", func(t *testing.T) {
+		got := bucketDefaults.Apply(cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal("b"),
+		}))
+		want := cty.ObjectVal(map[string]cty.Value{
+			"name":    cty.StringVal("b"),
+			"region":  cty.StringVal("static"),
+			"enabled": cty.True,
+		})
+		if !cmp.Equal(want, got, valueComparer) {
+			t.Errorf("wrong result\n%s", cmp.Diff(want, got, valueComparer))
+		}
+	})
+}
+
+func TestDefaults_DefaultForPath(t *testing.T) {
+	_, defaults := parseTypeWithDefaults(t, `object({
+		name    = optional(string, "web")
+		servers = optional(list(object({
+			port = optional(number, 80)
+			tags = optional(map(object({
+				value = optional(string, "none")
+			})))
+		})))
+		pair    = optional(tuple([string, object({ enabled = optional(bool, true) })]))
+		other   = optional(string)
+	})`)
+
+	tests := map[string]struct {
+		path cty.Path
+		want cty.Value
+	}{
+		"root attribute": {
+			cty.GetAttrPath("name"),
+			cty.StringVal("web"),
+		},
+		"list element attribute": {
+			cty.GetAttrPath("servers").IndexInt(2).GetAttr("port"),
+			cty.NumberIntVal(80),
+		},
+		"unknown list index": {
+			cty.GetAttrPath("servers").Index(cty.UnknownVal(cty.Number)).GetAttr("port"),
+			cty.NumberIntVal(80),
+		},
+		"map element attribute": {
+			cty.GetAttrPath("servers").IndexInt(0).GetAttr("tags").IndexString("env").GetAttr("value"),
+			cty.StringVal("none"),
+		},
+		"tuple element attribute": {
+			cty.GetAttrPath("pair").IndexInt(1).GetAttr("enabled"),
+			cty.True,
+		},
+		"attribute as index": {
+			cty.Path{cty.IndexStep{Key: cty.StringVal("name")}},
+			cty.StringVal("web"),
+		},
+		"attribute without default": {
+			cty.GetAttrPath("other"),
+			cty.NilVal,
+		},
+		"unknown attribute": {
+			cty.GetAttrPath("nope"),
+			cty.NilVal,
+		},
+		"tuple element out of range": {
+			cty.GetAttrPath("pair").IndexInt(2).GetAttr("enabled"),
+			cty.NilVal,
+		},
+		"tuple element without defaults": {
+			cty.GetAttrPath("pair").IndexInt(0).GetAttr("enabled"),
+			cty.NilVal,
+		},
+		"not an attribute": {
+			cty.GetAttrPath("servers").IndexInt(0),
+			cty.NilVal,
+		},
+		"empty path": {
+			nil,
+			cty.NilVal,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := defaults.DefaultForPath(test.path)
+			if wantOk := test.want != cty.NilVal; ok != wantOk {
+				t.Fatalf("wrong ok %t; want %t", ok, wantOk)
+			}
+			if ok && !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var d *Defaults
+		if _, ok := d.DefaultForPath(cty.GetAttrPath("name")); ok {
+			t.Errorf("unexpected default")
+		}
+	})
+}
+
+func TestDefaults_DefaultObject(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want cty.Value
+	}{
+		"flat": {
+			`object({
+				name = string
+				port = optional(number, 80)
+				tags = optional(list(string), ["a"])
+			})`,
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.NullVal(cty.String),
+				"port": cty.NumberIntVal(80),
+				"tags": cty.ListVal([]cty.Value{cty.StringVal("a")}),
+			}),
+		},
+		"nested objects": {
+			`object({
+				name    = optional(string, "web")
+				network = optional(object({
+					subnet = string
+					public = optional(bool, false)
+					dns    = optional(object({
+						ttl = optional(number, 300)
+					}))
+				}))
+				servers = optional(list(object({
+					port = optional(number, 80)
+				})))
+				plain   = optional(object({ value = string }))
+			})`,
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.NullVal(cty.String),
+					"public": cty.False,
+					"dns": cty.ObjectVal(map[string]cty.Value{
+						"ttl": cty.NumberIntVal(300),
+					}),
+				}),
+				"servers": cty.NullVal(cty.List(cty.Object(map[string]cty.Type{"port": cty.Number}))),
+				"plain":   cty.NullVal(cty.Object(map[string]cty.Type{"value": cty.String})),
+			}),
+		},
+		"default with nested defaults": {
+			`object({
+				network = optional(object({
+					subnet = optional(string, "10.0.0.0/8")
+					public = optional(bool, false)
+				}), {})
+			})`,
+			cty.ObjectVal(map[string]cty.Value{
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.StringVal("10.0.0.0/8"),
+					"public": cty.False,
+				}),
+			}),
+		},
+		"not an object": {
+			`list(object({ port = optional(number, 80) }))`,
+			cty.NullVal(cty.List(cty.Object(map[string]cty.Type{"port": cty.Number}))),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, defaults := parseTypeWithDefaults(t, test.src)
+			got := defaults.DefaultObject()
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var d *Defaults
+		if got := d.DefaultObject(); got != cty.NilVal {
+			t.Errorf("wrong result %#v; want cty.NilVal", got)
+		}
+	})
+}
+
+func TestDefaults_Unset(t *testing.T) {
+	ty, defaults := parseTypeWithDefaults(t, `object({
+		name    = string
+		port    = optional(number, 80)
+		tags    = optional(list(string), [])
+		network = optional(object({
+			subnet = optional(string, "10.0.0.0/8")
+			public = optional(bool, false)
+		}), {})
+		servers = optional(list(object({
+			host = string
+			tls  = optional(bool, true)
+		})))
+	})`)
+	serverType := ty.AttributeType("servers").ElementType().WithoutOptionalAttributesDeep()
+	networkType := ty.AttributeType("network").WithoutOptionalAttributesDeep()
+
+	tests := map[string]struct {
+		value cty.Value
+		want  cty.Value
+	}{
+		"all defaults": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NumberIntVal(80),
+				"tags": cty.ListValEmpty(cty.String),
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.StringVal("10.0.0.0/8"),
+					"public": cty.False,
+				}),
+				"servers": cty.NullVal(cty.List(serverType)),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name":    cty.StringVal("web"),
+				"port":    cty.NullVal(cty.Number),
+				"tags":    cty.NullVal(cty.List(cty.String)),
+				"network": cty.NullVal(networkType),
+				"servers": cty.NullVal(cty.List(serverType)),
+			}),
+		},
+		"non-default values": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NumberIntVal(8080),
+				"tags": cty.ListVal([]cty.Value{cty.StringVal("a")}),
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.StringVal("10.0.0.0/8"),
+					"public": cty.True,
+				}),
+				"servers": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"host": cty.StringVal("a"),
+						"tls":  cty.True,
+					}),
+					cty.ObjectVal(map[string]cty.Value{
+						"host": cty.StringVal("b"),
+						"tls":  cty.False,
+					}),
+				}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NumberIntVal(8080),
+				"tags": cty.ListVal([]cty.Value{cty.StringVal("a")}),
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.NullVal(cty.String),
+					"public": cty.True,
+				}),
+				"servers": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"host": cty.StringVal("a"),
+						"tls":  cty.NullVal(cty.Bool),
+					}),
+					cty.ObjectVal(map[string]cty.Value{
+						"host": cty.StringVal("b"),
+						"tls":  cty.False,
+					}),
+				}),
+			}),
+		},
+		"unconverted value": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NumberIntVal(80),
+				"tags": cty.EmptyTupleVal,
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NullVal(cty.Number),
+				"tags": cty.NullVal(cty.EmptyTuple),
+			}),
+		},
+		"marks": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NumberIntVal(80).Mark("sensitive"),
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.StringVal("10.0.0.0/8"),
+					"public": cty.True.Mark("sensitive"),
+				}).Mark("reviewed"),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NullVal(cty.Number).Mark("sensitive"),
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.NullVal(cty.String),
+					"public": cty.True.Mark("sensitive"),
+				}).Mark("reviewed"),
+			}),
+		},
+		"unknown values": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.UnknownVal(cty.Number),
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.StringVal("10.0.0.0/8"),
+					"public": cty.UnknownVal(cty.Bool),
+				}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.UnknownVal(cty.Number),
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.NullVal(cty.String),
+					"public": cty.UnknownVal(cty.Bool),
+				}),
+			}),
+		},
+		"null": {
+			cty.NullVal(ty.WithoutOptionalAttributesDeep()),
+			cty.NullVal(ty.WithoutOptionalAttributesDeep()),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := defaults.Unset(test.value)
+			if !cmp.Equal(test.want, got, valueComparer) {
+				t.Errorf("wrong result\n%s", cmp.Diff(test.want, got, valueComparer))
+			}
+		})
+	}
+}
+
+func TestDefaults_Unset_roundTrip(t *testing.T) {
+	_, defaults := parseTypeWithDefaults(t, `object({
+		name    = string
+		port    = optional(number, 80)
+		servers = optional(map(object({
+			host = string
+			tls  = optional(bool, true)
+		})), {})
+	})`)
+
+	val := cty.ObjectVal(map[string]cty.Value{
+		"name": cty.StringVal("web"),
+		"servers": cty.ObjectVal(map[string]cty.Value{
+			"a": cty.ObjectVal(map[string]cty.Value{
+				"host": cty.StringVal("a"),
+				"tls":  cty.False,
+			}),
+			"b": cty.ObjectVal(map[string]cty.Value{
+				"host": cty.StringVal("b"),
+			}),
+		}),
+	})
+	applied, err := defaults.ApplyAndConvert(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := defaults.Unset(applied)
+	want := cty.ObjectVal(map[string]cty.Value{
+		"name": cty.StringVal("web"),
+		"port": cty.NullVal(cty.Number),
+		"servers": cty.MapVal(map[string]cty.Value{
+			"a": cty.ObjectVal(map[string]cty.Value{
+				"host": cty.StringVal("a"),
+				"tls":  cty.False,
+			}),
+			"b": cty.ObjectVal(map[string]cty.Value{
+				"host": cty.StringVal("b"),
+				"tls":  cty.NullVal(cty.Bool),
+			}),
+		}),
+	})
+	if !cmp.Equal(want, got, valueComparer) {
+		t.Errorf("wrong result\n%s", cmp.Diff(want, got, valueComparer))
+	}
+	if reapplied, err := defaults.ApplyAndConvert(got); err != nil || !reapplied.RawEquals(applied) {
+		t.Errorf("applying defaults again gave %#v, %v; want %#v", reapplied, err, applied)
+	}
+}
+
+func TestDefaults_Walk(t *testing.T) {
+	itemType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name": cty.String,
+	}, []string{"name"})
+	pairType := cty.Tuple([]cty.Type{cty.String, itemType})
+	rootType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"list": cty.List(itemType),
+		"map":  cty.Map(itemType),
+		"set":  cty.Set(itemType),
+		"pair": pairType,
+	}, []string{"list", "map", "set", "pair"})
+	item := &Defaults{
+		Type: itemType,
+		DefaultValues: map[string]cty.Value{
+			"name": cty.StringVal("unnamed"),
+		},
+	}
+	defaults := &Defaults{
+		Type: rootType,
+		Children: map[string]*Defaults{
+			"list": {
+				Type:     cty.List(itemType),
+				Children: map[string]*Defaults{"": item},
+			},
+			"map": {
+				Type:     cty.Map(itemType),
+				Children: map[string]*Defaults{"": item},
+			},
+			"set": {
+				Type:     cty.Set(itemType),
+				Children: map[string]*Defaults{"": item},
+			},
+			"pair": {
+				Type:     pairType,
+				Children: map[string]*Defaults{"1": item},
+			},
+		},
+	}
+
+	var gotPaths []cty.Path
+	var gotNodes []*Defaults
+	err := defaults.Walk(func(path cty.Path, d *Defaults) error {
+		gotPaths = append(gotPaths, path)
+		gotNodes = append(gotNodes, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	wantPaths := []cty.Path{
+		nil,
+		cty.GetAttrPath("list"),
+		cty.GetAttrPath("list").Index(cty.UnknownVal(cty.Number)),
+		cty.GetAttrPath("map"),
+		cty.GetAttrPath("map").Index(cty.UnknownVal(cty.String)),
+		cty.GetAttrPath("pair"),
+		cty.GetAttrPath("pair").IndexInt(1),
+		cty.GetAttrPath("set"),
+		cty.GetAttrPath("set").Index(cty.UnknownVal(itemType)),
+	}
+	if len(gotPaths) != len(wantPaths) {
+		t.Fatalf("wrong number of nodes %d; want %d\n%#v", len(gotPaths), len(wantPaths), gotPaths)
+	}
+	for i := range wantPaths {
+		if !gotPaths[i].Equals(wantPaths[i]) {
+			t.Errorf("wrong path %d\ngot:  %#v\nwant: %#v", i, gotPaths[i], wantPaths[i])
+		}
+	}
+	if gotNodes[0] != defaults || gotNodes[2] != item {
+		t.Errorf("wrong nodes visited")
+	}
+
+	t.Run("abort", func(t *testing.T) {
+		stop := errors.New("stop")
+		count := 0
+		err := defaults.Walk(func(path cty.Path, d *Defaults) error {
+			count++
+			if d == item {
+				return stop
+			}
+			return nil
+		})
+		if err != stop {
+			t.Errorf("wrong error %#v; want %#v", err, stop)
+		}
+		if count != 3 {
+			t.Errorf("visited %d nodes; want 3", count)
+		}
+	})
+}
+
+func TestDefaults_Apply_cyclic(t *testing.T) {
+	// Validate rejects cyclic trees, but applying one anyway must not
+	// recurse forever.
+	ty := cty.Object(map[string]cty.Type{
+		"a": cty.String,
+	})
+	defaults := &Defaults{
+		Type: ty,
+	}
+	defaults.Children = map[string]*Defaults{
+		"a": defaults,
+	}
+	val := cty.ObjectVal(map[string]cty.Value{
+		"a": cty.StringVal("x"),
+	})
+
+	if got := defaults.Apply(val); !got.RawEquals(val) {
+		t.Errorf("wrong result from Apply\ngot:  %#v\nwant: %#v", got, val)
+	}
+	if got := defaults.ApplyFillingNulls(val); !got.RawEquals(val) {
+		t.Errorf("wrong result from ApplyFillingNulls\ngot:  %#v\nwant: %#v", got, val)
+	}
+}
+
+func TestDefaults_maxDepth(t *testing.T) {
+	// deepDefaults returns defaults for an object type nested to the given
+	// depth through attribute "a", with a default for attribute "b" at
+	// every level, along with a value that has no attributes set other
+	// than those needed to reach the same depth.
+	deepDefaults := func(depth int) (*Defaults, cty.Value) {
+		ty := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+			"b": cty.Bool,
+		}, []string{"b"})
+		defaults := &Defaults{
+			Type: ty,
+			DefaultValues: map[string]cty.Value{
+				"b": cty.True,
+			},
+		}
+		val := cty.EmptyObjectVal
+		for i := 1; i < depth; i++ {
+			ty = cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+				"a": ty,
+				"b": cty.Bool,
+			}, []string{"b"})
+			defaults = &Defaults{
+				Type: ty,
+				DefaultValues: map[string]cty.Value{
+					"b": cty.True,
+				},
+				Children: map[string]*Defaults{
+					"a": defaults,
+				},
+			}
+			val = cty.ObjectVal(map[string]cty.Value{
+				"a": val,
+			})
+		}
+		return defaults, val
+	}
+
+	t.Run("within default limit", func(t *testing.T) {
+		defaults, val := deepDefaults(DefaultMaxApplyDepth)
+		if _, err := defaults.ApplyAndConvert(val); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("exceeds default limit", func(t *testing.T) {
+		defaults, val := deepDefaults(DefaultMaxApplyDepth + 5)
+		_, err := defaults.ApplyAndConvert(val)
+
+		var applyErr *ApplyError
+		if !errors.As(err, &applyErr) {
+			t.Fatalf("wrong error %#v; want *ApplyError", err)
+		}
+		if got, want := len(applyErr.Path), DefaultMaxApplyDepth+1; got != want {
+			t.Errorf("wrong path length %d; want %d", got, want)
+		}
+		if got, want := applyErr.Msg, "value is nested more deeply than the maximum of 1000 levels"; got != want {
+			t.Errorf("wrong message\ngot:  %s\nwant: %s", got, want)
+		}
+
+		// Apply is permissive, so it just stops applying defaults.
+		got := defaults.Apply(val)
+		if !got.GetAttr("b").RawEquals(cty.True) {
+			t.Errorf("defaults not applied at the top level")
+		}
+
+		// The other methods that return diagnostics report it.
+		_, diags := defaults.ApplyContext(context.Background(), val)
+		if len(diags) != 1 || diags[0].Summary != "Value nested too deeply" {
+			t.Errorf("wrong diagnostics from ApplyContext\n%s", diags.Error())
+		}
+		_, diags = defaults.ApplyWithContext(val, nil)
+		if len(diags) != 1 || diags[0].Summary != "Value nested too deeply" {
+			t.Errorf("wrong diagnostics from ApplyWithContext\n%s", diags.Error())
+		}
+	})
+
+	t.Run("custom limit", func(t *testing.T) {
+		defaults, val := deepDefaults(20)
+		_, diags := defaults.ApplyWithOptions(val, ApplyOptions{
+			MaxDepth: 10,
+		})
+		if len(diags) != 1 {
+			t.Fatalf("got %d diagnostics; want 1\n%s", len(diags), diags.Error())
+		}
+		if got, want := diags[0].Detail, "Unsuitable value at .a.a.a.a.a.a.a.a.a.a.a: value is nested more deeply than the maximum of 10 levels."; got != want {
+			t.Errorf("wrong detail\ngot:  %s\nwant: %s", got, want)
+		}
+
+		_, diags = defaults.ApplyWithOptions(val, ApplyOptions{})
+		if len(diags) != 0 {
+			t.Errorf("unexpected diagnostics with default limit: %s", diags.Error())
+		}
+	})
+}
+
+func TestDefaults_ApplyContext(t *testing.T) {
+	itemType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name": cty.String,
+	}, []string{"name"})
+	defaults := &Defaults{
+		Type: cty.List(itemType),
+		Children: map[string]*Defaults{
+			"": {
+				Type: itemType,
+				DefaultValues: map[string]cty.Value{
+					"name": cty.StringVal("unnamed"),
+				},
+			},
+		},
+	}
+	items := make([]cty.Value, 10000)
+	for i := range items {
+		items[i] = cty.EmptyObjectVal
+	}
+	val := cty.TupleVal(items)
+
+	t.Run("not cancelled", func(t *testing.T) {
+		got, diags := defaults.ApplyContext(context.Background(), val)
+		if len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics: %s", diags.Error())
+		}
+		if want := defaults.Apply(val); !got.RawEquals(want) {
+			t.Errorf("result differs from Apply")
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		got, diags := defaults.ApplyContext(ctx, val)
+		if len(diags) != 1 {
+			t.Fatalf("got %d diagnostics; want 1\n%s", len(diags), diags.Error())
+		}
+		if got, want := diags[0].Detail, "Default values were not applied because the operation was cancelled: context canceled."; got != want {
+			t.Errorf("wrong detail\ngot:  %s\nwant: %s", got, want)
+		}
+		if !got.RawEquals(val) {
+			t.Errorf("cancelled result is not the given value")
+		}
+	})
+}
+
+func TestDefaults_Apply_everyCollectionElement(t *testing.T) {
+	tests := map[string]struct {
+		source string
+		value  cty.Value
+		want   cty.Value
+	}{
+		"list": {
+			`list(object({ a = string, b = optional(string, "def") }))`,
+			cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x")}),
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("y")}),
+			}),
+			cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x"), "b": cty.StringVal("def")}),
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("y"), "b": cty.StringVal("def")}),
+			}),
+		},
+		"tuple for list": {
+			`list(object({ a = string, b = optional(string, "def") }))`,
+			cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x")}),
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("y"), "b": cty.StringVal("z")}),
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("w")}),
+			}),
+			cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x"), "b": cty.StringVal("def")}),
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("y"), "b": cty.StringVal("z")}),
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("w"), "b": cty.StringVal("def")}),
+			}),
+		},
+		"object for map": {
+			`map(object({ a = optional(string, "x"), b = optional(number) }))`,
+			cty.ObjectVal(map[string]cty.Value{
+				"k1": cty.EmptyObjectVal,
+				"k2": cty.ObjectVal(map[string]cty.Value{"b": cty.NumberIntVal(1)}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"k1": cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x")}),
+				"k2": cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x"), "b": cty.NumberIntVal(1)}),
+			}),
+		},
+		"set": {
+			`set(object({ a = string, b = optional(string, "def") }))`,
+			cty.SetVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x"), "b": cty.NullVal(cty.String)}),
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("y"), "b": cty.NullVal(cty.String)}),
+			}),
+			cty.SetVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("x"), "b": cty.StringVal("def")}),
+				cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("y"), "b": cty.StringVal("def")}),
+			}),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, defaults := parseTypeWithDefaults(t, test.source)
+			got := defaults.Apply(test.value)
+			if !cmp.Equal(test.want, got, valueComparer) {
+				t.Errorf("wrong result\n%s", cmp.Diff(test.want, got, valueComparer))
+			}
+		})
+	}
+}
+
+func TestDefaults_ApplyAndConvert_absentOptionals(t *testing.T) {
+	_, defaults := parseTypeWithDefaults(t, `object({
+		name  = optional(string)
+		port  = optional(number)
+		tags  = optional(list(string))
+		env   = optional(map(bool))
+		owner = optional(object({ id = string }))
+		any   = optional(any)
+		tls   = optional(object({ cert = optional(string), port = optional(number, 443) }), {})
+	})`)
+
+	tests := map[string]cty.Value{
+		"absent": cty.EmptyObjectVal,
+		"explicitly null": cty.ObjectVal(map[string]cty.Value{
+			"name":  cty.NullVal(cty.DynamicPseudoType),
+			"port":  cty.NullVal(cty.DynamicPseudoType),
+			"tags":  cty.NullVal(cty.DynamicPseudoType),
+			"env":   cty.NullVal(cty.DynamicPseudoType),
+			"owner": cty.NullVal(cty.DynamicPseudoType),
+		}),
+	}
+	want := cty.ObjectVal(map[string]cty.Value{
+		"name":  cty.NullVal(cty.String),
+		"port":  cty.NullVal(cty.Number),
+		"tags":  cty.NullVal(cty.List(cty.String)),
+		"env":   cty.NullVal(cty.Map(cty.Bool)),
+		"owner": cty.NullVal(cty.Object(map[string]cty.Type{"id": cty.String})),
+		"any":   cty.NullVal(cty.DynamicPseudoType),
+		"tls": cty.ObjectVal(map[string]cty.Value{
+			"cert": cty.NullVal(cty.String),
+			"port": cty.NumberIntVal(443),
+		}),
+	})
+
+	for name, val := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := defaults.ApplyAndConvert(val)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !got.RawEquals(want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestDefaults_nil(t *testing.T) {
+	var defaults *Defaults
+	val := cty.ObjectVal(map[string]cty.Value{
+		"name": cty.StringVal("a"),
+		"tags": cty.ListVal([]cty.Value{cty.StringVal("b")}),
+	})
+
+	if got := defaults.Apply(val); !got.RawEquals(val) {
+		t.Errorf("wrong result from Apply\ngot:  %#v\nwant: %#v", got, val)
+	}
+	if got := defaults.ApplyFillingNulls(val); !got.RawEquals(val) {
+		t.Errorf("wrong result from ApplyFillingNulls\ngot:  %#v\nwant: %#v", got, val)
+	}
+	if got, err := defaults.ApplyAndConvert(val); err != nil || !got.RawEquals(val) {
+		t.Errorf("wrong result from ApplyAndConvert\ngot:  %#v, %v\nwant: %#v", got, err, val)
+	}
+	if got, diags := defaults.ApplyContext(context.Background(), val); len(diags) != 0 || !got.RawEquals(val) {
+		t.Errorf("wrong result from ApplyContext\ngot:  %#v, %s\nwant: %#v", got, diags.Error(), val)
+	}
+	if got, diags := defaults.ApplyWithContext(val, nil); len(diags) != 0 || !got.RawEquals(val) {
+		t.Errorf("wrong result from ApplyWithContext\ngot:  %#v, %s\nwant: %#v", got, diags.Error(), val)
+	}
+	if got, diags := defaults.ApplyWithOptions(val, ApplyOptions{RejectUnknownAttributes: true}); len(diags) != 0 || !got.RawEquals(val) {
+		t.Errorf("wrong result from ApplyWithOptions\ngot:  %#v, %s\nwant: %#v", got, diags.Error(), val)
+	}
+
+	next := defaults.ApplyIter(val.GetAttr("tags"))
+	if got, ok := next(); !ok || !got.RawEquals(cty.StringVal("b")) {
+		t.Errorf("wrong first element from ApplyIter: %#v, %t", got, ok)
+	}
+	if _, ok := next(); ok {
+		t.Errorf("ApplyIter produced too many elements")
+	}
+}
+
+func TestTypeConstraintWithDefaults_noDefaults(t *testing.T) {
+	tests := map[string]bool{
+		`string`:                                   false,
+		`list(object({ a = string }))`:             false,
+		`object({ a = optional(string) })`:         false,
+		`object({ a = optional(string, "x") })`:    true,
+		`map(object({ a = optional(number, 1) }))`: true,
+	}
+
+	for src, wantDefaults := range tests {
+		t.Run(src, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(src), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("failed to parse: %s", diags)
+			}
+			_, defaults, diags := TypeConstraintWithDefaults(expr)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Error())
+			}
+			if got := defaults != nil; got != wantDefaults {
+				t.Errorf("wrong defaults %#v; want defaults: %t", defaults, wantDefaults)
+			}
+		})
+	}
+}
+
+func TestDefaults_Apply_capsule(t *testing.T) {
+	handleType := cty.Capsule("handle", reflect.TypeOf(0))
+	objType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"handle": handleType,
+		"name":   cty.String,
+	}, []string{"name"})
+	defaults := &Defaults{
+		Type: cty.List(objType),
+		Children: map[string]*Defaults{
+			"": {
+				Type: objType,
+				DefaultValues: map[string]cty.Value{
+					"name": cty.StringVal("x"),
+				},
+				Children: map[string]*Defaults{
+					// Defaults for a capsule type are meaningless, and so
+					// they are ignored.
+					"handle": {
+						Type: handleType,
+						DefaultValues: map[string]cty.Value{
+							"name": cty.StringVal("y"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	handle := 1
+	handleVal := cty.CapsuleVal(handleType, &handle)
+	val := cty.ListVal([]cty.Value{
+		cty.ObjectVal(map[string]cty.Value{
+			"handle": handleVal,
+		}),
+	})
+	want := cty.ListVal([]cty.Value{
+		cty.ObjectVal(map[string]cty.Value{
+			"handle": handleVal,
+			"name":   cty.StringVal("x"),
+		}),
+	})
+
+	got, err := defaults.ApplyAndConvert(val)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !cmp.Equal(want, got, valueComparer) {
+		t.Errorf("wrong result\n%s", cmp.Diff(want, got, valueComparer))
+	}
+
+	// A capsule value given where the defaults expect something else is
+	// also passed through unchanged.
+	if got := defaults.Apply(handleVal); !got.RawEquals(handleVal) {
+		t.Errorf("capsule value was changed\ngot: %#v", got)
+	}
+}
+
+func TestDefaults_ApplyWithOptions_onDefault(t *testing.T) {
+	_, defaults := parseTypeWithDefaults(t, `list(object({
+		name = string
+		port = optional(number, 80)
+		tls  = optional(object({ enabled = optional(bool, false) }), {})
+	}))`)
+	val := cty.TupleVal([]cty.Value{
+		cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal("a"),
+		}),
+		cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal("b"),
+			"port": cty.NumberIntVal(8080),
+			"tls":  cty.ObjectVal(map[string]cty.Value{"enabled": cty.True}),
+		}),
+	})
+
+	got := make(map[string]cty.Value)
+	ret, diags := defaults.ApplyWithOptions(val, ApplyOptions{
+		OnDefault: func(path cty.Path, value cty.Value) {
+			got[formatApplyPath(path)] = value
+		},
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+	want := map[string]cty.Value{
+		"[0].port":        cty.NumberIntVal(80),
+		"[0].tls":         cty.ObjectVal(map[string]cty.Value{"enabled": cty.False}),
+		"[0].tls.enabled": cty.False,
+	}
+	if !cmp.Equal(want, got, valueComparer) {
+		t.Errorf("wrong defaults reported\n%s", cmp.Diff(want, got, valueComparer))
+	}
+
+	wantRet, _ := defaults.ApplyWithDiagnostics(val)
+	if !ret.RawEquals(wantRet) {
+		t.Errorf("result differs from ApplyWithDiagnostics\ngot:  %#v\nwant: %#v", ret, wantRet)
+	}
+}
+
+func TestDefaults_ApplyWithOptions_onDefaultOrder(t *testing.T) {
+	_, defaults := parseTypeWithDefaults(t, `object({
+		zone    = optional(string, "a")
+		name    = optional(string, "web")
+		servers = optional(map(object({
+			port = optional(number, 80)
+			host = optional(string, "localhost")
+		})))
+		tls     = optional(object({ enabled = optional(bool, false) }), {})
+		count   = optional(number, 1)
+	})`)
+	val := cty.ObjectVal(map[string]cty.Value{
+		"servers": cty.MapVal(map[string]cty.Value{
+			"c": cty.ObjectVal(map[string]cty.Value{"port": cty.NullVal(cty.Number), "host": cty.NullVal(cty.String)}),
+			"a": cty.ObjectVal(map[string]cty.Value{"port": cty.NullVal(cty.Number), "host": cty.NullVal(cty.String)}),
+			"b": cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(8080), "host": cty.NullVal(cty.String)}),
+		}),
+	})
+
+	want := []string{
+		`.servers["a"].host`,
+		`.servers["a"].port`,
+		`.servers["b"].host`,
+		`.servers["c"].host`,
+		`.servers["c"].port`,
+		`.count`,
+		`.name`,
+		`.tls.enabled`,
+		`.tls`,
+		`.zone`,
+	}
+	for i := 0; i < 20; i++ {
+		var got []string
+		_, diags := defaults.ApplyWithOptions(val, ApplyOptions{
+			OnDefault: func(path cty.Path, value cty.Value) {
+				got = append(got, formatApplyPath(path))
+			},
+		})
+		if diags.HasErrors() {
+			t.Fatalf("unexpected diagnostics: %s", diags.Error())
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Fatalf("wrong order of defaults on run %d\n%s", i, diff)
+		}
+	}
+}
+
+var benchmarkApplyResult cty.Value
+
+func BenchmarkDefaults_Apply_largeMap(b *testing.B) {
+	elemType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"a": cty.String,
+		"b": cty.String,
+	}, []string{"b"})
+	rootType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"name": cty.String,
+		"tags": cty.Map(elemType),
+	}, []string{"name"})
+
+	elems := make(map[string]cty.Value, 50000)
+	for i := 0; i < 50000; i++ {
+		elems[fmt.Sprintf("k%d", i)] = cty.ObjectVal(map[string]cty.Value{
+			"a": cty.StringVal("x"),
+			"b": cty.StringVal("y"),
+		})
+	}
+	val := cty.ObjectVal(map[string]cty.Value{
+		"tags": cty.MapVal(elems),
+	})
+
+	for name, elemValues := range map[string]map[string]cty.Value{
+		"no element defaults": nil,
+		"element defaults": {
+			"b": cty.StringVal("default"),
+		},
+	} {
+		defaults := &Defaults{
+			Type: rootType,
+			DefaultValues: map[string]cty.Value{
+				"name": cty.StringVal("default"),
+			},
+			Children: map[string]*Defaults{
+				"tags": {
+					Type: cty.Map(elemType),
+					Children: map[string]*Defaults{
+						"": {
+							Type:          elemType,
+							DefaultValues: elemValues,
+						},
+					},
+				},
+			},
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				benchmarkApplyResult = defaults.Apply(val)
+			}
+		})
+	}
+}
+
+func BenchmarkDefaults_ApplyAndConvert_largeList(b *testing.B) {
+	_, defaults := parseTypeWithDefaults(b, `list(object({
+		name = string
+		port = optional(number, 80)
+	}))`)
+
+	for name, elem := range map[string]cty.Value{
+		"exact type": cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal("web"),
+			"port": cty.NumberIntVal(8080),
+		}),
+		"needs conversion": cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal("web"),
+			"port": cty.StringVal("8080"),
+		}),
+	} {
+		elems := make([]cty.Value, 50000)
+		for i := range elems {
+			elems[i] = elem
+		}
+		val := cty.ListVal(elems)
+
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var err error
+				benchmarkApplyResult, err = defaults.ApplyAndConvert(val)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaults_ApplyWithOptions_rejectUnknownAttributes(t *testing.T) {
+	_, defaults := parseTypeWithDefaults(t, `object({
+		name    = string
+		port    = optional(number, 80)
+		servers = optional(list(object({ host = string })), [])
+		tags    = optional(map(object({ value = string })), {})
+	})`)
+
+	tests := map[string]struct {
+		value     cty.Value
+		wantDiags []string
+	}{
+		"no unknown attributes": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("a"),
+				"servers": cty.TupleVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{"host": cty.StringVal("h")}),
+				}),
+			}),
+			nil,
+		},
+		"unknown attributes": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("a"),
+				"prot": cty.NumberIntVal(8080),
+				"servers": cty.TupleVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{"host": cty.StringVal("h")}),
+					cty.ObjectVal(map[string]cty.Value{"hots": cty.StringVal("h")}),
+				}),
+				"tags": cty.ObjectVal(map[string]cty.Value{
+					"env": cty.ObjectVal(map[string]cty.Value{"vaule": cty.StringVal("prod")}),
+				}),
+			}),
+			[]string{
+				"The attribute at .prot is not declared in the type constraint, and so would be discarded.",
+				"The attribute at .servers[1].hots is not declared in the type constraint, and so would be discarded.",
+				`The attribute at .tags.env.vaule is not declared in the type constraint, and so would be discarded.`,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, diags := defaults.ApplyWithOptions(test.value, ApplyOptions{
+				RejectUnknownAttributes: true,
+			})
+			var gotDiags []string
+			for _, diag := range diags {
+				gotDiags = append(gotDiags, diag.Detail)
+			}
+			if !cmp.Equal(test.wantDiags, gotDiags) {
+				t.Fatalf("wrong diagnostics\n%s", cmp.Diff(test.wantDiags, gotDiags))
+			}
+			if len(diags) > 0 {
+				if !got.RawEquals(test.value) {
+					t.Errorf("value was modified despite errors")
+				}
+				return
+			}
+			want, _ := defaults.ApplyWithDiagnostics(test.value)
+			if !got.RawEquals(want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestDefaults_ApplyWithOptions_treatEmptyAsMissing(t *testing.T) {
+	_, defaults := parseTypeWithDefaults(t, `object({
+		name      = optional(string, "default")
+		list      = optional(list(string), ["a"])
+		set       = optional(set(string), ["a"])
+		tuple     = optional(tuple([string]), ["a"])
+		map       = optional(map(string), { a = "b" })
+		object    = optional(object({ a = optional(string) }), { a = "b" })
+		nodefault = optional(string)
+	})`)
+	withDefaults, _ := defaults.ApplyWithDiagnostics(cty.EmptyObjectVal)
+
+	tests := map[string]struct {
+		attr        string
+		value       cty.Value
+		wantDefault bool
+	}{
+		"empty string": {
+			"name", cty.StringVal(""), true,
+		},
+		"empty list": {
+			"list", cty.ListValEmpty(cty.String), true,
+		},
+		"empty tuple for list": {
+			"list", cty.EmptyTupleVal, true,
+		},
+		"empty set": {
+			"set", cty.SetValEmpty(cty.String), true,
+		},
+		"empty tuple for tuple": {
+			"tuple", cty.EmptyTupleVal, true,
+		},
+		"empty map": {
+			"map", cty.MapValEmpty(cty.String), true,
+		},
+		"empty object for map": {
+			"map", cty.EmptyObjectVal, true,
+		},
+		"empty object": {
+			"object", cty.EmptyObjectVal, true,
+		},
+		"marked empty string": {
+			"name", cty.StringVal("").Mark("sensitive"), true,
+		},
+		"non-empty string": {
+			"name", cty.StringVal("x"), false,
+		},
+		"non-empty list": {
+			"list", cty.ListVal([]cty.Value{cty.StringVal("x")}), false,
+		},
+		"unknown string": {
+			"name", cty.UnknownVal(cty.String), false,
+		},
+		"empty string without a default": {
+			"nodefault", cty.StringVal(""), false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			input := cty.ObjectVal(map[string]cty.Value{test.attr: test.value})
+
+			got, diags := defaults.ApplyWithOptions(input, ApplyOptions{
+				TreatEmptyAsMissing: true,
+			})
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags)
+			}
+			want, _ := defaults.ApplyWithDiagnostics(input)
+			if test.wantDefault {
+				// The default keeps any marks of the value it replaced.
+				_, marks := test.value.Unmark()
+				attrs := withDefaults.AsValueMap()
+				attrs[test.attr] = attrs[test.attr].WithMarks(marks)
+				want = cty.ObjectVal(attrs)
+			}
+			if !got.RawEquals(want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+			}
+
+			// Without the option, the empty value must be kept.
+			got, _ = defaults.ApplyWithOptions(input, ApplyOptions{})
+			if want, _ := defaults.ApplyWithDiagnostics(input); !got.RawEquals(want) {
+				t.Errorf("wrong result without option\ngot:  %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+// TestDefaults_Apply_partiallyUnknown checks that unknown values within a
+// known structure are left alone without preventing defaults from being
+// applied to their known siblings. An unknown value for an attribute that
+// has a non-null default is refined as not null, as tested in
+// TestDefaults_Apply.
+func TestDefaults_Apply_partiallyUnknown(t *testing.T) {
+	ty, defaults := parseTypeWithDefaults(t, `object({
+		name    = optional(string, "web")
+		port    = optional(number, 80)
+		network = optional(object({
+			subnet = optional(string, "10.0.0.0/8")
+			public = optional(bool, false)
+		}))
+		servers = optional(list(object({
+			host = string
+			tls  = optional(bool, true)
+		})))
+		tags    = optional(map(object({
+			value = optional(string, "none")
+		})))
+		ids     = optional(set(object({
+			id   = string
+			kind = optional(string, "default")
+		})))
+	})`)
+	serverType := ty.AttributeType("servers").ElementType().WithoutOptionalAttributesDeep()
+	tagType := ty.AttributeType("tags").ElementType().WithoutOptionalAttributesDeep()
+
+	tests := map[string]struct {
+		value cty.Value
+		want  cty.Value
+	}{
+		"unknown attribute beside missing ones": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.UnknownVal(cty.String),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.UnknownVal(cty.String).RefineNotNull(),
+				"port": cty.NumberIntVal(80),
+			}),
+		},
+		"unknown nested attribute": {
+			cty.ObjectVal(map[string]cty.Value{
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.UnknownVal(cty.String),
+				}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NumberIntVal(80),
+				"network": cty.ObjectVal(map[string]cty.Value{
+					"subnet": cty.UnknownVal(cty.String).RefineNotNull(),
+					"public": cty.False,
+				}),
+			}),
+		},
+		"unknown nested object": {
+			cty.ObjectVal(map[string]cty.Value{
+				"network": cty.UnknownVal(cty.EmptyObject),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name":    cty.StringVal("web"),
+				"port":    cty.NumberIntVal(80),
+				"network": cty.UnknownVal(cty.EmptyObject),
+			}),
+		},
+		"unknown list element": {
+			cty.ObjectVal(map[string]cty.Value{
+				"servers": cty.ListVal([]cty.Value{
+					cty.UnknownVal(serverType),
+					cty.ObjectVal(map[string]cty.Value{
+						"host": cty.StringVal("b"),
+						"tls":  cty.NullVal(cty.Bool),
+					}),
+				}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NumberIntVal(80),
+				"servers": cty.ListVal([]cty.Value{
+					cty.UnknownVal(serverType),
+					cty.ObjectVal(map[string]cty.Value{
+						"host": cty.StringVal("b"),
+						"tls":  cty.True,
+					}),
+				}),
+			}),
+		},
+		"unknown tuple element": {
+			cty.ObjectVal(map[string]cty.Value{
+				"servers": cty.TupleVal([]cty.Value{
+					cty.DynamicVal,
+					cty.ObjectVal(map[string]cty.Value{
+						"host": cty.UnknownVal(cty.String),
+					}),
+				}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NumberIntVal(80),
+				"servers": cty.TupleVal([]cty.Value{
+					cty.DynamicVal,
+					cty.ObjectVal(map[string]cty.Value{
+						"host": cty.UnknownVal(cty.String),
+						"tls":  cty.True,
+					}),
+				}),
+			}),
+		},
+		"unknown map element": {
+			cty.ObjectVal(map[string]cty.Value{
+				"tags": cty.MapVal(map[string]cty.Value{
+					"a": cty.UnknownVal(tagType),
+					"b": cty.ObjectVal(map[string]cty.Value{
+						"value": cty.NullVal(cty.String),
+					}),
+				}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NumberIntVal(80),
+				"tags": cty.MapVal(map[string]cty.Value{
+					"a": cty.UnknownVal(tagType),
+					"b": cty.ObjectVal(map[string]cty.Value{
+						"value": cty.StringVal("none"),
+					}),
+				}),
+			}),
+		},
+		"unknown set element": {
+			cty.ObjectVal(map[string]cty.Value{
+				"ids": cty.SetVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"id":   cty.StringVal("a"),
+						"kind": cty.NullVal(cty.String),
+					}),
+					cty.ObjectVal(map[string]cty.Value{
+						"id":   cty.UnknownVal(cty.String),
+						"kind": cty.NullVal(cty.String),
+					}),
+				}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"port": cty.NumberIntVal(80),
+				"ids": cty.SetVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"id":   cty.StringVal("a"),
+						"kind": cty.StringVal("default"),
+					}),
+					cty.ObjectVal(map[string]cty.Value{
+						"id":   cty.UnknownVal(cty.String),
+						"kind": cty.StringVal("default"),
+					}),
+				}),
+			}),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := defaults.Apply(test.value)
+			if !cmp.Equal(test.want, got, valueComparer) {
+				t.Errorf("wrong result\n%s", cmp.Diff(test.want, got, valueComparer))
+			}
+		})
+	}
+}
+
+func TestDefaults_Apply_wildcard(t *testing.T) {
+	serverType := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"host": cty.String,
+		"port": cty.Number,
+	}, []string{"port"})
+	ty := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"primary":   serverType,
+		"secondary": serverType,
+		"backup":    serverType,
+	}, []string{"primary", "secondary", "backup"})
+	defaults := &Defaults{
+		Type: ty,
+		DefaultValues: map[string]cty.Value{
+			"backup": cty.NullVal(serverType.WithoutOptionalAttributesDeep()),
+			WildcardAttribute: cty.ObjectVal(map[string]cty.Value{
+				"host": cty.StringVal("localhost"),
+			}),
+		},
+		Children: map[string]*Defaults{
+			"primary": {
+				Type: serverType,
+				DefaultValues: map[string]cty.Value{
+					"port": cty.NumberIntVal(443),
+				},
+			},
+			WildcardAttribute: {
+				Type: serverType,
+				DefaultValues: map[string]cty.Value{
+					"port": cty.NumberIntVal(80),
+				},
+			},
+		},
+	}
+	if diags := defaults.Validate(); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+	server := func(host string, port int64) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"host": cty.StringVal(host),
+			"port": cty.NumberIntVal(port),
+		})
+	}
+
+	tests := map[string]struct {
+		value cty.Value
+		want  cty.Value
+	}{
+		// The wildcard default applies to primary and secondary, but not to
+		// backup, which has a default of its own. The wildcard child applies
+		// to secondary and backup, but not to primary, which has a child of
+		// its own.
+		"all missing": {
+			cty.EmptyObjectVal,
+			cty.ObjectVal(map[string]cty.Value{
+				"primary":   server("localhost", 443),
+				"secondary": server("localhost", 80),
+				"backup":    cty.NullVal(serverType.WithoutOptionalAttributesDeep()),
+			}),
+		},
+		"all set": {
+			cty.ObjectVal(map[string]cty.Value{
+				"primary": cty.ObjectVal(map[string]cty.Value{
+					"host": cty.StringVal("a"),
+				}),
+				"secondary": cty.ObjectVal(map[string]cty.Value{
+					"host": cty.StringVal("b"),
+				}),
+				"backup": cty.ObjectVal(map[string]cty.Value{
+					"host": cty.StringVal("c"),
+				}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"primary":   server("a", 443),
+				"secondary": server("b", 80),
+				"backup":    server("c", 80),
+			}),
+		},
+		"null": {
+			cty.ObjectVal(map[string]cty.Value{
+				"primary":   cty.NullVal(serverType.WithoutOptionalAttributesDeep()),
+				"secondary": server("b", 8080),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"primary":   server("localhost", 443),
+				"secondary": server("b", 8080),
+				"backup":    cty.NullVal(serverType.WithoutOptionalAttributesDeep()),
+			}),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := defaults.ApplyAndConvert(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(test.want, got, valueComparer) {
+				t.Errorf("wrong result\n%s", cmp.Diff(test.want, got, valueComparer))
+			}
+			if unset := defaults.Unset(got); !cmp.Equal(got, defaults.Apply(unset), valueComparer) {
+				t.Errorf("applying defaults after unsetting them gave a different result\n%s", cmp.Diff(got, defaults.Apply(unset), valueComparer))
+			}
+		})
+	}
+
+	t.Run("required attributes", func(t *testing.T) {
+		defaults := &Defaults{
+			Type: cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+				"required": cty.String,
+				"optional": cty.String,
+			}, []string{"optional"}),
+			DefaultValues: map[string]cty.Value{
+				WildcardAttribute: cty.StringVal("default"),
+			},
+		}
+		want := cty.ObjectVal(map[string]cty.Value{
+			"required": cty.StringVal("default"),
+			"optional": cty.StringVal("default"),
+		})
+		got, err := defaults.ApplyAndConvert(cty.EmptyObjectVal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(want, got, valueComparer) {
+			t.Errorf("wrong result\n%s", cmp.Diff(want, got, valueComparer))
+		}
+	})
+
+	t.Run("attribute named like the wildcard", func(t *testing.T) {
+		defaults := &Defaults{
+			Type: cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+				"*": cty.String,
+				"a": cty.String,
+			}, []string{"*", "a"}),
+			DefaultValues: map[string]cty.Value{
+				WildcardAttribute: cty.StringVal("star"),
+			},
+		}
+		want := cty.ObjectVal(map[string]cty.Value{
+			"*": cty.StringVal("star"),
+			"a": cty.NullVal(cty.String),
+		})
+		got, err := defaults.ApplyAndConvert(cty.EmptyObjectVal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(want, got, valueComparer) {
+			t.Errorf("wrong result\n%s", cmp.Diff(want, got, valueComparer))
+		}
+	})
+
+	t.Run("DefaultForPath", func(t *testing.T) {
+		got, ok := defaults.DefaultForPath(cty.GetAttrPath("secondary").GetAttr("port"))
+		if want := cty.NumberIntVal(80); !ok || !got.RawEquals(want) {
+			t.Errorf("wrong result %#v, %t; want %#v", got, ok, want)
+		}
+	})
+
+	t.Run("ToHCLWrite", func(t *testing.T) {
+		got := string(hclwrite.Format(defaults.ToHCLWrite().Bytes()))
+		want := `{
+  backup = null
+  primary = {
+    host = "localhost"
+    port = 443
+  }
+  secondary = {
+    host = "localhost"
+    port = 80
+  }
+}`
+		if got != want {
+			t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
+
+func TestDefaults_ApplyWithOptions_unifyAndConvert(t *testing.T) {
+	_, defaults := parseTypeWithDefaults(t, `object({
+		ports = list(object({
+			port = optional(string, "http")
+		}))
+	})`)
+	val := cty.ObjectVal(map[string]cty.Value{
+		"ports": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"port": cty.NumberIntVal(8080),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"port": cty.NullVal(cty.Number),
+			}),
+		}),
+	})
+
+	// strictUnify refuses to unify types unless they are all the same.
+	var unifyCalls int
+	strictUnify := func(types []cty.Type) (cty.Type, []convert.Conversion) {
+		unifyCalls++
+		for _, ty := range types[1:] {
+			if !ty.Equals(types[0]) {
+				return cty.NilType, nil
+			}
+		}
+		return types[0], make([]convert.Conversion, len(types))
+	}
+	// strictConvert refuses to convert numbers to strings.
+	strictConvert := func(val cty.Value, ty cty.Type) (cty.Value, error) {
+		var err error
+		cty.Walk(val, func(path cty.Path, v cty.Value) (bool, error) {
+			if v.Type() != cty.Number {
+				return true, nil
+			}
+			if want, ok := typeAtPath(ty, path); ok && want == cty.String {
+				err = path.NewErrorf("a number is required to be written as a string")
+				return false, nil
+			}
+			return true, nil
+		})
+		if err != nil {
+			return val, err
+		}
+		return convert.Convert(val, ty)
+	}
+
+	t.Run("go-cty", func(t *testing.T) {
+		got, diags := defaults.ApplyWithOptions(val, ApplyOptions{})
+		if diags.HasErrors() {
+			t.Fatalf("unexpected diagnostics: %s", diags.Error())
+		}
+		want := cty.ObjectVal(map[string]cty.Value{
+			"ports": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"port": cty.StringVal("8080"),
+				}),
+				cty.ObjectVal(map[string]cty.Value{
+					"port": cty.StringVal("http"),
+				}),
+			}),
+		})
+		if !cmp.Equal(want, got, valueComparer) {
+			t.Errorf("wrong result\n%s", cmp.Diff(want, got, valueComparer))
+		}
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		_, diags := defaults.ApplyWithOptions(val, ApplyOptions{
+			Unify:   strictUnify,
+			Convert: strictConvert,
+		})
+		if unifyCalls != 1 {
+			t.Errorf("Unify called %d times; want 1", unifyCalls)
+		}
+		var got []string
+		for _, diag := range diags {
+			got = append(got, diag.Detail)
+		}
+		want := []string{
+			`Unsuitable value at .ports[0].port: a number is required to be written as a string.`,
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong diagnostics\n%s", diff)
+		}
+	})
+
+	t.Run("exact type", func(t *testing.T) {
+		_, diags := defaults.ApplyWithOptions(cty.ObjectVal(map[string]cty.Value{
+			"ports": cty.ListValEmpty(cty.Object(map[string]cty.Type{"port": cty.String})),
+		}), ApplyOptions{
+			Convert: func(val cty.Value, ty cty.Type) (cty.Value, error) {
+				t.Errorf("unexpected call to Convert")
+				return val, nil
+			},
+		})
+		if diags.HasErrors() {
+			t.Fatalf("unexpected diagnostics: %s", diags.Error())
+		}
+	})
+}
@@ -18,8 +18,9 @@ const invalidTypeSummary = "Invalid type specification"
 // TypeConstraintWithDefaults, using the passed flags to distinguish. When
 // `constraint` is true, the "any" keyword can be used in place of a concrete
 // type. When `withDefaults` is true, the "optional" call expression supports
-// an additional argument describing a default value.
-func getType(expr hcl.Expression, constraint, withDefaults bool) (cty.Type, *Defaults, hcl.Diagnostics) {
+// an additional argument describing a default value. When `strict` is also
+// true, each default value must be safely convertible to its attribute type.
+func getType(expr hcl.Expression, constraint, withDefaults, strict bool) (cty.Type, *Defaults, hcl.Diagnostics) {
 	// First we'll try for one of our keywords
 	kw := hcl.ExprAsKeyword(expr)
 	switch kw {
@@ -141,15 +142,15 @@ func getType(expr hcl.Expression, constraint, withDefaults bool) (cty.Type, *Def
 	switch call.Name {
 
 	case "list":
-		ety, defaults, diags := getType(call.Arguments[0], constraint, withDefaults)
+		ety, defaults, diags := getType(call.Arguments[0], constraint, withDefaults, strict)
 		ty := cty.List(ety)
 		return ty, collectionDefaults(ty, defaults), diags
 	case "set":
-		ety, defaults, diags := getType(call.Arguments[0], constraint, withDefaults)
+		ety, defaults, diags := getType(call.Arguments[0], constraint, withDefaults, strict)
 		ty := cty.Set(ety)
 		return ty, collectionDefaults(ty, defaults), diags
 	case "map":
-		ety, defaults, diags := getType(call.Arguments[0], constraint, withDefaults)
+		ety, defaults, diags := getType(call.Arguments[0], constraint, withDefaults, strict)
 		ty := cty.Map(ety)
 		return ty, collectionDefaults(ty, defaults), diags
 	case "object":
@@ -259,13 +260,19 @@ func getType(expr hcl.Expression, constraint, withDefaults bool) (cty.Type, *Def
 				}
 			}
 
-			aty, aDefaults, attrDiags := getType(atyExpr, constraint, withDefaults)
+			aty, aDefaults, attrDiags := getType(atyExpr, constraint, withDefaults, strict)
 			diags = append(diags, attrDiags...)
 
 			// If a default is set for an optional attribute, verify that it is
 			// convertible to the attribute type.
 			if defaultVal, ok := defaultValues[attrName]; ok {
-				convertedDefaultVal, err := convert.Convert(defaultVal, aty)
+				var convertedDefaultVal cty.Value
+				var err error
+				if strict {
+					convertedDefaultVal, err = convertDefaultStrict(defaultVal, aty)
+				} else {
+					convertedDefaultVal, err = convert.Convert(defaultVal, aty)
+				}
 				if err != nil {
 					diags = append(diags, &hcl.Diagnostic{
 						Severity: hcl.DiagError,
@@ -300,7 +307,7 @@ func getType(expr hcl.Expression, constraint, withDefaults bool) (cty.Type, *Def
 		etys := make([]cty.Type, len(elemDefs))
 		children := make(map[string]*Defaults, len(elemDefs))
 		for i, defExpr := range elemDefs {
-			ety, elemDefaults, elemDiags := getType(defExpr, constraint, withDefaults)
+			ety, elemDefaults, elemDiags := getType(defExpr, constraint, withDefaults, strict)
 			diags = append(diags, elemDiags...)
 			etys[i] = ety
 			if elemDefaults != nil {
@@ -328,6 +335,23 @@ func getType(expr hcl.Expression, constraint, withDefaults bool) (cty.Type, *Def
 	}
 }
 
+// convertDefaultStrict converts a default value to its attribute type,
+// permitting only conversions that cannot fail. This rejects defaults such as
+// the string "5" for a number attribute, which the lenient mode would accept.
+func convertDefaultStrict(val cty.Value, ty cty.Type) (cty.Value, error) {
+	if val.IsNull() || val.Type().Equals(ty) {
+		return convert.Convert(val, ty)
+	}
+	conv := convert.GetConversion(val.Type(), ty)
+	if conv == nil {
+		if _, err := convert.Convert(val, ty); err != nil {
+			return cty.NilVal, err
+		}
+		return cty.NilVal, fmt.Errorf("%s is required, but the default value is %s and strict type constraints do not allow converting it", ty.FriendlyName(), val.Type().FriendlyName())
+	}
+	return conv(val)
+}
+
 func collectionDefaults(ty cty.Type, defaults *Defaults) *Defaults {
 	if defaults == nil {
 		return nil
@@ -331,7 +331,7 @@ func TestGetType(t *testing.T) {
 				t.Fatalf("failed to parse: %s", diags)
 			}
 
-			got, _, diags := getType(expr, test.Constraint, false)
+			got, _, diags := getType(expr, test.Constraint, false, false)
 			if test.WantError == "" {
 				for _, diag := range diags {
 					t.Error(diag)
@@ -403,7 +403,7 @@ func TestGetTypeJSON(t *testing.T) {
 				t.Fatalf("failed to decode: %s", diags)
 			}
 
-			got, _, diags := getType(content.Expr, test.Constraint, false)
+			got, _, diags := getType(content.Expr, test.Constraint, false, false)
 			if test.WantError == "" {
 				for _, diag := range diags {
 					t.Error(diag)
@@ -734,7 +734,7 @@ func TestGetTypeDefaults(t *testing.T) {
 				t.Fatalf("failed to parse: %s", diags)
 			}
 
-			_, got, diags := getType(expr, true, true)
+			_, got, diags := getType(expr, true, true, false)
 			if test.WantError == "" {
 				for _, diag := range diags {
 					t.Error(diag)
@@ -758,3 +758,97 @@ func TestGetTypeDefaults(t *testing.T) {
 		})
 	}
 }
+
+func TestTypeConstraintWithDefaultsStrict(t *testing.T) {
+	tests := map[string]struct {
+		Source    string
+		WantError string
+	}{
+		"matching default": {
+			`object({ a = optional(number, 5) })`,
+			"",
+		},
+		"safe conversion": {
+			`object({ a = optional(string, 5), b = optional(list(string), []) })`,
+			"",
+		},
+		"any type": {
+			`object({ a = optional(any, "five") })`,
+			"",
+		},
+		"null default": {
+			`object({ a = optional(number, null) })`,
+			"",
+		},
+		"unsafe conversion": {
+			`object({ a = optional(number, "5") })`,
+			"This default value is not compatible with the attribute's type constraint: number is required, but the default value is string and strict type constraints do not allow converting it.",
+		},
+		"impossible conversion": {
+			`object({ a = optional(number, "five") })`,
+			"This default value is not compatible with the attribute's type constraint: a number is required.",
+		},
+		"nested": {
+			`list(object({ a = optional(object({ b = optional(bool, "true") }), {}) }))`,
+			"This default value is not compatible with the attribute's type constraint: bool is required, but the default value is string and strict type constraints do not allow converting it.",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(test.Source), "", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatalf("failed to parse: %s", diags)
+			}
+
+			_, _, diags = TypeConstraintWithDefaultsStrict(expr)
+			if test.WantError == "" {
+				for _, diag := range diags {
+					t.Error(diag)
+				}
+				return
+			}
+			if len(diags) != 1 {
+				t.Fatalf("got %d diagnostics; want 1\n%s", len(diags), diags.Error())
+			}
+			if got := diags[0].Detail; got != test.WantError {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.WantError)
+			}
+		})
+	}
+}
+
+func TestValidateTypeConstraint(t *testing.T) {
+	src := `
+tags: map(string) = {
+  env = "prod"
+}
+port: optional(number, 80) = 8080
+bad: list(strin) = []
+untyped = "a"
+`
+	file, diags := hclsyntax.ParseConfigWithOptions([]byte(src), "test.hcl", hcl.InitialPos, hclsyntax.ParseOptions{
+		TypeAnnotations: ValidateTypeConstraint,
+	})
+	if len(diags) != 2 {
+		t.Fatalf("got %d diagnostics; want 2\n%s", len(diags), diags.Error())
+	}
+	if got, want := diags[0].Subject.Start.Line, 5; got != want {
+		t.Errorf("wrong line for first diagnostic %d; want %d\n%s", got, want, diags[0])
+	}
+	if got, want := diags[1].Subject.Start.Line, 6; got != want {
+		t.Errorf("wrong line for second diagnostic %d; want %d\n%s", got, want, diags[1])
+	}
+
+	attrs := file.Body.(*hclsyntax.Body).Attributes
+	ty, diags := TypeConstraint(attrs["tags"].TypeExpr)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	if want := cty.Map(cty.String); !ty.Equals(want) {
+		t.Errorf("wrong type for tags %#v; want %#v", ty, want)
+	}
+	if attrs["untyped"].TypeExpr != nil {
+		t.Errorf("unexpected type annotation for untyped")
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strconv"
 
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/zclconf/go-cty/cty"
@@ -18,7 +20,7 @@ import (
 // successful, returns the resulting type. If unsuccessful, error diagnostics
 // are returned.
 func Type(expr hcl.Expression) (cty.Type, hcl.Diagnostics) {
-	ty, _, diags := getType(expr, false, false)
+	ty, _, diags := getType(expr, false, false, false)
 	return ty, diags
 }
 
@@ -30,7 +32,7 @@ func Type(expr hcl.Expression) (cty.Type, hcl.Diagnostics) {
 // allows the keyword "any" to represent cty.DynamicPseudoType, which is often
 // used as a wildcard in type checking and type conversion operations.
 func TypeConstraint(expr hcl.Expression) (cty.Type, hcl.Diagnostics) {
-	ty, _, diags := getType(expr, true, false)
+	ty, _, diags := getType(expr, true, false, false)
 	return ty, diags
 }
 
@@ -38,8 +40,44 @@ func TypeConstraint(expr hcl.Expression) (cty.Type, hcl.Diagnostics) {
 // constraint which may include default values for object attributes. If
 // successful both the resulting type and corresponding defaults are returned.
 // If unsuccessful, error diagnostics are returned.
+//
+// The defaults are nil if the type constraint has no default values anywhere
+// within it, in which case there is nothing to apply. The methods of Defaults
+// that apply defaults all accept a nil receiver, returning the given value
+// unchanged, so callers can use the result either way.
 func TypeConstraintWithDefaults(expr hcl.Expression) (cty.Type, *Defaults, hcl.Diagnostics) {
-	return getType(expr, true, true)
+	ty, defaults, diags := getType(expr, true, true, false)
+	return ty, withoutEmptyDefaults(defaults), diags
+}
+
+// TypeConstraintWithDefaultsStrict is like TypeConstraintWithDefaults, but
+// additionally requires that each default value can be converted to its
+// attribute type without any possibility of failure.
+//
+// In particular, strings are not accepted as defaults for number or bool
+// attributes, even if they would be convertible, so that type errors in
+// default values are reported at the default rather than being deferred.
+func TypeConstraintWithDefaultsStrict(expr hcl.Expression) (cty.Type, *Defaults, hcl.Diagnostics) {
+	ty, defaults, diags := getType(expr, true, true, true)
+	return ty, withoutEmptyDefaults(defaults), diags
+}
+
+// withoutEmptyDefaults returns the given defaults, or nil if they have no
+// default values or expressions anywhere within them.
+func withoutEmptyDefaults(d *Defaults) *Defaults {
+	if d == nil || !d.hasDefaults(&applyState{withExprs: true}) {
+		return nil
+	}
+	return d
+}
+
+// ValidateTypeConstraint returns error diagnostics if the given expression is
+// not a valid type constraint as accepted by TypeConstraintWithDefaults. Its
+// signature makes it suitable for use as hclsyntax.ParseOptions.TypeAnnotations,
+// to validate type annotations on attributes while parsing.
+func ValidateTypeConstraint(expr hcl.Expression) hcl.Diagnostics {
+	_, _, diags := TypeConstraintWithDefaults(expr)
+	return diags
 }
 
 // TypeString returns a string rendering of the given type as it would be
@@ -140,3 +178,271 @@ func TypeString(ty cty.Type) string {
 	// Should never happen because we covered all cases above.
 	panic(fmt.Errorf("unsupported type %#v", ty))
 }
+
+// TypeStringIndented is like TypeString except that it renders object and
+// tuple types across multiple lines, with each nested level indented by the
+// given number of spaces, to make larger types easier to read in generated
+// documentation. Attribute names within each object type are aligned in the
+// same way as hclwrite.Format would align them.
+//
+// Unlike TypeString, TypeStringIndented also renders optional object
+// attributes using the optional(...) modifier, so that the result can be
+// used as a type constraint that is equivalent to the given type. Default
+// values for optional attributes are not included, because they are not
+// recorded in the type itself.
+//
+// If the given indent is less than one then two spaces are used per level.
+//
+// TypeStringIndented has the same limitations as TypeString for types not
+// produced by the functions in this package. In particular, it cannot support
+// capsule types.
+func TypeStringIndented(ty cty.Type, indent int) string {
+	if indent < 1 {
+		indent = 2
+	}
+	var buf bytes.Buffer
+	writeTypeStringIndented(&buf, ty, indent, 0)
+	return buf.String()
+}
+
+func writeTypeStringIndented(buf *bytes.Buffer, ty cty.Type, indent, level int) {
+	if ty.IsCapsuleType() {
+		panic("TypeStringIndented does not support capsule types")
+	}
+
+	switch {
+	case ty.IsCollectionType():
+		switch {
+		case ty.IsListType():
+			buf.WriteString("list(")
+		case ty.IsSetType():
+			buf.WriteString("set(")
+		case ty.IsMapType():
+			buf.WriteString("map(")
+		default:
+			// Should never happen because the above is exhaustive
+			panic("unsupported collection type")
+		}
+		writeTypeStringIndented(buf, ty.ElementType(), indent, level)
+		buf.WriteByte(')')
+
+	case ty.IsObjectType():
+		atys := ty.AttributeTypes()
+		if len(atys) == 0 {
+			buf.WriteString("object({})")
+			return
+		}
+		names := make([]string, 0, len(atys))
+		for name := range atys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		keys := make([]string, len(names))
+		width := 0
+		for i, name := range names {
+			if !hclsyntax.ValidIdentifier(name) {
+				// As in TypeString, this should never happen for types
+				// produced by this package.
+				keys[i] = fmt.Sprintf("%q", name)
+			} else {
+				keys[i] = name
+			}
+			if len(keys[i]) > width {
+				width = len(keys[i])
+			}
+		}
+
+		buf.WriteString("object({\n")
+		for i, name := range names {
+			writeIndent(buf, indent*(level+1))
+			buf.WriteString(keys[i])
+			writeIndent(buf, width-len(keys[i]))
+			buf.WriteString(" = ")
+			if ty.AttributeOptional(name) {
+				buf.WriteString("optional(")
+				writeTypeStringIndented(buf, atys[name], indent, level+1)
+				buf.WriteByte(')')
+			} else {
+				writeTypeStringIndented(buf, atys[name], indent, level+1)
+			}
+			buf.WriteByte('\n')
+		}
+		writeIndent(buf, indent*level)
+		buf.WriteString("})")
+
+	case ty.IsTupleType():
+		etys := ty.TupleElementTypes()
+		if len(etys) == 0 {
+			buf.WriteString("tuple([])")
+			return
+		}
+		buf.WriteString("tuple([\n")
+		for _, ety := range etys {
+			writeIndent(buf, indent*(level+1))
+			writeTypeStringIndented(buf, ety, indent, level+1)
+			buf.WriteString(",\n")
+		}
+		writeIndent(buf, indent*level)
+		buf.WriteString("])")
+
+	default:
+		// All of the remaining types render the same as with TypeString.
+		buf.WriteString(TypeString(ty))
+	}
+}
+
+// TypeStringWithDefaults is like TypeString except that it also renders
+// optional object attributes using the optional(...) modifier, including the
+// default value for each attribute that has one in the given defaults.
+//
+// The result is intended to re-parse through TypeConstraintWithDefaults to a
+// type and defaults equal to those given, as long as the defaults are of the
+// shape that TypeConstraintWithDefaults produces for the type. Default values
+// that are not wholly known cannot be rendered as literals, and so are
+// omitted. Defaults given by expressions in DefaultExprs are not rendered.
+//
+// The given defaults may be nil, in which case the result is the same as
+// TypeStringIndented would produce, but on a single line.
+//
+// TypeStringWithDefaults has the same limitations as TypeString for types not
+// produced by the functions in this package. In particular, it cannot support
+// capsule types.
+func TypeStringWithDefaults(ty cty.Type, defaults *Defaults) string {
+	var buf bytes.Buffer
+	writeTypeStringWithDefaults(&buf, ty, defaults)
+	return buf.String()
+}
+
+func writeTypeStringWithDefaults(buf *bytes.Buffer, ty cty.Type, defaults *Defaults) {
+	if ty.IsCapsuleType() {
+		panic("TypeStringWithDefaults does not support capsule types")
+	}
+
+	child := func(key string) *Defaults {
+		if defaults == nil {
+			return nil
+		}
+		return defaults.Children[key]
+	}
+
+	switch {
+	case ty.IsCollectionType():
+		switch {
+		case ty.IsListType():
+			buf.WriteString("list(")
+		case ty.IsSetType():
+			buf.WriteString("set(")
+		case ty.IsMapType():
+			buf.WriteString("map(")
+		default:
+			// Should never happen because the above is exhaustive
+			panic("unsupported collection type")
+		}
+		writeTypeStringWithDefaults(buf, ty.ElementType(), child(""))
+		buf.WriteByte(')')
+
+	case ty.IsObjectType():
+		atys := ty.AttributeTypes()
+		names := make([]string, 0, len(atys))
+		for name := range atys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		buf.WriteString("object({")
+		for i, name := range names {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeObjectKey(buf, name)
+			buf.WriteByte('=')
+			if !ty.AttributeOptional(name) {
+				writeTypeStringWithDefaults(buf, atys[name], child(name))
+				continue
+			}
+			buf.WriteString("optional(")
+			writeTypeStringWithDefaults(buf, atys[name], child(name))
+			if defaults != nil {
+				if val, ok := defaults.DefaultValues[name]; ok {
+					if val, _ = val.UnmarkDeep(); val.IsWhollyKnown() {
+						buf.WriteByte(',')
+						writeValueLiteral(buf, val)
+					}
+				}
+			}
+			buf.WriteByte(')')
+		}
+		buf.WriteString("})")
+
+	case ty.IsTupleType():
+		buf.WriteString("tuple([")
+		for i, ety := range ty.TupleElementTypes() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeTypeStringWithDefaults(buf, ety, child(strconv.Itoa(i)))
+		}
+		buf.WriteString("])")
+
+	default:
+		// All of the remaining types render the same as with TypeString.
+		buf.WriteString(TypeString(ty))
+	}
+}
+
+// writeValueLiteral writes the given wholly-known, unmarked value as an HCL
+// literal on a single line.
+func writeValueLiteral(buf *bytes.Buffer, val cty.Value) {
+	ty := val.Type()
+	switch {
+	case val.IsNull():
+		buf.WriteString("null")
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		buf.WriteByte('[')
+		for i, elem := range val.AsValueSlice() {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeValueLiteral(buf, elem)
+		}
+		buf.WriteByte(']')
+	case ty.IsMapType() || ty.IsObjectType():
+		elems := val.AsValueMap()
+		keys := make([]string, 0, len(elems))
+		for key := range elems {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeObjectKey(buf, key)
+			buf.WriteString(" = ")
+			writeValueLiteral(buf, elems[key])
+		}
+		buf.WriteByte('}')
+	default:
+		buf.Write(hclwrite.TokensForValue(val).Bytes())
+	}
+}
+
+// writeObjectKey writes the given attribute name as it would appear in an
+// object type or object constructor, quoting it if it is not a valid
+// identifier.
+func writeObjectKey(buf *bytes.Buffer, name string) {
+	if hclsyntax.ValidIdentifier(name) {
+		buf.WriteString(name)
+		return
+	}
+	buf.Write(hclwrite.TokensForValue(cty.StringVal(name)).Bytes())
+}
+
+func writeIndent(buf *bytes.Buffer, n int) {
+	for i := 0; i < n; i++ {
+		buf.WriteByte(' ')
+	}
+}
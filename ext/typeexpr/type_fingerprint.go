@@ -0,0 +1,35 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package typeexpr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// TypeFingerprint returns a hash of the given type, as a string of
+// hexadecimal digits, which is the same for equal types across runs and
+// across processes. This is useful for detecting changes to a schema by
+// comparing the fingerprint of its type with one recorded earlier.
+//
+// The hash is of cty's JSON serialization of the type, which lists object
+// attributes in lexical order by name and so doesn't depend on the order of
+// the type's attribute map. Optional attribute markers are included, so an
+// object type with an optional attribute has a different fingerprint than one
+// where the same attribute is required. Default values are not included,
+// because they are not part of the type.
+//
+// TypeFingerprint panics if given a capsule type, because such types have no
+// serialization.
+func TypeFingerprint(ty cty.Type) string {
+	src, err := ty.MarshalJSON()
+	if err != nil {
+		panic(fmt.Sprintf("TypeFingerprint does not support the given type: %s", err))
+	}
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
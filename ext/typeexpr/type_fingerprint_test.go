@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package typeexpr
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestTypeFingerprint(t *testing.T) {
+	tests := map[string]struct {
+		A, B  cty.Type
+		Equal bool
+	}{
+		"same primitive": {
+			cty.String,
+			cty.String,
+			true,
+		},
+		"different primitives": {
+			cty.String,
+			cty.Number,
+			false,
+		},
+		"same object": {
+			cty.Object(map[string]cty.Type{
+				"a": cty.String,
+				"b": cty.Number,
+				"c": cty.Bool,
+			}),
+			cty.Object(map[string]cty.Type{
+				"c": cty.Bool,
+				"b": cty.Number,
+				"a": cty.String,
+			}),
+			true,
+		},
+		"different attribute types": {
+			cty.Object(map[string]cty.Type{
+				"a": cty.String,
+			}),
+			cty.Object(map[string]cty.Type{
+				"a": cty.Number,
+			}),
+			false,
+		},
+		"optional attribute": {
+			cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+				"a": cty.String,
+				"b": cty.String,
+			}, []string{"b", "a"}),
+			cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+				"a": cty.String,
+				"b": cty.String,
+			}, []string{"a", "b"}),
+			true,
+		},
+		"optional vs required": {
+			cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+				"a": cty.String,
+			}, []string{"a"}),
+			cty.Object(map[string]cty.Type{
+				"a": cty.String,
+			}),
+			false,
+		},
+		"nested collections": {
+			cty.Map(cty.List(cty.Set(cty.String))),
+			cty.Map(cty.List(cty.Set(cty.String))),
+			true,
+		},
+		"list vs set": {
+			cty.List(cty.String),
+			cty.Set(cty.String),
+			false,
+		},
+		"tuple order": {
+			cty.Tuple([]cty.Type{cty.String, cty.Number}),
+			cty.Tuple([]cty.Type{cty.Number, cty.String}),
+			false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a, b := TypeFingerprint(test.A), TypeFingerprint(test.B)
+			if got := a == b; got != test.Equal {
+				t.Errorf("wrong result\na: %s\nb: %s\nwant equal: %t", a, b, test.Equal)
+			}
+		})
+	}
+
+	t.Run("stable", func(t *testing.T) {
+		// This fingerprint must not change between releases, because
+		// callers may persist it.
+		got := TypeFingerprint(cty.Object(map[string]cty.Type{"a": cty.String}))
+		want := "a6e11352ff5b1197f65d46b7e06a4ab49a1be431588da53316760e81ae6a059d"
+		if got != want {
+			t.Errorf("wrong fingerprint\ngot:  %s\nwant: %s", got, want)
+		}
+	})
+}
@@ -6,6 +6,8 @@ package typeexpr
 import (
 	"testing"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -101,3 +103,168 @@ func TestTypeString(t *testing.T) {
 		})
 	}
 }
+
+func TestTypeStringIndented(t *testing.T) {
+	tests := map[string]struct {
+		Type   cty.Type
+		Indent int
+		Want   string
+	}{
+		"primitive": {
+			cty.String,
+			2,
+			"string",
+		},
+		"collection of primitive": {
+			cty.Map(cty.List(cty.Number)),
+			2,
+			"map(list(number))",
+		},
+		"empty object": {
+			cty.EmptyObject,
+			2,
+			"object({})",
+		},
+		"empty tuple": {
+			cty.EmptyTuple,
+			2,
+			"tuple([])",
+		},
+		"object": {
+			cty.Object(map[string]cty.Type{
+				"name": cty.String,
+				"tags": cty.Map(cty.String),
+			}),
+			2,
+			"object({\n  name = string\n  tags = map(string)\n})",
+		},
+		"object with aligned names": {
+			cty.Object(map[string]cty.Type{
+				"a":    cty.String,
+				"long": cty.Bool,
+			}),
+			2,
+			"object({\n  a    = string\n  long = bool\n})",
+		},
+		"nested": {
+			cty.List(cty.Object(map[string]cty.Type{
+				"inner": cty.Object(map[string]cty.Type{
+					"value": cty.Number,
+				}),
+				"pair": cty.Tuple([]cty.Type{cty.String, cty.Bool}),
+			})),
+			4,
+			"list(object({\n    inner = object({\n        value = number\n    })\n    pair  = tuple([\n        string,\n        bool,\n    ])\n}))",
+		},
+		"optional attributes": {
+			cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+				"name": cty.String,
+				"size": cty.Number,
+			}, []string{"size"}),
+			2,
+			"object({\n  name = string\n  size = optional(number)\n})",
+		},
+		"default indent": {
+			cty.Object(map[string]cty.Type{
+				"name": cty.String,
+			}),
+			0,
+			"object({\n  name = string\n})",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := TypeStringIndented(test.Type, test.Indent)
+			if got != test.Want {
+				t.Fatalf("wrong result\ntype: %#v\ngot:\n%s\nwant:\n%s", test.Type, got, test.Want)
+			}
+
+			// The result should always be a valid type constraint that
+			// produces the same type again.
+			expr, diags := hclsyntax.ParseExpression([]byte(got), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("result is not a valid expression: %s", diags)
+			}
+			ty, _, diags := TypeConstraintWithDefaults(expr)
+			if diags.HasErrors() {
+				t.Fatalf("result is not a valid type constraint: %s", diags)
+			}
+			if !ty.Equals(test.Type) {
+				t.Errorf("wrong round-trip type\ngot:  %#v\nwant: %#v", ty, test.Type)
+			}
+		})
+	}
+}
+
+func TestTypeStringWithDefaults(t *testing.T) {
+	tests := map[string]struct {
+		Source string
+		Want   string
+	}{
+		"primitive": {
+			`string`,
+			`string`,
+		},
+		"optional without default": {
+			`object({ a = optional(string), b = number })`,
+			`object({a=optional(string),b=number})`,
+		},
+		"primitive defaults": {
+			`object({ a = optional(string, "x"), b = optional(number, 1.5), c = optional(bool, true) })`,
+			`object({a=optional(string,"x"),b=optional(number,1.5),c=optional(bool,true)})`,
+		},
+		"string quoting": {
+			`object({ a = optional(string, "say \"hi\" to $${name}\n") })`,
+			`object({a=optional(string,"say \"hi\" to $${name}\n")})`,
+		},
+		"null default": {
+			`object({ a = optional(string, null) })`,
+			`object({a=optional(string,null)})`,
+		},
+		"collection defaults": {
+			`object({ a = optional(list(string), ["x", "y"]), b = optional(map(number), { "k 1" = 1 }) })`,
+			`object({a=optional(list(string),["x", "y"]),b=optional(map(number),{"k 1" = 1})})`,
+		},
+		"nested objects": {
+			`list(object({ name = string, inner = optional(object({ v = optional(number, 5) }), {}) }))`,
+			`list(object({inner=optional(object({v=optional(number,5)}),{v = null}),name=string}))`,
+		},
+		"tuple": {
+			`tuple([string, object({ a = optional(bool, false) })])`,
+			`tuple([string,object({a=optional(bool,false)})])`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ty, defaults := parseTypeWithDefaults(t, test.Source)
+
+			got := TypeStringWithDefaults(ty, defaults)
+			if got != test.Want {
+				t.Errorf("wrong result\ngot:  %s\nwant: %s", got, test.Want)
+			}
+
+			gotTy, gotDefaults := parseTypeWithDefaults(t, got)
+			if !gotTy.Equals(ty) {
+				t.Errorf("wrong type after round-trip\ngot:  %#v\nwant: %#v", gotTy, ty)
+			}
+			if !gotDefaults.Equal(defaults) {
+				t.Errorf("wrong defaults after round-trip\ngot:  %#v\nwant: %#v", gotDefaults, defaults)
+			}
+		})
+	}
+}
+
+func parseTypeWithDefaults(t testing.TB, src string) (cty.Type, *Defaults) {
+	t.Helper()
+	expr, diags := hclsyntax.ParseExpression([]byte(src), "", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatalf("failed to parse %s: %s", src, diags)
+	}
+	ty, defaults, diags := TypeConstraintWithDefaults(expr)
+	if diags.HasErrors() {
+		t.Fatalf("invalid type constraint %s: %s", src, diags)
+	}
+	return ty, defaults
+}
@@ -4,6 +4,8 @@
 package userfunc
 
 import (
+	"fmt"
+
 	"github.com/hashicorp/hcl/v2"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
@@ -27,25 +29,49 @@ var funcBodySchema = &hcl.BodySchema{
 }
 
 func decodeUserFunctions(body hcl.Body, blockType string, contextFunc ContextFunc) (funcs map[string]function.Function, remain hcl.Body, diags hcl.Diagnostics) {
-	schema := &hcl.BodySchema{
-		Blocks: []hcl.BlockHeaderSchema{
-			{
-				Type:       blockType,
-				LabelNames: []string{"name"},
-			},
-		},
-	}
+	funcs, _, remain, diags = decodeUserFunctionsBody(body, blockType, newBaseCtxFunc(contextFunc))
+	return funcs, remain, diags
+}
 
-	content, remain, diags := body.PartialContent(schema)
-	if diags.HasErrors() {
-		return nil, remain, diags
+func decodeUserFunctionsMulti(bodies []hcl.Body, blockType string, contextFunc ContextFunc) (funcs map[string]function.Function, remains []hcl.Body, diags hcl.Diagnostics) {
+	// All of the bodies share the same base context, just as all of the
+	// functions within a single body do.
+	getBaseCtx := newBaseCtxFunc(contextFunc)
+
+	funcs = make(map[string]function.Function)
+	defRanges := make(map[string]hcl.Range)
+	remains = make([]hcl.Body, len(bodies))
+	for i, body := range bodies {
+		bodyFuncs, bodyRanges, remain, bodyDiags := decodeUserFunctionsBody(body, blockType, getBaseCtx)
+		diags = append(diags, bodyDiags...)
+		remains[i] = remain
+
+		for name, f := range bodyFuncs {
+			if prevRange, exists := defRanges[name]; exists {
+				rng := bodyRanges[name]
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Duplicate function definition",
+					Detail:   fmt.Sprintf("A function named %q was already defined at %s. Function names must be unique across all files.", name, prevRange),
+					Subject:  &rng,
+				})
+				continue
+			}
+			funcs[name] = f
+			defRanges[name] = bodyRanges[name]
+		}
 	}
 
-	// first call to getBaseCtx will populate context, and then the same
-	// context will be used for all subsequent calls. It's assumed that
-	// all functions in a given body should see an identical context.
+	return funcs, remains, diags
+}
+
+// newBaseCtxFunc wraps the given ContextFunc so that it will be called at
+// most once, with the same context then returned for all subsequent calls.
+// It's assumed that all functions decoded together should see an identical
+// context.
+func newBaseCtxFunc(contextFunc ContextFunc) ContextFunc {
 	var baseCtx *hcl.EvalContext
-	getBaseCtx := func() *hcl.EvalContext {
+	return func() *hcl.EvalContext {
 		if baseCtx == nil {
 			if contextFunc != nil {
 				baseCtx = contextFunc()
@@ -54,8 +80,25 @@ func decodeUserFunctions(body hcl.Body, blockType string, contextFunc ContextFun
 		// baseCtx might still be nil here, and that's okay
 		return baseCtx
 	}
+}
+
+func decodeUserFunctionsBody(body hcl.Body, blockType string, getBaseCtx ContextFunc) (funcs map[string]function.Function, defRanges map[string]hcl.Range, remain hcl.Body, diags hcl.Diagnostics) {
+	schema := &hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{
+			{
+				Type:       blockType,
+				LabelNames: []string{"name"},
+			},
+		},
+	}
+
+	content, remain, diags := body.PartialContent(schema)
+	if diags.HasErrors() {
+		return nil, nil, remain, diags
+	}
 
 	funcs = make(map[string]function.Function)
+	defRanges = make(map[string]hcl.Range)
 Blocks:
 	for _, block := range content.Blocks {
 		name := block.Labels[0]
@@ -153,7 +196,8 @@ Blocks:
 			return impl(args)
 		}
 		funcs[name] = function.New(spec)
+		defRanges[name] = block.DefRange
 	}
 
-	return funcs, remain, diags
+	return funcs, defRanges, remain, diags
 }
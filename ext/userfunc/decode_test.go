@@ -5,11 +5,13 @@ package userfunc
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
 )
 
 func TestDecodeUserFunctions(t *testing.T) {
@@ -175,3 +177,87 @@ function "neg" {
 		})
 	}
 }
+
+func TestDecodeUserFunctionsMulti(t *testing.T) {
+	parse := func(src, filename string) hcl.Body {
+		f, diags := hclsyntax.ParseConfig([]byte(src), filename, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			t.Fatalf("failed to parse %s: %s", filename, diags)
+		}
+		return f.Body
+	}
+
+	lib := parse(`
+function "greet" {
+  params = [name]
+  result = "Hello, ${name}."
+}
+`, "lib.hcl")
+	main := parse(`
+function "shout" {
+  params = [name]
+  result = "${greet(name)}!"
+}
+
+other = true
+`, "main.hcl")
+	dupe := parse(`
+function "greet" {
+  params = []
+  result = "Hi."
+}
+`, "dupe.hcl")
+
+	t.Run("success", func(t *testing.T) {
+		var funcs map[string]function.Function
+		funcs, remains, diags := decodeUserFunctionsMulti([]hcl.Body{lib, main}, "function", func() *hcl.EvalContext {
+			return &hcl.EvalContext{
+				Functions: funcs,
+			}
+		})
+		if len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics: %s", diags)
+		}
+		if len(remains) != 2 {
+			t.Fatalf("wrong number of remaining bodies %d; want 2", len(remains))
+		}
+		attrs, _ := remains[1].JustAttributes()
+		if _, ok := attrs["other"]; !ok {
+			t.Errorf("remaining body for main.hcl does not include \"other\"")
+		}
+
+		expr, _ := hclsyntax.ParseExpression([]byte(`shout("Ermintrude")`), "testexpr", hcl.Pos{Line: 1, Column: 1})
+		got, diags := expr.Value(&hcl.EvalContext{
+			Functions: funcs,
+		})
+		if len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics: %s", diags)
+		}
+		if want := cty.StringVal("Hello, Ermintrude.!"); !got.RawEquals(want) {
+			t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+		}
+	})
+
+	t.Run("collision", func(t *testing.T) {
+		funcs, _, diags := decodeUserFunctionsMulti([]hcl.Body{lib, dupe}, "function", nil)
+		if len(diags) != 1 {
+			t.Fatalf("wrong number of diagnostics %d; want 1\n%s", len(diags), diags)
+		}
+		diag := diags[0]
+		if got, want := diag.Summary, "Duplicate function definition"; got != want {
+			t.Errorf("wrong summary %q; want %q", got, want)
+		}
+		if got, want := diag.Subject.Filename, "dupe.hcl"; got != want {
+			t.Errorf("wrong subject filename %q; want %q", got, want)
+		}
+		if diag.Context != nil {
+			t.Errorf("unexpected context %s, which doesn't contain the subject", diag.Context)
+		}
+		if !strings.Contains(diag.Detail, "lib.hcl") {
+			t.Errorf("detail does not mention the previous definition\n%s", diag.Detail)
+		}
+		if _, ok := funcs["greet"]; !ok {
+			t.Errorf("first definition of greet is missing from the result")
+		}
+	})
+}
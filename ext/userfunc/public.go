@@ -43,3 +43,19 @@ type ContextFunc func() *hcl.EvalContext
 func DecodeUserFunctions(body hcl.Body, blockType string, context ContextFunc) (funcs map[string]function.Function, remain hcl.Body, diags hcl.Diagnostics) {
 	return decodeUserFunctions(body, blockType, context)
 }
+
+// DecodeUserFunctionsMulti is a variant of DecodeUserFunctions that looks
+// for function definitions across several bodies, such as a library of
+// functions defined in one file alongside the main configuration in
+// another, and merges them into a single function map.
+//
+// The result includes one remaining body for each of the given bodies, in
+// the same order. All of the functions share a single base context, obtained
+// from the given ContextFunc in the same way as for DecodeUserFunctions.
+//
+// If a function of the same name is defined in more than one of the given
+// bodies then error diagnostics are returned that refer to both definitions,
+// and only the first definition is included in the result.
+func DecodeUserFunctionsMulti(bodies []hcl.Body, blockType string, context ContextFunc) (funcs map[string]function.Function, remains []hcl.Body, diags hcl.Diagnostics) {
+	return decodeUserFunctionsMulti(bodies, blockType, context)
+}
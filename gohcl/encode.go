@@ -48,7 +48,9 @@ func EncodeIntoBody(val interface{}, dst *hclwrite.Body) {
 	}
 
 	tags := getFieldTags(ty)
-	populateBody(rv, ty, tags, dst)
+	if err := populateBody(rv, ty, tags, dst, false); err != nil {
+		panic(err.Error())
+	}
 }
 
 // EncodeAsBlock creates a new hclwrite.Block populated with the data from
@@ -61,31 +63,75 @@ func EncodeIntoBody(val interface{}, dst *hclwrite.Body) {
 // This function has the same constraints as EncodeIntoBody and will panic
 // if they are violated.
 func EncodeAsBlock(val interface{}, blockType string) *hclwrite.Block {
+	block, err := encodeAsBlock(val, blockType, nil, false)
+	if err != nil {
+		panic(err.Error())
+	}
+	return block
+}
+
+// AppendBlockFromStruct appends a new block of the given type and labels to
+// the given hclwrite Body, populated with the data from the given value,
+// which must be a struct or pointer to struct with the struct tags defined
+// in this package. The new block is returned so that the caller can make
+// further changes to it.
+//
+// The block labels are given explicitly, so any fields tagged as "label" are
+// ignored at the top level. Nested blocks are encoded as in EncodeAsBlock,
+// using their own "label" fields.
+//
+// Fields tagged as "optional" are omitted when they hold the zero value for
+// their type, so that the result includes only the settings that differ
+// from what decoding an absent attribute would produce. Otherwise the result
+// is laid out in the same way as for EncodeIntoBody.
+//
+// Unlike EncodeIntoBody and EncodeAsBlock, this function returns an error
+// rather than panicking if the given value or any of its fields cannot be
+// encoded. The given body is not modified if an error is returned.
+func AppendBlockFromStruct(dst *hclwrite.Body, typeName string, labels []string, val interface{}) (*hclwrite.Block, error) {
+	block, err := encodeAsBlock(val, typeName, labels, true)
+	if err != nil {
+		return nil, err
+	}
+	return dst.AppendBlock(block), nil
+}
+
+// encodeAsBlock is the main implementation of EncodeAsBlock and
+// AppendBlockFromStruct. If labels is nil then the labels are taken from the
+// fields tagged as "label" in the given value.
+func encodeAsBlock(val interface{}, blockType string, labels []string, omitEmpty bool) (*hclwrite.Block, error) {
 	rv := reflect.ValueOf(val)
+	if !rv.IsValid() {
+		return nil, fmt.Errorf("value is nil, not struct")
+	}
 	ty := rv.Type()
 	if ty.Kind() == reflect.Ptr {
 		rv = rv.Elem()
 		ty = rv.Type()
 	}
 	if ty.Kind() != reflect.Struct {
-		panic(fmt.Sprintf("value is %s, not struct", ty.Kind()))
+		return nil, fmt.Errorf("value is %s, not struct", ty.Kind())
 	}
 
 	tags := getFieldTags(ty)
-	labels := make([]string, len(tags.Labels))
-	for i, lf := range tags.Labels {
-		lv := rv.Field(lf.FieldIndex)
-		// We just stringify whatever we find. It should always be a string
-		// but if not then we'll still do something reasonable.
-		labels[i] = fmt.Sprintf("%s", lv.Interface())
+	if labels == nil {
+		labels = make([]string, len(tags.Labels))
+		for i, lf := range tags.Labels {
+			lv := rv.Field(lf.FieldIndex)
+			// We just stringify whatever we find. It should always be a string
+			// but if not then we'll still do something reasonable.
+			labels[i] = fmt.Sprintf("%s", lv.Interface())
+		}
 	}
 
 	block := hclwrite.NewBlock(blockType, labels)
-	populateBody(rv, ty, tags, block.Body())
-	return block
+	if err := populateBody(rv, ty, tags, block.Body(), omitEmpty); err != nil {
+		return nil, err
+	}
+	return block, nil
 }
 
-func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwrite.Body) {
+func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwrite.Body, omitEmpty bool) error {
 	nameIdxs := make(map[string]int, len(tags.Attributes)+len(tags.Blocks))
 	namesOrder := make([]string, 0, len(tags.Attributes)+len(tags.Blocks))
 	for n, i := range tags.Attributes {
@@ -126,6 +172,9 @@ func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwr
 			if fieldTy.Kind() == reflect.Ptr && fieldVal.IsNil() {
 				continue // ignore
 			}
+			if omitEmpty && tags.Optional[name] && fieldVal.IsZero() {
+				continue // ignore (optional attribute left unset)
+			}
 			if prevWasBlock {
 				dst.AppendNewline()
 				prevWasBlock = false
@@ -133,14 +182,14 @@ func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwr
 
 			valTy, err := gocty.ImpliedType(fieldVal.Interface())
 			if err != nil {
-				panic(fmt.Sprintf("cannot encode %T as HCL expression: %s", fieldVal.Interface(), err))
+				return fmt.Errorf("cannot encode %T as HCL expression: %s", fieldVal.Interface(), err)
 			}
 
 			val, err := gocty.ToCtyValue(fieldVal.Interface(), valTy)
 			if err != nil {
 				// This should never happen, since we should always be able
 				// to decode into the implied type.
-				panic(fmt.Sprintf("failed to encode %T as %#v: %s", fieldVal.Interface(), valTy, err))
+				return fmt.Errorf("failed to encode %T as %#v: %s", fieldVal.Interface(), valTy, err)
 			}
 
 			dst.SetAttributeValue(name, val)
@@ -168,7 +217,10 @@ func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwr
 					if elemTy.Kind() == reflect.Ptr && elemVal.IsNil() {
 						continue // ignore
 					}
-					block := EncodeAsBlock(elemVal.Interface(), name)
+					block, err := encodeAsBlock(elemVal.Interface(), name, nil, omitEmpty)
+					if err != nil {
+						return err
+					}
 					if !prevWasBlock {
 						dst.AppendNewline()
 						prevWasBlock = true
@@ -182,7 +234,10 @@ func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwr
 				if elemTy.Kind() == reflect.Ptr && fieldVal.IsNil() {
 					continue // ignore
 				}
-				block := EncodeAsBlock(fieldVal.Interface(), name)
+				block, err := encodeAsBlock(fieldVal.Interface(), name, nil, omitEmpty)
+				if err != nil {
+					return err
+				}
 				if !prevWasBlock {
 					dst.AppendNewline()
 					prevWasBlock = true
@@ -191,4 +246,6 @@ func populateBody(rv reflect.Value, ty reflect.Type, tags *fieldTags, dst *hclwr
 			}
 		}
 	}
+
+	return nil
 }
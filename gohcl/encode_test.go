@@ -5,6 +5,7 @@ package gohcl_test
 
 import (
 	"fmt"
+	"testing"
 
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclwrite"
@@ -65,3 +66,64 @@ func ExampleEncodeIntoBody() {
 	//   executable = ["./worker"]
 	// }
 }
+
+func ExampleAppendBlockFromStruct() {
+	type Listener struct {
+		Port    int    `hcl:"port"`
+		TLS     bool   `hcl:"tls,optional"`
+		Comment string `hcl:"comment,optional"`
+	}
+	type Server struct {
+		Host      string     `hcl:"host"`
+		Timeout   int        `hcl:"timeout,optional"`
+		Listeners []Listener `hcl:"listener,block"`
+	}
+
+	server := Server{
+		Host: "example.com",
+		Listeners: []Listener{
+			{Port: 80},
+			{Port: 443, TLS: true},
+		},
+	}
+
+	f := hclwrite.NewEmptyFile()
+	if _, err := gohcl.AppendBlockFromStruct(f.Body(), "server", []string{"web"}, &server); err != nil {
+		fmt.Printf("error: %s", err)
+		return
+	}
+	fmt.Printf("%s", f.Bytes())
+
+	// Output:
+	// server "web" {
+	//   host = "example.com"
+	//
+	//   listener {
+	//     port = 80
+	//   }
+	//   listener {
+	//     port = 443
+	//     tls  = true
+	//   }
+	// }
+}
+
+func TestAppendBlockFromStructError(t *testing.T) {
+	type Unsupported struct {
+		Callback func() `hcl:"callback"`
+	}
+
+	f := hclwrite.NewEmptyFile()
+	_, err := gohcl.AppendBlockFromStruct(f.Body(), "thing", nil, &Unsupported{Callback: func() {}})
+	if err == nil {
+		t.Fatal("unexpected success; want error")
+	}
+	if got := f.Bytes(); len(got) != 0 {
+		t.Errorf("body was modified despite error:\n%s", got)
+	}
+
+	_, err = gohcl.AppendBlockFromStruct(f.Body(), "thing", nil, "not a struct")
+	if err == nil {
+		t.Fatal("unexpected success for non-struct value; want error")
+	}
+}
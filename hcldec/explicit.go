@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcldec
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DecodeWithExplicitPaths is like Decode except that it additionally returns
+// the paths within the result whose values were set explicitly in the
+// configuration, as opposed to being filled in by the Default of a
+// DefaultSpec or left unset.
+//
+// A path is considered to be explicitly set if the value at that path is
+// non-null when the body is decoded with all DefaultSpec defaults ignored.
+// Paths into nested objects, maps, and collections are included alongside
+// the path of their container, so a caller can re-emit only the
+// user-provided settings by visiting each of the returned paths.
+//
+// Omitted optional attributes of an object type constraint are null in the
+// decoded value, and so those attributes are not included in the result
+// even if the caller then fills them in using defaults from
+// typeexpr.TypeConstraintWithDefaults. Likewise, an attribute explicitly
+// set to null is indistinguishable from one that was not set at all.
+//
+// The returned paths are in a deterministic order, and are only meaningful
+// if the returned diagnostics do not contain errors.
+func DecodeWithExplicitPaths(body hcl.Body, spec Spec, ctx *hcl.EvalContext) (cty.Value, []cty.Path, hcl.Diagnostics) {
+	val, diags := Decode(body, spec, ctx)
+	if diags.HasErrors() {
+		return val, nil, diags
+	}
+
+	// Any problems with the raw decode would also have been reported by
+	// the full decode above, so we can safely ignore its diagnostics.
+	raw, _ := Decode(body, withoutDefaults(spec), ctx)
+	raw, _ = raw.UnmarkDeep()
+
+	var paths []cty.Path
+	cty.Walk(raw, func(path cty.Path, v cty.Value) (bool, error) {
+		if len(path) == 0 {
+			return true, nil
+		}
+		if v.IsNull() {
+			return false, nil
+		}
+		paths = append(paths, path.Copy())
+		return true, nil
+	})
+
+	return val, paths, diags
+}
+
+// withoutDefaults returns a copy of the given spec in which each DefaultSpec
+// is replaced by its Primary spec, so that decoding with it produces null
+// values anywhere a default would otherwise have been used.
+func withoutDefaults(spec Spec) Spec {
+	switch spec := spec.(type) {
+	case ObjectSpec:
+		ret := make(ObjectSpec, len(spec))
+		for k, s := range spec {
+			ret[k] = withoutDefaults(s)
+		}
+		return ret
+	case TupleSpec:
+		ret := make(TupleSpec, len(spec))
+		for i, s := range spec {
+			ret[i] = withoutDefaults(s)
+		}
+		return ret
+	case *DefaultSpec:
+		return withoutDefaults(spec.Primary)
+	case *BlockSpec:
+		ret := *spec
+		ret.Nested = withoutDefaults(spec.Nested)
+		return &ret
+	case *BlockListSpec:
+		ret := *spec
+		ret.Nested = withoutDefaults(spec.Nested)
+		return &ret
+	case *BlockTupleSpec:
+		ret := *spec
+		ret.Nested = withoutDefaults(spec.Nested)
+		return &ret
+	case *BlockSetSpec:
+		ret := *spec
+		ret.Nested = withoutDefaults(spec.Nested)
+		return &ret
+	case *BlockMapSpec:
+		ret := *spec
+		ret.Nested = withoutDefaults(spec.Nested)
+		return &ret
+	case *BlockObjectSpec:
+		ret := *spec
+		ret.Nested = withoutDefaults(spec.Nested)
+		return &ret
+	case *TransformExprSpec:
+		ret := *spec
+		ret.Wrapped = withoutDefaults(spec.Wrapped)
+		return &ret
+	case *TransformFuncSpec:
+		ret := *spec
+		ret.Wrapped = withoutDefaults(spec.Wrapped)
+		return &ret
+	case *RefineValueSpec:
+		// Refinements only affect unknown values and may not be consistent
+		// with the nulls that result from ignoring defaults.
+		return withoutDefaults(spec.Wrapped)
+	case *ValidateSpec:
+		// Validation rules are written against the value with defaults
+		// applied, so we skip them for the raw decode.
+		return withoutDefaults(spec.Wrapped)
+	default:
+		return spec
+	}
+}
@@ -31,8 +31,14 @@ func PartialDecode(body hcl.Body, spec Spec, ctx *hcl.EvalContext) (cty.Value, h
 
 // ImpliedType returns the value type that should result from decoding the
 // given spec.
+//
+// Attributes whose type constraint includes optional object attributes
+// produce values that always have those attributes, set to null if they
+// were omitted, and so the result never includes optional attribute
+// markers. Specs whose result type depends on the content of the body, such
+// as BlockTupleSpec and BlockObjectSpec, produce cty.DynamicPseudoType.
 func ImpliedType(spec Spec) cty.Type {
-	return impliedType(spec)
+	return impliedType(spec).WithoutOptionalAttributesDeep()
 }
 
 // SourceRange interprets the given body using the given specification and
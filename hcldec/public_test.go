@@ -678,7 +678,7 @@ b "bar" {}
 				Nested:     ObjectSpec{},
 			},
 			nil,
-			cty.MapValEmpty(cty.EmptyObject),
+			cty.MapValEmpty(cty.Map(cty.EmptyObject)),
 			1, // not enough labels
 		},
 		{
@@ -1074,6 +1074,151 @@ b "bar" {
 	}
 }
 
+func TestImpliedType(t *testing.T) {
+	optObj := cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+		"a": cty.String,
+		"b": cty.Number,
+	}, []string{"b"})
+	obj := cty.Object(map[string]cty.Type{
+		"a": cty.String,
+		"b": cty.Number,
+	})
+	nested := ObjectSpec{
+		"name": &AttrSpec{
+			Name: "name",
+			Type: cty.String,
+		},
+		"opts": &AttrSpec{
+			Name: "opts",
+			Type: optObj,
+		},
+	}
+	nestedTy := cty.Object(map[string]cty.Type{
+		"name": cty.String,
+		"opts": obj,
+	})
+
+	tests := map[string]struct {
+		spec Spec
+		want cty.Type
+	}{
+		"empty object": {
+			ObjectSpec{},
+			cty.EmptyObject,
+		},
+		"attribute with optional attributes": {
+			&AttrSpec{
+				Name: "foo",
+				Type: cty.List(optObj),
+			},
+			cty.List(obj),
+		},
+		"literal": {
+			&LiteralSpec{
+				Value: cty.True,
+			},
+			cty.Bool,
+		},
+		"tuple": {
+			TupleSpec{
+				&AttrSpec{Name: "foo", Type: optObj},
+				&LiteralSpec{Value: cty.Zero},
+			},
+			cty.Tuple([]cty.Type{obj, cty.Number}),
+		},
+		"block": {
+			&BlockSpec{
+				TypeName: "thing",
+				Nested:   nested,
+			},
+			nestedTy,
+		},
+		"block list": {
+			&BlockListSpec{
+				TypeName: "thing",
+				Nested:   nested,
+			},
+			cty.List(nestedTy),
+		},
+		"block set": {
+			&BlockSetSpec{
+				TypeName: "thing",
+				Nested:   nested,
+			},
+			cty.Set(nestedTy),
+		},
+		"block map": {
+			&BlockMapSpec{
+				TypeName:   "thing",
+				LabelNames: []string{"type", "name"},
+				Nested:     nested,
+			},
+			cty.Map(cty.Map(nestedTy)),
+		},
+		"block tuple": {
+			&BlockTupleSpec{
+				TypeName: "thing",
+				Nested:   nested,
+			},
+			cty.DynamicPseudoType,
+		},
+		"block object": {
+			&BlockObjectSpec{
+				TypeName:   "thing",
+				LabelNames: []string{"name"},
+				Nested:     nested,
+			},
+			cty.DynamicPseudoType,
+		},
+		"block attrs": {
+			&BlockAttrsSpec{
+				TypeName:    "thing",
+				ElementType: optObj,
+			},
+			cty.Map(obj),
+		},
+		"default": {
+			&DefaultSpec{
+				Primary: &AttrSpec{Name: "foo", Type: optObj},
+				Default: &LiteralSpec{Value: cty.NullVal(obj)},
+			},
+			obj,
+		},
+		"validate wrapping block list": {
+			&ValidateSpec{
+				Wrapped: &BlockListSpec{
+					TypeName: "thing",
+					Nested:   nested,
+				},
+				Func: func(cty.Value) hcl.Diagnostics { return nil },
+			},
+			cty.List(nestedTy),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ImpliedType(test.spec)
+			if !got.Equals(test.want) {
+				t.Fatalf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+
+			if test.want == cty.DynamicPseudoType {
+				return
+			}
+
+			// Decoding an empty body must produce a value of the implied type.
+			val, diags := Decode(hcl.EmptyBody(), test.spec, nil)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags)
+			}
+			if !val.Type().Equals(got) {
+				t.Errorf("decoded value has wrong type\ngot:  %#v\nwant: %#v", val.Type(), got)
+			}
+		})
+	}
+}
+
 func TestSourceRange(t *testing.T) {
 	tests := []struct {
 		config string
@@ -1151,3 +1296,130 @@ b {
 	}
 
 }
+
+func TestDecodeWithExplicitPaths(t *testing.T) {
+	config := `
+name = "web"
+
+network {
+  port = 8080
+  tags = {
+    env = "prod"
+  }
+}
+
+disk {
+  size = 10
+}
+disk {
+}
+`
+	f, diags := hclsyntax.ParseConfig([]byte(config), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags)
+	}
+
+	spec := ObjectSpec{
+		"name": &AttrSpec{
+			Name: "name",
+			Type: cty.String,
+		},
+		"region": &DefaultSpec{
+			Primary: &AttrSpec{
+				Name: "region",
+				Type: cty.String,
+			},
+			Default: &LiteralSpec{
+				Value: cty.StringVal("us-east-1"),
+			},
+		},
+		"network": &BlockSpec{
+			TypeName: "network",
+			Nested: ObjectSpec{
+				"port": &DefaultSpec{
+					Primary: &AttrSpec{
+						Name: "port",
+						Type: cty.Number,
+					},
+					Default: &LiteralSpec{
+						Value: cty.NumberIntVal(80),
+					},
+				},
+				"protocol": &DefaultSpec{
+					Primary: &AttrSpec{
+						Name: "protocol",
+						Type: cty.String,
+					},
+					Default: &LiteralSpec{
+						Value: cty.StringVal("tcp"),
+					},
+				},
+				"tags": &AttrSpec{
+					Name: "tags",
+					Type: cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+						"env":   cty.String,
+						"owner": cty.String,
+					}, []string{"owner"}),
+				},
+			},
+		},
+		"disk": &BlockListSpec{
+			TypeName: "disk",
+			Nested: ObjectSpec{
+				"size": &DefaultSpec{
+					Primary: &AttrSpec{
+						Name: "size",
+						Type: cty.Number,
+					},
+					Default: &LiteralSpec{
+						Value: cty.NumberIntVal(1),
+					},
+				},
+			},
+		},
+	}
+
+	got, paths, diags := DecodeWithExplicitPaths(f.Body, spec, nil)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags)
+	}
+
+	want := cty.ObjectVal(map[string]cty.Value{
+		"name":   cty.StringVal("web"),
+		"region": cty.StringVal("us-east-1"),
+		"network": cty.ObjectVal(map[string]cty.Value{
+			"port":     cty.NumberIntVal(8080),
+			"protocol": cty.StringVal("tcp"),
+			"tags": cty.ObjectVal(map[string]cty.Value{
+				"env":   cty.StringVal("prod"),
+				"owner": cty.NullVal(cty.String),
+			}),
+		}),
+		"disk": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"size": cty.NumberIntVal(10),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"size": cty.NumberIntVal(1),
+			}),
+		}),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	wantPaths := []cty.Path{
+		cty.GetAttrPath("disk"),
+		cty.GetAttrPath("disk").IndexInt(0),
+		cty.GetAttrPath("disk").IndexInt(0).GetAttr("size"),
+		cty.GetAttrPath("disk").IndexInt(1),
+		cty.GetAttrPath("name"),
+		cty.GetAttrPath("network"),
+		cty.GetAttrPath("network").GetAttr("port"),
+		cty.GetAttrPath("network").GetAttr("tags"),
+		cty.GetAttrPath("network").GetAttr("tags").GetAttr("env"),
+	}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("wrong paths\ngot:  %#v\nwant: %#v", paths, wantPaths)
+	}
+}
@@ -510,7 +510,7 @@ func (s *BlockListSpec) decode(content *hcl.BodyContent, blockLabels []blockLabe
 	}
 
 	if len(elems) == 0 {
-		return cty.ListValEmpty(s.Nested.impliedType()), diags
+		return cty.ListValEmpty(s.Nested.impliedType().WithoutOptionalAttributesDeep()), diags
 	}
 
 	// Since our target is a list, all of the decoded elements must have the
@@ -797,7 +797,7 @@ func (s *BlockSetSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel
 	}
 
 	if len(elems) == 0 {
-		return cty.SetValEmpty(s.Nested.impliedType()), diags
+		return cty.SetValEmpty(s.Nested.impliedType().WithoutOptionalAttributesDeep()), diags
 	}
 
 	// Since our target is a set, all of the decoded elements must have the
@@ -970,7 +970,9 @@ func (s *BlockMapSpec) decode(content *hcl.BodyContent, blockLabels []blockLabel
 	}
 
 	if len(elems) == 0 {
-		return cty.MapValEmpty(s.Nested.impliedType()), diags
+		// With more than one label the result is a map of maps, so the
+		// element type is not necessarily the nested spec's type.
+		return cty.MapValEmpty(s.impliedType().WithoutOptionalAttributesDeep().ElementType()), diags
 	}
 
 	var ctyMap func(map[string]interface{}, int) cty.Value
@@ -1537,7 +1539,7 @@ func (s *TransformExprSpec) decode(content *hcl.BodyContent, blockLabels []block
 }
 
 func (s *TransformExprSpec) impliedType() cty.Type {
-	wrappedTy := s.Wrapped.impliedType()
+	wrappedTy := s.Wrapped.impliedType().WithoutOptionalAttributesDeep()
 	chiCtx := s.TransformCtx.NewChild()
 	chiCtx.Variables = map[string]cty.Value{
 		s.VarName: cty.UnknownVal(wrappedTy),
@@ -1601,7 +1603,7 @@ func (s *TransformFuncSpec) decode(content *hcl.BodyContent, blockLabels []block
 }
 
 func (s *TransformFuncSpec) impliedType() cty.Type {
-	wrappedTy := s.Wrapped.impliedType()
+	wrappedTy := s.Wrapped.impliedType().WithoutOptionalAttributesDeep()
 	resultTy, err := s.Func.ReturnType([]cty.Type{wrappedTy})
 	if err != nil {
 		// Should never happen with a correctly-configured spec
@@ -0,0 +1,323 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcldec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// SpecFromJSON builds a spec from a JSON description, allowing applications
+// to load their validation rules as data at runtime rather than constructing
+// them in Go code.
+//
+// Each spec is described by a JSON object with a single property whose name
+// selects the kind of spec, using the same names and arguments as the spec
+// file format of the hcldec command line tool:
+//
+//	{
+//	  "object": {
+//	    "name": {"attr": {"name": "name", "type": "string", "required": true}},
+//	    "tags": {"attr": {"name": "tags", "type": "map(string)"}},
+//	    "disks": {
+//	      "block_list": {
+//	        "block_type": "disk",
+//	        "min_items": 1,
+//	        "nested": {
+//	          "object": {
+//	            "size": {
+//	              "default": [
+//	                {"attr": {"name": "size", "type": "number"}},
+//	                {"literal": {"value": 10}}
+//	              ]
+//	            }
+//	          }
+//	        }
+//	      }
+//	    }
+//	  }
+//	}
+//
+// The supported kinds are "object", "array", "attr", "block", "block_list",
+// "block_set", "block_map", "block_attrs", "block_label", "literal", and
+// "default". Type arguments are written using the type constraint syntax
+// from package typeexpr. An error is returned if the description is not
+// valid, including if it uses an unsupported kind of spec.
+func SpecFromJSON(data []byte) (Spec, error) {
+	return specFromJSON(json.RawMessage(data), "spec")
+}
+
+func specFromJSON(raw json.RawMessage, path string) (Spec, error) {
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &wrapper); err != nil {
+		return nil, fmt.Errorf("%s: a spec must be a JSON object: %w", path, err)
+	}
+	if len(wrapper) != 1 {
+		return nil, fmt.Errorf("%s: a spec must have exactly one property, naming the kind of spec", path)
+	}
+
+	var kind string
+	var body json.RawMessage
+	for k, v := range wrapper {
+		kind, body = k, v
+	}
+	path = path + "." + kind
+
+	switch kind {
+	case "object":
+		var raws map[string]json.RawMessage
+		if err := decodeSpecJSON(body, &raws, path); err != nil {
+			return nil, err
+		}
+		ret := make(ObjectSpec, len(raws))
+		for name, childRaw := range raws {
+			child, err := specFromJSON(childRaw, path+"."+name)
+			if err != nil {
+				return nil, err
+			}
+			ret[name] = child
+		}
+		return ret, nil
+
+	case "array":
+		var raws []json.RawMessage
+		if err := decodeSpecJSON(body, &raws, path); err != nil {
+			return nil, err
+		}
+		ret := make(TupleSpec, len(raws))
+		for i, childRaw := range raws {
+			child, err := specFromJSON(childRaw, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = child
+		}
+		return ret, nil
+
+	case "attr":
+		var args struct {
+			Name     string `json:"name"`
+			Type     string `json:"type"`
+			Required bool   `json:"required"`
+		}
+		if err := decodeSpecJSON(body, &args, path); err != nil {
+			return nil, err
+		}
+		if args.Name == "" {
+			return nil, fmt.Errorf("%s: missing required argument \"name\"", path)
+		}
+		ty, err := typeFromJSON(args.Type, path)
+		if err != nil {
+			return nil, err
+		}
+		return &AttrSpec{
+			Name:     args.Name,
+			Type:     ty,
+			Required: args.Required,
+		}, nil
+
+	case "block", "block_list", "block_set", "block_map":
+		var args struct {
+			BlockType string          `json:"block_type"`
+			Required  bool            `json:"required"`
+			MinItems  int             `json:"min_items"`
+			MaxItems  int             `json:"max_items"`
+			Labels    []string        `json:"labels"`
+			Nested    json.RawMessage `json:"nested"`
+		}
+		if err := decodeSpecJSON(body, &args, path); err != nil {
+			return nil, err
+		}
+		if args.BlockType == "" {
+			return nil, fmt.Errorf("%s: missing required argument \"block_type\"", path)
+		}
+		if len(args.Nested) == 0 {
+			return nil, fmt.Errorf("%s: missing required argument \"nested\"", path)
+		}
+		nested, err := specFromJSON(args.Nested, path+".nested")
+		if err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case "block":
+			return &BlockSpec{
+				TypeName: args.BlockType,
+				Nested:   nested,
+				Required: args.Required,
+			}, nil
+		case "block_list":
+			return &BlockListSpec{
+				TypeName: args.BlockType,
+				Nested:   nested,
+				MinItems: args.MinItems,
+				MaxItems: args.MaxItems,
+			}, nil
+		case "block_set":
+			return &BlockSetSpec{
+				TypeName: args.BlockType,
+				Nested:   nested,
+				MinItems: args.MinItems,
+				MaxItems: args.MaxItems,
+			}, nil
+		default: // "block_map"
+			if len(args.Labels) == 0 {
+				return nil, fmt.Errorf("%s: missing required argument \"labels\"", path)
+			}
+			if ImpliedType(nested).HasDynamicTypes() {
+				return nil, fmt.Errorf("%s: nested spec must not use the \"any\" type constraint", path)
+			}
+			return &BlockMapSpec{
+				TypeName:   args.BlockType,
+				LabelNames: args.Labels,
+				Nested:     nested,
+			}, nil
+		}
+
+	case "block_attrs":
+		var args struct {
+			BlockType   string `json:"block_type"`
+			ElementType string `json:"element_type"`
+			Required    bool   `json:"required"`
+		}
+		if err := decodeSpecJSON(body, &args, path); err != nil {
+			return nil, err
+		}
+		if args.BlockType == "" {
+			return nil, fmt.Errorf("%s: missing required argument \"block_type\"", path)
+		}
+		if args.ElementType == "" {
+			return nil, fmt.Errorf("%s: missing required argument \"element_type\"", path)
+		}
+		ty, err := typeFromJSON(args.ElementType, path)
+		if err != nil {
+			return nil, err
+		}
+		return &BlockAttrsSpec{
+			TypeName:    args.BlockType,
+			ElementType: ty,
+			Required:    args.Required,
+		}, nil
+
+	case "block_label":
+		var args struct {
+			Index int    `json:"index"`
+			Name  string `json:"name"`
+		}
+		if err := decodeSpecJSON(body, &args, path); err != nil {
+			return nil, err
+		}
+		if args.Name == "" {
+			return nil, fmt.Errorf("%s: missing required argument \"name\"", path)
+		}
+		if args.Index < 0 {
+			return nil, fmt.Errorf("%s: label index must not be negative", path)
+		}
+		return &BlockLabelSpec{
+			Index: args.Index,
+			Name:  args.Name,
+		}, nil
+
+	case "literal":
+		var args struct {
+			Value json.RawMessage `json:"value"`
+			Type  string          `json:"type"`
+		}
+		if err := decodeSpecJSON(body, &args, path); err != nil {
+			return nil, err
+		}
+		if len(args.Value) == 0 {
+			return nil, fmt.Errorf("%s: missing required argument \"value\"", path)
+		}
+		var ty cty.Type
+		if args.Type != "" {
+			var err error
+			ty, err = typeFromJSON(args.Type, path)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			var err error
+			ty, err = ctyjson.ImpliedType(args.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid value: %w", path, err)
+			}
+		}
+		val, err := ctyjson.Unmarshal(args.Value, ty)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid value: %w", path, err)
+		}
+		return &LiteralSpec{
+			Value: val,
+		}, nil
+
+	case "default":
+		var raws []json.RawMessage
+		if err := decodeSpecJSON(body, &raws, path); err != nil {
+			return nil, err
+		}
+		if len(raws) == 0 {
+			return nil, fmt.Errorf("%s: must have at least one nested spec", path)
+		}
+
+		// As with the hcldec tool, each spec is a fallback for the one
+		// before it, so we build a chain of DefaultSpecs from the end.
+		var ret Spec
+		for i := len(raws) - 1; i >= 0; i-- {
+			child, err := specFromJSON(raws[i], fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			if ret == nil {
+				ret = child
+				continue
+			}
+			ret = &DefaultSpec{
+				Primary: child,
+				Default: ret,
+			}
+		}
+		return ret, nil
+
+	default:
+		return nil, fmt.Errorf("%s: unsupported spec kind %q; must be one of %s", path, kind, supportedJSONSpecKinds)
+	}
+}
+
+// decodeSpecJSON decodes the arguments of a spec, rejecting any arguments
+// that the spec kind doesn't support.
+func decodeSpecJSON(raw json.RawMessage, target interface{}, path string) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(target); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// typeFromJSON parses a type constraint given as a string in a JSON spec.
+// An empty string represents the "any" type constraint.
+func typeFromJSON(src string, path string) (cty.Type, error) {
+	if src == "" {
+		return cty.DynamicPseudoType, nil
+	}
+	expr, diags := hclsyntax.ParseExpression([]byte(src), path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.NilType, fmt.Errorf("%s: invalid type %q: %s", path, src, diags.Error())
+	}
+	ty, diags := typeexpr.TypeConstraint(expr)
+	if diags.HasErrors() {
+		return cty.NilType, fmt.Errorf("%s: invalid type %q: %s", path, src, diags.Error())
+	}
+	return ty, nil
+}
+
+const supportedJSONSpecKinds = `"array", "attr", "block", "block_attrs", "block_label", "block_list", "block_map", "block_set", "default", "literal", "object"`
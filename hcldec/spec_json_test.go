@@ -0,0 +1,172 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcldec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSpecFromJSON(t *testing.T) {
+	tests := map[string]struct {
+		spec    string
+		config  string
+		want    cty.Value
+		wantErr string
+	}{
+		"attributes and blocks": {
+			spec: `{
+				"object": {
+					"name": {"attr": {"name": "name", "type": "string", "required": true}},
+					"tags": {"attr": {"name": "tags", "type": "map(string)"}},
+					"disks": {
+						"block_list": {
+							"block_type": "disk",
+							"min_items": 1,
+							"nested": {
+								"object": {
+									"size": {
+										"default": [
+											{"attr": {"name": "size", "type": "number"}},
+											{"literal": {"value": 10}}
+										]
+									}
+								}
+							}
+						}
+					}
+				}
+			}`,
+			config: `
+name = "web"
+disk {
+  size = 20
+}
+disk {
+}
+`,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+				"tags": cty.NullVal(cty.Map(cty.String)),
+				"disks": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{"size": cty.NumberIntVal(20)}),
+					cty.ObjectVal(map[string]cty.Value{"size": cty.NumberIntVal(10)}),
+				}),
+			}),
+		},
+		"block map": {
+			spec: `{
+				"block_map": {
+					"block_type": "service",
+					"labels": ["name"],
+					"nested": {"attr": {"name": "port", "type": "number"}}
+				}
+			}`,
+			config: `
+service "http" {
+  port = 80
+}
+`,
+			want: cty.MapVal(map[string]cty.Value{
+				"http": cty.NumberIntVal(80),
+			}),
+		},
+		"block with label": {
+			spec: `{
+				"block": {
+					"block_type": "service",
+					"nested": {
+						"array": [
+							{"block_label": {"index": 0, "name": "name"}},
+							{"attr": {"name": "port"}}
+						]
+					}
+				}
+			}`,
+			config: `
+service "http" {
+  port = 80
+}
+`,
+			want: cty.TupleVal([]cty.Value{
+				cty.StringVal("http"),
+				cty.NumberIntVal(80),
+			}),
+		},
+		"block attrs": {
+			spec: `{"block_attrs": {"block_type": "env", "element_type": "string"}}`,
+			config: `
+env {
+  FOO = "bar"
+}
+`,
+			want: cty.MapVal(map[string]cty.Value{
+				"FOO": cty.StringVal("bar"),
+			}),
+		},
+		"literal with type": {
+			spec:   `{"literal": {"value": ["a"], "type": "set(string)"}}`,
+			config: ``,
+			want:   cty.SetVal([]cty.Value{cty.StringVal("a")}),
+		},
+		"unsupported kind": {
+			spec:    `{"object": {"foo": {"attribute": {"name": "foo"}}}}`,
+			wantErr: `spec.object.foo.attribute: unsupported spec kind "attribute"`,
+		},
+		"multiple kinds": {
+			spec:    `{"attr": {"name": "foo"}, "literal": {"value": 1}}`,
+			wantErr: `spec: a spec must have exactly one property`,
+		},
+		"invalid type": {
+			spec:    `{"attr": {"name": "foo", "type": "strang"}}`,
+			wantErr: `spec.attr: invalid type "strang"`,
+		},
+		"unknown argument": {
+			spec:    `{"attr": {"name": "foo", "optional": true}}`,
+			wantErr: `spec.attr: json: unknown field "optional"`,
+		},
+		"block map with dynamic types": {
+			spec:    `{"block_map": {"block_type": "foo", "labels": ["name"], "nested": {"attr": {"name": "bar"}}}}`,
+			wantErr: `spec.block_map: nested spec must not use the "any" type constraint`,
+		},
+		"missing nested": {
+			spec:    `{"block": {"block_type": "foo"}}`,
+			wantErr: `spec.block: missing required argument "nested"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			spec, err := SpecFromJSON([]byte(test.spec))
+			if test.wantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success; want error containing %q", test.wantErr)
+				}
+				if !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("wrong error\ngot:  %s\nwant: %s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			f, diags := hclsyntax.ParseConfig([]byte(test.config), "test.hcl", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse diagnostics: %s", diags.Error())
+			}
+			got, diags := Decode(f.Body, spec, nil)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected decode diagnostics: %s", diags.Error())
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"bytes"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// Annotation is a directive embedded in a comment, such as # @deprecated,
+// which was extracted by the parser as selected by
+// ParseOptions.AnnotationPrefix.
+type Annotation struct {
+	// Text is the content of the comment following the annotation prefix,
+	// with any surrounding whitespace removed. For example, the comment
+	// # @deprecated use "name" instead has the text
+	// deprecated use "name" instead when the prefix is "@".
+	Text string
+
+	// Range is the source range of the whole comment.
+	Range hcl.Range
+}
+
+// commentAnnotation returns the annotation represented by the given comment
+// token, or false if the comment doesn't begin with the given prefix.
+func commentAnnotation(tok Token, prefix string) (Annotation, bool) {
+	text := tok.Bytes
+	switch {
+	case bytes.HasPrefix(text, []byte("#")):
+		text = text[1:]
+	case bytes.HasPrefix(text, []byte("//")):
+		text = text[2:]
+	case bytes.HasPrefix(text, []byte("/*")):
+		text = bytes.TrimSuffix(text[2:], []byte("*/"))
+	}
+	text = bytes.TrimSpace(text)
+	if !bytes.HasPrefix(text, []byte(prefix)) {
+		return Annotation{}, false
+	}
+
+	return Annotation{
+		Text:  string(bytes.TrimSpace(text[len(prefix):])),
+		Range: tok.Range,
+	}, true
+}
+
+// annotationsBefore returns the annotations in the comments between the
+// token at the given start index and the next token the peeker would
+// return, or nil if annotations are not enabled.
+func (p *parser) annotationsBefore(start int) []Annotation {
+	if p.annotationPrefix == "" {
+		return nil
+	}
+
+	_, next := p.nextToken()
+	var ret []Annotation
+	for _, tok := range p.Tokens[start : next-1] {
+		if tok.Type != TokenComment {
+			continue
+		}
+		if annot, ok := commentAnnotation(tok, p.annotationPrefix); ok {
+			ret = append(ret, annot)
+		}
+	}
+	return ret
+}
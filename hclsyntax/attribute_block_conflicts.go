@@ -0,0 +1,53 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// DetectAttributeBlockConflicts returns a warning diagnostic for each name
+// that is used both as an attribute name and as a block type within the same
+// body, either the given body or the body of any block nested inside it.
+//
+// Whether such a body is valid depends on the schema it is decoded with, and
+// so the decoder will typically report an error for only one of the two or,
+// in some cases, silently ignore one of them. This function works on the raw
+// body without a schema, so that applications can warn about this confusing
+// mistake regardless of how the body will eventually be decoded.
+//
+// Each diagnostic refers to the name of the attribute, with a context range
+// covering both the attribute and the header of the first block of the
+// conflicting type, and its detail message includes the location of the block.
+func DetectAttributeBlockConflicts(body *Body) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	if body == nil {
+		return diags
+	}
+	diags = append(diags, body.parseDeferred()...)
+
+	reported := make(map[string]struct{})
+	for _, block := range body.Blocks {
+		attr, conflict := body.Attributes[block.Type]
+		if _, done := reported[block.Type]; conflict && !done {
+			reported[block.Type] = struct{}{}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Attribute and block with the same name",
+				Detail: fmt.Sprintf(
+					"The name %q is used for both an attribute and a block, at %s. Only one of them will be used when the configuration is decoded, so one of them should be renamed or removed.",
+					block.Type, block.TypeRange,
+				),
+				Subject: attr.NameRange.Ptr(),
+				Context: hcl.RangeOver(attr.SrcRange, hcl.RangeBetween(block.TypeRange, block.OpenBraceRange)).Ptr(),
+			})
+		}
+
+		diags = append(diags, DetectAttributeBlockConflicts(block.Body)...)
+	}
+
+	return diags
+}
@@ -0,0 +1,106 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestDetectAttributeBlockConflicts(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want []hcl.Range // subject and then context for each diagnostic
+	}{
+		"empty": {
+			``,
+			nil,
+		},
+		"no conflicts": {
+			`
+name = "a"
+network {}
+`,
+			nil,
+		},
+		"conflict": {
+			`
+network = {}
+network {}
+network {}
+`,
+			[]hcl.Range{
+				{
+					Start: hcl.Pos{Line: 2, Column: 1, Byte: 1},
+					End:   hcl.Pos{Line: 2, Column: 8, Byte: 8},
+				},
+				{
+					Start: hcl.Pos{Line: 2, Column: 1, Byte: 1},
+					End:   hcl.Pos{Line: 3, Column: 10, Byte: 23},
+				},
+			},
+		},
+		"nested conflict": {
+			`
+service {
+  port {}
+  port = 80
+}
+`,
+			[]hcl.Range{
+				{
+					Start: hcl.Pos{Line: 4, Column: 3, Byte: 23},
+					End:   hcl.Pos{Line: 4, Column: 7, Byte: 27},
+				},
+				{
+					Start: hcl.Pos{Line: 3, Column: 3, Byte: 13},
+					End:   hcl.Pos{Line: 4, Column: 12, Byte: 32},
+				},
+			},
+		},
+		"different bodies": {
+			`
+port = 80
+service {
+  port {}
+}
+`,
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfig([]byte(test.src), "test.hcl", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags)
+			}
+
+			diags = DetectAttributeBlockConflicts(f.Body.(*Body))
+			if len(diags) != len(test.want)/2 {
+				t.Fatalf("wrong number of diagnostics %d; want %d\n%s", len(diags), len(test.want)/2, diags)
+			}
+			for i, diag := range diags {
+				if diag.Severity != hcl.DiagWarning {
+					t.Errorf("diagnostic %d is not a warning", i)
+				}
+				want := test.want[i*2]
+				want.Filename = "test.hcl"
+				if got := *diag.Subject; got != want {
+					t.Errorf("wrong subject for diagnostic %d\ngot:  %#v\nwant: %#v", i, got, want)
+				}
+				want = test.want[i*2+1]
+				want.Filename = "test.hcl"
+				if got := *diag.Context; got != want {
+					t.Errorf("wrong context for diagnostic %d\ngot:  %#v\nwant: %#v", i, got, want)
+				}
+				if !strings.Contains(diag.Detail, "test.hcl:") {
+					t.Errorf("detail for diagnostic %d does not include the block location: %s", i, diag.Detail)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// ValidateExactlyOneOf returns error diagnostics unless exactly one of the
+// attributes of the given names is present in the given body.
+//
+// This is equivalent to using the zero value of PresenceOptions, so an
+// attribute set explicitly to null counts as present. Use
+// PresenceOptions.ValidateExactlyOneOf to customize that.
+func ValidateExactlyOneOf(body *Body, names ...string) hcl.Diagnostics {
+	return PresenceOptions{}.ValidateExactlyOneOf(body, names...)
+}
+
+// ValidateAtMostOneOf returns error diagnostics if more than one of the
+// attributes of the given names is present in the given body.
+//
+// This is equivalent to using the zero value of PresenceOptions, so an
+// attribute set explicitly to null counts as present. Use
+// PresenceOptions.ValidateAtMostOneOf to customize that.
+func ValidateAtMostOneOf(body *Body, names ...string) hcl.Diagnostics {
+	return PresenceOptions{}.ValidateAtMostOneOf(body, names...)
+}
+
+// ValidateAtLeastOneOf returns an error diagnostic if none of the attributes
+// of the given names are present in the given body.
+//
+// This is equivalent to using the zero value of PresenceOptions, so an
+// attribute set explicitly to null counts as present. Use
+// PresenceOptions.ValidateAtLeastOneOf to customize that.
+func ValidateAtLeastOneOf(body *Body, names ...string) hcl.Diagnostics {
+	return PresenceOptions{}.ValidateAtLeastOneOf(body, names...)
+}
+
+// PresenceOptions customizes how the attribute presence validation functions
+// decide whether an attribute is present.
+type PresenceOptions struct {
+	// NullIsAbsent causes attributes whose expressions are statically null,
+	// such as a = null, to be treated as if they were not present at all.
+	// Expressions that could only be null after evaluation with variables
+	// or functions are still considered to be present.
+	NullIsAbsent bool
+}
+
+// ValidateExactlyOneOf is like the package-level function of the same name,
+// but uses the receiving options to decide which attributes are present.
+func (o PresenceOptions) ValidateExactlyOneOf(body *Body, names ...string) hcl.Diagnostics {
+	present, diags := o.presentAttributes(body, names)
+	if len(present) == 0 {
+		return append(diags, o.missingDiagnostic(body, "Exactly one", names))
+	}
+	return append(diags, o.conflictDiagnostics(present, names)...)
+}
+
+// ValidateAtMostOneOf is like the package-level function of the same name,
+// but uses the receiving options to decide which attributes are present.
+func (o PresenceOptions) ValidateAtMostOneOf(body *Body, names ...string) hcl.Diagnostics {
+	present, diags := o.presentAttributes(body, names)
+	return append(diags, o.conflictDiagnostics(present, names)...)
+}
+
+// ValidateAtLeastOneOf is like the package-level function of the same name,
+// but uses the receiving options to decide which attributes are present.
+func (o PresenceOptions) ValidateAtLeastOneOf(body *Body, names ...string) hcl.Diagnostics {
+	present, diags := o.presentAttributes(body, names)
+	if len(present) == 0 {
+		return append(diags, o.missingDiagnostic(body, "At least one", names))
+	}
+	return diags
+}
+
+// presentAttributes returns the attributes of the given names that are
+// present in the given body, in the order they appear in the source,
+// along with any diagnostics from parsing the body if its parsing was
+// deferred by ParseOptions.LazyBlockBodies.
+func (o PresenceOptions) presentAttributes(body *Body, names []string) ([]*Attribute, hcl.Diagnostics) {
+	diags := body.parseDeferred()
+	var ret []*Attribute
+	for _, name := range names {
+		attr, exists := body.Attributes[name]
+		if !exists {
+			continue
+		}
+		if o.NullIsAbsent && len(attr.Expr.Variables()) == 0 {
+			val, diags := attr.Expr.Value(nil)
+			if !diags.HasErrors() && val.IsNull() {
+				continue
+			}
+		}
+		ret = append(ret, attr)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].SrcRange.Start.Byte < ret[j].SrcRange.Start.Byte
+	})
+	return ret, diags
+}
+
+func (o PresenceOptions) missingDiagnostic(body *Body, quantity string, names []string) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Missing required argument",
+		Detail:   fmt.Sprintf("%s of the arguments %s must be set.", quantity, presenceNamesList(names)),
+		Subject:  body.MissingItemRange().Ptr(),
+	}
+}
+
+// conflictDiagnostics returns one error diagnostic for each of the given
+// present attributes after the first, since only one may be present.
+func (o PresenceOptions) conflictDiagnostics(present []*Attribute, names []string) hcl.Diagnostics {
+	if len(present) < 2 {
+		return nil
+	}
+
+	var diags hcl.Diagnostics
+	first := present[0]
+	for _, attr := range present[1:] {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Conflicting arguments",
+			Detail: fmt.Sprintf(
+				"The argument %q cannot be set together with %q, at %s. Only one of the arguments %s may be set.",
+				attr.Name, first.Name, first.NameRange, presenceNamesList(names),
+			),
+			Subject: attr.NameRange.Ptr(),
+			Context: hcl.RangeBetween(first.SrcRange, attr.SrcRange).Ptr(),
+		})
+	}
+	return diags
+}
+
+func presenceNamesList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return strings.Join(quoted, ", ")
+}
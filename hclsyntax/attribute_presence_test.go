@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestValidateOneOf(t *testing.T) {
+	type validateFunc func(o PresenceOptions, body *Body, names ...string) hcl.Diagnostics
+	exactlyOne := PresenceOptions.ValidateExactlyOneOf
+	atMostOne := PresenceOptions.ValidateAtMostOneOf
+	atLeastOne := PresenceOptions.ValidateAtLeastOneOf
+
+	tests := map[string]struct {
+		src          string
+		validate     validateFunc
+		nullIsAbsent bool
+
+		// Each diagnostic is described by its summary and the line where
+		// its subject starts.
+		wantSummaries []string
+		wantLines     []int
+	}{
+		"exactly one, one set": {
+			"a = 1\nother = 2\n",
+			exactlyOne,
+			false,
+			nil,
+			nil,
+		},
+		"exactly one, none set": {
+			"other = 2\n",
+			exactlyOne,
+			false,
+			[]string{"Missing required argument"},
+			[]int{1},
+		},
+		"exactly one, several set": {
+			"c = 3\nb = 2\na = 1\n",
+			exactlyOne,
+			false,
+			[]string{"Conflicting arguments", "Conflicting arguments"},
+			[]int{2, 3},
+		},
+		"exactly one, null counts": {
+			"a = 1\nb = null\n",
+			exactlyOne,
+			false,
+			[]string{"Conflicting arguments"},
+			[]int{2},
+		},
+		"exactly one, null is absent": {
+			"a = 1\nb = null\n",
+			exactlyOne,
+			true,
+			nil,
+			nil,
+		},
+		"exactly one, only null": {
+			"a = null\n",
+			exactlyOne,
+			true,
+			[]string{"Missing required argument"},
+			[]int{1},
+		},
+		"exactly one, dynamic null is present": {
+			"a = 1\nb = var.maybe_null\n",
+			exactlyOne,
+			true,
+			[]string{"Conflicting arguments"},
+			[]int{2},
+		},
+		"at most one, none set": {
+			"other = 2\n",
+			atMostOne,
+			false,
+			nil,
+			nil,
+		},
+		"at most one, two set": {
+			"a = 1\nb = 2\n",
+			atMostOne,
+			false,
+			[]string{"Conflicting arguments"},
+			[]int{2},
+		},
+		"at least one, none set": {
+			"other = 2\n",
+			atLeastOne,
+			false,
+			[]string{"Missing required argument"},
+			[]int{1},
+		},
+		"at least one, several set": {
+			"a = 1\nb = 2\nc = 3\n",
+			atLeastOne,
+			false,
+			nil,
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfig([]byte(test.src), "test.hcl", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags)
+			}
+
+			opts := PresenceOptions{NullIsAbsent: test.nullIsAbsent}
+			diags = test.validate(opts, f.Body.(*Body), "a", "b", "c")
+			if len(diags) != len(test.wantSummaries) {
+				t.Fatalf("wrong number of diagnostics %d; want %d\n%s", len(diags), len(test.wantSummaries), diags)
+			}
+			for i, diag := range diags {
+				if diag.Severity != hcl.DiagError {
+					t.Errorf("diagnostic %d is not an error", i)
+				}
+				if got, want := diag.Summary, test.wantSummaries[i]; got != want {
+					t.Errorf("wrong summary for diagnostic %d\ngot:  %s\nwant: %s", i, got, want)
+				}
+				if got, want := diag.Subject.Start.Line, test.wantLines[i]; got != want {
+					t.Errorf("wrong subject line for diagnostic %d: got %d, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOneOf_packageFuncs(t *testing.T) {
+	f, diags := ParseConfig([]byte("a = 1\nb = null\n"), "test.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected parse errors: %s", diags)
+	}
+	body := f.Body.(*Body)
+
+	if diags := ValidateExactlyOneOf(body, "a", "b"); len(diags) != 1 {
+		t.Errorf("wrong number of ValidateExactlyOneOf diagnostics %d; want 1\n%s", len(diags), diags)
+	}
+	if diags := ValidateAtMostOneOf(body, "a", "b"); len(diags) != 1 {
+		t.Errorf("wrong number of ValidateAtMostOneOf diagnostics %d; want 1\n%s", len(diags), diags)
+	}
+	if diags := ValidateAtLeastOneOf(body, "c"); len(diags) != 1 {
+		t.Errorf("wrong number of ValidateAtLeastOneOf diagnostics %d; want 1\n%s", len(diags), diags)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// ValidateBlockLabels checks that each label of every block of the given
+// type that appears directly in the given body matches the given pattern,
+// returning an error diagnostic for each label that does not.
+//
+// This is intended as a linting helper for enforcing naming conventions,
+// such as requiring that labels be lowercase and separated by hyphens. It
+// does not recurse into nested blocks, and it does not check that blocks
+// have the expected number of labels; that is the job of the schema given
+// when decoding the body.
+func ValidateBlockLabels(body *Body, typeName string, pattern *regexp.Regexp) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	if body == nil {
+		return diags
+	}
+	diags = append(diags, body.parseDeferred()...)
+
+	for _, block := range body.Blocks {
+		if block.Type != typeName {
+			continue
+		}
+		for i, label := range block.Labels {
+			if pattern.MatchString(label) {
+				continue
+			}
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid block label",
+				Detail:   fmt.Sprintf("The label %q for this %s block does not match the required pattern %s.", label, typeName, pattern),
+				Subject:  block.LabelRanges[i].Ptr(),
+				Context:  hcl.RangeBetween(block.TypeRange, block.OpenBraceRange).Ptr(),
+			})
+		}
+	}
+
+	return diags
+}
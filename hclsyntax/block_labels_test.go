@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestValidateBlockLabels(t *testing.T) {
+	pattern := regexp.MustCompile(`^[a-z]+(-[a-z]+)*$`)
+
+	tests := map[string]struct {
+		src  string
+		want []hcl.Range
+	}{
+		"no blocks": {
+			``,
+			nil,
+		},
+		"all valid": {
+			`
+service "web-server" "eu-west" {}
+service "db" "us" {}
+`,
+			nil,
+		},
+		"invalid labels": {
+			`
+service "Web_Server" "eu-west" {}
+service "db" "US" {}
+`,
+			[]hcl.Range{
+				{
+					Start: hcl.Pos{Line: 2, Column: 9, Byte: 9},
+					End:   hcl.Pos{Line: 2, Column: 21, Byte: 21},
+				},
+				{
+					Start: hcl.Pos{Line: 3, Column: 14, Byte: 48},
+					End:   hcl.Pos{Line: 3, Column: 18, Byte: 52},
+				},
+			},
+		},
+		"other block types ignored": {
+			`
+other "Web_Server" {}
+service "ok" {
+  service "Nested_Is_Ignored" {}
+}
+`,
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfig([]byte(test.src), "test.hcl", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags)
+			}
+
+			diags = ValidateBlockLabels(f.Body.(*Body), "service", pattern)
+			if len(diags) != len(test.want) {
+				t.Fatalf("wrong number of diagnostics %d; want %d\n%s", len(diags), len(test.want), diags)
+			}
+			for i, diag := range diags {
+				if diag.Severity != hcl.DiagError {
+					t.Errorf("diagnostic %d is not an error", i)
+				}
+				want := test.want[i]
+				want.Filename = "test.hcl"
+				if got := *diag.Subject; got != want {
+					t.Errorf("wrong subject for diagnostic %d\ngot:  %#v\nwant: %#v", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// ConcatFiles concatenates the given files, in lexical order of their
+// filenames, into a single source buffer, and returns that buffer along
+// with a function that maps positions in it back to the file and position
+// they came from.
+//
+// A newline is inserted after any file that doesn't already end with one, so
+// that each file starts on a new line of the result. A position within such
+// an inserted newline maps to the end of the file it follows, as does any
+// position beyond the end of the result. If there are no files then the
+// source map returns an empty filename and the given position unchanged.
+//
+// The mapping is based on the Byte field of the given position, with the
+// line and column then adjusted to match. Tools that parse the combined
+// source can use this to report diagnostics against the original files,
+// though ranges that span more than one file cannot be represented.
+func ConcatFiles(files map[string][]byte) (combined []byte, sourceMap func(hcl.Pos) (string, hcl.Pos)) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type segment struct {
+		filename string
+		start    hcl.Pos
+		size     int
+		end      hcl.Pos // the position after the last byte of the file
+	}
+	segments := make([]segment, 0, len(names))
+
+	var buf bytes.Buffer
+	line := 1
+	for _, name := range names {
+		src := files[name]
+		seg := segment{
+			filename: name,
+			start:    hcl.Pos{Line: line, Column: 1, Byte: buf.Len()},
+			size:     len(src),
+			end:      hcl.OffsetToPos(src, len(src)),
+		}
+		segments = append(segments, seg)
+
+		buf.Write(src)
+		line += bytes.Count(src, []byte{'\n'})
+		if len(src) > 0 && src[len(src)-1] != '\n' {
+			buf.WriteByte('\n')
+			line++
+		}
+	}
+
+	sourceMap = func(pos hcl.Pos) (string, hcl.Pos) {
+		if len(segments) == 0 {
+			return "", pos
+		}
+
+		// Find the last file that starts at or before the given position.
+		i := sort.Search(len(segments), func(i int) bool {
+			return segments[i].start.Byte > pos.Byte
+		}) - 1
+		if i < 0 {
+			i = 0
+		}
+		seg := segments[i]
+
+		offset := pos.Byte - seg.start.Byte
+		if offset < 0 {
+			return seg.filename, hcl.InitialPos
+		}
+		if offset > seg.size {
+			return seg.filename, seg.end
+		}
+		return seg.filename, hcl.Pos{
+			Line:   pos.Line - seg.start.Line + 1,
+			Column: pos.Column,
+			Byte:   offset,
+		}
+	}
+	return buf.Bytes(), sourceMap
+}
@@ -0,0 +1,113 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestConcatFiles(t *testing.T) {
+	files := map[string][]byte{
+		"b.hcl": []byte("b = 2\nc = 3\n"),
+		"a.hcl": []byte("a = 1"),
+		"c.hcl": []byte("d = [\n  4,\n]\n"),
+	}
+	combined, sourceMap := ConcatFiles(files)
+
+	if got, want := string(combined), "a = 1\nb = 2\nc = 3\nd = [\n  4,\n]\n"; got != want {
+		t.Fatalf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	tests := map[string]struct {
+		pos          hcl.Pos
+		wantFilename string
+		wantPos      hcl.Pos
+	}{
+		"start": {
+			hcl.Pos{Line: 1, Column: 1, Byte: 0},
+			"a.hcl",
+			hcl.Pos{Line: 1, Column: 1, Byte: 0},
+		},
+		"within first file": {
+			hcl.Pos{Line: 1, Column: 5, Byte: 4},
+			"a.hcl",
+			hcl.Pos{Line: 1, Column: 5, Byte: 4},
+		},
+		"inserted newline": {
+			hcl.Pos{Line: 1, Column: 6, Byte: 5},
+			"a.hcl",
+			hcl.Pos{Line: 1, Column: 6, Byte: 5},
+		},
+		"start of second file": {
+			hcl.Pos{Line: 2, Column: 1, Byte: 6},
+			"b.hcl",
+			hcl.Pos{Line: 1, Column: 1, Byte: 0},
+		},
+		"second line of second file": {
+			hcl.Pos{Line: 3, Column: 5, Byte: 16},
+			"b.hcl",
+			hcl.Pos{Line: 2, Column: 5, Byte: 10},
+		},
+		"within third file": {
+			hcl.Pos{Line: 5, Column: 3, Byte: 26},
+			"c.hcl",
+			hcl.Pos{Line: 2, Column: 3, Byte: 8},
+		},
+		"beyond end": {
+			hcl.Pos{Line: 9, Column: 1, Byte: 100},
+			"c.hcl",
+			hcl.Pos{Line: 4, Column: 1, Byte: 13},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotFilename, gotPos := sourceMap(test.pos)
+			if gotFilename != test.wantFilename {
+				t.Errorf("wrong filename %q; want %q", gotFilename, test.wantFilename)
+			}
+			if gotPos != test.wantPos {
+				t.Errorf("wrong position\ngot:  %#v\nwant: %#v", gotPos, test.wantPos)
+			}
+		})
+	}
+
+	t.Run("diagnostics", func(t *testing.T) {
+		_, diags := ParseConfig(combined, "bundle.hcl", hcl.InitialPos)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+
+		bad := map[string][]byte{
+			"a.hcl": []byte("a = 1\n"),
+			"b.hcl": []byte("b = 2\nc = \n"),
+		}
+		combined, sourceMap := ConcatFiles(bad)
+		_, diags = ParseConfig(combined, "bundle.hcl", hcl.InitialPos)
+		if !diags.HasErrors() {
+			t.Fatalf("unexpected success")
+		}
+		filename, pos := sourceMap(diags[0].Subject.Start)
+		if got, want := filename, "b.hcl"; got != want {
+			t.Errorf("wrong filename %q; want %q", got, want)
+		}
+		if got, want := pos.Line, 2; got != want {
+			t.Errorf("wrong line %d; want %d", got, want)
+		}
+	})
+}
+
+func TestConcatFiles_empty(t *testing.T) {
+	combined, sourceMap := ConcatFiles(nil)
+	if len(combined) != 0 {
+		t.Errorf("unexpected result %q", combined)
+	}
+	pos := hcl.Pos{Line: 2, Column: 3, Byte: 4}
+	filename, got := sourceMap(pos)
+	if filename != "" || got != pos {
+		t.Errorf("wrong mapping %q %#v", filename, got)
+	}
+}
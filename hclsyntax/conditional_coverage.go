@@ -0,0 +1,163 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// CheckConditionalCoverage returns a warning for each chain of conditional
+// expressions within the given expression that chooses between cases by
+// comparing a variable with string literals, such as
+//
+//	var.env == "prod" ? 3 : var.env == "staging" ? 2 : 1
+//
+// when the variable is known to have one of a small set of values and more
+// than one of those values would fall through to the final false result
+// without being mentioned. Such a chain often means that a case was
+// forgotten, or that a value was added to the set without updating the
+// conditionals that select on it.
+//
+// The known sets of values are given as a map from a reference to a
+// variable, written as a root name followed by any attribute names separated
+// by periods, such as "var.env", to the values it may have. Variables not in
+// the map are ignored.
+//
+// This is a heuristic with a deliberately narrow scope, to avoid false
+// positives. A chain is recognized only when each condition is an equality
+// comparison, using ==, between the same variable and a literal string,
+// optionally in parentheses, and the chain continues only through false
+// results. Conditions of any other form, including inequality comparisons
+// and comparisons combined using logical operators, end the chain, so that
+// the conditional they belong to is treated as the final false result.
+func CheckConditionalCoverage(expr Expression, enums map[string][]string) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	inChain := make(map[*ConditionalExpr]bool)
+	VisitAll(expr, func(node Node) hcl.Diagnostics {
+		cond, ok := node.(*ConditionalExpr)
+		if !ok || inChain[cond] {
+			return nil
+		}
+		subject, _, ok := conditionalCase(cond.Condition)
+		if !ok {
+			return nil
+		}
+
+		// Collect the values compared with in each conditional of the chain,
+		// marking them so that they aren't also treated as the start of a
+		// chain when we visit them.
+		compared := make(map[string]bool)
+		var final Expression
+		for cur := cond; ; {
+			inChain[cur] = true
+			_, val, _ := conditionalCase(cur.Condition)
+			compared[val] = true
+
+			final = unwrapParens(cur.FalseResult)
+			next, ok := final.(*ConditionalExpr)
+			if !ok {
+				break
+			}
+			if nextSubject, _, ok := conditionalCase(next.Condition); !ok || nextSubject != subject {
+				break
+			}
+			cur = next
+		}
+
+		values, ok := enums[subject]
+		if !ok {
+			return nil
+		}
+		var mentioned, remaining []string
+		seen := make(map[string]bool)
+		for _, val := range values {
+			if seen[val] {
+				continue
+			}
+			seen[val] = true
+			if compared[val] {
+				mentioned = append(mentioned, fmt.Sprintf("%q", val))
+			} else {
+				remaining = append(remaining, fmt.Sprintf("%q", val))
+			}
+		}
+		if len(remaining) < 2 {
+			// The final result is either unreachable or is clearly the
+			// result for the single remaining value.
+			return nil
+		}
+
+		mentionedDesc := "none of its possible values"
+		if len(mentioned) > 0 {
+			mentionedDesc = strings.Join(mentioned, ", ")
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Conditional does not cover all cases",
+			Detail: fmt.Sprintf(
+				"This chain of conditional expressions compares %s with %s, so this final result is used for all of %s. If that is intended, compare with all but one of them explicitly so that it's clear which case the final result is for.",
+				subject, mentionedDesc, strings.Join(remaining, ", "),
+			),
+			Subject: final.Range().Ptr(),
+			Context: cond.Range().Ptr(),
+		})
+		return nil
+	})
+	return diags
+}
+
+// conditionalCase recognizes a condition that compares a variable with a
+// literal string, returning the variable in the form used as a key for
+// CheckConditionalCoverage and the string it is compared with.
+func conditionalCase(cond Expression) (string, string, bool) {
+	op, ok := unwrapParens(cond).(*BinaryOpExpr)
+	if !ok || op.Op != OpEqual {
+		return "", "", false
+	}
+	lhs, rhs := unwrapParens(op.LHS), unwrapParens(op.RHS)
+	if _, ok := lhs.(*ScopeTraversalExpr); !ok {
+		lhs, rhs = rhs, lhs
+	}
+	ref, ok := lhs.(*ScopeTraversalExpr)
+	if !ok || len(rhs.Variables()) > 0 || len(ref.Traversal) == 0 {
+		return "", "", false
+	}
+
+	// The literal may be any expression without references whose value is
+	// a known string, which includes quoted template literals.
+	val, diags := rhs.Value(nil)
+	if diags.HasErrors() || val.Type() != cty.String || !val.IsWhollyKnown() || val.IsNull() || val.IsMarked() {
+		return "", "", false
+	}
+
+	var subject strings.Builder
+	for _, step := range ref.Traversal {
+		switch step := step.(type) {
+		case hcl.TraverseRoot:
+			subject.WriteString(step.Name)
+		case hcl.TraverseAttr:
+			subject.WriteString("." + step.Name)
+		default:
+			return "", "", false
+		}
+	}
+	return subject.String(), val.AsString(), true
+}
+
+// unwrapParens returns the expression inside any parentheses around the
+// given expression.
+func unwrapParens(expr Expression) Expression {
+	for {
+		paren, ok := expr.(*ParenthesesExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.Expression
+	}
+}
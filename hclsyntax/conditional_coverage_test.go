@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestCheckConditionalCoverage(t *testing.T) {
+	enums := map[string][]string{
+		"var.env":     {"prod", "staging", "dev", "test"},
+		"local.color": {"red", "green"},
+	}
+
+	type diag struct {
+		Subject string
+		Detail  string
+	}
+	tests := map[string]struct {
+		src  string
+		want []diag
+	}{
+		"all but one covered": {
+			`var.env == "prod" ? 3 : var.env == "staging" ? 2 : var.env == "dev" ? 1 : 0`,
+			nil,
+		},
+		"two remaining": {
+			`var.env == "prod" ? 3 : var.env == "staging" ? 2 : 1`,
+			[]diag{{
+				"1",
+				`This chain of conditional expressions compares var.env with "prod", "staging", so this final result is used for all of "dev", "test". If that is intended, compare with all but one of them explicitly so that it's clear which case the final result is for.`,
+			}},
+		},
+		"single conditional": {
+			`"prod" == var.env ? 3 : 1`,
+			[]diag{{
+				"1",
+				`This chain of conditional expressions compares var.env with "prod", so this final result is used for all of "staging", "dev", "test". If that is intended, compare with all but one of them explicitly so that it's clear which case the final result is for.`,
+			}},
+		},
+		"parentheses": {
+			`(var.env == "prod") ? 3 : (var.env == "staging" ? 2 : (var.env == "dev" ? 1 : 0))`,
+			nil,
+		},
+		"two-value enum": {
+			`local.color == "red" ? 1 : 2`,
+			nil,
+		},
+		"unknown variable": {
+			`var.region == "eu" ? 1 : 2`,
+			nil,
+		},
+		"inequality is not a case": {
+			`var.env != "prod" ? 1 : 2`,
+			nil,
+		},
+		"chain ends at other condition": {
+			`var.env == "prod" ? 3 : var.env == "staging" || var.env == "dev" ? 2 : 1`,
+			[]diag{{
+				`var.env == "staging" || var.env == "dev" ? 2 : 1`,
+				`This chain of conditional expressions compares var.env with "prod", so this final result is used for all of "staging", "dev", "test". If that is intended, compare with all but one of them explicitly so that it's clear which case the final result is for.`,
+			}},
+		},
+		"nested in other expressions": {
+			`[for x in var.list : var.env == "prod" ? x : null]`,
+			[]diag{{
+				"null",
+				`This chain of conditional expressions compares var.env with "prod", so this final result is used for all of "staging", "dev", "test". If that is intended, compare with all but one of them explicitly so that it's clear which case the final result is for.`,
+			}},
+		},
+		"nested chain in true result": {
+			`local.color == "red" ? (var.env == "prod" ? 1 : 2) : 3`,
+			[]diag{{
+				"2",
+				`This chain of conditional expressions compares var.env with "prod", so this final result is used for all of "staging", "dev", "test". If that is intended, compare with all but one of them explicitly so that it's clear which case the final result is for.`,
+			}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := []byte(test.src)
+			expr, diags := ParseExpression(src, "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags)
+			}
+
+			var got []diag
+			for _, d := range CheckConditionalCoverage(expr, enums) {
+				if d.Severity != hcl.DiagWarning {
+					t.Errorf("diagnostic is not a warning: %s", d.Summary)
+				}
+				got = append(got, diag{string(d.Subject.SliceBytes(src)), d.Detail})
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("wrong diagnostics\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// TraceStep is a single entry in the trace produced by EvalTrace, recording
+// the result of evaluating one sub-expression.
+type TraceStep struct {
+	// Expr is the sub-expression that was evaluated.
+	Expr Expression
+
+	// Depth is the nesting depth of Expr beneath the expression given to
+	// EvalTrace, which is itself at depth zero.
+	Depth int
+
+	// Value and Diagnostics are the result of evaluating Expr.
+	Value       cty.Value
+	Diagnostics hcl.Diagnostics
+}
+
+// SourceText returns the source code of the step's expression, given the
+// source buffer that the expression was parsed from.
+func (s TraceStep) SourceText(src []byte) string {
+	rng := s.Expr.Range()
+	if !rng.CanSliceBytes(src) {
+		return ""
+	}
+	return string(rng.SliceBytes(src))
+}
+
+const (
+	// maxTraceDepth is the deepest level of sub-expressions that EvalTrace
+	// will record.
+	maxTraceDepth = 32
+
+	// maxTraceSteps is the maximum number of steps that EvalTrace will
+	// record for a single expression.
+	maxTraceSteps = 1000
+)
+
+// EvalTrace evaluates the given expression in the same way as its Value
+// method, but additionally returns a trace recording the result of each
+// of its sub-expressions, as a debugging aid for understanding how a
+// surprising result was produced.
+//
+// The steps are in the order that a depth-first evaluation would complete
+// them, so the final step is always for the given expression itself. The
+// trace is limited to a fixed maximum nesting depth and number of steps to
+// keep it to a manageable size, so very large expressions are only partially
+// traced. The given expression's own step is always included.
+//
+// Each sub-expression is evaluated separately in the given context, so any
+// functions called by the expression may be called more than once. The result
+// and diagnostics for the whole expression come from a single normal
+// evaluation, and the diagnostics in the individual steps are for
+// information only. Sub-expressions that can only be evaluated in a child
+// scope, such as the result expressions of a for expression or the traversal
+// part of a splat expression, are not traced individually.
+func EvalTrace(expr Expression, ctx *hcl.EvalContext) (cty.Value, []TraceStep, hcl.Diagnostics) {
+	val, diags := expr.Value(ctx)
+
+	t := &evalTracer{ctx: ctx}
+	t.visitChildren(expr, 0)
+	t.steps = append(t.steps, TraceStep{
+		Expr:        expr,
+		Depth:       0,
+		Value:       val,
+		Diagnostics: diags,
+	})
+
+	return val, t.steps, diags
+}
+
+type evalTracer struct {
+	ctx   *hcl.EvalContext
+	steps []TraceStep
+}
+
+func (t *evalTracer) visit(expr Expression, depth int) {
+	if depth > maxTraceDepth || len(t.steps) >= maxTraceSteps-1 {
+		return
+	}
+
+	t.visitChildren(expr, depth)
+	if len(t.steps) >= maxTraceSteps-1 {
+		// Leave room for the step for the top-level expression.
+		return
+	}
+
+	val, diags := expr.Value(t.ctx)
+	t.steps = append(t.steps, TraceStep{
+		Expr:        expr,
+		Depth:       depth,
+		Value:       val,
+		Diagnostics: diags,
+	})
+}
+
+func (t *evalTracer) visitChildren(expr Expression, depth int) {
+	switch expr := expr.(type) {
+	case *SplatExpr:
+		// The "Each" part of a splat refers to a symbol that is only
+		// meaningful during the splat's own evaluation.
+		t.visit(expr.Source, depth+1)
+		return
+	case *ObjectConsKeyExpr:
+		// A key expression often wraps a bare identifier that is not
+		// intended to be evaluated as a variable reference, so we treat
+		// keys as leaves.
+		return
+	}
+
+	expr.walkChildNodes(func(node Node) {
+		// Non-expression nodes, such as the ChildScope nodes used for the
+		// bodies of for expressions, are not traceable in our context.
+		if child, ok := node.(Expression); ok {
+			t.visit(child, depth+1)
+		}
+	})
+}
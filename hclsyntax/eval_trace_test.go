@@ -0,0 +1,146 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestEvalTrace(t *testing.T) {
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"a": cty.NumberIntVal(2),
+			"list": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("x")}),
+			}),
+		},
+	}
+
+	tests := map[string]struct {
+		src  string
+		want []string
+	}{
+		"arithmetic": {
+			`a * (3 + 1)`,
+			[]string{
+				"1 a = 2",
+				"3 3 = 3",
+				"3 1 = 1",
+				"2 3 + 1 = 4",
+				"1 (3 + 1) = 4",
+				"0 a * (3 + 1) = 8",
+			},
+		},
+		"object and splat": {
+			`{ k = list[*].name }`,
+			[]string{
+				"1 k = \"k\"",
+				"2 list = [{name: \"x\"}]",
+				"1 list[*].name = [\"x\"]",
+				"0 { k = list[*].name } = {k: [\"x\"]}",
+			},
+		},
+		"for expression": {
+			`[for v in list : v.name]`,
+			[]string{
+				"1 list = [{name: \"x\"}]",
+				"0 [for v in list : v.name] = [\"x\"]",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := []byte(test.src)
+			expr, diags := ParseExpression(src, "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("failed to parse: %s", diags)
+			}
+
+			_, steps, diags := EvalTrace(expr, ctx)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected diagnostics: %s", diags)
+			}
+
+			var got []string
+			for _, step := range steps {
+				got = append(got, fmt.Sprintf("%d %s = %s", step.Depth, step.SourceText(src), traceTestValueString(step.Value)))
+			}
+			if !cmp.Equal(test.want, got) {
+				t.Errorf("wrong trace\n%s", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestEvalTrace_limits(t *testing.T) {
+	// Build an expression with many more sub-expressions than we'll trace.
+	src := "0"
+	for i := 0; i < maxTraceSteps; i++ {
+		src = fmt.Sprintf("(%s + 1)", src)
+	}
+	expr, diags := ParseExpression([]byte(src), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("failed to parse: %s", diags)
+	}
+
+	got, steps, diags := EvalTrace(expr, nil)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %s", diags)
+	}
+	if want := cty.NumberIntVal(maxTraceSteps); !got.RawEquals(want) {
+		t.Errorf("wrong result %#v; want %#v", got, want)
+	}
+	if len(steps) > maxTraceSteps {
+		t.Errorf("too many steps %d; want at most %d", len(steps), maxTraceSteps)
+	}
+	for _, step := range steps {
+		if step.Depth > maxTraceDepth {
+			t.Fatalf("step at depth %d exceeds maximum %d", step.Depth, maxTraceDepth)
+		}
+	}
+	if last := steps[len(steps)-1]; last.Expr != expr {
+		t.Errorf("final step is not for the top-level expression")
+	}
+}
+
+func traceTestValueString(v cty.Value) string {
+	switch {
+	case v.Type() == cty.Number:
+		return v.AsBigFloat().Text('f', -1)
+	case v.Type() == cty.String:
+		return fmt.Sprintf("%q", v.AsString())
+	case v.Type().IsObjectType():
+		s := "{"
+		first := true
+		for it := v.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			if !first {
+				s += ", "
+			}
+			s += k.AsString() + ": " + traceTestValueString(ev)
+			first = false
+		}
+		return s + "}"
+	case v.CanIterateElements():
+		s := "["
+		first := true
+		for it := v.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			if !first {
+				s += ", "
+			}
+			s += traceTestValueString(ev)
+			first = false
+		}
+		return s + "]"
+	default:
+		return v.GoString()
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+// ExprKind is a broad classification of native syntax expressions, returned
+// by ClassifyExpr.
+type ExprKind int
+
+const (
+	// ExprKindUnknown is the kind of any expression that ClassifyExpr
+	// doesn't recognize.
+	ExprKindUnknown ExprKind = iota
+
+	// ExprKindLiteral is a literal value, such as a number, a keyword like
+	// true or null, or a quoted string with no interpolations or directives.
+	ExprKindLiteral
+
+	// ExprKindReference is a reference to a variable, such as var.foo[0].
+	ExprKindReference
+
+	// ExprKindTraversal is an attribute access or index operation applied
+	// to the result of some other expression, such as foo()[0] or a[b].
+	ExprKindTraversal
+
+	// ExprKindSplat is a splat expression, such as foo[*].id.
+	ExprKindSplat
+
+	// ExprKindFunctionCall is a function call, such as upper("a").
+	ExprKindFunctionCall
+
+	// ExprKindTemplate is a string template containing interpolations or
+	// directives, including a heredoc template.
+	ExprKindTemplate
+
+	// ExprKindTuple is a tuple constructor, such as [1, 2].
+	ExprKindTuple
+
+	// ExprKindObject is an object constructor, such as { a = 1 }.
+	ExprKindObject
+
+	// ExprKindFor is a for expression, producing either a tuple or an object.
+	ExprKindFor
+
+	// ExprKindConditional is a conditional expression, such as a ? b : c.
+	ExprKindConditional
+
+	// ExprKindOperation is a unary or binary operation, such as !a or a + b.
+	ExprKindOperation
+)
+
+// String returns a short lowercase description of the kind, suitable for
+// use in messages.
+func (k ExprKind) String() string {
+	switch k {
+	case ExprKindLiteral:
+		return "literal"
+	case ExprKindReference:
+		return "reference"
+	case ExprKindTraversal:
+		return "traversal"
+	case ExprKindSplat:
+		return "splat"
+	case ExprKindFunctionCall:
+		return "function call"
+	case ExprKindTemplate:
+		return "template"
+	case ExprKindTuple:
+		return "tuple constructor"
+	case ExprKindObject:
+		return "object constructor"
+	case ExprKindFor:
+		return "for expression"
+	case ExprKindConditional:
+		return "conditional"
+	case ExprKindOperation:
+		return "operation"
+	default:
+		return "unknown"
+	}
+}
+
+// ClassifyExpr returns the kind of the given expression, based only on its
+// syntax.
+//
+// Parentheses are transparent to classification, so (a + b) is an
+// operation. A quoted string with no interpolations or directives is a
+// literal rather than a template, as is an object constructor key that is
+// interpreted as a literal attribute name. The result is ExprKindUnknown
+// for expressions that are not part of the native syntax.
+func ClassifyExpr(expr Expression) ExprKind {
+	switch e := expr.(type) {
+	case *ParenthesesExpr:
+		return ClassifyExpr(e.Expression)
+	case *ObjectConsKeyExpr:
+		if !e.ForceNonLiteral && e.literalName() != "" {
+			return ExprKindLiteral
+		}
+		return ClassifyExpr(e.Wrapped)
+	case *LiteralValueExpr:
+		return ExprKindLiteral
+	case *TemplateExpr:
+		if e.IsStringLiteral() {
+			return ExprKindLiteral
+		}
+		return ExprKindTemplate
+	case *TemplateWrapExpr, *TemplateJoinExpr:
+		return ExprKindTemplate
+	case *ScopeTraversalExpr:
+		return ExprKindReference
+	case *RelativeTraversalExpr, *IndexExpr:
+		return ExprKindTraversal
+	case *SplatExpr:
+		return ExprKindSplat
+	case *FunctionCallExpr:
+		return ExprKindFunctionCall
+	case *TupleConsExpr:
+		return ExprKindTuple
+	case *ObjectConsExpr:
+		return ExprKindObject
+	case *ForExpr:
+		return ExprKindFor
+	case *ConditionalExpr:
+		return ExprKindConditional
+	case *BinaryOpExpr, *UnaryOpExpr:
+		return ExprKindOperation
+	default:
+		return ExprKindUnknown
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestClassifyExpr(t *testing.T) {
+	tests := map[string]ExprKind{
+		`1`:       ExprKindLiteral,
+		`null`:    ExprKindLiteral,
+		`"hello"`: ExprKindLiteral,
+		`<<EOT
+hello
+EOT
+`: ExprKindLiteral,
+		`"hello ${name}"`:               ExprKindTemplate,
+		`"${name}"`:                     ExprKindTemplate,
+		`"%{for x in xs}${x}%{endfor}"`: ExprKindTemplate,
+		`var.foo[0]`:                    ExprKindReference,
+		`foo()[0]`:                      ExprKindTraversal,
+		`a[b]`:                          ExprKindTraversal,
+		`a[*].id`:                       ExprKindSplat,
+		`a.*.id`:                        ExprKindSplat,
+		`upper("a")`:                    ExprKindFunctionCall,
+		`[1, 2]`:                        ExprKindTuple,
+		`{ a = 1 }`:                     ExprKindObject,
+		`[for x in xs : x]`:             ExprKindFor,
+		`{for k, v in m : k => v}`:      ExprKindFor,
+		`a ? b : c`:                     ExprKindConditional,
+		`a + b`:                         ExprKindOperation,
+		`!a`:                            ExprKindOperation,
+		`(a + b)`:                       ExprKindOperation,
+		`((a))`:                         ExprKindReference,
+	}
+
+	for src, want := range tests {
+		t.Run(src, func(t *testing.T) {
+			expr, diags := ParseExpression([]byte(src), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Error())
+			}
+			if got := ClassifyExpr(expr); got != want {
+				t.Errorf("wrong kind %s; want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestClassifyExpr_objectKeys(t *testing.T) {
+	expr, diags := ParseExpression([]byte(`{ a = 1, (b) = 2, "c" = 3, d.e = 4 }`), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	want := []ExprKind{
+		ExprKindLiteral,
+		ExprKindReference,
+		ExprKindLiteral,
+		ExprKindReference,
+	}
+	for i, item := range expr.(*ObjectConsExpr).Items {
+		if got := ClassifyExpr(item.KeyExpr); got != want[i] {
+			t.Errorf("wrong kind %s for key %d; want %s", got, i, want[i])
+		}
+	}
+
+	if got, want := ClassifyExpr(&AnonSymbolExpr{}), ExprKindUnknown; got != want {
+		t.Errorf("wrong kind %s for anonymous symbol; want %s", got, want)
+	}
+}
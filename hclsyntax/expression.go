@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/ext/customdecode"
@@ -58,6 +59,25 @@ func (e *ParenthesesExpr) walkChildNodes(w internalWalkFunc) {
 	w(e.Expression)
 }
 
+// evalStepBudgetDiags consumes an evaluation step from the budget in the
+// given context, if any, and returns an error diagnostic to report at the
+// given expression if the budget has been exceeded. It returns nil otherwise.
+func evalStepBudgetDiags(ctx *hcl.EvalContext, expr Expression) hcl.Diagnostics {
+	if ctx.ConsumeEvalStep() {
+		return nil
+	}
+	return hcl.Diagnostics{
+		&hcl.Diagnostic{
+			Severity:    hcl.DiagError,
+			Summary:     "Evaluation exceeded step budget",
+			Detail:      "This expression is too expensive to evaluate, because evaluation exceeded the maximum number of steps allowed by the application.",
+			Subject:     expr.Range().Ptr(),
+			Expression:  expr,
+			EvalContext: ctx,
+		},
+	}
+}
+
 // LiteralValueExpr is an expression that just always returns a given value.
 type LiteralValueExpr struct {
 	Val      cty.Value
@@ -69,6 +89,9 @@ func (e *LiteralValueExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *LiteralValueExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	return e.Val, nil
 }
 
@@ -137,6 +160,9 @@ func (e *ScopeTraversalExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *ScopeTraversalExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	val, diags := e.Traversal.TraverseAbs(ctx)
 	setDiagEvalContext(diags, e, ctx)
 	return val, diags
@@ -168,6 +194,9 @@ func (e *RelativeTraversalExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *RelativeTraversalExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	src, diags := e.Source.Value(ctx)
 	ret, travDiags := e.Traversal.TraverseRel(src)
 	setDiagEvalContext(travDiags, e, ctx)
@@ -219,6 +248,9 @@ func (e *FunctionCallExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *FunctionCallExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	var diags hcl.Diagnostics
 
 	var f function.Function
@@ -238,6 +270,30 @@ func (e *FunctionCallExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnosti
 		thisCtx = thisCtx.Parent()
 	}
 
+	if !exists {
+		// If the function isn't statically defined then we'll give any
+		// function resolvers a chance to provide it on demand. A context
+		// with a resolver allows function calls even if it has no static
+		// function table.
+		for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.Parent() {
+			if thisCtx.FunctionResolver == nil {
+				continue
+			}
+			hasNonNilMap = true
+			f, exists = thisCtx.FunctionResolver(e.Name)
+			if exists {
+				break
+			}
+		}
+	}
+
+	unknownFuncs := ctx.UnknownFunctionsMode()
+	if unknownFuncs != hcl.UnknownFunctionsError {
+		// A context that asks for unknown functions to be tolerated
+		// implicitly allows function calls.
+		hasNonNilMap = true
+	}
+
 	if !exists {
 		if !hasNonNilMap {
 			return cty.DynamicVal, hcl.Diagnostics{
@@ -255,6 +311,7 @@ func (e *FunctionCallExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnosti
 		extraUnknown := &functionCallUnknown{
 			name: e.Name,
 		}
+		var diag *hcl.Diagnostic
 
 		// For historical reasons, we represent namespaced function names
 		// as strings with :: separating the names. If this was an attempt
@@ -289,17 +346,15 @@ func (e *FunctionCallExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnosti
 				// the function names than the namespaces, because in many
 				// applications there will be relatively few namespaces compared
 				// to the number of distinct functions.
-				return cty.DynamicVal, hcl.Diagnostics{
-					{
-						Severity:    hcl.DiagError,
-						Summary:     "Call to unknown function",
-						Detail:      fmt.Sprintf("There are no functions in namespace %q.", namespace),
-						Subject:     &e.NameRange,
-						Context:     e.Range().Ptr(),
-						Expression:  e,
-						EvalContext: ctx,
-						Extra:       extraUnknown,
-					},
+				diag = &hcl.Diagnostic{
+					Severity:    hcl.DiagError,
+					Summary:     "Call to unknown function",
+					Detail:      fmt.Sprintf("There are no functions in namespace %q.", namespace),
+					Subject:     &e.NameRange,
+					Context:     e.Range().Ptr(),
+					Expression:  e,
+					EvalContext: ctx,
+					Extra:       extraUnknown,
 				}
 			} else {
 				suggestion := nameSuggestion(name, avail)
@@ -307,32 +362,28 @@ func (e *FunctionCallExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnosti
 					suggestion = fmt.Sprintf(" Did you mean %s%s?", namespace, suggestion)
 				}
 
-				return cty.DynamicVal, hcl.Diagnostics{
-					{
-						Severity:    hcl.DiagError,
-						Summary:     "Call to unknown function",
-						Detail:      fmt.Sprintf("There is no function named %q in namespace %s.%s", name, namespace, suggestion),
-						Subject:     &e.NameRange,
-						Context:     e.Range().Ptr(),
-						Expression:  e,
-						EvalContext: ctx,
-						Extra:       extraUnknown,
-					},
+				diag = &hcl.Diagnostic{
+					Severity:    hcl.DiagError,
+					Summary:     "Call to unknown function",
+					Detail:      fmt.Sprintf("There is no function named %q in namespace %s.%s", name, namespace, suggestion),
+					Subject:     &e.NameRange,
+					Context:     e.Range().Ptr(),
+					Expression:  e,
+					EvalContext: ctx,
+					Extra:       extraUnknown,
 				}
 			}
-		}
-
-		avail := make([]string, 0, len(ctx.Functions))
-		for name := range ctx.Functions {
-			avail = append(avail, name)
-		}
-		suggestion := nameSuggestion(e.Name, avail)
-		if suggestion != "" {
-			suggestion = fmt.Sprintf(" Did you mean %q?", suggestion)
-		}
+		} else {
+			avail := make([]string, 0, len(ctx.Functions))
+			for name := range ctx.Functions {
+				avail = append(avail, name)
+			}
+			suggestion := nameSuggestion(e.Name, avail)
+			if suggestion != "" {
+				suggestion = fmt.Sprintf(" Did you mean %q?", suggestion)
+			}
 
-		return cty.DynamicVal, hcl.Diagnostics{
-			{
+			diag = &hcl.Diagnostic{
 				Severity:    hcl.DiagError,
 				Summary:     "Call to unknown function",
 				Detail:      fmt.Sprintf("There is no function named %q.%s", e.Name, suggestion),
@@ -341,8 +392,30 @@ func (e *FunctionCallExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnosti
 				Expression:  e,
 				EvalContext: ctx,
 				Extra:       extraUnknown,
-			},
+			}
 		}
+
+		switch unknownFuncs {
+		case hcl.UnknownFunctionsWarn:
+			diag.Severity = hcl.DiagWarning
+		case hcl.UnknownFunctionsIgnore:
+			diag = nil
+		default:
+			return cty.DynamicVal, hcl.Diagnostics{diag}
+		}
+
+		// The caller has asked us to treat the unknown function as
+		// returning an unknown value of unknown type, but we still
+		// evaluate the arguments so that any problems within them are
+		// reported as usual.
+		if diag != nil {
+			diags = append(diags, diag)
+		}
+		for _, argExpr := range e.Args {
+			_, argDiags := argExpr.Value(ctx)
+			diags = append(diags, argDiags...)
+		}
+		return cty.DynamicVal, diags
 	}
 
 	diagExtra := functionCallDiagExtra{
@@ -525,6 +598,26 @@ func (e *FunctionCallExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnosti
 		return cty.DynamicVal, diags
 	}
 
+	// Any function call validators get a chance to reject the call before
+	// we run the function, innermost context first.
+	for thisCtx := ctx; thisCtx != nil; thisCtx = thisCtx.Parent() {
+		if thisCtx.FunctionCallValidator == nil {
+			continue
+		}
+		hookDiags := thisCtx.FunctionCallValidator(e.Name, argVals)
+		for _, diag := range hookDiags {
+			if diag.Subject == nil {
+				diag.Subject = e.Range().Ptr()
+				diag.Expression = e
+				diag.EvalContext = ctx
+			}
+		}
+		diags = append(diags, hookDiags...)
+		if hookDiags.HasErrors() {
+			return cty.DynamicVal, diags
+		}
+	}
+
 	resultVal, err := f.Call(argVals)
 	if err != nil {
 		// For errors in the underlying call itself we also return the raw
@@ -724,6 +817,9 @@ func (e *ConditionalExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *ConditionalExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	trueResult, trueDiags := e.TrueResult.Value(ctx)
 	falseResult, falseDiags := e.FalseResult.Value(ctx)
 	var diags hcl.Diagnostics
@@ -844,6 +940,26 @@ func (e *ConditionalExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostic
 			return ref.NewValue().WithMarks(resMarks), diags
 		}
 
+		// Likewise, any prefix shared by both of the possible strings must
+		// also be a prefix of the result.
+		if trueResult.Type() == cty.String && falseResult.Type() == cty.String {
+			ref := cty.UnknownVal(cty.String).Refine()
+			if trueRange.DefinitelyNotNull() && falseRange.DefinitelyNotNull() {
+				ref = ref.NotNull()
+			}
+			if prefix := commonStringPrefix(knownStringPrefix(trueResult), knownStringPrefix(falseResult)); prefix != "" {
+				// As for templates, limit the prefix to 128 bytes to stay well
+				// within the safety limits in cty's msgpack decoder. This is
+				// safe because StringPrefix removes incomplete trailing
+				// grapheme clusters.
+				if len(prefix) > 128 {
+					prefix = prefix[:128]
+				}
+				ref = ref.StringPrefix(prefix)
+			}
+			return ref.NewValue().WithMarks(resMarks), diags
+		}
+
 		if trueResult.Type().IsCollectionType() && falseResult.Type().IsCollectionType() {
 			if trueResult.Type().Equals(falseResult.Type()) {
 				ref := cty.UnknownVal(resultType).Refine()
@@ -1104,6 +1220,9 @@ func (e *IndexExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *IndexExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	var diags hcl.Diagnostics
 	coll, collDiags := e.Collection.Value(ctx)
 	key, keyDiags := e.Key.Value(ctx)
@@ -1138,6 +1257,9 @@ func (e *TupleConsExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *TupleConsExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	var vals []cty.Value
 	var diags hcl.Diagnostics
 
@@ -1188,6 +1310,9 @@ func (e *ObjectConsExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *ObjectConsExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	var vals map[string]cty.Value
 	var diags hcl.Diagnostics
 	var marks []cty.ValueMarks
@@ -1309,6 +1434,9 @@ func (e *ObjectConsKeyExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *ObjectConsKeyExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	// Because we accept a naked identifier as a literal key rather than a
 	// reference, it's confusing to accept a traversal containing periods
 	// here since we can't tell if the user intends to create a key with
@@ -1389,6 +1517,9 @@ type ForExpr struct {
 }
 
 func (e *ForExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	var diags hcl.Diagnostics
 	var marks []cty.ValueMarks
 
@@ -1491,6 +1622,10 @@ func (e *ForExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 
 		known := true
 		for it.Next() {
+			if stepDiags := evalStepBudgetDiags(ctx, e); stepDiags != nil {
+				diags = append(diags, stepDiags...)
+				return cty.DynamicVal, diags
+			}
 			k, v := it.Element()
 			childCtx := ctx.NewChild()
 			childCtx.Variables = map[string]cty.Value{}
@@ -1638,6 +1773,10 @@ func (e *ForExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 
 		known := true
 		for it.Next() {
+			if stepDiags := evalStepBudgetDiags(ctx, e); stepDiags != nil {
+				diags = append(diags, stepDiags...)
+				return cty.DynamicVal, diags
+			}
 			k, v := it.Element()
 			childCtx := ctx.NewChild()
 			childCtx.Variables = map[string]cty.Value{}
@@ -1759,6 +1898,9 @@ type SplatExpr struct {
 }
 
 func (e *SplatExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	sourceVal, diags := e.Source.Value(ctx)
 	if diags.HasErrors() {
 		// We'll evaluate our "Each" expression here just to see if it
@@ -1889,6 +2031,11 @@ func (e *SplatExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
 	}
 	isKnown := true
 	for it.Next() {
+		if stepDiags := evalStepBudgetDiags(ctx, e); stepDiags != nil {
+			diags = append(diags, stepDiags...)
+			e.Item.clearValue(ctx)
+			return cty.DynamicVal, diags
+		}
 		_, sourceItem := it.Element()
 		e.Item.setValue(ctx, sourceItem)
 		newItem, itemDiags := e.Each.Value(ctx)
@@ -1961,6 +2108,9 @@ type AnonSymbolExpr struct {
 }
 
 func (e *AnonSymbolExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	if ctx == nil {
 		return cty.DynamicVal, nil
 	}
@@ -2038,3 +2188,27 @@ func (e *ExprSyntaxError) Range() hcl.Range {
 func (e *ExprSyntaxError) StartRange() hcl.Range {
 	return e.SrcRange
 }
+
+// knownStringPrefix returns the longest string known to be a prefix of the
+// given string value, which is the whole string if the value is known.
+func knownStringPrefix(v cty.Value) string {
+	if v.IsKnown() && !v.IsNull() {
+		return v.AsString()
+	}
+	return v.Range().StringPrefix()
+}
+
+// commonStringPrefix returns the longest prefix shared by both of the given
+// strings, without splitting any multi-byte characters.
+func commonStringPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) {
+		ra, size := utf8.DecodeRuneInString(a[i:])
+		rb, _ := utf8.DecodeRuneInString(b[i:])
+		if ra != rb {
+			break
+		}
+		i += size
+	}
+	return a[:i]
+}
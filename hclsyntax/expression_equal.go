@@ -0,0 +1,198 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ExprEqual returns true if the two given expressions have the same
+// structure, disregarding their source ranges and therefore any differences
+// in whitespace, comments, or layout.
+//
+// Literal values are compared by value, traversals step by step, operators by
+// identity, and function calls by name and arguments, recursively. Redundant
+// parentheses are ignored, as are the template wrappers around templates
+// that consist only of a single interpolation, and adjacent literal parts of
+// templates are combined before comparison, so that a quoted string and a
+// heredoc with the same content are equal.
+//
+// This is a structural comparison, not a comparison of the values that the
+// expressions would produce. For example, "1 + 1" is not equal to "2", and a
+// reference written as a.b is not equal to one written as a["b"]. Expressions
+// containing syntax errors are never equal to anything.
+func ExprEqual(a, b Expression) bool {
+	a, b = unwrapEqualExpr(a), unwrapEqualExpr(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch a := a.(type) {
+	case *LiteralValueExpr:
+		// The parser uses unknown literals as placeholders when recovering
+		// from syntax errors, so those are not equal to anything.
+		b, ok := b.(*LiteralValueExpr)
+		return ok && a.Val.IsWhollyKnown() && a.Val.RawEquals(b.Val)
+	case *ScopeTraversalExpr:
+		b, ok := b.(*ScopeTraversalExpr)
+		return ok && traversalEqual(a.Traversal, b.Traversal)
+	case *RelativeTraversalExpr:
+		b, ok := b.(*RelativeTraversalExpr)
+		return ok && traversalEqual(a.Traversal, b.Traversal) && ExprEqual(a.Source, b.Source)
+	case *FunctionCallExpr:
+		b, ok := b.(*FunctionCallExpr)
+		return ok && a.Name == b.Name && a.ExpandFinal == b.ExpandFinal && exprsEqual(a.Args, b.Args)
+	case *ConditionalExpr:
+		b, ok := b.(*ConditionalExpr)
+		return ok && ExprEqual(a.Condition, b.Condition) &&
+			ExprEqual(a.TrueResult, b.TrueResult) &&
+			ExprEqual(a.FalseResult, b.FalseResult)
+	case *IndexExpr:
+		b, ok := b.(*IndexExpr)
+		return ok && ExprEqual(a.Collection, b.Collection) && ExprEqual(a.Key, b.Key)
+	case *TupleConsExpr:
+		b, ok := b.(*TupleConsExpr)
+		return ok && exprsEqual(a.Exprs, b.Exprs)
+	case *ObjectConsExpr:
+		b, ok := b.(*ObjectConsExpr)
+		if !ok || len(a.Items) != len(b.Items) {
+			return false
+		}
+		for i := range a.Items {
+			if !ExprEqual(a.Items[i].KeyExpr, b.Items[i].KeyExpr) || !ExprEqual(a.Items[i].ValueExpr, b.Items[i].ValueExpr) {
+				return false
+			}
+		}
+		return true
+	case *ObjectConsKeyExpr:
+		b, ok := b.(*ObjectConsKeyExpr)
+		if !ok || a.ForceNonLiteral != b.ForceNonLiteral {
+			return false
+		}
+		if aName, bName := a.literalName(), b.literalName(); aName != "" || bName != "" {
+			return aName == bName
+		}
+		return ExprEqual(a.Wrapped, b.Wrapped)
+	case *ForExpr:
+		b, ok := b.(*ForExpr)
+		return ok && a.KeyVar == b.KeyVar && a.ValVar == b.ValVar && a.Group == b.Group &&
+			ExprEqual(a.CollExpr, b.CollExpr) &&
+			ExprEqual(a.KeyExpr, b.KeyExpr) &&
+			ExprEqual(a.ValExpr, b.ValExpr) &&
+			ExprEqual(a.CondExpr, b.CondExpr)
+	case *SplatExpr:
+		b, ok := b.(*SplatExpr)
+		return ok && ExprEqual(a.Source, b.Source) && ExprEqual(a.Each, b.Each)
+	case *AnonSymbolExpr:
+		// An anonymous symbol always refers to the current element of its
+		// enclosing splat expression, so any two are equivalent as long as
+		// their splat expressions are, which our caller is checking.
+		_, ok := b.(*AnonSymbolExpr)
+		return ok
+	case *BinaryOpExpr:
+		b, ok := b.(*BinaryOpExpr)
+		return ok && a.Op == b.Op && ExprEqual(a.LHS, b.LHS) && ExprEqual(a.RHS, b.RHS)
+	case *UnaryOpExpr:
+		b, ok := b.(*UnaryOpExpr)
+		return ok && a.Op == b.Op && ExprEqual(a.Val, b.Val)
+	case *TemplateExpr:
+		b, ok := b.(*TemplateExpr)
+		return ok && exprsEqual(mergeTemplateLiterals(a.Parts), mergeTemplateLiterals(b.Parts))
+	case *TemplateJoinExpr:
+		b, ok := b.(*TemplateJoinExpr)
+		return ok && ExprEqual(a.Tuple, b.Tuple)
+	default:
+		// Includes *ExprSyntaxError, whose content we can't meaningfully
+		// compare, and any expression types we don't know about.
+		return false
+	}
+}
+
+// unwrapEqualExpr removes any wrappers that don't affect the result of an
+// expression, for the purposes of ExprEqual.
+func unwrapEqualExpr(expr Expression) Expression {
+	for {
+		switch e := expr.(type) {
+		case *ParenthesesExpr:
+			expr = e.Expression
+		case *TemplateWrapExpr:
+			expr = e.Wrapped
+		default:
+			return expr
+		}
+	}
+}
+
+func exprsEqual(a, b []Expression) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !ExprEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func traversalEqual(a, b hcl.Traversal) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		switch as := a[i].(type) {
+		case hcl.TraverseRoot:
+			bs, ok := b[i].(hcl.TraverseRoot)
+			if !ok || as.Name != bs.Name {
+				return false
+			}
+		case hcl.TraverseAttr:
+			bs, ok := b[i].(hcl.TraverseAttr)
+			if !ok || as.Name != bs.Name {
+				return false
+			}
+		case hcl.TraverseIndex:
+			bs, ok := b[i].(hcl.TraverseIndex)
+			if !ok || !as.Key.RawEquals(bs.Key) {
+				return false
+			}
+		case hcl.TraverseSplat:
+			if _, ok := b[i].(hcl.TraverseSplat); !ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// mergeTemplateLiterals returns the given template parts with any adjacent
+// known string literals combined into a single literal, since the parser
+// may split literal text into several parts depending on how it was written.
+func mergeTemplateLiterals(parts []Expression) []Expression {
+	ret := make([]Expression, 0, len(parts))
+	for _, part := range parts {
+		lit, ok := part.(*LiteralValueExpr)
+		if !ok || !isKnownStringLit(lit) || len(ret) == 0 {
+			ret = append(ret, part)
+			continue
+		}
+		prev, ok := ret[len(ret)-1].(*LiteralValueExpr)
+		if !ok || !isKnownStringLit(prev) {
+			ret = append(ret, part)
+			continue
+		}
+		ret[len(ret)-1] = &LiteralValueExpr{
+			Val:      cty.StringVal(prev.Val.AsString() + lit.Val.AsString()),
+			SrcRange: hcl.RangeBetween(prev.SrcRange, lit.SrcRange),
+		}
+	}
+	return ret
+}
+
+func isKnownStringLit(lit *LiteralValueExpr) bool {
+	return lit.Val.Type() == cty.String && lit.Val.IsKnown() && !lit.Val.IsNull() && !lit.Val.IsMarked()
+}
@@ -0,0 +1,60 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestExprEqual(t *testing.T) {
+	tests := map[string]struct {
+		a, b string
+		want bool
+	}{
+		"identical":             {`a.b[0]`, `a.b[0]`, true},
+		"whitespace":            {`foo( a ,b )`, "foo(\n  a,\n  b,\n)", true},
+		"parentheses":           {`(a + b)`, `a + b`, true},
+		"precedence":            {`(a + b) * c`, `a + b * c`, false},
+		"literal by value":      {`1.0`, `1`, true},
+		"different literals":    {`1`, `2`, false},
+		"not value equivalence": {`1 + 1`, `2`, false},
+		"different operators":   {`a + b`, `a - b`, false},
+		"different traversals":  {`a.b`, `a.c`, false},
+		"attr vs index":         {`a.b`, `a["b"]`, false},
+		"function name":         {`upper(a)`, `lower(a)`, false},
+		"function expansion":    {`f(a...)`, `f(a)`, false},
+		"function arity":        {`f(a)`, `f(a, b)`, false},
+		"conditional":           {`a ? b : c`, `a ? b : c`, true},
+		"tuple":                 {`[a, 1]`, `[a, 1, ]`, true},
+		"object":                {`{ a = 1, b = c }`, "{\n  a = 1\n  b = c\n}", true},
+		"object keys":           {`{ a = 1 }`, `{ b = 1 }`, false},
+		"object key forced":     {`{ a = 1 }`, `{ (a) = 1 }`, false},
+		"for expression":        {`[for k, v in m : v if k != ""]`, `[for k, v in m: v if k != ""]`, true},
+		"for variable names":    {`[for v in m : v]`, `[for x in m : x]`, false},
+		"splat":                 {`a[*].b`, `a[*].b`, true},
+		"splat attribute":       {`a[*].b`, `a[*].c`, false},
+		"template":              {`"a${b}c"`, `"a${b}c"`, true},
+		"template escapes":      {`"$${a}"`, `"$${a}"`, true},
+		"template wrap":         {`"${a}"`, `a`, true},
+		"template literal":      {`"a"`, `"b"`, false},
+		"heredoc":               {"<<EOT\nhello ${name}\nEOT\n", `"hello ${name}\n"`, true},
+		"template directive":    {`"%{ if a }b%{ endif }"`, `"%{if a}b%{endif}"`, true},
+		"syntax error":          {`partial::namespaced`, `partial::namespaced`, false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			a, _ := ParseExpression([]byte(test.a), "a.hcl", hcl.InitialPos)
+			b, _ := ParseExpression([]byte(test.b), "b.hcl", hcl.InitialPos)
+			if got := ExprEqual(a, b); got != test.want {
+				t.Errorf("wrong result for %s and %s: %t; want %t", test.a, test.b, got, test.want)
+			}
+			if got := ExprEqual(b, a); got != test.want {
+				t.Errorf("wrong result for %s and %s: %t; want %t", test.b, test.a, got, test.want)
+			}
+		})
+	}
+}
@@ -137,6 +137,9 @@ func (e *BinaryOpExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *BinaryOpExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	impl := e.Op.Impl // assumed to be a function taking exactly two arguments
 	params := impl.Params()
 	lhsParam := params[0]
@@ -149,6 +152,12 @@ func (e *BinaryOpExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics)
 	diags = append(diags, lhsDiags...)
 	diags = append(diags, rhsDiags...)
 
+	if compare := ctx.StringComparer(); compare != nil && !diags.HasErrors() {
+		if result, ok := compareStrings(e.Op, givenLHSVal, givenRHSVal, compare); ok {
+			return result, diags
+		}
+	}
+
 	lhsVal, err := convert.Convert(givenLHSVal, lhsParam.Type)
 	if err != nil {
 		diags = append(diags, &hcl.Diagnostic{
@@ -180,6 +189,10 @@ func (e *BinaryOpExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics)
 		return cty.UnknownVal(e.Op.Type), diags
 	}
 
+	if result, ok := shortCircuitLogical(e.Op, lhsVal, rhsVal); ok {
+		return result, diags
+	}
+
 	args := []cty.Value{lhsVal, rhsVal}
 	result, err := impl.Call(args)
 	if err != nil {
@@ -195,9 +208,126 @@ func (e *BinaryOpExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics)
 		return cty.UnknownVal(e.Op.Type), diags
 	}
 
+	if ctx.NonFiniteNumbersMode() == hcl.NonFiniteNumbersError {
+		if detail := nonFiniteResultDetail(e.Op, lhsVal, rhsVal, result); detail != "" {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity:    hcl.DiagError,
+				Summary:     "Non-finite arithmetic result",
+				Detail:      detail,
+				Subject:     &e.SrcRange,
+				Expression:  e,
+				EvalContext: ctx,
+			})
+			return cty.UnknownVal(e.Op.Type), diags
+		}
+	}
+
 	return result, diags
 }
 
+// compareStrings implements the relational operators for two string operands
+// using the given comparison function, as selected by
+// hcl.EvalContext.CompareStrings.
+//
+// If the given operation is not a relational operator or the operands are not
+// both non-null strings then the second return value is false and the caller
+// should produce the result in the normal way.
+func compareStrings(op *Operation, lhsVal, rhsVal cty.Value, compare func(a, b string) int) (cty.Value, bool) {
+	switch op {
+	case OpLessThan, OpLessThanOrEqual, OpGreaterThan, OpGreaterThanOrEqual:
+	default:
+		return cty.NilVal, false
+	}
+	if lhsVal.Type() != cty.String || rhsVal.Type() != cty.String || lhsVal.IsNull() || rhsVal.IsNull() {
+		return cty.NilVal, false
+	}
+
+	lhs, lhsMarks := lhsVal.Unmark()
+	rhs, rhsMarks := rhsVal.Unmark()
+	if !lhs.IsKnown() || !rhs.IsKnown() {
+		return cty.UnknownVal(cty.Bool).RefineNotNull().WithMarks(lhsMarks, rhsMarks), true
+	}
+
+	c := compare(lhs.AsString(), rhs.AsString())
+	var result bool
+	switch op {
+	case OpLessThan:
+		result = c < 0
+	case OpLessThanOrEqual:
+		result = c <= 0
+	case OpGreaterThan:
+		result = c > 0
+	case OpGreaterThanOrEqual:
+		result = c >= 0
+	}
+	return cty.BoolVal(result).WithMarks(lhsMarks, rhsMarks), true
+}
+
+// nonFiniteResultDetail returns the detail message for an error about the
+// given arithmetic operation producing a non-finite result, or an empty
+// string if the result is acceptable under hcl.NonFiniteNumbersError.
+//
+// Only results that become infinite from finite operands are reported, along
+// with division or modulo by zero, whose results are not meaningful numbers
+// even when cty can represent them.
+func nonFiniteResultDetail(op *Operation, lhsVal, rhsVal, result cty.Value) string {
+	if isInfinite(lhsVal) || isInfinite(rhsVal) {
+		return ""
+	}
+	if op == OpDivide || op == OpModulo {
+		if rhs, _ := rhsVal.Unmark(); rhs.IsKnown() && !rhs.IsNull() && rhs.AsBigFloat().Sign() == 0 {
+			return "The right operand is zero, and division by zero is not allowed."
+		}
+	}
+	if isInfinite(result) {
+		return "The result of this operation is too large to be represented as a finite number."
+	}
+	return ""
+}
+
+// isInfinite returns true if the given value is a known, non-null number
+// that is positive or negative infinity, regardless of any marks.
+func isInfinite(v cty.Value) bool {
+	v, _ = v.UnmarkDeep()
+	if !v.IsKnown() || v.IsNull() || v.Type() != cty.Number {
+		return false
+	}
+	return v.AsBigFloat().IsInf()
+}
+
+// shortCircuitLogical deals with the logical operators in situations where
+// one operand is unknown but the other is known and alone decides the result,
+// such as true || unknown, which is always true.
+//
+// If the given operation and operands are not such a situation then the
+// second return value is false and the caller should produce the result in
+// the normal way.
+func shortCircuitLogical(op *Operation, lhsVal, rhsVal cty.Value) (cty.Value, bool) {
+	var decider cty.Value
+	switch op {
+	case OpLogicalOr:
+		decider = cty.True
+	case OpLogicalAnd:
+		decider = cty.False
+	default:
+		return cty.NilVal, false
+	}
+	if lhsVal.IsKnown() && rhsVal.IsKnown() {
+		return cty.NilVal, false
+	}
+
+	lhsVal, lhsMarks := lhsVal.Unmark()
+	rhsVal, rhsMarks := rhsVal.Unmark()
+	for _, v := range []cty.Value{lhsVal, rhsVal} {
+		if v.IsKnown() && !v.IsNull() && v.RawEquals(decider) {
+			// The unknown operand can't affect the result, but we still
+			// conservatively retain its marks.
+			return decider.WithMarks(lhsMarks, rhsMarks), true
+		}
+	}
+	return cty.NilVal, false
+}
+
 func (e *BinaryOpExpr) Range() hcl.Range {
 	return e.SrcRange
 }
@@ -219,6 +349,9 @@ func (e *UnaryOpExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *UnaryOpExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	impl := e.Op.Impl // assumed to be a function taking exactly one argument
 	params := impl.Params()
 	param := params[0]
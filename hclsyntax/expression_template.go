@@ -16,6 +16,10 @@ type TemplateExpr struct {
 	Parts []Expression
 
 	SrcRange hcl.Range
+
+	// heredoc describes the heredoc syntax this template was written with,
+	// if any, for Heredocs.
+	heredoc *HeredocInfo
 }
 
 func (e *TemplateExpr) walkChildNodes(w internalWalkFunc) {
@@ -25,6 +29,9 @@ func (e *TemplateExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *TemplateExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	buf := &bytes.Buffer{}
 	var diags hcl.Diagnostics
 	isKnown := true
@@ -33,29 +40,23 @@ func (e *TemplateExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics)
 	marks := make(cty.ValueMarks)
 
 	for _, part := range e.Parts {
-		partVal, partDiags := part.Value(ctx)
+		partVal, partMarks, partDiags := e.partValue(part, ctx)
 		diags = append(diags, partDiags...)
-
-		if partVal.IsNull() {
-			diags = append(diags, &hcl.Diagnostic{
-				Severity:    hcl.DiagError,
-				Summary:     "Invalid template interpolation value",
-				Detail:      "The expression result is null. Cannot include a null value in a string template.",
-				Subject:     part.Range().Ptr(),
-				Context:     &e.SrcRange,
-				Expression:  part,
-				EvalContext: ctx,
-			})
-			continue
-		}
-
-		// Unmark the part and merge its marks into the set
-		unmarkedVal, partMarks := partVal.Unmark()
 		for k, v := range partMarks {
 			marks[k] = v
 		}
+		if partVal == cty.NilVal {
+			continue
+		}
 
 		if !partVal.IsKnown() {
+			// If the first unknown part is a string with a known prefix
+			// then that prefix also contributes to the known prefix of
+			// the result.
+			if isKnown && !diags.HasErrors() && partVal.Type() == cty.String {
+				buf.WriteString(partVal.Range().StringPrefix())
+			}
+
 			// If any part is unknown then the result as a whole must be
 			// unknown too. We'll keep on processing the rest of the parts
 			// anyway, because we want to still emit any diagnostics resulting
@@ -64,28 +65,11 @@ func (e *TemplateExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics)
 			continue
 		}
 
-		strVal, err := convert.Convert(unmarkedVal, cty.String)
-		if err != nil {
-			diags = append(diags, &hcl.Diagnostic{
-				Severity: hcl.DiagError,
-				Summary:  "Invalid template interpolation value",
-				Detail: fmt.Sprintf(
-					"Cannot include the given value in a string template: %s.",
-					err.Error(),
-				),
-				Subject:     part.Range().Ptr(),
-				Context:     &e.SrcRange,
-				Expression:  part,
-				EvalContext: ctx,
-			})
-			continue
-		}
-
 		// If we're just continuing to validate after we found an unknown value
 		// then we'll skip appending so that "buf" will contain only the
 		// known prefix of the result.
 		if isKnown && !diags.HasErrors() {
-			buf.WriteString(strVal.AsString())
+			buf.WriteString(partVal.AsString())
 		}
 	}
 
@@ -117,6 +101,109 @@ func (e *TemplateExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics)
 	return ret.WithMarks(marks), diags
 }
 
+// partValue evaluates a single part of the template, returning its value
+// converted to a string and with its marks removed and returned separately.
+//
+// If the part is unknown then the result is its unknown value unconverted,
+// so that the caller can make use of any refinements. If the part is null or
+// can't be converted to a string then the result is cty.NilVal, and the
+// diagnostics explain why.
+func (e *TemplateExpr) partValue(part Expression, ctx *hcl.EvalContext) (cty.Value, cty.ValueMarks, hcl.Diagnostics) {
+	partVal, diags := part.Value(ctx)
+
+	if partVal.IsNull() {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity:    hcl.DiagError,
+			Summary:     "Invalid template interpolation value",
+			Detail:      "The expression result is null. Cannot include a null value in a string template.",
+			Subject:     part.Range().Ptr(),
+			Context:     &e.SrcRange,
+			Expression:  part,
+			EvalContext: ctx,
+		})
+		return cty.NilVal, nil, diags
+	}
+
+	unmarkedVal, marks := partVal.Unmark()
+	if !unmarkedVal.IsKnown() {
+		return unmarkedVal, marks, diags
+	}
+
+	strVal, err := convert.Convert(unmarkedVal, cty.String)
+	if err != nil {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid template interpolation value",
+			Detail: fmt.Sprintf(
+				"Cannot include the given value in a string template: %s.",
+				err.Error(),
+			),
+			Subject:     part.Range().Ptr(),
+			Context:     &e.SrcRange,
+			Expression:  part,
+			EvalContext: ctx,
+		})
+		return cty.NilVal, marks, diags
+	}
+	return strVal, marks, diags
+}
+
+// TemplateSegment is a single segment of the result of a template, as
+// returned by TemplateExpr.EvalSegments.
+type TemplateSegment struct {
+	// Value is the string value of the segment. It may be unknown, and
+	// it carries any marks from the value it was produced from.
+	Value cty.Value
+
+	// Interpolated is true if the segment was produced by an interpolation
+	// sequence or a template directive, or false if it is literal text
+	// from the template itself.
+	Interpolated bool
+
+	// Range is the source range of the template part that produced the
+	// segment.
+	Range hcl.Range
+}
+
+// EvalSegments evaluates the template in the same way as Value, but rather
+// than concatenating the results it returns a segment for each part of the
+// template, recording whether each one came from literal text or from an
+// interpolation. This allows a caller to treat the two differently, such as
+// by escaping only the interpolated segments when rendering the result as
+// HTML.
+//
+// The output of a template directive, such as %{ if } or %{ for }, is
+// returned as a single interpolated segment even if it includes literal text
+// from within the directive. Template parts that fail to evaluate, or produce
+// null values, are omitted from the result and reported in the returned
+// diagnostics.
+func (e *TemplateExpr) EvalSegments(ctx *hcl.EvalContext) ([]TemplateSegment, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	segments := make([]TemplateSegment, 0, len(e.Parts))
+
+	for _, part := range e.Parts {
+		partVal, marks, partDiags := e.partValue(part, ctx)
+		diags = append(diags, partDiags...)
+		if partVal == cty.NilVal {
+			continue
+		}
+		if !partVal.IsKnown() {
+			partVal = cty.UnknownVal(cty.String).RefineNotNull()
+		}
+
+		// Literal text is always a string literal. Other literals can only
+		// come from interpolation sequences like ${1}.
+		lit, isLiteral := part.(*LiteralValueExpr)
+		segments = append(segments, TemplateSegment{
+			Value:        partVal.WithMarks(marks),
+			Interpolated: !isLiteral || lit.Val.Type() != cty.String,
+			Range:        part.Range(),
+		})
+	}
+
+	return segments, diags
+}
+
 func (e *TemplateExpr) Range() hcl.Range {
 	return e.SrcRange
 }
@@ -145,6 +232,36 @@ func (e *TemplateExpr) IsStringLiteral() bool {
 	return ok
 }
 
+// TemplateLiterals returns the literal text segments of the given expression,
+// in the order they appear, if it is a *TemplateExpr. Interpolation sequences
+// and template directives are excluded, and any escape sequences in the
+// literal text are already resolved to the characters they represent.
+//
+// This is intended for tools that scan configuration for user-facing strings,
+// such as for translation. Any other kind of expression, including a template
+// consisting only of a single interpolation sequence, returns nil.
+func TemplateLiterals(expr Expression) []string {
+	tmpl, ok := expr.(*TemplateExpr)
+	if !ok {
+		return nil
+	}
+
+	var ret []string
+	for _, part := range tmpl.Parts {
+		lit, ok := part.(*LiteralValueExpr)
+		if !ok {
+			continue
+		}
+		// Literal text is always a known, non-null string. Other literals
+		// can only come from interpolation sequences like ${1}.
+		if lit.Val.Type() != cty.String || lit.Val.IsNull() || !lit.Val.IsKnown() {
+			continue
+		}
+		ret = append(ret, lit.Val.AsString())
+	}
+	return ret
+}
+
 // TemplateJoinExpr is used to convert tuples of strings produced by template
 // constructs (i.e. for loops) into flat strings, by converting the values
 // tos strings and joining them. This AST node is not used directly; it's
@@ -158,6 +275,9 @@ func (e *TemplateJoinExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *TemplateJoinExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	tuple, diags := e.Tuple.Value(ctx)
 
 	if tuple.IsNull() {
@@ -250,6 +370,9 @@ func (e *TemplateWrapExpr) walkChildNodes(w internalWalkFunc) {
 }
 
 func (e *TemplateWrapExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if diags := evalStepBudgetDiags(ctx, e); diags != nil {
+		return cty.DynamicVal, diags
+	}
 	return e.Wrapped.Value(ctx)
 }
 
@@ -4,6 +4,7 @@
 package hclsyntax
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
@@ -308,6 +309,27 @@ trim`,
 			cty.UnknownVal(cty.String).Refine().NotNull().StringPrefixFull("test_known_").NewValue(),
 			0,
 		},
+		{ // can include the refined prefix of the first unknown value
+			`test_${prefixed}_${unknown}`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"prefixed": cty.UnknownVal(cty.String).Refine().StringPrefixFull("abc-").NewValue(),
+					"unknown":  cty.UnknownVal(cty.String),
+				},
+			},
+			cty.UnknownVal(cty.String).Refine().NotNull().StringPrefixFull("test_abc-").NewValue(),
+			0,
+		},
+		{ // can include a prefix deduced from a conditional with an unknown condition
+			`test_${cond ? "foo-a" : "foo-b"}`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"cond": cty.UnknownVal(cty.Bool),
+				},
+			},
+			cty.UnknownVal(cty.String).Refine().NotNull().StringPrefixFull("test_foo-").NewValue(),
+			0,
+		},
 		{ // can preserve a static prefix as a refinement, but the length is limited to 128 B
 			strings.Repeat("_", 130) + `${unknown}`,
 			&hcl.EvalContext{
@@ -500,3 +522,197 @@ func TestTemplateExprIsStringLiteral(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateLiterals(t *testing.T) {
+	tests := map[string][]string{
+		`"hello"`:                          {"hello"},
+		`"hello ${name}!"`:                 {"hello ", "!"},
+		`"a\nb\t\"c\""`:                    {"a\nb\t\"c\""},
+		`"cost: $${price} or %%{n}"`:       {"cost: ${price} or %{n}"},
+		`"${a}${1}${true}"`:                nil,
+		`"${"inner"} outer"`:               {" outer"},
+		`"a%{ if x }b%{ endif }c"`:         {"a", "c"},
+		"<<EOT\nhello ${name}\nbye\nEOT\n": {"hello ", "\nbye\n"},
+		`"${name}"`:                        nil,
+		`name`:                             nil,
+		`5`:                                nil,
+	}
+	for input, want := range tests {
+		t.Run(input, func(t *testing.T) {
+			expr, diags := ParseExpression([]byte(input), "", hcl.InitialPos)
+			if len(diags) != 0 {
+				t.Fatalf("unexpected diags: %s", diags.Error())
+			}
+
+			got := TemplateLiterals(expr)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestTemplateExprEvalSegments(t *testing.T) {
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"name":    cty.StringVal("<b>"),
+			"count":   cty.NumberIntVal(2),
+			"secret":  cty.StringVal("hunter2").Mark("sensitive"),
+			"unknown": cty.UnknownVal(cty.String),
+			"list":    cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		},
+	}
+
+	type segment struct {
+		value        cty.Value
+		interpolated bool
+	}
+	tests := map[string]struct {
+		input     string
+		want      []segment
+		wantDiags int
+	}{
+		"literal only": {
+			`hello`,
+			[]segment{
+				{cty.StringVal("hello"), false},
+			},
+			0,
+		},
+		"interpolations": {
+			`Hello, ${name}! You have ${count} messages.`,
+			[]segment{
+				{cty.StringVal("Hello, "), false},
+				{cty.StringVal("<b>"), true},
+				{cty.StringVal("! You have "), false},
+				{cty.StringVal("2"), true},
+				{cty.StringVal(" messages."), false},
+			},
+			0,
+		},
+		"literal interpolation": {
+			`${1}${"a"}`,
+			[]segment{
+				{cty.StringVal("1"), true},
+				{cty.StringVal("a"), true},
+			},
+			0,
+		},
+		"marked and unknown": {
+			`${secret}/${unknown}`,
+			[]segment{
+				{cty.StringVal("hunter2").Mark("sensitive"), true},
+				{cty.StringVal("/"), false},
+				{cty.UnknownVal(cty.String).RefineNotNull(), true},
+			},
+			0,
+		},
+		"directive": {
+			`<%{ for x in list }${x},%{ endfor }>`,
+			[]segment{
+				{cty.StringVal("<"), false},
+				{cty.StringVal("a,b,"), true},
+				{cty.StringVal(">"), false},
+			},
+			0,
+		},
+		"invalid": {
+			`a${list}b`,
+			[]segment{
+				{cty.StringVal("a"), false},
+				{cty.StringVal("b"), false},
+			},
+			1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr, diags := ParseTemplate([]byte(test.input), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse diagnostics: %s", diags.Error())
+			}
+			tmpl, ok := expr.(*TemplateExpr)
+			if !ok {
+				t.Fatalf("parsed expression is %T, not *TemplateExpr", expr)
+			}
+
+			segments, diags := tmpl.EvalSegments(ctx)
+			if len(diags) != test.wantDiags {
+				t.Errorf("wrong number of diagnostics %d; want %d\n%s", len(diags), test.wantDiags, diags.Error())
+			}
+			if len(segments) != len(test.want) {
+				t.Fatalf("wrong number of segments %d; want %d\n%#v", len(segments), len(test.want), segments)
+			}
+			for i, got := range segments {
+				want := test.want[i]
+				if !got.Value.RawEquals(want.value) {
+					t.Errorf("wrong value for segment %d\ngot:  %#v\nwant: %#v", i, got.Value, want.value)
+				}
+				if got.Interpolated != want.interpolated {
+					t.Errorf("wrong interpolated flag for segment %d: %t; want %t", i, got.Interpolated, want.interpolated)
+				}
+			}
+		})
+	}
+}
+
+func TestTemplateExprStripMarkers(t *testing.T) {
+	// These templates span multiple lines, which the scanner produces as a
+	// separate literal token per line, so a strip marker must be able to
+	// strip whitespace across more than one token.
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"items": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		},
+	}
+	tests := map[string]struct {
+		input string
+		want  string
+	}{
+		"for with right strip": {
+			"<<EOT\nbefore\n%{ for v in items ~}\n- ${v}\n%{ endfor ~}\nafter\nEOT\n",
+			"before\n- a\n- b\nafter\n",
+		},
+		"for stripping indentation": {
+			"<<EOT\n%{~ for v in items ~}\n  ${v}\n%{~ endfor ~}\nEOT\n",
+			"ab",
+		},
+		"left strip of indented endfor": {
+			"<<EOT\n  %{~ for i, v in items }\n${i}=${v}\n  %{~ endfor }\nEOT\n",
+			"\n0=a\n1=b\n",
+		},
+		"if and else across lines": {
+			"<<EOT\nbefore\n  %{~ if true ~}\n  yes\n  %{~ else ~}\n  no\n  %{~ endif ~}\n\nafter\nEOT\n",
+			"beforeyesafter\n",
+		},
+		"interpolation across lines": {
+			"<<EOT\nbefore\n\n  ${~ \"x\" ~}\n\n  after\nEOT\n",
+			"beforexafter\n",
+		},
+		"flush heredoc": {
+			"<<-EOT\n    %{ for v in items ~}\n    - ${v}\n    %{ endfor ~}\n    done\n    EOT\n",
+			"- a\n- b\ndone\n",
+		},
+		"flush heredoc with nested indentation": {
+			"<<-EOT\n  list:\n  %{~ for v in items }\n    - ${v}\n  %{~ endfor }\n  EOT\n",
+			"list:\n  - a\n  - b\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr, parseDiags := ParseExpression([]byte(test.input), "", hcl.InitialPos)
+			if parseDiags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", parseDiags.Error())
+			}
+			got, diags := expr.Value(ctx)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Error())
+			}
+			if want := cty.StringVal(test.want); !got.RawEquals(want) {
+				t.Errorf("wrong result\ngot:  %q\nwant: %q", got.AsString(), test.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ package hclsyntax
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -1301,6 +1303,96 @@ upper(
 				NewValue(),
 			0,
 		},
+		{
+			`unklist[0]`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unklist": cty.UnknownVal(cty.List(cty.String)),
+					"unkobj": cty.UnknownVal(cty.Object(map[string]cty.Type{
+						"name": cty.String,
+					})),
+					"unktuple": cty.UnknownVal(cty.Tuple([]cty.Type{cty.String, cty.String})),
+					"unkidx":   cty.UnknownVal(cty.Number),
+				},
+			},
+			cty.UnknownVal(cty.String),
+			0,
+		},
+		{
+			`unklist[unkidx]`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unklist": cty.UnknownVal(cty.List(cty.String)),
+					"unkobj": cty.UnknownVal(cty.Object(map[string]cty.Type{
+						"name": cty.String,
+					})),
+					"unktuple": cty.UnknownVal(cty.Tuple([]cty.Type{cty.String, cty.String})),
+					"unkidx":   cty.UnknownVal(cty.Number),
+				},
+			},
+			cty.UnknownVal(cty.String),
+			0,
+		},
+		{
+			`unklist[*]`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unklist": cty.UnknownVal(cty.List(cty.String)),
+					"unkobj": cty.UnknownVal(cty.Object(map[string]cty.Type{
+						"name": cty.String,
+					})),
+					"unktuple": cty.UnknownVal(cty.Tuple([]cty.Type{cty.String, cty.String})),
+					"unkidx":   cty.UnknownVal(cty.Number),
+				},
+			},
+			cty.UnknownVal(cty.List(cty.String)).RefineNotNull(),
+			0,
+		},
+		{
+			`unklist.*`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unklist": cty.UnknownVal(cty.List(cty.String)),
+					"unkobj": cty.UnknownVal(cty.Object(map[string]cty.Type{
+						"name": cty.String,
+					})),
+					"unktuple": cty.UnknownVal(cty.Tuple([]cty.Type{cty.String, cty.String})),
+					"unkidx":   cty.UnknownVal(cty.Number),
+				},
+			},
+			cty.UnknownVal(cty.List(cty.String)).RefineNotNull(),
+			0,
+		},
+		{
+			`unkobj["name"]`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unklist": cty.UnknownVal(cty.List(cty.String)),
+					"unkobj": cty.UnknownVal(cty.Object(map[string]cty.Type{
+						"name": cty.String,
+					})),
+					"unktuple": cty.UnknownVal(cty.Tuple([]cty.Type{cty.String, cty.String})),
+					"unkidx":   cty.UnknownVal(cty.Number),
+				},
+			},
+			cty.UnknownVal(cty.String),
+			0,
+		},
+		{
+			`unktuple[unkidx]`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unklist": cty.UnknownVal(cty.List(cty.String)),
+					"unkobj": cty.UnknownVal(cty.Object(map[string]cty.Type{
+						"name": cty.String,
+					})),
+					"unktuple": cty.UnknownVal(cty.Tuple([]cty.Type{cty.String, cty.String})),
+					"unkidx":   cty.UnknownVal(cty.Number),
+				},
+			},
+			cty.UnknownVal(cty.String),
+			0,
+		},
 		{
 			`unktupleobj.*.name`,
 			&hcl.EvalContext{
@@ -2073,6 +2165,49 @@ EOT
 			cty.UnknownVal(cty.Bool).RefineNotNull(),
 			0,
 		},
+		{
+			`unknown ? "foo-a" : "foo-b"`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unknown": cty.UnknownVal(cty.Bool),
+				},
+			},
+			cty.UnknownVal(cty.String).Refine().NotNull().StringPrefixFull("foo-").NewValue(), // deduced through refinements
+			0,
+		},
+		{
+			`unknown ? as : "abc-z"`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unknown": cty.UnknownVal(cty.Bool),
+					"as":      cty.UnknownVal(cty.String).Refine().StringPrefixFull("abc-").NewValue(),
+				},
+			},
+			cty.UnknownVal(cty.String).Refine().StringPrefixFull("abc-").NewValue(), // prefix kept, but might be null
+			0,
+		},
+		{
+			`unknown ? a : b`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unknown": cty.UnknownVal(cty.Bool),
+					"a":       cty.StringVal(strings.Repeat("x", 200) + "a"),
+					"b":       cty.StringVal(strings.Repeat("x", 200) + "b"),
+				},
+			},
+			cty.UnknownVal(cty.String).Refine().NotNull().StringPrefix(strings.Repeat("x", 128)).NewValue(), // prefix limited to 128 bytes
+			0,
+		},
+		{
+			`unknown ? "a" : "b"`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unknown": cty.UnknownVal(cty.Bool),
+				},
+			},
+			cty.UnknownVal(cty.String).RefineNotNull(),
+			0,
+		},
 		{
 			`unknown ? al : bl`,
 			&hcl.EvalContext{
@@ -2229,6 +2364,77 @@ EOT
 			cty.UnknownVal(cty.String).RefineNotNull().Mark("sensitive"),
 			0,
 		},
+		{
+			`true || unk`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unk": cty.UnknownVal(cty.Bool),
+				},
+			},
+			cty.True,
+			0,
+		},
+		{
+			`unk || true`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unk": cty.UnknownVal(cty.Bool),
+				},
+			},
+			cty.True,
+			0,
+		},
+		{
+			`false && unk`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unk": cty.UnknownVal(cty.Bool),
+				},
+			},
+			cty.False,
+			0,
+		},
+		{
+			`unk && false`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unk": cty.DynamicVal,
+				},
+			},
+			cty.False,
+			0,
+		},
+		{
+			`false || unk`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unk": cty.UnknownVal(cty.Bool),
+				},
+			},
+			cty.UnknownVal(cty.Bool).RefineNotNull(),
+			0,
+		},
+		{
+			`unk && true`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unk": cty.UnknownVal(cty.Bool),
+				},
+			},
+			cty.UnknownVal(cty.Bool).RefineNotNull(),
+			0,
+		},
+		{
+			`sensitive || unk`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"sensitive": cty.True.Mark("sensitive"),
+					"unk":       cty.UnknownVal(cty.Bool),
+				},
+			},
+			cty.True.Mark("sensitive"),
+			0,
+		},
 	}
 
 	for _, test := range tests {
@@ -2391,6 +2597,12 @@ func TestFunctionCallExprValue(t *testing.T) {
 		"length":     stdlib.StrlenFunc,
 		"jsondecode": stdlib.JSONDecodeFunc,
 	}
+	resolver := func(name string) (function.Function, bool) {
+		if name == "upper" {
+			return stdlib.UpperFunc, true
+		}
+		return function.Function{}, false
+	}
 
 	tests := map[string]struct {
 		expr      *FunctionCallExpr
@@ -2544,6 +2756,66 @@ func TestFunctionCallExprValue(t *testing.T) {
 			cty.DynamicVal,
 			1,
 		},
+		"function from resolver": {
+			&FunctionCallExpr{
+				Name: "upper",
+				Args: []Expression{
+					&LiteralValueExpr{
+						Val: cty.StringVal("hello"),
+					},
+				},
+			},
+			&hcl.EvalContext{
+				Functions:        funcs,
+				FunctionResolver: resolver,
+			},
+			cty.StringVal("HELLO"),
+			0,
+		},
+		"function from parent resolver": {
+			&FunctionCallExpr{
+				Name: "upper",
+				Args: []Expression{
+					&LiteralValueExpr{
+						Val: cty.StringVal("hello"),
+					},
+				},
+			},
+			(&hcl.EvalContext{
+				FunctionResolver: resolver,
+			}).NewChild(),
+			cty.StringVal("HELLO"),
+			0,
+		},
+		"static function preferred over resolver": {
+			&FunctionCallExpr{
+				Name: "length",
+				Args: []Expression{
+					&LiteralValueExpr{
+						Val: cty.StringVal("hello"),
+					},
+				},
+			},
+			&hcl.EvalContext{
+				Functions: funcs,
+				FunctionResolver: func(name string) (function.Function, bool) {
+					return stdlib.UpperFunc, true
+				},
+			},
+			cty.NumberIntVal(5),
+			0,
+		},
+		"unknown function with resolver": {
+			&FunctionCallExpr{
+				Name: "lower",
+				Args: []Expression{},
+			},
+			&hcl.EvalContext{
+				FunctionResolver: resolver,
+			},
+			cty.DynamicVal,
+			1,
+		},
 	}
 
 	for name, test := range tests {
@@ -2564,6 +2836,438 @@ func TestFunctionCallExprValue(t *testing.T) {
 	}
 }
 
+func TestScopeTraversalExprValue_variableResolver(t *testing.T) {
+	// The resolver counts the traversals it resolves, and serves
+	// "db.<table>" by returning just the number of characters in the table
+	// name, as if it had looked up only what was needed.
+	calls := 0
+	resolver := func(traversal hcl.Traversal) (cty.Value, hcl.Diagnostics, bool) {
+		if traversal.RootName() != "db" {
+			return cty.NilVal, nil, false
+		}
+		calls++
+		if len(traversal) != 2 {
+			return cty.DynamicVal, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid database reference",
+					Subject:  traversal.SourceRange().Ptr(),
+				},
+			}, true
+		}
+		table := traversal[1].(hcl.TraverseAttr).Name
+		return cty.NumberIntVal(int64(len(table))), nil, true
+	}
+
+	tests := map[string]struct {
+		input     string
+		ctx       *hcl.EvalContext
+		want      cty.Value
+		diagCount int
+	}{
+		"resolved": {
+			`db.users`,
+			&hcl.EvalContext{
+				VariableResolver: resolver,
+			},
+			cty.NumberIntVal(5),
+			0,
+		},
+		"resolved from parent": {
+			`db.users + 1`,
+			(&hcl.EvalContext{
+				VariableResolver: resolver,
+			}).NewChild(),
+			cty.NumberIntVal(6),
+			0,
+		},
+		"static variable preferred": {
+			`db.users`,
+			&hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"db": cty.ObjectVal(map[string]cty.Value{
+						"users": cty.StringVal("static"),
+					}),
+				},
+				VariableResolver: resolver,
+			},
+			cty.StringVal("static"),
+			0,
+		},
+		"resolver diagnostics": {
+			`db`,
+			&hcl.EvalContext{
+				VariableResolver: resolver,
+			},
+			cty.DynamicVal,
+			1,
+		},
+		"not resolved": {
+			`other.users`,
+			&hcl.EvalContext{
+				VariableResolver: resolver,
+			},
+			cty.DynamicVal,
+			1, // Unknown variable
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr, parseDiags := ParseExpression([]byte(test.input), "", hcl.InitialPos)
+			if parseDiags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", parseDiags)
+			}
+
+			got, diags := expr.Value(test.ctx)
+			if len(diags) != test.diagCount {
+				t.Errorf("wrong number of diagnostics %d; want %d", len(diags), test.diagCount)
+				for _, diag := range diags {
+					t.Logf(" - %s", diag.Error())
+				}
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+
+	if got, want := calls, 3; got != want {
+		t.Errorf("resolver called for %d traversals; want %d", got, want)
+	}
+}
+
+func TestFunctionCallExprValue_validator(t *testing.T) {
+	// The validator forbids calling "upper" with any argument starting
+	// with "secret", and records the calls it sees.
+	var seen []string
+	validator := func(name string, args []cty.Value) hcl.Diagnostics {
+		seen = append(seen, name)
+		if name == "upper" && args[0].IsKnown() && strings.HasPrefix(args[0].AsString(), "secret") {
+			return hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Forbidden function call",
+					Detail:   "Secrets may not be uppercased.",
+				},
+			}
+		}
+		return nil
+	}
+	functions := map[string]function.Function{
+		"upper": stdlib.UpperFunc,
+		"lower": stdlib.LowerFunc,
+	}
+
+	tests := map[string]struct {
+		input     string
+		ctx       *hcl.EvalContext
+		want      cty.Value
+		diagCount int
+		wantSeen  []string
+	}{
+		"allowed": {
+			`upper("hello")`,
+			&hcl.EvalContext{
+				Functions:             functions,
+				FunctionCallValidator: validator,
+			},
+			cty.StringVal("HELLO"),
+			0,
+			[]string{"upper"},
+		},
+		"rejected": {
+			`upper("secret value")`,
+			&hcl.EvalContext{
+				Functions:             functions,
+				FunctionCallValidator: validator,
+			},
+			cty.DynamicVal,
+			1,
+			[]string{"upper"},
+		},
+		"nested calls in parent context": {
+			`lower(upper("ok"))`,
+			(&hcl.EvalContext{
+				Functions:             functions,
+				FunctionCallValidator: validator,
+			}).NewChild(),
+			cty.StringVal("ok"),
+			0,
+			[]string{"upper", "lower"},
+		},
+		"not called when arguments are invalid": {
+			`upper(nope)`,
+			&hcl.EvalContext{
+				Variables:             map[string]cty.Value{},
+				Functions:             functions,
+				FunctionCallValidator: validator,
+			},
+			cty.DynamicVal,
+			1, // Unknown variable
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			seen = nil
+			expr, parseDiags := ParseExpression([]byte(test.input), "", hcl.InitialPos)
+			if parseDiags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", parseDiags)
+			}
+
+			got, diags := expr.Value(test.ctx)
+			if len(diags) != test.diagCount {
+				t.Errorf("wrong number of diagnostics %d; want %d", len(diags), test.diagCount)
+				for _, diag := range diags {
+					t.Logf(" - %s", diag.Error())
+				}
+			}
+			for _, diag := range diags {
+				if diag.Subject == nil {
+					t.Errorf("diagnostic has no subject: %s", diag.Error())
+				}
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+			if !reflect.DeepEqual(seen, test.wantSeen) {
+				t.Errorf("wrong validated calls\ngot:  %#v\nwant: %#v", seen, test.wantSeen)
+			}
+		})
+	}
+}
+
+func TestFunctionCallExprValue_unknownFunctions(t *testing.T) {
+	functions := map[string]function.Function{
+		"upper": stdlib.UpperFunc,
+	}
+
+	tests := map[string]struct {
+		input    string
+		ctx      *hcl.EvalContext
+		want     cty.Value
+		wantSevs []hcl.DiagnosticSeverity
+	}{
+		"default is an error": {
+			`nope("a")`,
+			&hcl.EvalContext{
+				Functions: functions,
+			},
+			cty.DynamicVal,
+			[]hcl.DiagnosticSeverity{hcl.DiagError},
+		},
+		"ignore": {
+			`nope("a")`,
+			&hcl.EvalContext{
+				Functions:        functions,
+				UnknownFunctions: hcl.UnknownFunctionsIgnore,
+			},
+			cty.DynamicVal,
+			nil,
+		},
+		"warn": {
+			`nope("a")`,
+			&hcl.EvalContext{
+				Functions:        functions,
+				UnknownFunctions: hcl.UnknownFunctionsWarn,
+			},
+			cty.DynamicVal,
+			[]hcl.DiagnosticSeverity{hcl.DiagWarning},
+		},
+		"warn for namespaced function": {
+			`ns::nope("a")`,
+			&hcl.EvalContext{
+				Functions:        functions,
+				UnknownFunctions: hcl.UnknownFunctionsWarn,
+			},
+			cty.DynamicVal,
+			[]hcl.DiagnosticSeverity{hcl.DiagWarning},
+		},
+		"known functions still called": {
+			`upper(nope("a"))`,
+			&hcl.EvalContext{
+				Functions:        functions,
+				UnknownFunctions: hcl.UnknownFunctionsIgnore,
+			},
+			cty.UnknownVal(cty.String).RefineNotNull(),
+			nil,
+		},
+		"inherited from parent": {
+			`nope("a")`,
+			(&hcl.EvalContext{
+				Functions:        functions,
+				UnknownFunctions: hcl.UnknownFunctionsIgnore,
+			}).NewChild(),
+			cty.DynamicVal,
+			nil,
+		},
+		"allows calls without a function table": {
+			`nope("a")`,
+			&hcl.EvalContext{
+				UnknownFunctions: hcl.UnknownFunctionsIgnore,
+			},
+			cty.DynamicVal,
+			nil,
+		},
+		"arguments still evaluated": {
+			`nope(undefined)`,
+			&hcl.EvalContext{
+				Variables:        map[string]cty.Value{},
+				UnknownFunctions: hcl.UnknownFunctionsWarn,
+			},
+			cty.DynamicVal,
+			[]hcl.DiagnosticSeverity{hcl.DiagWarning, hcl.DiagError},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr, parseDiags := ParseExpression([]byte(test.input), "", hcl.InitialPos)
+			if parseDiags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", parseDiags)
+			}
+
+			got, diags := expr.Value(test.ctx)
+			var gotSevs []hcl.DiagnosticSeverity
+			for _, diag := range diags {
+				gotSevs = append(gotSevs, diag.Severity)
+			}
+			if !reflect.DeepEqual(gotSevs, test.wantSevs) {
+				t.Errorf("wrong diagnostic severities\ngot:  %#v\nwant: %#v", gotSevs, test.wantSevs)
+				for _, diag := range diags {
+					t.Logf(" - %s", diag.Error())
+				}
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExpressionValue_maxEvalSteps(t *testing.T) {
+	bigVals := make([]cty.Value, 1000)
+	for i := range bigVals {
+		bigVals[i] = cty.NumberIntVal(int64(i))
+	}
+	big := cty.ListVal(bigVals)
+
+	tests := map[string]struct {
+		input    string
+		maxSteps int
+		child    bool
+		want     cty.Value
+		wantErr  bool
+	}{
+		"unlimited": {
+			`length([for v in big: v * 2])`,
+			0,
+			false,
+			cty.NumberIntVal(1000),
+			false,
+		},
+		"within budget": {
+			`1 + 2`,
+			3,
+			false,
+			cty.NumberIntVal(3),
+			false,
+		},
+		"exceeded": {
+			`1 + 2`,
+			2,
+			false,
+			cty.UnknownVal(cty.Number),
+			true,
+		},
+		"for expression iterations": {
+			`[for v in big: v]`,
+			100,
+			false,
+			cty.DynamicVal,
+			true,
+		},
+		"for expression producing object": {
+			`{for v in big: v => v}`,
+			100,
+			false,
+			cty.DynamicVal,
+			true,
+		},
+		"splat iterations": {
+			`big[*]`,
+			100,
+			false,
+			cty.DynamicVal,
+			true,
+		},
+		"inherited from parent": {
+			`[for v in big: v]`,
+			100,
+			true,
+			cty.DynamicVal,
+			true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr, parseDiags := ParseExpression([]byte(test.input), "", hcl.InitialPos)
+			if parseDiags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", parseDiags)
+			}
+
+			ctx := &hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"big": big,
+				},
+				Functions: map[string]function.Function{
+					"length": stdlib.LengthFunc,
+				},
+				MaxEvalSteps: test.maxSteps,
+			}
+			if test.child {
+				ctx = ctx.NewChild()
+			}
+
+			got, diags := expr.Value(ctx)
+			if test.wantErr {
+				if !diags.HasErrors() {
+					t.Fatalf("unexpected success\nwant error")
+				}
+				for _, diag := range diags {
+					if diag.Summary != "Evaluation exceeded step budget" {
+						t.Errorf("unexpected diagnostic: %s", diag.Error())
+					}
+				}
+			} else if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Error())
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+
+	t.Run("shared across evaluations", func(t *testing.T) {
+		ctx := &hcl.EvalContext{
+			MaxEvalSteps: 3,
+		}
+		expr, parseDiags := ParseExpression([]byte(`1 + 2`), "", hcl.InitialPos)
+		if parseDiags.HasErrors() {
+			t.Fatalf("unexpected parse errors: %s", parseDiags)
+		}
+		if _, diags := expr.Value(ctx); diags.HasErrors() {
+			t.Fatalf("unexpected errors on first evaluation: %s", diags.Error())
+		}
+		if _, diags := expr.Value(ctx); !diags.HasErrors() {
+			t.Fatalf("unexpected success on second evaluation")
+		}
+	})
+}
+
 func TestExpressionAsTraversal(t *testing.T) {
 	expr, _ := ParseExpression([]byte("a.b[0][\"c\"]"), "", hcl.Pos{})
 	traversal, diags := hcl.AbsTraversalForExpr(expr)
@@ -2690,3 +3394,176 @@ func TestParseExpression_incompleteFunctionCall(t *testing.T) {
 		})
 	}
 }
+
+func TestExpressionValue_nonFiniteNumbers(t *testing.T) {
+	tests := map[string]struct {
+		input   string
+		mode    hcl.NonFiniteNumbersMode
+		want    cty.Value
+		wantErr string
+	}{
+		"allowed by default": {
+			`1 / 0`,
+			hcl.NonFiniteNumbersAllow,
+			cty.PositiveInfinity,
+			"",
+		},
+		"division by zero": {
+			`1 / 0`,
+			hcl.NonFiniteNumbersError,
+			cty.UnknownVal(cty.Number),
+			"The right operand is zero, and division by zero is not allowed.",
+		},
+		"negative division by zero": {
+			`-1 / zero`,
+			hcl.NonFiniteNumbersError,
+			cty.UnknownVal(cty.Number),
+			"The right operand is zero, and division by zero is not allowed.",
+		},
+		"modulo by zero": {
+			`5 % 0`,
+			hcl.NonFiniteNumbersError,
+			cty.UnknownVal(cty.Number),
+			"The right operand is zero, and division by zero is not allowed.",
+		},
+		"finite division": {
+			`1 / 4`,
+			hcl.NonFiniteNumbersError,
+			cty.NumberFloatVal(0.25),
+			"",
+		},
+		"infinite operand": {
+			`inf + 1`,
+			hcl.NonFiniteNumbersError,
+			cty.PositiveInfinity,
+			"",
+		},
+		"unknown divisor": {
+			`1 / unknown`,
+			hcl.NonFiniteNumbersError,
+			cty.UnknownVal(cty.Number).RefineNotNull(),
+			"",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr, parseDiags := ParseExpression([]byte(test.input), "", hcl.InitialPos)
+			if parseDiags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", parseDiags)
+			}
+
+			ctx := &hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"zero":    cty.Zero,
+					"inf":     cty.PositiveInfinity,
+					"unknown": cty.UnknownVal(cty.Number),
+				},
+				NonFiniteNumbers: test.mode,
+			}
+
+			got, diags := expr.Value(ctx.NewChild())
+			if test.wantErr == "" {
+				if diags.HasErrors() {
+					t.Fatalf("unexpected errors: %s", diags.Error())
+				}
+			} else {
+				if len(diags) != 1 {
+					t.Fatalf("got %d diagnostics; want 1\n%s", len(diags), diags.Error())
+				}
+				if got := diags[0].Detail; got != test.wantErr {
+					t.Errorf("wrong error\ngot:  %s\nwant: %s", got, test.wantErr)
+				}
+				if got, want := *diags[0].Subject, expr.Range(); got != want {
+					t.Errorf("wrong subject\ngot:  %s\nwant: %s", got, want)
+				}
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExpressionValue_compareStrings(t *testing.T) {
+	foldCase := func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	}
+
+	tests := map[string]struct {
+		input   string
+		compare func(a, b string) int
+		want    cty.Value
+	}{
+		"numeric strings by default": {
+			`"10" > "9"`,
+			nil,
+			cty.True,
+		},
+		"less than": {
+			`"a" < "B"`,
+			foldCase,
+			cty.True,
+		},
+		"less than or equal": {
+			`"A" <= "a"`,
+			foldCase,
+			cty.True,
+		},
+		"greater than": {
+			`"a" > "B"`,
+			foldCase,
+			cty.False,
+		},
+		"greater than or equal": {
+			`"b" >= "A"`,
+			foldCase,
+			cty.True,
+		},
+		"numbers": {
+			`10 > 9`,
+			foldCase,
+			cty.True,
+		},
+		"string and number": {
+			`"10" > 9`,
+			foldCase,
+			cty.True,
+		},
+		"unknown": {
+			`unknown < "a"`,
+			foldCase,
+			cty.UnknownVal(cty.Bool).RefineNotNull(),
+		},
+		"marked": {
+			`sensitive < "b"`,
+			foldCase,
+			cty.True.Mark("sensitive"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr, parseDiags := ParseExpression([]byte(test.input), "", hcl.InitialPos)
+			if parseDiags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", parseDiags)
+			}
+
+			ctx := &hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"unknown":   cty.UnknownVal(cty.String),
+					"sensitive": cty.StringVal("A").Mark("sensitive"),
+				},
+				CompareStrings: test.compare,
+			}
+
+			got, diags := expr.Value(ctx.NewChild())
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Error())
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
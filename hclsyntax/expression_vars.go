@@ -14,70 +14,150 @@ func (e *AnonSymbolExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *AnonSymbolExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *BinaryOpExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *BinaryOpExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *ConditionalExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *ConditionalExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *ExprSyntaxError) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *ExprSyntaxError) Functions() []string {
+	return Functions(e)
+}
+
 func (e *ForExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *ForExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *FunctionCallExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *FunctionCallExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *IndexExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *IndexExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *LiteralValueExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *LiteralValueExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *ObjectConsExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *ObjectConsExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *ObjectConsKeyExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *ObjectConsKeyExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *RelativeTraversalExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *RelativeTraversalExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *ScopeTraversalExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *ScopeTraversalExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *SplatExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *SplatExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *TemplateExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *TemplateExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *TemplateJoinExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *TemplateJoinExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *TemplateWrapExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *TemplateWrapExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *TupleConsExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
 
+func (e *TupleConsExpr) Functions() []string {
+	return Functions(e)
+}
+
 func (e *UnaryOpExpr) Variables() []hcl.Traversal {
 	return Variables(e)
 }
+
+func (e *UnaryOpExpr) Functions() []string {
+	return Functions(e)
+}
+
+func (e *memoExpr) Variables() []hcl.Traversal {
+	return Variables(e)
+}
+
+func (e *memoExpr) Functions() []string {
+	return Functions(e)
+}
@@ -2,10 +2,10 @@
 // SPDX-License-Identifier: MPL-2.0
 
 // This is a 'go generate'-oriented program for producing the "Variables"
-// method on every Expression implementation found within this package.
-// All expressions share the same implementation for this method, which
-// just wraps the package-level function "Variables" and uses an AST walk
-// to do its work.
+// and "Functions" methods on every Expression implementation found within
+// this package. All expressions share the same implementation for these
+// methods, which just wrap the package-level functions of the same names
+// and use an AST walk to do their work.
 
 //go:build ignore
 // +build ignore
@@ -42,6 +42,10 @@ func main() {
 			if fd.Name.Name != "Value" {
 				continue
 			}
+			if fd.Recv == nil || len(fd.Type.Params.List) != 1 {
+				// Expression.Value takes only the evaluation context.
+				continue
+			}
 			results := fd.Type.Results.List
 			if len(results) != 2 {
 				continue
@@ -81,7 +85,7 @@ func main() {
 
 	fmt.Fprint(of, outputPreamble)
 	for _, recv := range recvs {
-		fmt.Fprintf(of, outputMethodFmt, recv)
+		fmt.Fprintf(of, outputMethodFmt, recv, recv)
 	}
 	fmt.Fprint(of, "\n")
 
@@ -103,4 +107,8 @@ const outputMethodFmt = `
 
 func (e %s) Variables() []hcl.Traversal {
 	return Variables(e)
+}
+
+func (e %s) Functions() []string {
+	return Functions(e)
 }`
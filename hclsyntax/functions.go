@@ -0,0 +1,35 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// Functions returns the names of all of the functions called within a given
+// expression, in lexical order and without duplicates.
+//
+// This is the implementation of the "Functions" method on every native
+// expression.
+func Functions(expr Expression) []string {
+	seen := make(map[string]struct{})
+	VisitAll(expr, func(n Node) hcl.Diagnostics {
+		if call, ok := n.(*FunctionCallExpr); ok {
+			seen[call.Name] = struct{}{}
+		}
+		return nil
+	})
+	if len(seen) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
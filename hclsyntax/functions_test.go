@@ -0,0 +1,34 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestFunctions(t *testing.T) {
+	tests := map[string][]string{
+		`a`:                                 nil,
+		`upper(a)`:                          {"upper"},
+		`upper(lower(a)) + upper(b)`:        {"lower", "upper"},
+		`[for x in list(a) : core::abs(x)]`: {"core::abs", "list"},
+		`"${join(",", a)}"`:                 {"join"},
+	}
+
+	for src, want := range tests {
+		t.Run(src, func(t *testing.T) {
+			expr, diags := ParseExpression([]byte(src), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected diagnostics: %s", diags.Error())
+			}
+			got := expr.(interface{ Functions() []string }).Functions()
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+}
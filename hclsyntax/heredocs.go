@@ -0,0 +1,85 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// HeredocInfo describes a heredoc template found by Heredocs.
+type HeredocInfo struct {
+	// Marker is the identifier that opens and closes the heredoc, such as
+	// EOT for a heredoc introduced by <<EOT.
+	Marker string
+
+	// Content is the raw source of the heredoc's content, exactly as written
+	// between the line containing the opening marker and the line containing
+	// the closing marker. Interpolation and template directive sequences are
+	// included verbatim, and the indentation of a flush heredoc is not
+	// removed. If the file was parsed with ParseOptions.NormalizeLineEndings
+	// then its line endings are normalized.
+	Content string
+
+	// Flush is true for a heredoc introduced with <<- rather than <<, whose
+	// lines are indented in the source and have the common indentation
+	// removed when evaluated.
+	Flush bool
+
+	// ContentRange is the range of Content in the source, and Range is the
+	// range of the whole heredoc including its opening and closing markers.
+	ContentRange hcl.Range
+	Range        hcl.Range
+}
+
+// Heredocs returns a description of each heredoc template in the given body,
+// including those in the attributes of nested blocks and in any expressions
+// nested inside other expressions, in the order they appear in the source.
+//
+// This is intended for tools that inspect the content of heredocs without
+// evaluating them, such as linters for scripts embedded in configuration.
+func Heredocs(body *Body) []HeredocInfo {
+	var ret []HeredocInfo
+	VisitAll(body, func(node Node) hcl.Diagnostics {
+		if tmpl, ok := node.(*TemplateExpr); ok && tmpl.heredoc != nil {
+			ret = append(ret, *tmpl.heredoc)
+		}
+		return nil
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Range.Start.Byte < ret[j].Range.Start.Byte
+	})
+	return ret
+}
+
+// newHeredocInfo returns the description of a heredoc with the given opening
+// and closing tokens.
+func newHeredocInfo(open, close Token) *HeredocInfo {
+	marker := bytes.TrimPrefix(open.Bytes, []byte("<<"))
+	marker = bytes.TrimPrefix(marker, []byte("-"))
+	info := &HeredocInfo{
+		Marker: string(bytes.TrimSpace(marker)),
+		Flush:  tokenOpensFlushHeredoc(open),
+		ContentRange: hcl.Range{
+			Filename: open.Range.Filename,
+			Start:    open.Range.End,
+			End:      close.Range.Start,
+		},
+		Range: hcl.RangeBetween(open.Range, close.Range),
+	}
+
+	// The bytes of the tokens are slices of the same source buffer, so the
+	// difference between their capacities is the distance between them in
+	// that buffer. The byte offsets in their ranges can't be used for this,
+	// because they refer to the original source if its line endings were
+	// normalized before scanning.
+	start := len(open.Bytes)
+	if n := cap(open.Bytes) - start - cap(close.Bytes); n >= 0 {
+		info.Content = string(open.Bytes[start : start+n])
+	}
+
+	return info
+}
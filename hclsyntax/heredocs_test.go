@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestHeredocs(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		opts ParseOptions
+		want []HeredocInfo
+	}{
+		"none": {
+			src:  `a = "hello"`,
+			want: nil,
+		},
+		"nested": {
+			src: `script = <<EOT
+echo ${name}
+EOT
+service "web" {
+  env = {
+    sql = <<-SQL
+      SELECT 1;
+    SQL
+  }
+}
+`,
+			want: []HeredocInfo{
+				{
+					Marker:  "EOT",
+					Content: "echo ${name}\n",
+					ContentRange: hcl.Range{
+						Filename: "test.hcl",
+						Start:    hcl.Pos{Line: 2, Column: 1, Byte: 15},
+						End:      hcl.Pos{Line: 3, Column: 1, Byte: 28},
+					},
+					Range: hcl.Range{
+						Filename: "test.hcl",
+						Start:    hcl.Pos{Line: 1, Column: 10, Byte: 9},
+						End:      hcl.Pos{Line: 3, Column: 4, Byte: 31},
+					},
+				},
+				{
+					Marker:  "SQL",
+					Content: "      SELECT 1;\n",
+					Flush:   true,
+					ContentRange: hcl.Range{
+						Filename: "test.hcl",
+						Start:    hcl.Pos{Line: 7, Column: 1, Byte: 75},
+						End:      hcl.Pos{Line: 8, Column: 1, Byte: 91},
+					},
+					Range: hcl.Range{
+						Filename: "test.hcl",
+						Start:    hcl.Pos{Line: 6, Column: 11, Byte: 68},
+						End:      hcl.Pos{Line: 8, Column: 8, Byte: 98},
+					},
+				},
+			},
+		},
+		"lazy block body": {
+			src: `service {
+  script = <<EOT
+a
+EOT
+}
+`,
+			opts: ParseOptions{LazyBlockBodies: true},
+			want: []HeredocInfo{
+				{
+					Marker:  "EOT",
+					Content: "a\n",
+					ContentRange: hcl.Range{
+						Filename: "test.hcl",
+						Start:    hcl.Pos{Line: 3, Column: 1, Byte: 27},
+						End:      hcl.Pos{Line: 4, Column: 1, Byte: 29},
+					},
+					Range: hcl.Range{
+						Filename: "test.hcl",
+						Start:    hcl.Pos{Line: 2, Column: 12, Byte: 21},
+						End:      hcl.Pos{Line: 4, Column: 4, Byte: 32},
+					},
+				},
+			},
+		},
+		"normalized line endings": {
+			src:  "a = <<EOT\r\nx\r\ny\r\nEOT\r\n",
+			opts: ParseOptions{NormalizeLineEndings: true},
+			want: []HeredocInfo{
+				{
+					Marker:  "EOT",
+					Content: "x\ny\n",
+					ContentRange: hcl.Range{
+						Filename: "test.hcl",
+						Start:    hcl.Pos{Line: 2, Column: 1, Byte: 11},
+						End:      hcl.Pos{Line: 4, Column: 1, Byte: 17},
+					},
+					Range: hcl.Range{
+						Filename: "test.hcl",
+						Start:    hcl.Pos{Line: 1, Column: 5, Byte: 4},
+						End:      hcl.Pos{Line: 4, Column: 4, Byte: 20},
+					},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, diags := ParseConfigWithOptions([]byte(test.src), "test.hcl", hcl.InitialPos, test.opts)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors\n%s", diags)
+			}
+
+			got := Heredocs(file.Body.(*Body))
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("wrong result\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"bytes"
+	"sort"
+)
+
+// normalizeLineEndings returns a copy of the given buffer with each CRLF
+// sequence replaced by a single LF, along with a function that maps a byte
+// offset in the normalized buffer back to the corresponding offset in the
+// original buffer.
+//
+// Offsets are mapped so that a range ending just before a normalized newline
+// ends before the original carriage return, while a range covering a
+// normalized newline covers the whole original CRLF sequence. Line and column
+// numbers are unaffected by normalization, because the scanner already treats
+// CRLF as a single newline.
+//
+// If the buffer contains no CRLF sequences then it is returned verbatim.
+func normalizeLineEndings(src []byte) ([]byte, func(int) int) {
+	crlf := []byte{'\r', '\n'}
+	if !bytes.Contains(src, crlf) {
+		return src, func(offset int) int { return offset }
+	}
+
+	norm := make([]byte, 0, len(src))
+
+	// removed records the offset in the normalized buffer of the LF
+	// following each carriage return we removed, in increasing order.
+	var removed []int
+	for {
+		idx := bytes.Index(src, crlf)
+		if idx < 0 {
+			norm = append(norm, src...)
+			break
+		}
+		norm = append(norm, src[:idx]...)
+		removed = append(removed, len(norm))
+		norm = append(norm, '\n')
+		src = src[idx+2:]
+	}
+
+	return norm, func(offset int) int {
+		// Each removed carriage return before the given offset shifts
+		// the original offset by one.
+		return offset + sort.SearchInts(removed, offset)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestParseConfigWithOptions_normalizeLineEndings(t *testing.T) {
+	src := []byte("a = <<EOT\r\nhello\r\nEOT\r\nb = \"world\"\r\n\r\nc = 1 +\r\n")
+
+	for _, normalize := range []bool{false, true} {
+		file, diags := ParseConfigWithOptions(src, "test.hcl", hcl.InitialPos, ParseOptions{
+			NormalizeLineEndings: normalize,
+		})
+		// The "c" attribute is deliberately incomplete, so that we can
+		// check the range of the resulting diagnostic.
+		if len(diags) != 1 {
+			t.Fatalf("wrong number of diagnostics %d; want 1\n%s", len(diags), diags)
+		}
+		if got, want := *diags[0].Subject, (hcl.Range{
+			Filename: "test.hcl",
+			Start:    hcl.Pos{Line: 6, Column: 8, Byte: 45},
+			End:      hcl.Pos{Line: 7, Column: 1, Byte: 47},
+		}); got != want {
+			t.Errorf("wrong diagnostic range (normalize=%t)\ngot:  %#v\nwant: %#v", normalize, got, want)
+		}
+
+		attrs := file.Body.(*Body).Attributes
+		a, _ := attrs["a"].Expr.Value(nil)
+		wantA := cty.StringVal("hello\r\n")
+		if normalize {
+			wantA = cty.StringVal("hello\n")
+		}
+		if !a.RawEquals(wantA) {
+			t.Errorf("wrong value for a (normalize=%t)\ngot:  %#v\nwant: %#v", normalize, a, wantA)
+		}
+
+		b := attrs["b"]
+		if got, want := string(b.SrcRange.SliceBytes(src)), `b = "world"`; got != want {
+			t.Errorf("wrong source for b (normalize=%t)\ngot:  %q\nwant: %q", normalize, got, want)
+		}
+		if got, want := b.SrcRange.Start, (hcl.Pos{Line: 4, Column: 1, Byte: 23}); got != want {
+			t.Errorf("wrong start for b (normalize=%t)\ngot:  %#v\nwant: %#v", normalize, got, want)
+		}
+	}
+}
+
+func TestParseConfigWithOptions_normalizeLineEndingsStartPos(t *testing.T) {
+	src := []byte("a = 1\r\nb = 2\r\n")
+	start := hcl.Pos{Line: 10, Column: 1, Byte: 100}
+
+	eager, diags := ParseConfig(src, "test.hcl", start)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics\n%s", diags)
+	}
+	norm, diags := ParseConfigWithOptions(src, "test.hcl", start, ParseOptions{
+		NormalizeLineEndings: true,
+	})
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics\n%s", diags)
+	}
+
+	got := norm.Body.(*Body).Attributes["b"].SrcRange
+	want := eager.Body.(*Body).Attributes["b"].SrcRange
+	if got != want {
+		t.Errorf("wrong range for b\ngot:  %#v\nwant: %#v", got, want)
+	}
+	if got, want := got.Start.Byte, 107; got != want {
+		t.Errorf("wrong start offset for b %d; want %d", got, want)
+	}
+}
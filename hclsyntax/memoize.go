@@ -0,0 +1,209 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Memoizer evaluates expressions while caching the results of their
+// sub-expressions across evaluations, for applications that evaluate the
+// same expressions many times with only some of the variables changing
+// between evaluations.
+//
+// When a Memoizer is created it is given the names of the "volatile" root
+// variables that are expected to change between evaluations. Sub-expressions
+// that refer to any of those variables, directly or through their own
+// sub-expressions, are always evaluated normally. The result of any other
+// sub-expression is cached, keyed by the identity of the sub-expression and
+// the values of all of the variable traversals it refers to.
+//
+// A cached result is therefore reused only when each of the traversals
+// returned by the sub-expression's Variables method produces a value that
+// is equal, including any marks, to the value it produced when the result
+// was cached. A sub-expression whose traversals produce errors or that
+// belongs to the body of a splat expression is never cached.
+//
+// Functions are assumed to be pure and to be the same in all of the
+// evaluation contexts used with a particular Memoizer, so the function
+// definitions are not part of the cache key. Call Reset after changing any
+// functions, function resolvers, or function call validators, or if the
+// values of the volatile variables are not the only inputs that change.
+//
+// The cached results include any diagnostics from the first evaluation, so
+// the EvalContext of a cached diagnostic may be an earlier context than the
+// one given for the current evaluation.
+//
+// A Memoizer is safe for concurrent use.
+type Memoizer struct {
+	volatile map[string]struct{}
+
+	mu      sync.Mutex
+	shadows map[Expression]Expression
+}
+
+// NewMemoizer creates a new Memoizer that treats the variables with the
+// given root names as volatile.
+func NewMemoizer(volatile ...string) *Memoizer {
+	m := &Memoizer{
+		volatile: make(map[string]struct{}, len(volatile)),
+		shadows:  make(map[Expression]Expression),
+	}
+	for _, name := range volatile {
+		m.volatile[name] = struct{}{}
+	}
+	return m
+}
+
+// Value evaluates the given expression in the given context, just as
+// expr.Value(ctx) would, but reuses cached results for any sub-expressions
+// whose inputs have not changed since an earlier call.
+//
+// The given expression must not be modified after it is first given to
+// this method, unless Reset is called afterwards.
+func (m *Memoizer) Value(expr Expression, ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	m.mu.Lock()
+	shadow, ok := m.shadows[expr]
+	if !ok {
+		shadow = m.shadow(expr)
+		m.shadows[expr] = shadow
+	}
+	m.mu.Unlock()
+
+	return shadow.Value(ctx)
+}
+
+// Reset discards all of the cached results.
+func (m *Memoizer) Reset() {
+	m.mu.Lock()
+	m.shadows = make(map[Expression]Expression)
+	m.mu.Unlock()
+}
+
+// shadow returns a copy of the given expression tree in which each of the
+// largest subtrees that can be cached is replaced by a memoExpr.
+func (m *Memoizer) shadow(expr Expression) Expression {
+	r := &traversalRewriter{
+		fn: func(traversal hcl.Traversal) hcl.Traversal {
+			return traversal
+		},
+		locals: make(map[string]int),
+		anons:  make(map[*AnonSymbolExpr]*AnonSymbolExpr),
+		replace: func(expr Expression) (Expression, bool) {
+			switch expr.(type) {
+			case *LiteralValueExpr:
+				// Not worth caching, since there's nothing to evaluate.
+				return nil, false
+			case *ObjectConsKeyExpr:
+				// Must be retained because naked identifiers in object keys
+				// are recognized by type.
+				return nil, false
+			}
+			if !m.cacheable(expr) {
+				return nil, false
+			}
+			return &memoExpr{
+				Wrapped: expr,
+				vars:    expr.Variables(),
+				entries: make(map[int][]memoEntry),
+			}, true
+		},
+	}
+	return r.rewrite(expr)
+}
+
+// cacheable returns true if the given expression refers to no volatile
+// variables and doesn't contain any splat expression symbols.
+func (m *Memoizer) cacheable(expr Expression) bool {
+	for _, traversal := range expr.Variables() {
+		if _, volatile := m.volatile[traversal.RootName()]; volatile {
+			return false
+		}
+	}
+
+	ret := true
+	VisitAll(expr, func(node Node) hcl.Diagnostics {
+		if _, isAnon := node.(*AnonSymbolExpr); isAnon {
+			ret = false
+		}
+		return nil
+	})
+	return ret
+}
+
+// memoExpr is an expression that caches the results of the expression it
+// wraps, as described in the documentation for Memoizer.
+type memoExpr struct {
+	Wrapped Expression
+
+	vars []hcl.Traversal
+
+	mu      sync.Mutex
+	entries map[int][]memoEntry // keyed by the hash of the inputs
+}
+
+type memoEntry struct {
+	inputs []cty.Value
+	val    cty.Value
+	diags  hcl.Diagnostics
+}
+
+func (e *memoExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	inputs := make([]cty.Value, len(e.vars))
+	for i, traversal := range e.vars {
+		val, diags := traversal.TraverseAbs(ctx)
+		if diags.HasErrors() {
+			// We'll let the wrapped expression report the error.
+			return e.Wrapped.Value(ctx)
+		}
+		inputs[i] = val
+	}
+	unmarked, _ := cty.TupleVal(inputs).UnmarkDeep()
+	hash := unmarked.Hash()
+
+	e.mu.Lock()
+	for _, entry := range e.entries[hash] {
+		if memoInputsEqual(entry.inputs, inputs) {
+			e.mu.Unlock()
+			return entry.val, entry.diags
+		}
+	}
+	e.mu.Unlock()
+
+	val, diags := e.Wrapped.Value(ctx)
+
+	e.mu.Lock()
+	e.entries[hash] = append(e.entries[hash], memoEntry{
+		inputs: inputs,
+		val:    val,
+		diags:  diags,
+	})
+	e.mu.Unlock()
+
+	return val, diags
+}
+
+func memoInputsEqual(a, b []cty.Value) bool {
+	for i := range a {
+		if !a[i].RawEquals(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *memoExpr) walkChildNodes(w internalWalkFunc) {
+	w(e.Wrapped)
+}
+
+func (e *memoExpr) Range() hcl.Range {
+	return e.Wrapped.Range()
+}
+
+func (e *memoExpr) StartRange() hcl.Range {
+	return e.Wrapped.StartRange()
+}
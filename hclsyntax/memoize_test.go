@@ -0,0 +1,139 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+func TestMemoizer(t *testing.T) {
+	tests := map[string]struct {
+		src string
+
+		// Each step gives the values of "local" and "var" to evaluate with,
+		// and the number of calls to the "expensive" function that the step
+		// should cause.
+		steps []memoizerTestStep
+	}{
+		"stable sub-expression": {
+			`expensive(local.a) + var.x`,
+			[]memoizerTestStep{
+				{localA: 1, varX: 1, wantCalls: 1},
+				{localA: 1, varX: 2, wantCalls: 0},
+				{localA: 1, varX: 3, wantCalls: 0},
+				{localA: 2, varX: 3, wantCalls: 1},
+				{localA: 1, varX: 3, wantCalls: 0},
+			},
+		},
+		"volatile sub-expression": {
+			`expensive(var.x) + expensive(local.a)`,
+			[]memoizerTestStep{
+				{localA: 1, varX: 1, wantCalls: 2},
+				{localA: 1, varX: 1, wantCalls: 1},
+				{localA: 1, varX: 2, wantCalls: 1},
+			},
+		},
+		"template": {
+			`"${expensive(local.a)}-${var.x}"`,
+			[]memoizerTestStep{
+				{localA: 1, varX: 1, wantCalls: 1},
+				{localA: 1, varX: 2, wantCalls: 0},
+			},
+		},
+		"for expression": {
+			`[for v in [local.a, local.a + 1] : expensive(v) + var.x]`,
+			[]memoizerTestStep{
+				{localA: 1, varX: 1, wantCalls: 2},
+				{localA: 1, varX: 2, wantCalls: 0},
+				{localA: 2, varX: 2, wantCalls: 1}, // expensive(2) was already cached
+			},
+		},
+		"splat": {
+			`[{a = local.a}, {a = var.x}][*].a`,
+			[]memoizerTestStep{
+				{localA: 1, varX: 1, wantCalls: 0},
+				{localA: 1, varX: 2, wantCalls: 0},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			expensive := function.New(&function.Spec{
+				Params: []function.Parameter{
+					{Name: "n", Type: cty.Number},
+				},
+				Type: function.StaticReturnType(cty.Number),
+				Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+					calls++
+					return args[0], nil
+				},
+			})
+
+			expr, diags := ParseExpression([]byte(test.src), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags)
+			}
+
+			m := NewMemoizer("var")
+			for i, step := range test.steps {
+				ctx := &hcl.EvalContext{
+					Variables: map[string]cty.Value{
+						"local": cty.ObjectVal(map[string]cty.Value{
+							"a": cty.NumberIntVal(step.localA),
+						}),
+						"var": cty.ObjectVal(map[string]cty.Value{
+							"x": cty.NumberIntVal(step.varX),
+						}),
+					},
+					Functions: map[string]function.Function{
+						"expensive": expensive,
+					},
+				}
+
+				want, diags := expr.Value(ctx)
+				if diags.HasErrors() {
+					t.Fatalf("unexpected errors in step %d: %s", i, diags)
+				}
+
+				calls = 0
+				got, diags := m.Value(expr, ctx)
+				if diags.HasErrors() {
+					t.Fatalf("unexpected errors from memoizer in step %d: %s", i, diags)
+				}
+				if !got.RawEquals(want) {
+					t.Errorf("wrong result in step %d\ngot:  %#v\nwant: %#v", i, got, want)
+				}
+				if calls != step.wantCalls {
+					t.Errorf("wrong number of calls in step %d: got %d, want %d", i, calls, step.wantCalls)
+				}
+			}
+
+			m.Reset()
+			calls = 0
+			m.Value(expr, &hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"local": cty.ObjectVal(map[string]cty.Value{"a": cty.NumberIntVal(1)}),
+					"var":   cty.ObjectVal(map[string]cty.Value{"x": cty.NumberIntVal(1)}),
+				},
+				Functions: map[string]function.Function{
+					"expensive": expensive,
+				},
+			})
+			if want := test.steps[0].wantCalls; calls != want {
+				t.Errorf("wrong number of calls after reset: got %d, want %d", calls, want)
+			}
+		})
+	}
+}
+
+type memoizerTestStep struct {
+	localA, varX int64
+	wantCalls    int
+}
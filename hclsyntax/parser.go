@@ -22,6 +22,29 @@ type parser struct {
 	// in recovery mode, assuming that the recovery heuristics have failed
 	// in this case and left the peeker in a wrong place.
 	recovery bool
+
+	// set to true to produce warnings about constructs that are valid but
+	// easily misread, as selected by ParseOptions.PedanticConditionals.
+	pedanticConditionals bool
+
+	// if greater than zero, the maximum number of expression and body item
+	// nodes the parser may produce before it aborts, as selected by
+	// ParseOptions.MaxNodes. nodes counts the nodes produced so far.
+	maxNodes int
+	nodes    int
+
+	// set to true to defer parsing of multi-line block bodies until they
+	// are accessed, as selected by ParseOptions.LazyBlockBodies.
+	lazyBlockBodies bool
+
+	// if non-empty, comments starting with this prefix are extracted as
+	// annotations on the following body item, as selected by
+	// ParseOptions.AnnotationPrefix.
+	annotationPrefix string
+
+	// if set, attributes may have type annotations, which are validated
+	// by this function, as selected by ParseOptions.TypeAnnotations.
+	typeAnnotations func(expr hcl.Expression) hcl.Diagnostics
 }
 
 func (p *parser) ParseBody(end TokenType) (*Body, hcl.Diagnostics) {
@@ -32,6 +55,10 @@ func (p *parser) ParseBody(end TokenType) (*Body, hcl.Diagnostics) {
 	startRange := p.PrevRange()
 	var endRange hcl.Range
 
+	// itemStart is the index of the first token after the previous body
+	// item, so that we can find any annotations preceding the next one.
+	itemStart := p.NextIndex
+
 Token:
 	for {
 		next := p.Peek()
@@ -46,12 +73,16 @@ Token:
 			p.Read()
 			continue
 		case TokenIdent:
+			annots := p.annotationsBefore(itemStart)
 			item, itemDiags := p.ParseBodyItem()
+			itemStart = p.NextIndex
 			diags = append(diags, itemDiags...)
 			switch titem := item.(type) {
 			case *Block:
+				titem.Annotations = annots
 				blocks = append(blocks, titem)
 			case *Attribute:
+				titem.Annotations = annots
 				if existing, exists := attrs[titem.Name]; exists {
 					diags = append(diags, &hcl.Diagnostic{
 						Severity: hcl.DiagError,
@@ -140,6 +171,7 @@ Token:
 
 func (p *parser) ParseBodyItem() (Node, hcl.Diagnostics) {
 	ident := p.Read()
+	p.countNode(ident.Range)
 	if ident.Type != TokenIdent {
 		p.recoverAfterBodyItem()
 		return nil, hcl.Diagnostics{
@@ -153,6 +185,9 @@ func (p *parser) ParseBodyItem() (Node, hcl.Diagnostics) {
 	}
 
 	next := p.Peek()
+	if next.Type == TokenColon && p.typeAnnotations != nil {
+		return p.finishParsingBodyAttribute(ident, false)
+	}
 
 	switch next.Type {
 	case TokenEqual:
@@ -178,6 +213,7 @@ func (p *parser) ParseBodyItem() (Node, hcl.Diagnostics) {
 // immediately followed by the end token type with no intervening newlines.
 func (p *parser) parseSingleAttrBody(end TokenType) (*Body, hcl.Diagnostics) {
 	ident := p.Read()
+	p.countNode(ident.Range)
 	if ident.Type != TokenIdent {
 		p.recoverAfterBodyItem()
 		return nil, hcl.Diagnostics{
@@ -194,12 +230,20 @@ func (p *parser) parseSingleAttrBody(end TokenType) (*Body, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 
 	next := p.Peek()
+	if next.Type == TokenColon && p.typeAnnotations != nil {
+		// finishParsingBodyAttribute deals with the type annotation, and then
+		// continues as for an attribute without one.
+		next.Type = TokenEqual
+	}
 
 	switch next.Type {
 	case TokenEqual:
 		node, attrDiags := p.finishParsingBodyAttribute(ident, true)
 		diags = append(diags, attrDiags...)
-		attr = node.(*Attribute)
+		attr, _ = node.(*Attribute)
+		if attr == nil {
+			return nil, diags
+		}
 	case TokenOQuote, TokenOBrace, TokenIdent:
 		p.recoverAfterBodyItem()
 		return nil, hcl.Diagnostics{
@@ -238,6 +282,33 @@ func (p *parser) parseSingleAttrBody(end TokenType) (*Body, hcl.Diagnostics) {
 }
 
 func (p *parser) finishParsingBodyAttribute(ident Token, singleLine bool) (Node, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	var typeExpr Expression
+	if p.Peek().Type == TokenColon {
+		p.Read() // eat colon token
+		var typeDiags hcl.Diagnostics
+		typeExpr, typeDiags = p.ParseExpression()
+		diags = append(diags, typeDiags...)
+		if !typeDiags.HasErrors() {
+			diags = append(diags, p.typeAnnotations(typeExpr)...)
+		}
+
+		if next := p.Peek(); next.Type != TokenEqual {
+			if !typeDiags.HasErrors() {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Missing equals sign",
+					Detail:   "A type annotation must be followed by an equals sign \"=\" to introduce the argument value.",
+					Subject:  &next.Range,
+					Context:  hcl.RangeBetween(ident.Range, next.Range).Ptr(),
+				})
+			}
+			p.recoverAfterBodyItem()
+			return nil, diags
+		}
+	}
+
 	eqTok := p.Read() // eat equals token
 	if eqTok.Type != TokenEqual {
 		// should never happen if caller behaves
@@ -246,8 +317,9 @@ func (p *parser) finishParsingBodyAttribute(ident Token, singleLine bool) (Node,
 
 	var endRange hcl.Range
 
-	expr, diags := p.ParseExpression()
-	if p.recovery && diags.HasErrors() {
+	expr, exprDiags := p.ParseExpression()
+	diags = append(diags, exprDiags...)
+	if p.recovery && exprDiags.HasErrors() {
 		// recovery within expressions tends to be tricky, so we've probably
 		// landed somewhere weird. We'll try to reset to the start of a body
 		// item so parsing can continue.
@@ -285,8 +357,9 @@ func (p *parser) finishParsingBodyAttribute(ident Token, singleLine bool) (Node,
 	}
 
 	return &Attribute{
-		Name: string(ident.Bytes),
-		Expr: expr,
+		Name:     string(ident.Bytes),
+		Expr:     expr,
+		TypeExpr: typeExpr,
 
 		SrcRange:    hcl.RangeBetween(ident.Range, endRange),
 		NameRange:   ident.Range,
@@ -381,7 +454,12 @@ Token:
 	var bodyDiags hcl.Diagnostics
 	switch p.Peek().Type {
 	case TokenNewline, TokenEOF, TokenCBrace:
-		body, bodyDiags = p.ParseBody(TokenCBrace)
+		if p.lazyBlockBodies {
+			body = p.deferBlockBody()
+		}
+		if body == nil {
+			body, bodyDiags = p.ParseBody(TokenCBrace)
+		}
 	default:
 		// Special one-line, single-attribute block parsing mode.
 		body, bodyDiags = p.parseSingleAttrBody(TokenCBrace)
@@ -475,6 +553,52 @@ Token:
 	}, diags
 }
 
+// deferBlockBody skips over the block body whose opening brace was just
+// read, returning a body that will parse the skipped tokens only when it is
+// first accessed.
+//
+// If the body isn't closed then deferBlockBody returns nil and leaves the
+// peeker where it was, so that the caller can parse the body immediately
+// and report the problem.
+func (p *parser) deferBlockBody() *Body {
+	start := p.NextIndex - 1 // the opening brace
+
+	// recover enables recovery mode, but we're using it only to skip over
+	// balanced braces and so we don't want to suppress any later errors.
+	recovery := p.recovery
+	end := p.recover(TokenCBrace)
+	p.recovery = recovery
+	if end.Type != TokenCBrace {
+		p.NextIndex = start + 1
+		return nil
+	}
+
+	endPos := hcl.Range{
+		Filename: end.Range.Filename,
+		Start:    end.Range.End,
+		End:      end.Range.End,
+	}
+	tokens := make(Tokens, 0, p.NextIndex-start+1)
+	tokens = append(tokens, p.Tokens[start:p.NextIndex]...)
+	tokens = append(tokens, Token{
+		Type:  TokenEOF,
+		Range: endPos,
+	})
+
+	return &Body{
+		SrcRange: hcl.RangeBetween(p.Tokens[start].Range, end.Range),
+		EndRange: endPos,
+
+		deferred: &deferredBody{
+			tokens:               tokens,
+			pedanticConditionals: p.pedanticConditionals,
+			maxNodes:             p.maxNodes,
+			annotationPrefix:     p.annotationPrefix,
+			typeAnnotations:      p.typeAnnotations,
+		},
+	}
+}
+
 func (p *parser) ParseExpression() (Expression, hcl.Diagnostics) {
 	return p.parseTernaryConditional()
 }
@@ -503,7 +627,7 @@ func (p *parser) parseTernaryConditional() (Expression, hcl.Diagnostics) {
 		return condExpr, diags
 	}
 
-	p.Read() // eat question mark
+	p.countNode(p.Read().Range) // eat question mark
 
 	trueExpr, trueDiags := p.ParseExpression()
 	diags = append(diags, trueDiags...)
@@ -531,6 +655,20 @@ func (p *parser) parseTernaryConditional() (Expression, hcl.Diagnostics) {
 		return condExpr, diags
 	}
 
+	// Because the false expression is parsed with ParseExpression, chained
+	// conditionals like a ? b : c ? d : e are right-associative, grouping
+	// as a ? b : (c ? d : e). That's the usual interpretation, but readers
+	// are sometimes unsure so we can optionally suggest parentheses.
+	if nested, ok := falseExpr.(*ConditionalExpr); ok && p.pedanticConditionals {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Nested conditional without parentheses",
+			Detail:   "The false result of this conditional expression is itself a conditional expression. This is interpreted as if the nested conditional expression were enclosed in parentheses, but adding the parentheses explicitly will make the meaning clearer to future readers.",
+			Subject:  nested.Range().Ptr(),
+			Context:  hcl.RangeBetween(startRange, nested.Range()).Ptr(),
+		})
+	}
+
 	return &ConditionalExpr{
 		Condition:   condExpr,
 		TrueResult:  trueExpr,
@@ -593,7 +731,7 @@ func (p *parser) parseBinaryOps(ops []map[TokenType]*Operation) (Expression, hcl
 		}
 
 		operation = newOp
-		p.Read() // eat operator token
+		p.countNode(p.Read().Range) // eat operator token
 		var rhsDiags hcl.Diagnostics
 		rhs, rhsDiags = p.parseBinaryOps(remaining)
 		diags = append(diags, rhsDiags...)
@@ -634,6 +772,7 @@ Traversal:
 		case TokenDot:
 			// Attribute access or splat
 			dot := p.Read()
+			p.countNode(dot.Range)
 			attrTok := p.Peek()
 
 			switch attrTok.Type {
@@ -830,6 +969,7 @@ Traversal:
 			// the key value is something constant.
 
 			open := p.Read()
+			p.countNode(open.Range)
 			switch p.Peek().Type {
 			case TokenStar:
 				// This is a full splat expression, like foo[*], which consumes
@@ -950,6 +1090,7 @@ func makeRelativeTraversal(expr Expression, next hcl.Traverser, rng hcl.Range) E
 
 func (p *parser) parseExpressionTerm() (Expression, hcl.Diagnostics) {
 	start := p.Peek()
+	p.countNode(start.Range)
 
 	switch start.Type {
 	case TokenOParen:
@@ -1056,10 +1197,16 @@ func (p *parser) parseExpressionTerm() (Expression, hcl.Diagnostics) {
 			}, diags
 		}
 
-		return &TemplateExpr{
+		tmpl := &TemplateExpr{
 			Parts:    exprs,
 			SrcRange: hcl.RangeBetween(open.Range, closeRange),
-		}, diags
+		}
+		if open.Type == TokenOHeredoc {
+			if close := p.Tokens[p.NextIndex-1]; close.Type == TokenCHeredoc {
+				tmpl.heredoc = newHeredocInfo(open, close)
+			}
+		}
+		return tmpl, diags
 
 	case TokenMinus:
 		tok := p.Read() // eat minus token
@@ -2066,6 +2213,26 @@ Slices:
 	return string(ret), diags
 }
 
+// countNode records that the parser is about to produce another AST node
+// starting at the given range. If that exceeds the node budget given in
+// p.maxNodes then countNode panics with parseNodeBudgetExceeded, which
+// the public parse functions recover in order to abort parsing.
+func (p *parser) countNode(rng hcl.Range) {
+	if p.maxNodes <= 0 {
+		return
+	}
+	p.nodes++
+	if p.nodes > p.maxNodes {
+		panic(parseNodeBudgetExceeded{Range: rng})
+	}
+}
+
+// parseNodeBudgetExceeded is used as a panic value to abort parsing when
+// the node budget given in ParseOptions.MaxNodes is exceeded.
+type parseNodeBudgetExceeded struct {
+	Range hcl.Range
+}
+
 // setRecovery turns on recovery mode without actually doing any recovery.
 // This can be used when a parser knowingly leaves the peeker in a useless
 // place and wants to suppress errors that might result from that decision.
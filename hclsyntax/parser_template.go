@@ -41,6 +41,9 @@ func (p *parser) parseTemplateInner(end TokenType, flushHeredoc bool) ([]Express
 	if flushHeredoc {
 		flushHeredocTemplateParts(parts) // Trim off leading spaces on lines per the flush heredoc spec
 	}
+	// Strip markers must be applied after the flush heredoc processing,
+	// because stripping newlines would otherwise hide where lines begin.
+	applyTemplateStripMarkers(parts)
 	meldConsecutiveStringLiterals(parts)
 	tp := templateParser{
 		Tokens:   parts.Tokens,
@@ -358,8 +361,8 @@ func (p *templateParser) Read() templateToken {
 }
 
 // parseTemplateParts produces a flat sequence of "template tokens", which are
-// either literal values (with any "trimming" already applied), interpolation
-// sequences, or control flow markers.
+// either literal values (with any "trimming" marked for applyTemplateStripMarkers
+// to apply), interpolation sequences, or control flow markers.
 //
 // A further pass is required on the result to turn it into an AST.
 func (p *parser) parseTemplateParts(end TokenType) (*templateParts, hcl.Diagnostics) {
@@ -390,13 +393,11 @@ Token:
 			str, strDiags := ParseStringLiteralToken(next)
 			diags = append(diags, strDiags...)
 
-			if ltrim {
-				str = strings.TrimLeftFunc(str, unicode.IsSpace)
-			}
-
+			p.countNode(next.Range)
 			parts = append(parts, &templateLiteralToken{
 				Val:      str,
 				SrcRange: next.Range,
+				trimLeft: ltrim,
 			})
 			nextCanTrimPrev = true
 
@@ -407,7 +408,7 @@ Token:
 			if canTrimPrev && len(next.Bytes) == 3 && next.Bytes[2] == '~' && len(parts) > 0 {
 				prevExpr := parts[len(parts)-1]
 				if lexpr, ok := prevExpr.(*templateLiteralToken); ok {
-					lexpr.Val = strings.TrimRightFunc(lexpr.Val, unicode.IsSpace)
+					lexpr.trimRight = true
 				}
 			}
 
@@ -480,7 +481,7 @@ Token:
 			if canTrimPrev && len(next.Bytes) == 3 && next.Bytes[2] == '~' && len(parts) > 0 {
 				prevExpr := parts[len(parts)-1]
 				if lexpr, ok := prevExpr.(*templateLiteralToken); ok {
-					lexpr.Val = strings.TrimRightFunc(lexpr.Val, unicode.IsSpace)
+					lexpr.trimRight = true
 				}
 			}
 			p.PushIncludeNewlines(false)
@@ -755,6 +756,42 @@ func flushHeredocTemplateParts(parts *templateParts) {
 	}
 }
 
+// applyTemplateStripMarkers modifies in-place the literal strings adjacent to
+// strip markers, such as in ${~ and ~}, removing all of the whitespace between
+// the marker and the nearest non-whitespace character or non-literal token.
+//
+// A heredoc template produces a separate literal token for each line, so a
+// literal left empty by stripping passes the stripping on to the next literal
+// in the same direction. That way a marker strips all of the whitespace
+// adjacent to it, rather than only that within the first line.
+func applyTemplateStripMarkers(parts *templateParts) {
+	toks := parts.Tokens
+	for i, ttok := range toks {
+		lit, ok := ttok.(*templateLiteralToken)
+		if !ok || !lit.trimLeft {
+			continue
+		}
+		lit.Val = strings.TrimLeftFunc(lit.Val, unicode.IsSpace)
+		if lit.Val == "" && i+1 < len(toks) {
+			if next, ok := toks[i+1].(*templateLiteralToken); ok {
+				next.trimLeft = true
+			}
+		}
+	}
+	for i := len(toks) - 1; i >= 0; i-- {
+		lit, ok := toks[i].(*templateLiteralToken)
+		if !ok || !lit.trimRight {
+			continue
+		}
+		lit.Val = strings.TrimRightFunc(lit.Val, unicode.IsSpace)
+		if lit.Val == "" && i > 0 {
+			if prev, ok := toks[i-1].(*templateLiteralToken); ok {
+				prev.trimRight = true
+			}
+		}
+	}
+}
+
 // meldConsecutiveStringLiterals simplifies the AST output by combining a
 // sequence of string literal tokens into a single string literal. This must be
 // performed after any whitespace trimming operations.
@@ -802,6 +839,11 @@ type templateToken interface {
 type templateLiteralToken struct {
 	Val      string
 	SrcRange hcl.Range
+
+	// trimLeft and trimRight record that the literal is adjacent to a strip
+	// marker, for applyTemplateStripMarkers.
+	trimLeft, trimRight bool
+
 	isTemplateToken
 }
 
@@ -5,6 +5,8 @@ package hclsyntax
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"sync"
 	"testing"
 
@@ -1507,6 +1509,18 @@ block "valid" {}
 								Start: hcl.Pos{Line: 1, Column: 5, Byte: 4},
 								End:   hcl.Pos{Line: 3, Column: 4, Byte: 19},
 							},
+							heredoc: &HeredocInfo{
+								Marker:  "EOT",
+								Content: "Hello\n",
+								ContentRange: hcl.Range{
+									Start: hcl.Pos{Line: 2, Column: 1, Byte: 10},
+									End:   hcl.Pos{Line: 3, Column: 1, Byte: 16},
+								},
+								Range: hcl.Range{
+									Start: hcl.Pos{Line: 1, Column: 5, Byte: 4},
+									End:   hcl.Pos{Line: 3, Column: 4, Byte: 19},
+								},
+							},
 						},
 
 						SrcRange: hcl.Range{
@@ -2772,6 +2786,7 @@ block "valid" {}
 				cmp.AllowUnexported(
 					Body{},
 					AnonSymbolExpr{},
+					TemplateExpr{},
 					hcl.TraverseRoot{},
 					hcl.TraverseAttr{},
 					hcl.TraverseIndex{},
@@ -2789,6 +2804,495 @@ block "valid" {}
 	}
 }
 
+func TestParseConfig_chainedConditionals(t *testing.T) {
+	// shape renders the structure of a conditional expression tree so that
+	// we can easily see how the operands were grouped.
+	var shape func(expr Expression) string
+	shape = func(expr Expression) string {
+		switch expr := expr.(type) {
+		case *ConditionalExpr:
+			return fmt.Sprintf("cond(%s, %s, %s)", shape(expr.Condition), shape(expr.TrueResult), shape(expr.FalseResult))
+		case *ParenthesesExpr:
+			return fmt.Sprintf("paren(%s)", shape(expr.Expression))
+		case *ScopeTraversalExpr:
+			return expr.Traversal.RootName()
+		default:
+			return fmt.Sprintf("%T", expr)
+		}
+	}
+
+	tests := map[string]struct {
+		input     string
+		want      string
+		pedantic  bool
+		wantWarns int
+	}{
+		"single": {
+			input: `a ? b : c`,
+			want:  `cond(a, b, c)`,
+		},
+		"chained in false result": {
+			input: `a ? b : c ? d : e`,
+			want:  `cond(a, b, cond(c, d, e))`,
+		},
+		"chained three deep": {
+			input: `a ? b : c ? d : e ? f : g`,
+			want:  `cond(a, b, cond(c, d, cond(e, f, g)))`,
+		},
+		"chained in true result": {
+			input: `a ? b ? c : d : e`,
+			want:  `cond(a, cond(b, c, d), e)`,
+		},
+		"parenthesized condition": {
+			input: `(a ? b : c) ? d : e`,
+			want:  `cond(paren(cond(a, b, c)), d, e)`,
+		},
+		"pedantic chained in false result": {
+			input:     `a ? b : c ? d : e`,
+			want:      `cond(a, b, cond(c, d, e))`,
+			pedantic:  true,
+			wantWarns: 1,
+		},
+		"pedantic chained three deep": {
+			input:     `a ? b : c ? d : e ? f : g`,
+			want:      `cond(a, b, cond(c, d, cond(e, f, g)))`,
+			pedantic:  true,
+			wantWarns: 2,
+		},
+		"pedantic parenthesized false result": {
+			input:    `a ? b : (c ? d : e)`,
+			want:     `cond(a, b, paren(cond(c, d, e)))`,
+			pedantic: true,
+		},
+		"pedantic chained in true result": {
+			input:    `a ? b ? c : d : e`,
+			want:     `cond(a, cond(b, c, d), e)`,
+			pedantic: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := "x = " + test.input + "\n"
+			f, diags := ParseConfigWithOptions([]byte(src), "", hcl.InitialPos, ParseOptions{
+				PedanticConditionals: test.pedantic,
+			})
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags)
+			}
+			if len(diags) != test.wantWarns {
+				t.Errorf("wrong number of warnings %d; want %d\n%s", len(diags), test.wantWarns, diags)
+			}
+
+			expr := f.Body.(*Body).Attributes["x"].Expr
+			if got := shape(expr); got != test.want {
+				t.Errorf("wrong shape\ngot:  %s\nwant: %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigWithOptions_maxNodes(t *testing.T) {
+	nested := strings.Repeat("[", 50) + strings.Repeat("]", 50)
+
+	tests := map[string]struct {
+		src      string
+		maxNodes int
+		wantErr  bool
+		wantAttr bool
+	}{
+		"unlimited": {
+			src:      "a = " + nested + "\n",
+			maxNodes: 0,
+			wantAttr: true,
+		},
+		"within budget": {
+			src:      "a = 1\nb { c = d.e + 2 }\n",
+			maxNodes: 8,
+			wantAttr: true,
+		},
+		"deeply nested": {
+			src:      "a = " + nested + "\n",
+			maxNodes: 20,
+			wantErr:  true,
+		},
+		"many body items": {
+			src:      strings.Repeat("b {}\n", 10) + "a = 1\n",
+			maxNodes: 5,
+			wantErr:  true,
+		},
+		"just over budget": {
+			src:      "a = 1\nb { c = d.e + 2 }\n",
+			maxNodes: 7,
+			wantErr:  true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfigWithOptions([]byte(test.src), "test.hcl", hcl.InitialPos, ParseOptions{
+				MaxNodes: test.maxNodes,
+			})
+			if got := diags.HasErrors(); got != test.wantErr {
+				t.Fatalf("wrong error result %t; want %t\n%s", got, test.wantErr, diags)
+			}
+			if test.wantErr {
+				if got, want := diags[0].Summary, "Configuration too complex"; got != want {
+					t.Errorf("wrong summary %q; want %q", got, want)
+				}
+			}
+
+			_, gotAttr := f.Body.(*Body).Attributes["a"]
+			if gotAttr != test.wantAttr {
+				t.Errorf("wrong attribute presence %t; want %t", gotAttr, test.wantAttr)
+			}
+		})
+	}
+}
+
+func TestParseConfigWithOptions_lazyBlockBodies(t *testing.T) {
+	src := `a = 1
+
+service "web" {
+  port = 8080 # comment
+  tags = { env = "prod", nested = { deep = true } }
+  template = "${var.x}%{ if true }y%{ endif }"
+
+  listener {
+    protocol = <<EOT
+http {
+EOT
+  }
+}
+
+broken {
+  b = = 2
+}
+
+single { c = 3 }
+`
+
+	eager, diags := ParseConfig([]byte(src), "test.hcl", hcl.InitialPos)
+	if got, want := len(diags), 1; got != want {
+		t.Fatalf("got %d eager diagnostics; want %d\n%s", got, want, diags)
+	}
+	lazy, diags := ParseConfigWithOptions([]byte(src), "test.hcl", hcl.InitialPos, ParseOptions{
+		LazyBlockBodies: true,
+	})
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics from lazy parse\n%s", diags)
+	}
+
+	eagerBody := eager.Body.(*Body)
+	lazyBody := lazy.Body.(*Body)
+	if got, want := len(lazyBody.Blocks), 3; got != want {
+		t.Fatalf("got %d top-level blocks; want %d", got, want)
+	}
+	service := lazyBody.Blocks[0].Body
+	if service.Attributes != nil || service.Blocks != nil {
+		t.Fatalf("service body was parsed eagerly")
+	}
+	if single := lazyBody.Blocks[2].Body; single.Attributes == nil {
+		t.Errorf("single-line block body was deferred")
+	}
+	for i, block := range lazyBody.Blocks {
+		if got, want := block.Body.SrcRange, eagerBody.Blocks[i].Body.SrcRange; got != want {
+			t.Errorf("wrong range for block %d body\ngot:  %#v\nwant: %#v", i, got, want)
+		}
+		if got, want := block.Body.EndRange, eagerBody.Blocks[i].Body.EndRange; got != want {
+			t.Errorf("wrong end range for block %d body\ngot:  %#v\nwant: %#v", i, got, want)
+		}
+	}
+
+	content, diags := service.Content(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "port", Required: true},
+			{Name: "tags"},
+			{Name: "template"},
+		},
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "listener"},
+		},
+	})
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics from Content\n%s", diags)
+	}
+	port, _ := content.Attributes["port"].Expr.Value(nil)
+	if !port.RawEquals(cty.NumberIntVal(8080)) {
+		t.Errorf("wrong port %#v", port)
+	}
+	listener := content.Blocks[0].Body.(*Body)
+	if listener.deferred == nil {
+		t.Errorf("nested block body was parsed eagerly")
+	}
+	attrs, diags := listener.JustAttributes()
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics from JustAttributes\n%s", diags)
+	}
+	protocol, _ := attrs["protocol"].Expr.Value(nil)
+	if !protocol.RawEquals(cty.StringVal("http {\n")) {
+		t.Errorf("wrong protocol %#v", protocol)
+	}
+
+	// Syntax errors in a deferred body are reported each time it's accessed.
+	broken := lazyBody.Blocks[1].Body
+	for i := 0; i < 2; i++ {
+		_, diags = broken.JustAttributes()
+		if got, want := len(diags), 1; got != want {
+			t.Fatalf("got %d diagnostics from broken body; want %d\n%s", got, want, diags)
+		}
+		if got, want := diags[0].Subject.Start.Line, 16; got != want {
+			t.Errorf("diagnostic on line %d; want %d", got, want)
+		}
+	}
+
+	// Walking the lazily-parsed tree visits the same nodes as the eager one.
+	ranges := func(body *Body) []hcl.Range {
+		var ret []hcl.Range
+		VisitAll(body, func(node Node) hcl.Diagnostics {
+			ret = append(ret, node.Range())
+			return nil
+		})
+		return ret
+	}
+	lazy, _ = ParseConfigWithOptions([]byte(src), "test.hcl", hcl.InitialPos, ParseOptions{
+		LazyBlockBodies: true,
+	})
+	gotRanges := ranges(lazy.Body.(*Body))
+	wantRanges := ranges(eagerBody)
+	if len(gotRanges) != len(wantRanges) {
+		t.Fatalf("visited %d nodes; want %d", len(gotRanges), len(wantRanges))
+	}
+}
+
+func TestParseConfigWithOptions_lazyUnclosedBlock(t *testing.T) {
+	_, diags := ParseConfigWithOptions([]byte("a {\n  b = 1\n"), "test.hcl", hcl.InitialPos, ParseOptions{
+		LazyBlockBodies: true,
+	})
+	if got, want := len(diags), 1; got != want {
+		t.Fatalf("got %d diagnostics; want %d\n%s", got, want, diags)
+	}
+	if got, want := diags[0].Summary, "Unclosed configuration block"; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+}
+
+func TestParseConfigWithOptions_lazyBodyValidators(t *testing.T) {
+	src := `outer {
+  a = 1
+  b = 2
+  solo {
+  }
+  solo {
+  }
+  named "Bad_Label" {
+  }
+  dup = 1
+  dup {
+  }
+}
+`
+
+	tests := map[string]struct {
+		validate func(body *Body) hcl.Diagnostics
+		want     int
+	}{
+		"ValidateUniqueBlocks": {
+			func(body *Body) hcl.Diagnostics { return ValidateUniqueBlocks(body, "solo") },
+			1,
+		},
+		"ValidateBlockLabels": {
+			func(body *Body) hcl.Diagnostics {
+				return ValidateBlockLabels(body, "named", regexp.MustCompile(`^[a-z-]+$`))
+			},
+			1,
+		},
+		"DetectAttributeBlockConflicts": {
+			DetectAttributeBlockConflicts,
+			1,
+		},
+		"ValidateExactlyOneOf": {
+			func(body *Body) hcl.Diagnostics { return ValidateExactlyOneOf(body, "a", "b") },
+			1,
+		},
+		"ValidateAtMostOneOf": {
+			func(body *Body) hcl.Diagnostics { return ValidateAtMostOneOf(body, "a", "b") },
+			1,
+		},
+		"ValidateAtLeastOneOf": {
+			func(body *Body) hcl.Diagnostics { return ValidateAtLeastOneOf(body, "a") },
+			0,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, diags := ParseConfigWithOptions([]byte(src), "test.hcl", hcl.InitialPos, ParseOptions{
+				LazyBlockBodies: true,
+			})
+			if len(diags) != 0 {
+				t.Fatalf("unexpected diagnostics from parse\n%s", diags)
+			}
+			body := file.Body.(*Body).Blocks[0].Body
+			if body.Attributes != nil || body.Blocks != nil {
+				t.Fatalf("outer body was parsed eagerly")
+			}
+
+			diags = test.validate(body)
+			if got := len(diags); got != test.want {
+				t.Errorf("got %d diagnostics; want %d\n%s", got, test.want, diags)
+			}
+		})
+	}
+}
+
+func TestParseConfigWithOptions_typeAnnotations(t *testing.T) {
+	src := `tags: map(string) = {}
+name = "a"
+service {
+  port: number = 80
+}
+single { enabled: bool = true }
+`
+
+	t.Run("disabled", func(t *testing.T) {
+		_, diags := ParseConfig([]byte(src), "test.hcl", hcl.InitialPos)
+		if !diags.HasErrors() {
+			t.Fatalf("unexpected success")
+		}
+	})
+
+	for _, lazy := range []bool{false, true} {
+		t.Run(fmt.Sprintf("lazy=%t", lazy), func(t *testing.T) {
+			var validated []string
+			file, diags := ParseConfigWithOptions([]byte(src), "test.hcl", hcl.InitialPos, ParseOptions{
+				LazyBlockBodies: lazy,
+				TypeAnnotations: func(expr hcl.Expression) hcl.Diagnostics {
+					validated = append(validated, string(expr.Range().SliceBytes([]byte(src))))
+					if hcl.ExprAsKeyword(expr) == "bool" {
+						return hcl.Diagnostics{
+							{
+								Severity: hcl.DiagError,
+								Summary:  "Bool not allowed",
+								Subject:  expr.Range().Ptr(),
+							},
+						}
+					}
+					return nil
+				},
+			})
+			if len(diags) != 1 || diags[0].Summary != "Bool not allowed" {
+				t.Fatalf("wrong diagnostics\n%s", diags)
+			}
+			body := file.Body.(*Body)
+
+			if _, ok := body.Attributes["tags"].Expr.(*ObjectConsExpr); !ok {
+				t.Errorf("wrong value expression for tags: %#v", body.Attributes["tags"].Expr)
+			}
+			if got := body.Attributes["tags"].TypeExpr; got == nil || got.Range().Start.Column != 7 {
+				t.Errorf("wrong type expression for tags: %#v", got)
+			}
+			if got := body.Attributes["name"].TypeExpr; got != nil {
+				t.Errorf("unexpected type expression for name: %#v", got)
+			}
+
+			content, diags := body.Blocks[0].Body.JustAttributes()
+			if len(diags) != 0 {
+				t.Fatalf("unexpected diagnostics from nested body\n%s", diags)
+			}
+			if got, want := hcl.ExprAsKeyword(body.Blocks[0].Body.Attributes["port"].TypeExpr), "number"; got != want {
+				t.Errorf("wrong type annotation for port %q; want %q", got, want)
+			}
+			if _, ok := content["port"]; !ok {
+				t.Errorf("port attribute is missing")
+			}
+			if got, want := hcl.ExprAsKeyword(body.Blocks[1].Body.Attributes["enabled"].TypeExpr), "bool"; got != want {
+				t.Errorf("wrong type annotation for enabled %q; want %q", got, want)
+			}
+
+			want := []string{"map(string)", "number", "bool"}
+			if lazy {
+				// The deferred body is parsed only when it's first accessed.
+				want = []string{"map(string)", "bool", "number"}
+			}
+			if !cmp.Equal(validated, want) {
+				t.Errorf("wrong validated annotations\ngot:  %#v\nwant: %#v", validated, want)
+			}
+		})
+	}
+}
+
+func TestParseConfigWithOptions_annotationPrefix(t *testing.T) {
+	src := `# @deprecated use "name" instead
+# an ordinary comment
+// @since 1.2
+title = "a"
+name = "b" # @not-an-annotation
+
+/* @internal */
+service "web" {
+  # @secret
+  token = "c"
+}
+`
+
+	for _, lazy := range []bool{false, true} {
+		t.Run(fmt.Sprintf("lazy=%t", lazy), func(t *testing.T) {
+			file, diags := ParseConfigWithOptions([]byte(src), "test.hcl", hcl.InitialPos, ParseOptions{
+				AnnotationPrefix: "@",
+				LazyBlockBodies:  lazy,
+			})
+			if len(diags) != 0 {
+				t.Fatalf("unexpected diagnostics\n%s", diags)
+			}
+			body := file.Body.(*Body)
+
+			texts := func(annots []Annotation) []string {
+				var ret []string
+				for _, annot := range annots {
+					ret = append(ret, annot.Text)
+				}
+				return ret
+			}
+
+			if got, want := texts(body.Attributes["title"].Annotations), []string{`deprecated use "name" instead`, "since 1.2"}; !cmp.Equal(got, want) {
+				t.Errorf("wrong annotations for title\ngot:  %#v\nwant: %#v", got, want)
+			}
+			if got := body.Attributes["name"].Annotations; got != nil {
+				t.Errorf("unexpected annotations for name: %#v", got)
+			}
+			block := body.Blocks[0]
+			if got, want := texts(block.Annotations), []string{"internal"}; !cmp.Equal(got, want) {
+				t.Errorf("wrong annotations for block\ngot:  %#v\nwant: %#v", got, want)
+			}
+			if got, want := block.Annotations[0].Range.Start.Line, 7; got != want {
+				t.Errorf("wrong annotation line %d; want %d", got, want)
+			}
+
+			attrs, diags := block.Body.JustAttributes()
+			if len(diags) != 0 {
+				t.Fatalf("unexpected diagnostics\n%s", diags)
+			}
+			if attrs["token"] == nil {
+				t.Fatalf("missing token attribute")
+			}
+			if got, want := texts(block.Body.Attributes["token"].Annotations), []string{"secret"}; !cmp.Equal(got, want) {
+				t.Errorf("wrong annotations for token\ngot:  %#v\nwant: %#v", got, want)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		file, diags := ParseConfig([]byte(src), "test.hcl", hcl.InitialPos)
+		if len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics\n%s", diags)
+		}
+		if got := file.Body.(*Body).Attributes["title"].Annotations; got != nil {
+			t.Errorf("unexpected annotations: %#v", got)
+		}
+	})
+}
+
 func TestParseConfig_incompleteFunctionCall(t *testing.T) {
 	tests := []struct {
 		input string
@@ -3822,6 +4326,7 @@ another_block {
 	opts := cmp.Options{
 		cmpopts.IgnoreUnexported(FunctionCallExpr{}),
 		cmpopts.IgnoreUnexported(Body{}),
+		cmpopts.IgnoreUnexported(TemplateExpr{}),
 		cmpopts.IgnoreUnexported(cty.Value{}),
 	}
 	for i, test := range tests {
@@ -4,6 +4,10 @@
 package hclsyntax
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+
 	"github.com/hashicorp/hcl/v2"
 )
 
@@ -18,17 +22,115 @@ import (
 // should be served using the hcl.Body interface to ensure compatibility with
 // other configurationg syntaxes, such as JSON.
 func ParseConfig(src []byte, filename string, start hcl.Pos) (*hcl.File, hcl.Diagnostics) {
-	tokens, diags := LexConfig(src, filename, start)
+	return ParseConfigWithOptions(src, filename, start, ParseOptions{})
+}
+
+// ParseOptions contains optional settings that modify the behavior of
+// ParseConfigWithOptions. The zero value of ParseOptions selects the same
+// behavior as ParseConfig.
+type ParseOptions struct {
+	// NormalizeLineEndings causes any CRLF sequences in the source to be
+	// treated as a single LF character, so that multi-line constructs such
+	// as heredoc templates produce the same values regardless of the line
+	// ending convention used when the file was authored.
+	//
+	// The source ranges in the result still refer to the original bytes
+	// given, so that diagnostics and editor integrations point at the
+	// correct locations.
+	NormalizeLineEndings bool
+
+	// PedanticConditionals causes the parser to produce warnings for
+	// conditional expressions whose false result is another conditional
+	// expression that isn't enclosed in parentheses, such as
+	// a ? b : c ? d : e. Such chains are always right-associative, but
+	// some readers find them confusing without explicit parentheses.
+	PedanticConditionals bool
+
+	// MaxNodes, if greater than zero, limits the number of expression and
+	// block nodes the parser may produce. If the source would produce more
+	// than that then parsing stops early with an error diagnostic and the
+	// returned file has an empty body.
+	//
+	// This is intended to protect applications that parse untrusted input
+	// from sources that are small in bytes but expand into very large
+	// syntax trees, such as deeply-nested structures.
+	MaxNodes int
+
+	// LazyBlockBodies causes the parser to defer parsing the bodies of
+	// multi-line blocks until they are first needed, which can save work
+	// for large configurations where only a few blocks are inspected. A
+	// deferred body is parsed when it is first accessed through its
+	// Content, PartialContent, or JustAttributes methods, or any other
+	// method of Body, or when it is walked with Walk or VisitAll.
+	//
+	// Until then the Attributes and Blocks fields of the deferred body are
+	// nil, so callers that access those fields directly must first call one
+	// of those methods. Diagnostics for syntax errors in a deferred body are
+	// not returned by ParseConfigWithOptions, but are instead included in
+	// the result of each method call that accesses the body, and so a
+	// caller must check the diagnostics of every such call. A limit set in
+	// MaxNodes applies separately to each deferred body.
+	LazyBlockBodies bool
+
+	// AnnotationPrefix, if non-empty, causes the parser to extract comments
+	// whose text begins with this prefix, such as "@" for # @deprecated, as
+	// annotations on the attribute or block that follows them. This allows
+	// tools to read metadata directives embedded in comments. The
+	// annotations are available in the Annotations fields of the resulting
+	// Attribute and Block nodes.
+	//
+	// All such comments between an item and the previous item in the same
+	// body are attached to it. Comments at the end of the line after an
+	// attribute or block belong to that item and so are not annotations,
+	// and nor are comments inside single-line blocks. Comments that don't
+	// begin with the prefix are treated as normal.
+	AnnotationPrefix string
+
+	// TypeAnnotations, if set, allows each attribute to have a type
+	// annotation between its name and its equals sign, as in
+	// tags: map(string) = {}, which is stored as the TypeExpr of the
+	// resulting Attribute node. The given function is called to validate
+	// each annotation, and any diagnostics it returns are included in the
+	// result. Applications typically use typeexpr.ValidateTypeConstraint,
+	// and then use the annotation with the typeexpr package when decoding
+	// to convert the attribute's value to the declared type.
+	//
+	// Type annotations are a syntax error if this is not set.
+	TypeAnnotations func(expr hcl.Expression) hcl.Diagnostics
+}
+
+// ParseConfigWithOptions is a variant of ParseConfig which accepts additional
+// options to modify the parser's behavior.
+func ParseConfigWithOptions(src []byte, filename string, start hcl.Pos, opts ParseOptions) (*hcl.File, hcl.Diagnostics) {
+	var tokens Tokens
+	var diags hcl.Diagnostics
+	if opts.NormalizeLineEndings {
+		norm, origOffset := normalizeLineEndings(src)
+		tokens = scanTokens(norm, filename, start, scanNormal)
+		// The token offsets include start.Byte, but the mapping works with
+		// offsets relative to the start of the buffer.
+		for i := range tokens {
+			rng := &tokens[i].Range
+			rng.Start.Byte = start.Byte + origOffset(rng.Start.Byte-start.Byte)
+			rng.End.Byte = start.Byte + origOffset(rng.End.Byte-start.Byte)
+		}
+		diags = checkInvalidTokens(tokens)
+	} else {
+		tokens, diags = LexConfig(src, filename, start)
+	}
+
 	peeker := newPeeker(tokens, false)
-	parser := &parser{peeker: peeker}
-	body, parseDiags := parser.ParseBody(TokenEOF)
+	parser := &parser{
+		peeker:               peeker,
+		pedanticConditionals: opts.PedanticConditionals,
+		maxNodes:             opts.MaxNodes,
+		lazyBlockBodies:      opts.LazyBlockBodies,
+		annotationPrefix:     opts.AnnotationPrefix,
+		typeAnnotations:      opts.TypeAnnotations,
+	}
+	body, parseDiags := parseBodyWithBudget(parser, tokens, filename, TokenEOF)
 	diags = append(diags, parseDiags...)
 
-	// Panic if the parser uses incorrect stack discipline with the peeker's
-	// newlines stack, since otherwise it will produce confusing downstream
-	// errors.
-	peeker.AssertEmptyIncludeNewlinesStack()
-
 	return &hcl.File{
 		Body:  body,
 		Bytes: src,
@@ -39,6 +141,91 @@ func ParseConfig(src []byte, filename string, start hcl.Pos) (*hcl.File, hcl.Dia
 	}, diags
 }
 
+// parseBodyWithBudget parses a body ending with the given token using the
+// given parser, returning an empty body and an error diagnostic if the
+// parser's node budget is exceeded part way through.
+func parseBodyWithBudget(parser *parser, tokens Tokens, filename string, end TokenType) (body *Body, diags hcl.Diagnostics) {
+	defer func() {
+		if r := recover(); r != nil {
+			exceeded, ok := r.(parseNodeBudgetExceeded)
+			if !ok {
+				panic(r)
+			}
+			rng := hcl.RangeBetween(tokens[0].Range, tokens[len(tokens)-1].Range)
+			body = &Body{
+				Attributes: Attributes{},
+				Blocks:     Blocks{},
+				SrcRange:   rng,
+				EndRange:   tokens[len(tokens)-1].Range,
+			}
+			diags = hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  "Configuration too complex",
+					Detail:   fmt.Sprintf("The configuration in %s has more than the maximum of %d expressions and blocks, so parsing was stopped here.", filename, parser.maxNodes),
+					Subject:  exceeded.Range.Ptr(),
+				},
+			}
+		}
+	}()
+
+	body, diags = parser.ParseBody(end)
+
+	// Panic if the parser uses incorrect stack discipline with the peeker's
+	// newlines stack, since otherwise it will produce confusing downstream
+	// errors.
+	parser.AssertEmptyIncludeNewlinesStack()
+
+	return body, diags
+}
+
+// ParseMultiDocument parses the given buffer as a sequence of whole HCL config
+// files separated by the given marker, such as "---", returning one *hcl.File
+// per document in the order they appear.
+//
+// The separator is recognized only when it appears alone on its own line,
+// optionally followed by a carriage return. It is not recognized inside
+// heredocs or multi-line comments because no tokenization is done before
+// splitting, so callers should choose a separator that cannot appear
+// alone on a line within a document.
+//
+// Source ranges in the returned files and diagnostics are relative to the
+// start of the whole given buffer, so they point at the correct lines in the
+// original stream. For that reason, the Bytes field of each returned file
+// is the entire given buffer rather than just the bytes of that document,
+// which allows diagnostic renderers to find the correct source snippets.
+func ParseMultiDocument(src []byte, filename string, sep []byte) ([]*hcl.File, hcl.Diagnostics) {
+	var files []*hcl.File
+	var diags hcl.Diagnostics
+
+	parseDoc := func(start hcl.Pos, end int) {
+		file, docDiags := ParseConfig(src[start.Byte:end], filename, start)
+		file.Bytes = src
+		files = append(files, file)
+		diags = append(diags, docDiags...)
+	}
+
+	start := hcl.Pos{Line: 1, Column: 1, Byte: 0}
+	line := 1
+	for offset := 0; offset < len(src); line++ {
+		next := len(src)
+		lineEnd := len(src)
+		if nl := bytes.IndexByte(src[offset:], '\n'); nl >= 0 {
+			lineEnd = offset + nl
+			next = lineEnd + 1
+		}
+
+		if bytes.Equal(bytes.TrimSuffix(src[offset:lineEnd], []byte{'\r'}), sep) {
+			parseDoc(start, offset)
+			start = hcl.Pos{Line: line + 1, Column: 1, Byte: next}
+		}
+		offset = next
+	}
+	parseDoc(start, len(src))
+
+	return files, diags
+}
+
 // ParseExpression parses the given buffer as a standalone HCL expression,
 // returning it as an instance of Expression.
 func ParseExpression(src []byte, filename string, start hcl.Pos) (Expression, hcl.Diagnostics) {
@@ -156,12 +343,86 @@ func ParseTraversalPartial(src []byte, filename string, start hcl.Pos) (hcl.Trav
 // diagnostics may include errors about lexical issues such as bad character
 // encodings or unrecognized characters, but full parsing is required to
 // detect _all_ syntax errors.
+//
+// Lexical analysis continues past any invalid characters, which are returned
+// as TokenInvalid or TokenBadUTF8 tokens covering only the offending bytes,
+// so the result still includes tokens for the remainder of the input. This
+// makes the result suitable for tasks like syntax highlighting of files that
+// are only partially valid.
 func LexConfig(src []byte, filename string, start hcl.Pos) (Tokens, hcl.Diagnostics) {
 	tokens := scanTokens(src, filename, start, scanNormal)
 	diags := checkInvalidTokens(tokens)
 	return tokens, diags
 }
 
+// StreamTokens performs lexical analysis on the given buffer in the same way
+// as LexConfig, but sends each token on the returned token channel as soon
+// as it is scanned, rather than collecting all of the tokens into a slice.
+// This allows processing very large inputs without retaining all of their
+// tokens in memory at once.
+//
+// Tokens are sent in the same order that LexConfig would return them, ending
+// with a TokenEOF token. The token channel is closed once all tokens have
+// been sent, and only then is a single set of diagnostics sent on the
+// diagnostics channel, which is then also closed. The caller must therefore
+// keep receiving from the token channel until it is closed before waiting for
+// the diagnostics, unless it cancels the given context.
+//
+// If the given context is cancelled before all tokens have been sent then
+// scanning stops early, the token channel is closed without a TokenEOF token,
+// and the diagnostics include an error describing the cancellation.
+func StreamTokens(ctx context.Context, src []byte, filename string) (<-chan Token, <-chan hcl.Diagnostics) {
+	tokensCh := make(chan Token)
+	diagsCh := make(chan hcl.Diagnostics, 1)
+
+	go func() {
+		defer close(diagsCh)
+
+		var diags hcl.Diagnostics
+		var checker invalidTokenChecker
+		f := &tokenAccum{
+			Emit: func(tok Token) {
+				diags = append(diags, checker.check(tok)...)
+				if ctx.Err() != nil {
+					// Unwind out of the scanner, which has no other way
+					// to stop early.
+					panic(streamTokensCancelled{})
+				}
+				select {
+				case tokensCh <- tok:
+				case <-ctx.Done():
+					panic(streamTokensCancelled{})
+				}
+			},
+		}
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					if _, ok := r.(streamTokensCancelled); !ok {
+						panic(r)
+					}
+					diags = append(diags, &hcl.Diagnostic{
+						Severity: hcl.DiagError,
+						Summary:  "Tokenization cancelled",
+						Detail:   fmt.Sprintf("Lexical analysis of %s was stopped early: %s.", filename, ctx.Err()),
+					})
+				}
+			}()
+			scanTokensAccum(f, src, filename, hcl.InitialPos, scanNormal)
+		}()
+
+		close(tokensCh)
+		diagsCh <- diags
+	}()
+
+	return tokensCh, diagsCh
+}
+
+// streamTokensCancelled is used as a panic value to abort scanning in
+// StreamTokens when its context is cancelled.
+type streamTokensCancelled struct{}
+
 // LexExpression performs lexical analysis on the given buffer, treating it as
 // a standalone HCL expression, and returns the resulting tokens.
 //
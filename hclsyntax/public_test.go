@@ -4,8 +4,10 @@
 package hclsyntax
 
 import (
+	"context"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/hashicorp/hcl/v2"
 )
 
@@ -50,6 +52,96 @@ func TestValidIdentifier(t *testing.T) {
 	}
 }
 
+func TestParseMultiDocument(t *testing.T) {
+	src := []byte("a = 1\n---\nb = 2\nc = 3\r\n---\r\n# not --- a separator\n  ---\nd = \n")
+
+	files, diags := ParseMultiDocument(src, "bundle.hcl", []byte("---"))
+	if len(files) != 3 {
+		t.Fatalf("wrong number of documents %d; want 3", len(files))
+	}
+
+	wantAttrs := [][]string{{"a"}, {"b", "c"}, {}}
+	for i, file := range files {
+		attrs := file.Body.(*Body).Attributes
+		if len(attrs) != len(wantAttrs[i]) {
+			t.Errorf("document %d has %d attributes; want %d", i, len(attrs), len(wantAttrs[i]))
+		}
+		for _, name := range wantAttrs[i] {
+			if _, ok := attrs[name]; !ok {
+				t.Errorf("document %d is missing attribute %q", i, name)
+			}
+		}
+	}
+
+	b := files[1].Body.(*Body).Attributes["b"]
+	if got, want := b.SrcRange.Start, (hcl.Pos{Line: 3, Column: 1, Byte: 10}); got != want {
+		t.Errorf("wrong start position for b\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	// The indented marker in the final document is not a separator, and so
+	// it is a syntax error which must be reported relative to the whole
+	// stream.
+	if !diags.HasErrors() {
+		t.Fatalf("unexpected success; want syntax error")
+	}
+	if got, want := diags[0].Subject.Start.Line, 7; got != want {
+		t.Errorf("wrong diagnostic line %d; want %d", got, want)
+	}
+}
+
+func TestStreamTokens(t *testing.T) {
+	src := []byte("a = 1\nb = \"${c}\" ; \n")
+
+	wantTokens, wantDiags := LexConfig(src, "test.hcl", hcl.InitialPos)
+
+	tokensCh, diagsCh := StreamTokens(context.Background(), src, "test.hcl")
+	var gotTokens Tokens
+	for tok := range tokensCh {
+		gotTokens = append(gotTokens, tok)
+	}
+	gotDiags := <-diagsCh
+
+	if !cmp.Equal(wantTokens, gotTokens) {
+		t.Errorf("wrong tokens\n%s", cmp.Diff(wantTokens, gotTokens))
+	}
+	if !cmp.Equal(wantDiags, gotDiags) {
+		t.Errorf("wrong diagnostics\n%s", cmp.Diff(wantDiags, gotDiags))
+	}
+}
+
+func TestStreamTokens_cancel(t *testing.T) {
+	src := []byte("a = 1\nb = 2\nc = 3\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tokensCh, diagsCh := StreamTokens(ctx, src, "test.hcl")
+
+	first := <-tokensCh
+	if first.Type != TokenIdent {
+		t.Errorf("wrong first token type %s; want %s", first.Type, TokenIdent)
+	}
+	cancel()
+
+	count := 1
+	for tok := range tokensCh {
+		if tok.Type == TokenEOF {
+			t.Errorf("received EOF token after cancellation")
+		}
+		count++
+	}
+	if count > 2 {
+		// At most one more token can be in flight when we cancel.
+		t.Errorf("received %d tokens; want at most 2", count)
+	}
+
+	diags := <-diagsCh
+	if !diags.HasErrors() {
+		t.Fatalf("no error diagnostics after cancellation")
+	}
+	if got, want := diags[len(diags)-1].Summary, "Tokenization cancelled"; got != want {
+		t.Errorf("wrong diagnostic summary %q; want %q", got, want)
+	}
+}
+
 var T Tokens
 
 func BenchmarkLexConfig(b *testing.B) {
@@ -65,3 +157,23 @@ func BenchmarkLexConfig(b *testing.B) {
 
 	T = tokens
 }
+
+func TestLexConfig_offsetPositions(t *testing.T) {
+	// The positions in the tokens produced by the scanner should agree
+	// exactly with hcl.OffsetToPos and hcl.PosToOffset.
+	src := []byte("a = \"éé\" # comment\r\nb = <<EOT\n  x\ry\nEOT\n/* multi\nline */ c = [1,\n  2]\n")
+	// The lone CR in the heredoc is reported as an invalid character, but
+	// the scanner still produces tokens for it.
+	tokens, _ := LexConfig(src, "", hcl.InitialPos)
+
+	for _, tok := range tokens {
+		for _, pos := range []hcl.Pos{tok.Range.Start, tok.Range.End} {
+			if got := hcl.OffsetToPos(src, pos.Byte); got != pos {
+				t.Errorf("wrong OffsetToPos(%d) for %s\ngot:  %#v\nwant: %#v", pos.Byte, tok.Type, got, pos)
+			}
+			if got := hcl.PosToOffset(src, pos); got != pos.Byte {
+				t.Errorf("wrong PosToOffset(%#v) for %s: got %d, want %d", pos, tok.Type, got, pos.Byte)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"github.com/hashicorp/hcl/v2"
+)
+
+// RewriteTraversals returns a copy of the given expression tree with each
+// of the absolute traversals that refer to variables in the evaluation scope
+// replaced by the result of calling the given function with that traversal.
+// The function may return the traversal it was given to leave it unchanged.
+//
+// The traversals visited are the same ones that Variables would return,
+// including those in template interpolations, function call arguments, and
+// the collection, key, value, and condition of for expressions. References
+// to the temporary symbols declared by an enclosing for expression are not
+// passed to the function, because they don't refer to the scope. Likewise,
+// naked identifiers used as keys in object constructors are left unchanged
+// because they are interpreted as literal strings.
+//
+// The given expression is not modified. The source ranges in the result are
+// the same as in the original, including the SrcRange of each rewritten
+// ScopeTraversalExpr, so that diagnostics still refer to the original source.
+func RewriteTraversals(expr Expression, fn func(hcl.Traversal) hcl.Traversal) Expression {
+	r := &traversalRewriter{
+		fn:     fn,
+		locals: make(map[string]int),
+		anons:  make(map[*AnonSymbolExpr]*AnonSymbolExpr),
+	}
+	return r.rewrite(expr)
+}
+
+type traversalRewriter struct {
+	fn func(hcl.Traversal) hcl.Traversal
+
+	// locals counts the enclosing for expressions that declare each
+	// temporary symbol name, so that references to them can be skipped.
+	locals map[string]int
+
+	// anons maps the AnonSymbolExpr of each enclosing splat expression to
+	// its replacement, since the copied Each expression must refer to the
+	// copied Item.
+	anons map[*AnonSymbolExpr]*AnonSymbolExpr
+
+	// replace, if set, is called for each expression before it is copied.
+	// If it returns true then its result is used in place of the whole
+	// subtree rooted at that expression.
+	replace func(Expression) (Expression, bool)
+}
+
+func (r *traversalRewriter) rewrite(expr Expression) Expression {
+	if r.replace != nil && expr != nil {
+		if ret, ok := r.replace(expr); ok {
+			return ret
+		}
+	}
+
+	switch e := expr.(type) {
+	case nil:
+		return nil
+
+	case *ScopeTraversalExpr:
+		ret := *e
+		ret.Traversal = append(hcl.Traversal(nil), e.Traversal...)
+		if r.locals[e.Traversal.RootName()] == 0 {
+			ret.Traversal = r.fn(ret.Traversal)
+		}
+		return &ret
+
+	case *RelativeTraversalExpr:
+		ret := *e
+		ret.Source = r.rewrite(e.Source)
+		return &ret
+
+	case *FunctionCallExpr:
+		ret := *e
+		ret.Args = r.rewriteAll(e.Args)
+		return &ret
+
+	case *ConditionalExpr:
+		ret := *e
+		ret.Condition = r.rewrite(e.Condition)
+		ret.TrueResult = r.rewrite(e.TrueResult)
+		ret.FalseResult = r.rewrite(e.FalseResult)
+		return &ret
+
+	case *IndexExpr:
+		ret := *e
+		ret.Collection = r.rewrite(e.Collection)
+		ret.Key = r.rewrite(e.Key)
+		return &ret
+
+	case *TupleConsExpr:
+		ret := *e
+		ret.Exprs = r.rewriteAll(e.Exprs)
+		return &ret
+
+	case *ObjectConsExpr:
+		ret := *e
+		ret.Items = make([]ObjectConsItem, len(e.Items))
+		for i, item := range e.Items {
+			ret.Items[i] = ObjectConsItem{
+				KeyExpr:   r.rewrite(item.KeyExpr),
+				ValueExpr: r.rewrite(item.ValueExpr),
+			}
+		}
+		return &ret
+
+	case *ObjectConsKeyExpr:
+		ret := *e
+		if e.literalName() == "" {
+			ret.Wrapped = r.rewrite(e.Wrapped)
+		}
+		return &ret
+
+	case *ForExpr:
+		ret := *e
+		ret.CollExpr = r.rewrite(e.CollExpr)
+		if e.KeyVar != "" {
+			r.locals[e.KeyVar]++
+		}
+		r.locals[e.ValVar]++
+		ret.KeyExpr = r.rewrite(e.KeyExpr)
+		ret.ValExpr = r.rewrite(e.ValExpr)
+		ret.CondExpr = r.rewrite(e.CondExpr)
+		if e.KeyVar != "" {
+			r.locals[e.KeyVar]--
+		}
+		r.locals[e.ValVar]--
+		return &ret
+
+	case *SplatExpr:
+		ret := *e
+		ret.Source = r.rewrite(e.Source)
+		ret.Item = &AnonSymbolExpr{
+			SrcRange: e.Item.SrcRange,
+		}
+		r.anons[e.Item] = ret.Item
+		ret.Each = r.rewrite(e.Each)
+		delete(r.anons, e.Item)
+		return &ret
+
+	case *AnonSymbolExpr:
+		if replacement, ok := r.anons[e]; ok {
+			return replacement
+		}
+		// An AnonSymbolExpr outside of its splat expression has no value
+		// anyway, so we'll just retain it as-is.
+		return e
+
+	case *BinaryOpExpr:
+		ret := *e
+		ret.LHS = r.rewrite(e.LHS)
+		ret.RHS = r.rewrite(e.RHS)
+		return &ret
+
+	case *UnaryOpExpr:
+		ret := *e
+		ret.Val = r.rewrite(e.Val)
+		return &ret
+
+	case *TemplateExpr:
+		ret := *e
+		ret.Parts = r.rewriteAll(e.Parts)
+		return &ret
+
+	case *TemplateJoinExpr:
+		ret := *e
+		ret.Tuple = r.rewrite(e.Tuple)
+		return &ret
+
+	case *TemplateWrapExpr:
+		ret := *e
+		ret.Wrapped = r.rewrite(e.Wrapped)
+		return &ret
+
+	case *ParenthesesExpr:
+		ret := *e
+		ret.Expression = r.rewrite(e.Expression)
+		return &ret
+
+	case *LiteralValueExpr:
+		ret := *e
+		return &ret
+
+	case *ExprSyntaxError:
+		ret := *e
+		return &ret
+
+	default:
+		// All of the expression types in this package are covered above,
+		// and any others cannot contain traversals we know how to find.
+		return expr
+	}
+}
+
+func (r *traversalRewriter) rewriteAll(exprs []Expression) []Expression {
+	if exprs == nil {
+		return nil
+	}
+	ret := make([]Expression, len(exprs))
+	for i, expr := range exprs {
+		ret[i] = r.rewrite(expr)
+	}
+	return ret
+}
@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+func TestRewriteTraversals(t *testing.T) {
+	// The rewrite function moves everything under local into module.x, so
+	// evaluating the result in a context where the locals are nested
+	// there should give the same result as evaluating the original in a
+	// context where they are at the top level.
+	rewrite := func(traversal hcl.Traversal) hcl.Traversal {
+		if traversal.RootName() != "local" {
+			return traversal
+		}
+		ret := hcl.Traversal{
+			hcl.TraverseRoot{Name: "module"},
+			hcl.TraverseAttr{Name: "x"},
+			hcl.TraverseAttr{Name: "local"},
+		}
+		return append(ret, traversal[1:]...)
+	}
+	locals := cty.ObjectVal(map[string]cty.Value{
+		"name": cty.StringVal("example"),
+		"list": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"id": cty.StringVal("a")}),
+			cty.ObjectVal(map[string]cty.Value{"id": cty.StringVal("b")}),
+		}),
+		"enabled": cty.True,
+	})
+	functions := map[string]function.Function{
+		"upper": stdlib.UpperFunc,
+	}
+	beforeCtx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"local": locals,
+			"var":   cty.ObjectVal(map[string]cty.Value{"suffix": cty.StringVal("!")}),
+		},
+		Functions: functions,
+	}
+	afterCtx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"module": cty.ObjectVal(map[string]cty.Value{
+				"x": cty.ObjectVal(map[string]cty.Value{"local": locals}),
+			}),
+			"var": cty.ObjectVal(map[string]cty.Value{"suffix": cty.StringVal("!")}),
+		},
+		Functions: functions,
+	}
+
+	tests := map[string]struct {
+		src      string
+		wantVars []string
+	}{
+		"traversal": {
+			`local.name`,
+			[]string{"module.x.local.name"},
+		},
+		"unchanged traversal": {
+			`var.suffix`,
+			[]string{"var.suffix"},
+		},
+		"template": {
+			`"${local.name}${var.suffix}"`,
+			[]string{"module.x.local.name", "var.suffix"},
+		},
+		"template directive": {
+			`"%{ if local.enabled }${local.name}%{ endif }"`,
+			[]string{"module.x.local.enabled", "module.x.local.name"},
+		},
+		"function call": {
+			`upper(local.name)`,
+			[]string{"module.x.local.name"},
+		},
+		"for expression": {
+			`{for i, v in local.list : v.id => "${local.name}-${i}" if local.enabled}`,
+			[]string{"module.x.local.list", "module.x.local.name", "module.x.local.enabled"},
+		},
+		"for expression shadowing": {
+			`[for local in local.list : local.id]`,
+			[]string{"module.x.local.list"},
+		},
+		"splat": {
+			`local.list[*].id`,
+			[]string{"module.x.local.list"},
+		},
+		"index and conditional": {
+			`local.enabled ? local.list[0].id : (local.name)`,
+			[]string{"module.x.local.enabled", "module.x.local.list[...].id", "module.x.local.name"},
+		},
+		"object keys": {
+			`{name = local.name, (local.name) = 1}`,
+			[]string{"module.x.local.name", "module.x.local.name"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			expr, diags := ParseExpression([]byte(test.src), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags)
+			}
+			origVars := Variables(expr)
+
+			got := RewriteTraversals(expr, rewrite)
+
+			gotVars := Variables(got)
+			if len(gotVars) != len(test.wantVars) {
+				t.Fatalf("wrong number of variables %d; want %d", len(gotVars), len(test.wantVars))
+			}
+			for i, traversal := range gotVars {
+				if got, want := traversalString(traversal), test.wantVars[i]; got != want {
+					t.Errorf("wrong variable %d\ngot:  %s\nwant: %s", i, got, want)
+				}
+			}
+
+			// The original expression must be unchanged.
+			for i, traversal := range Variables(expr) {
+				if got, want := traversalString(traversal), traversalString(origVars[i]); got != want {
+					t.Errorf("original variable %d was modified\ngot:  %s\nwant: %s", i, got, want)
+				}
+			}
+
+			wantVal, diags := expr.Value(beforeCtx)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors evaluating original: %s", diags)
+			}
+			gotVal, diags := got.Value(afterCtx)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors evaluating result: %s", diags)
+			}
+			if !gotVal.RawEquals(wantVal) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", gotVal, wantVal)
+			}
+		})
+	}
+}
+
+func traversalString(traversal hcl.Traversal) string {
+	var ret string
+	for _, step := range traversal {
+		switch step := step.(type) {
+		case hcl.TraverseRoot:
+			ret += step.Name
+		case hcl.TraverseAttr:
+			ret += "." + step.Name
+		default:
+			ret += "[...]"
+		}
+	}
+	return ret
+}
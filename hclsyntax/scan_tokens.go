@@ -4220,18 +4220,26 @@ const hcltok_en_main int = 1459
 //line scan_tokens.rl:18
 
 func scanTokens(data []byte, filename string, start hcl.Pos, mode scanMode) []Token {
+	f := &tokenAccum{}
+	scanTokensAccum(f, data, filename, start, mode)
+	return f.Tokens
+}
+
+// scanTokensAccum is the main implementation of scanTokens, which passes
+// each token to the given accumulator as it is scanned. If the accumulator
+// has an Emit function then the tokens are passed to it rather than being
+// collected, which allows scanning without retaining all of the tokens.
+func scanTokensAccum(f *tokenAccum, data []byte, filename string, start hcl.Pos, mode scanMode) {
 	stripData := stripUTF8BOM(data)
 	start.Byte += len(data) - len(stripData)
 	data = stripData
 
-	f := &tokenAccum{
-		Filename:  filename,
-		Bytes:     data,
-		Pos:       start,
-		StartByte: start.Byte,
-	}
+	f.Filename = filename
+	f.Bytes = data
+	f.Pos = start
+	f.StartByte = start.Byte
 
-//line scan_tokens.rl:317
+//line scan_tokens.rl:325
 
 	// Ragel state
 	p := 0          // "Pointer" into data
@@ -4259,7 +4267,7 @@ func scanTokens(data []byte, filename string, start hcl.Pos, mode scanMode) []To
 	var retBraces []int              // stack of brace levels that cause us to use fret
 	var heredocs []heredocInProgress // stack of heredocs we're currently processing
 
-//line scan_tokens.rl:352
+//line scan_tokens.rl:360
 
 	// Make Go compiler happy
 	_ = ts
@@ -4287,967 +4295,994 @@ func scanTokens(data []byte, filename string, start hcl.Pos, mode scanMode) []To
 		act = 0
 	}
 
+	for {
 //line scan_tokens.go:4300
-	{
-		var _klen int
-		var _trans int
-		var _acts int
-		var _nacts uint
-		var _keys int
-		if p == pe {
-			goto _test_eof
-		}
-		if cs == 0 {
-			goto _out
-		}
-	_resume:
-		_acts = int(_hcltok_from_state_actions[cs])
-		_nacts = uint(_hcltok_actions[_acts])
-		_acts++
-		for ; _nacts > 0; _nacts-- {
+		{
+			var _klen int
+			var _trans int
+			var _acts int
+			var _nacts uint
+			var _keys int
+			if p == pe {
+				goto _test_eof
+			}
+			if cs == 0 {
+				goto _out
+			}
+		_resume:
+			_acts = int(_hcltok_from_state_actions[cs])
+			_nacts = uint(_hcltok_actions[_acts])
 			_acts++
-			switch _hcltok_actions[_acts-1] {
-			case 3:
+			for ; _nacts > 0; _nacts-- {
+				_acts++
+				switch _hcltok_actions[_acts-1] {
+				case 3:
 //line NONE:1
-				ts = p
+					ts = p
 
 //line scan_tokens.go:4323
+				}
 			}
-		}
 
-		_keys = int(_hcltok_key_offsets[cs])
-		_trans = int(_hcltok_index_offsets[cs])
+			_keys = int(_hcltok_key_offsets[cs])
+			_trans = int(_hcltok_index_offsets[cs])
 
-		_klen = int(_hcltok_single_lengths[cs])
-		if _klen > 0 {
-			_lower := int(_keys)
-			var _mid int
-			_upper := int(_keys + _klen - 1)
-			for {
-				if _upper < _lower {
-					break
-				}
+			_klen = int(_hcltok_single_lengths[cs])
+			if _klen > 0 {
+				_lower := int(_keys)
+				var _mid int
+				_upper := int(_keys + _klen - 1)
+				for {
+					if _upper < _lower {
+						break
+					}
 
-				_mid = _lower + ((_upper - _lower) >> 1)
-				switch {
-				case data[p] < _hcltok_trans_keys[_mid]:
-					_upper = _mid - 1
-				case data[p] > _hcltok_trans_keys[_mid]:
-					_lower = _mid + 1
-				default:
-					_trans += int(_mid - int(_keys))
-					goto _match
+					_mid = _lower + ((_upper - _lower) >> 1)
+					switch {
+					case data[p] < _hcltok_trans_keys[_mid]:
+						_upper = _mid - 1
+					case data[p] > _hcltok_trans_keys[_mid]:
+						_lower = _mid + 1
+					default:
+						_trans += int(_mid - int(_keys))
+						goto _match
+					}
 				}
+				_keys += _klen
+				_trans += _klen
 			}
-			_keys += _klen
-			_trans += _klen
-		}
 
-		_klen = int(_hcltok_range_lengths[cs])
-		if _klen > 0 {
-			_lower := int(_keys)
-			var _mid int
-			_upper := int(_keys + (_klen << 1) - 2)
-			for {
-				if _upper < _lower {
-					break
-				}
+			_klen = int(_hcltok_range_lengths[cs])
+			if _klen > 0 {
+				_lower := int(_keys)
+				var _mid int
+				_upper := int(_keys + (_klen << 1) - 2)
+				for {
+					if _upper < _lower {
+						break
+					}
 
-				_mid = _lower + (((_upper - _lower) >> 1) & ^1)
-				switch {
-				case data[p] < _hcltok_trans_keys[_mid]:
-					_upper = _mid - 2
-				case data[p] > _hcltok_trans_keys[_mid+1]:
-					_lower = _mid + 2
-				default:
-					_trans += int((_mid - int(_keys)) >> 1)
-					goto _match
+					_mid = _lower + (((_upper - _lower) >> 1) & ^1)
+					switch {
+					case data[p] < _hcltok_trans_keys[_mid]:
+						_upper = _mid - 2
+					case data[p] > _hcltok_trans_keys[_mid+1]:
+						_lower = _mid + 2
+					default:
+						_trans += int((_mid - int(_keys)) >> 1)
+						goto _match
+					}
 				}
+				_trans += _klen
 			}
-			_trans += _klen
-		}
 
-	_match:
-		_trans = int(_hcltok_indicies[_trans])
-	_eof_trans:
-		cs = int(_hcltok_trans_targs[_trans])
+		_match:
+			_trans = int(_hcltok_indicies[_trans])
+		_eof_trans:
+			cs = int(_hcltok_trans_targs[_trans])
 
-		if _hcltok_trans_actions[_trans] == 0 {
-			goto _again
-		}
+			if _hcltok_trans_actions[_trans] == 0 {
+				goto _again
+			}
 
-		_acts = int(_hcltok_trans_actions[_trans])
-		_nacts = uint(_hcltok_actions[_acts])
-		_acts++
-		for ; _nacts > 0; _nacts-- {
+			_acts = int(_hcltok_trans_actions[_trans])
+			_nacts = uint(_hcltok_actions[_acts])
 			_acts++
-			switch _hcltok_actions[_acts-1] {
-			case 0:
-//line scan_tokens.rl:235
-				p--
+			for ; _nacts > 0; _nacts-- {
+				_acts++
+				switch _hcltok_actions[_acts-1] {
+				case 0:
+//line scan_tokens.rl:243
+					p--
 
-			case 4:
+				case 4:
 //line NONE:1
-				te = p + 1
+					te = p + 1
 
-			case 5:
-//line scan_tokens.rl:259
-				act = 4
-			case 6:
-//line scan_tokens.rl:261
-				act = 6
-			case 7:
-//line scan_tokens.rl:171
-				te = p + 1
-				{
-					token(TokenTemplateInterp)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
-						heredocs[len(heredocs)-1].StartOfLine = false
-					}
+				case 5:
+//line scan_tokens.rl:267
+					act = 4
+				case 6:
+//line scan_tokens.rl:269
+					act = 6
+				case 7:
+//line scan_tokens.rl:179
+					te = p + 1
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
-						goto _again
+						token(TokenTemplateInterp)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
 					}
-				}
-			case 8:
-//line scan_tokens.rl:181
-				te = p + 1
-				{
-					token(TokenTemplateControl)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
-						heredocs[len(heredocs)-1].StartOfLine = false
+				case 8:
+//line scan_tokens.rl:189
+					te = p + 1
+					{
+						token(TokenTemplateControl)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
 					}
+				case 9:
+//line scan_tokens.rl:103
+					te = p + 1
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
+						token(TokenCQuote)
+						top--
+						cs = stack[top]
+						{
+							stack = stack[:len(stack)-1]
+						}
 						goto _again
+
 					}
-				}
-			case 9:
-//line scan_tokens.rl:95
-				te = p + 1
-				{
-					token(TokenCQuote)
-					top--
-					cs = stack[top]
+				case 10:
+//line scan_tokens.rl:267
+					te = p + 1
 					{
-						stack = stack[:len(stack)-1]
-					}
-					goto _again
-
-				}
-			case 10:
-//line scan_tokens.rl:259
-				te = p + 1
-				{
-					token(TokenQuotedLit)
-				}
-			case 11:
-//line scan_tokens.rl:262
-				te = p + 1
-				{
-					token(TokenBadUTF8)
-				}
-			case 12:
-//line scan_tokens.rl:171
-				te = p
-				p--
-				{
-					token(TokenTemplateInterp)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
-						heredocs[len(heredocs)-1].StartOfLine = false
+						token(TokenQuotedLit)
 					}
+				case 11:
+//line scan_tokens.rl:270
+					te = p + 1
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
-						goto _again
+						token(TokenBadUTF8)
 					}
-				}
-			case 13:
-//line scan_tokens.rl:181
-				te = p
-				p--
-				{
-					token(TokenTemplateControl)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
-						heredocs[len(heredocs)-1].StartOfLine = false
+				case 12:
+//line scan_tokens.rl:179
+					te = p
+					p--
+					{
+						token(TokenTemplateInterp)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
 					}
+				case 13:
+//line scan_tokens.rl:189
+					te = p
+					p--
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
-						goto _again
+						token(TokenTemplateControl)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
 					}
-				}
-			case 14:
-//line scan_tokens.rl:259
-				te = p
-				p--
-				{
-					token(TokenQuotedLit)
-				}
-			case 15:
-//line scan_tokens.rl:260
-				te = p
-				p--
-				{
-					token(TokenQuotedNewline)
-				}
-			case 16:
-//line scan_tokens.rl:261
-				te = p
-				p--
-				{
-					token(TokenInvalid)
-				}
-			case 17:
-//line scan_tokens.rl:262
-				te = p
-				p--
-				{
-					token(TokenBadUTF8)
-				}
-			case 18:
-//line scan_tokens.rl:259
-				p = (te) - 1
-				{
-					token(TokenQuotedLit)
-				}
-			case 19:
-//line scan_tokens.rl:262
-				p = (te) - 1
-				{
-					token(TokenBadUTF8)
-				}
-			case 20:
-//line NONE:1
-				switch act {
-				case 4:
+				case 14:
+//line scan_tokens.rl:267
+					te = p
+					p--
 					{
-						p = (te) - 1
 						token(TokenQuotedLit)
 					}
-				case 6:
+				case 15:
+//line scan_tokens.rl:268
+					te = p
+					p--
+					{
+						token(TokenQuotedNewline)
+					}
+				case 16:
+//line scan_tokens.rl:269
+					te = p
+					p--
 					{
-						p = (te) - 1
 						token(TokenInvalid)
 					}
-				}
-
-			case 21:
-//line scan_tokens.rl:159
-				act = 11
-			case 22:
+				case 17:
 //line scan_tokens.rl:270
-				act = 12
-			case 23:
-//line scan_tokens.rl:171
-				te = p + 1
-				{
-					token(TokenTemplateInterp)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
-						heredocs[len(heredocs)-1].StartOfLine = false
+					te = p
+					p--
+					{
+						token(TokenBadUTF8)
 					}
+				case 18:
+//line scan_tokens.rl:267
+					p = (te) - 1
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
-						goto _again
+						token(TokenQuotedLit)
 					}
-				}
-			case 24:
-//line scan_tokens.rl:181
-				te = p + 1
-				{
-					token(TokenTemplateControl)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
-						heredocs[len(heredocs)-1].StartOfLine = false
+				case 19:
+//line scan_tokens.rl:270
+					p = (te) - 1
+					{
+						token(TokenBadUTF8)
 					}
+				case 20:
+//line NONE:1
+					switch act {
+					case 4:
+						{
+							p = (te) - 1
+							token(TokenQuotedLit)
+						}
+					case 6:
+						{
+							p = (te) - 1
+							token(TokenInvalid)
+						}
+					}
+
+				case 21:
+//line scan_tokens.rl:167
+					act = 11
+				case 22:
+//line scan_tokens.rl:278
+					act = 12
+				case 23:
+//line scan_tokens.rl:179
+					te = p + 1
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
-						goto _again
+						token(TokenTemplateInterp)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
 					}
-				}
-			case 25:
-//line scan_tokens.rl:122
-				te = p + 1
-				{
-					// This action is called specificially when a heredoc literal
-					// ends with a newline character.
+				case 24:
+//line scan_tokens.rl:189
+					te = p + 1
+					{
+						token(TokenTemplateControl)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
+					}
+				case 25:
+//line scan_tokens.rl:130
+					te = p + 1
+					{
+						// This action is called specificially when a heredoc literal
+						// ends with a newline character.
 
-					// This might actually be our end marker.
-					topdoc := &heredocs[len(heredocs)-1]
-					if topdoc.StartOfLine {
-						maybeMarker := bytes.TrimSpace(data[ts:te])
-						if bytes.Equal(maybeMarker, topdoc.Marker) {
-							// We actually emit two tokens here: the end-of-heredoc
-							// marker first, and then separately the newline that
-							// follows it. This then avoids issues with the closing
-							// marker consuming a newline that would normally be used
-							// to mark the end of an attribute definition.
-							// We might have either a \n sequence or an \r\n sequence
-							// here, so we must handle both.
-							nls := te - 1
-							nle := te
-							te--
-							if data[te-1] == '\r' {
-								// back up one more byte
-								nls--
+						// This might actually be our end marker.
+						topdoc := &heredocs[len(heredocs)-1]
+						if topdoc.StartOfLine {
+							maybeMarker := bytes.TrimSpace(data[ts:te])
+							if bytes.Equal(maybeMarker, topdoc.Marker) {
+								// We actually emit two tokens here: the end-of-heredoc
+								// marker first, and then separately the newline that
+								// follows it. This then avoids issues with the closing
+								// marker consuming a newline that would normally be used
+								// to mark the end of an attribute definition.
+								// We might have either a \n sequence or an \r\n sequence
+								// here, so we must handle both.
+								nls := te - 1
+								nle := te
 								te--
-							}
-							token(TokenCHeredoc)
-							ts = nls
-							te = nle
-							token(TokenNewline)
-							heredocs = heredocs[:len(heredocs)-1]
-							top--
-							cs = stack[top]
-							{
-								stack = stack[:len(stack)-1]
-							}
-							goto _again
+								if data[te-1] == '\r' {
+									// back up one more byte
+									nls--
+									te--
+								}
+								token(TokenCHeredoc)
+								ts = nls
+								te = nle
+								token(TokenNewline)
+								heredocs = heredocs[:len(heredocs)-1]
+								top--
+								cs = stack[top]
+								{
+									stack = stack[:len(stack)-1]
+								}
+								goto _again
 
+							}
 						}
-					}
 
-					topdoc.StartOfLine = true
-					token(TokenStringLit)
-				}
-			case 26:
-//line scan_tokens.rl:270
-				te = p + 1
-				{
-					token(TokenBadUTF8)
-				}
-			case 27:
-//line scan_tokens.rl:171
-				te = p
-				p--
-				{
-					token(TokenTemplateInterp)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
-						heredocs[len(heredocs)-1].StartOfLine = false
+						topdoc.StartOfLine = true
+						token(TokenStringLit)
 					}
+				case 26:
+//line scan_tokens.rl:278
+					te = p + 1
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
-						goto _again
-					}
-				}
-			case 28:
-//line scan_tokens.rl:181
-				te = p
-				p--
-				{
-					token(TokenTemplateControl)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
-						heredocs[len(heredocs)-1].StartOfLine = false
+						token(TokenBadUTF8)
 					}
+				case 27:
+//line scan_tokens.rl:179
+					te = p
+					p--
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
-						goto _again
+						token(TokenTemplateInterp)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
 					}
-				}
-			case 29:
-//line scan_tokens.rl:159
-				te = p
-				p--
-				{
-					// This action is called when a heredoc literal _doesn't_ end
-					// with a newline character, e.g. because we're about to enter
-					// an interpolation sequence.
-					heredocs[len(heredocs)-1].StartOfLine = false
-					token(TokenStringLit)
-				}
-			case 30:
-//line scan_tokens.rl:270
-				te = p
-				p--
-				{
-					token(TokenBadUTF8)
-				}
-			case 31:
-//line scan_tokens.rl:159
-				p = (te) - 1
-				{
-					// This action is called when a heredoc literal _doesn't_ end
-					// with a newline character, e.g. because we're about to enter
-					// an interpolation sequence.
-					heredocs[len(heredocs)-1].StartOfLine = false
-					token(TokenStringLit)
-				}
-			case 32:
-//line NONE:1
-				switch act {
-				case 0:
+				case 28:
+//line scan_tokens.rl:189
+					te = p
+					p--
 					{
-						cs = 0
-						goto _again
+						token(TokenTemplateControl)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
 					}
-				case 11:
+				case 29:
+//line scan_tokens.rl:167
+					te = p
+					p--
 					{
-						p = (te) - 1
-
 						// This action is called when a heredoc literal _doesn't_ end
 						// with a newline character, e.g. because we're about to enter
 						// an interpolation sequence.
 						heredocs[len(heredocs)-1].StartOfLine = false
 						token(TokenStringLit)
 					}
-				case 12:
+				case 30:
+//line scan_tokens.rl:278
+					te = p
+					p--
 					{
-						p = (te) - 1
 						token(TokenBadUTF8)
 					}
-				}
-
-			case 33:
+				case 31:
 //line scan_tokens.rl:167
-				act = 15
-			case 34:
-//line scan_tokens.rl:277
-				act = 16
-			case 35:
-//line scan_tokens.rl:171
-				te = p + 1
-				{
-					token(TokenTemplateInterp)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
+					p = (te) - 1
+					{
+						// This action is called when a heredoc literal _doesn't_ end
+						// with a newline character, e.g. because we're about to enter
+						// an interpolation sequence.
 						heredocs[len(heredocs)-1].StartOfLine = false
+						token(TokenStringLit)
 					}
+				case 32:
+//line NONE:1
+					switch act {
+					case 0:
+						{
+							cs = 0
+							goto _again
+						}
+					case 11:
+						{
+							p = (te) - 1
+
+							// This action is called when a heredoc literal _doesn't_ end
+							// with a newline character, e.g. because we're about to enter
+							// an interpolation sequence.
+							heredocs[len(heredocs)-1].StartOfLine = false
+							token(TokenStringLit)
+						}
+					case 12:
+						{
+							p = (te) - 1
+							token(TokenBadUTF8)
+						}
+					}
+
+				case 33:
+//line scan_tokens.rl:175
+					act = 15
+				case 34:
+//line scan_tokens.rl:285
+					act = 16
+				case 35:
+//line scan_tokens.rl:179
+					te = p + 1
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
-						goto _again
+						token(TokenTemplateInterp)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
 					}
-				}
-			case 36:
-//line scan_tokens.rl:181
-				te = p + 1
-				{
-					token(TokenTemplateControl)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
-						heredocs[len(heredocs)-1].StartOfLine = false
+				case 36:
+//line scan_tokens.rl:189
+					te = p + 1
+					{
+						token(TokenTemplateControl)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
 					}
+				case 37:
+//line scan_tokens.rl:175
+					te = p + 1
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
-						goto _again
+						token(TokenStringLit)
 					}
-				}
-			case 37:
-//line scan_tokens.rl:167
-				te = p + 1
-				{
-					token(TokenStringLit)
-				}
-			case 38:
-//line scan_tokens.rl:277
-				te = p + 1
-				{
-					token(TokenBadUTF8)
-				}
-			case 39:
-//line scan_tokens.rl:171
-				te = p
-				p--
-				{
-					token(TokenTemplateInterp)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
-						heredocs[len(heredocs)-1].StartOfLine = false
+				case 38:
+//line scan_tokens.rl:285
+					te = p + 1
+					{
+						token(TokenBadUTF8)
 					}
+				case 39:
+//line scan_tokens.rl:179
+					te = p
+					p--
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
-						goto _again
+						token(TokenTemplateInterp)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
 					}
-				}
-			case 40:
-//line scan_tokens.rl:181
-				te = p
-				p--
-				{
-					token(TokenTemplateControl)
-					braces++
-					retBraces = append(retBraces, braces)
-					if len(heredocs) > 0 {
-						heredocs[len(heredocs)-1].StartOfLine = false
+				case 40:
+//line scan_tokens.rl:189
+					te = p
+					p--
+					{
+						token(TokenTemplateControl)
+						braces++
+						retBraces = append(retBraces, braces)
+						if len(heredocs) > 0 {
+							heredocs[len(heredocs)-1].StartOfLine = false
+						}
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1459
+							goto _again
+						}
 					}
+				case 41:
+//line scan_tokens.rl:175
+					te = p
+					p--
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1459
-						goto _again
+						token(TokenStringLit)
 					}
-				}
-			case 41:
-//line scan_tokens.rl:167
-				te = p
-				p--
-				{
-					token(TokenStringLit)
-				}
-			case 42:
-//line scan_tokens.rl:277
-				te = p
-				p--
-				{
-					token(TokenBadUTF8)
-				}
-			case 43:
-//line scan_tokens.rl:167
-				p = (te) - 1
-				{
-					token(TokenStringLit)
-				}
-			case 44:
-//line NONE:1
-				switch act {
-				case 0:
+				case 42:
+//line scan_tokens.rl:285
+					te = p
+					p--
 					{
-						cs = 0
-						goto _again
+						token(TokenBadUTF8)
 					}
-				case 15:
+				case 43:
+//line scan_tokens.rl:175
+					p = (te) - 1
 					{
-						p = (te) - 1
-
 						token(TokenStringLit)
 					}
-				case 16:
+				case 44:
+//line NONE:1
+					switch act {
+					case 0:
+						{
+							cs = 0
+							goto _again
+						}
+					case 15:
+						{
+							p = (te) - 1
+
+							token(TokenStringLit)
+						}
+					case 16:
+						{
+							p = (te) - 1
+							token(TokenBadUTF8)
+						}
+					}
+
+				case 45:
+//line scan_tokens.rl:289
+					act = 17
+				case 46:
+//line scan_tokens.rl:290
+					act = 18
+				case 47:
+//line scan_tokens.rl:290
+					te = p + 1
 					{
-						p = (te) - 1
 						token(TokenBadUTF8)
 					}
-				}
-
-			case 45:
-//line scan_tokens.rl:281
-				act = 17
-			case 46:
-//line scan_tokens.rl:282
-				act = 18
-			case 47:
-//line scan_tokens.rl:282
-				te = p + 1
-				{
-					token(TokenBadUTF8)
-				}
-			case 48:
-//line scan_tokens.rl:283
-				te = p + 1
-				{
-					token(TokenInvalid)
-				}
-			case 49:
-//line scan_tokens.rl:281
-				te = p
-				p--
-				{
-					token(TokenIdent)
-				}
-			case 50:
-//line scan_tokens.rl:282
-				te = p
-				p--
-				{
-					token(TokenBadUTF8)
-				}
-			case 51:
-//line scan_tokens.rl:281
-				p = (te) - 1
-				{
-					token(TokenIdent)
-				}
-			case 52:
-//line scan_tokens.rl:282
-				p = (te) - 1
-				{
-					token(TokenBadUTF8)
-				}
-			case 53:
-//line NONE:1
-				switch act {
-				case 17:
+				case 48:
+//line scan_tokens.rl:291
+					te = p + 1
+					{
+						token(TokenInvalid)
+					}
+				case 49:
+//line scan_tokens.rl:289
+					te = p
+					p--
 					{
-						p = (te) - 1
 						token(TokenIdent)
 					}
-				case 18:
+				case 50:
+//line scan_tokens.rl:290
+					te = p
+					p--
 					{
-						p = (te) - 1
 						token(TokenBadUTF8)
 					}
-				}
-
-			case 54:
+				case 51:
 //line scan_tokens.rl:289
-				act = 22
-			case 55:
-//line scan_tokens.rl:313
-				act = 40
-			case 56:
-//line scan_tokens.rl:291
-				te = p + 1
-				{
-					token(TokenComment)
-				}
-			case 57:
-//line scan_tokens.rl:292
-				te = p + 1
-				{
-					token(TokenNewline)
-				}
-			case 58:
-//line scan_tokens.rl:294
-				te = p + 1
-				{
-					token(TokenEqualOp)
-				}
-			case 59:
-//line scan_tokens.rl:295
-				te = p + 1
-				{
-					token(TokenNotEqual)
-				}
-			case 60:
-//line scan_tokens.rl:296
-				te = p + 1
-				{
-					token(TokenGreaterThanEq)
-				}
-			case 61:
+					p = (te) - 1
+					{
+						token(TokenIdent)
+					}
+				case 52:
+//line scan_tokens.rl:290
+					p = (te) - 1
+					{
+						token(TokenBadUTF8)
+					}
+				case 53:
+//line NONE:1
+					switch act {
+					case 17:
+						{
+							p = (te) - 1
+							token(TokenIdent)
+						}
+					case 18:
+						{
+							p = (te) - 1
+							token(TokenBadUTF8)
+						}
+					}
+
+				case 54:
 //line scan_tokens.rl:297
-				te = p + 1
-				{
-					token(TokenLessThanEq)
-				}
-			case 62:
-//line scan_tokens.rl:298
-				te = p + 1
-				{
-					token(TokenAnd)
-				}
-			case 63:
+					act = 22
+				case 55:
+//line scan_tokens.rl:321
+					act = 40
+				case 56:
 //line scan_tokens.rl:299
-				te = p + 1
-				{
-					token(TokenOr)
-				}
-			case 64:
+					te = p + 1
+					{
+						token(TokenComment)
+					}
+				case 57:
 //line scan_tokens.rl:300
-				te = p + 1
-				{
-					token(TokenDoubleColon)
-				}
-			case 65:
-//line scan_tokens.rl:301
-				te = p + 1
-				{
-					token(TokenEllipsis)
-				}
-			case 66:
+					te = p + 1
+					{
+						token(TokenNewline)
+					}
+				case 58:
 //line scan_tokens.rl:302
-				te = p + 1
-				{
-					token(TokenFatArrow)
-				}
-			case 67:
+					te = p + 1
+					{
+						token(TokenEqualOp)
+					}
+				case 59:
 //line scan_tokens.rl:303
-				te = p + 1
-				{
-					selfToken()
-				}
-			case 68:
-//line scan_tokens.rl:191
-				te = p + 1
-				{
-					token(TokenOBrace)
-					braces++
-				}
-			case 69:
-//line scan_tokens.rl:196
-				te = p + 1
-				{
-					if len(retBraces) > 0 && retBraces[len(retBraces)-1] == braces {
-						token(TokenTemplateSeqEnd)
-						braces--
-						retBraces = retBraces[0 : len(retBraces)-1]
-						top--
-						cs = stack[top]
-						{
-							stack = stack[:len(stack)-1]
+					te = p + 1
+					{
+						token(TokenNotEqual)
+					}
+				case 60:
+//line scan_tokens.rl:304
+					te = p + 1
+					{
+						token(TokenGreaterThanEq)
+					}
+				case 61:
+//line scan_tokens.rl:305
+					te = p + 1
+					{
+						token(TokenLessThanEq)
+					}
+				case 62:
+//line scan_tokens.rl:306
+					te = p + 1
+					{
+						token(TokenAnd)
+					}
+				case 63:
+//line scan_tokens.rl:307
+					te = p + 1
+					{
+						token(TokenOr)
+					}
+				case 64:
+//line scan_tokens.rl:308
+					te = p + 1
+					{
+						token(TokenDoubleColon)
+					}
+				case 65:
+//line scan_tokens.rl:309
+					te = p + 1
+					{
+						token(TokenEllipsis)
+					}
+				case 66:
+//line scan_tokens.rl:310
+					te = p + 1
+					{
+						token(TokenFatArrow)
+					}
+				case 67:
+//line scan_tokens.rl:311
+					te = p + 1
+					{
+						selfToken()
+					}
+				case 68:
+//line scan_tokens.rl:199
+					te = p + 1
+					{
+						token(TokenOBrace)
+						braces++
+					}
+				case 69:
+//line scan_tokens.rl:204
+					te = p + 1
+					{
+						if len(retBraces) > 0 && retBraces[len(retBraces)-1] == braces {
+							token(TokenTemplateSeqEnd)
+							braces--
+							retBraces = retBraces[0 : len(retBraces)-1]
+							top--
+							cs = stack[top]
+							{
+								stack = stack[:len(stack)-1]
+							}
+							goto _again
+
+						} else {
+							token(TokenCBrace)
+							braces--
 						}
-						goto _again
+					}
+				case 70:
+//line scan_tokens.rl:216
+					te = p + 1
+					{
+						// Only consume from the retBraces stack and return if we are at
+						// a suitable brace nesting level, otherwise things will get
+						// confused. (Not entering this branch indicates a syntax error,
+						// which we will catch in the parser.)
+						if len(retBraces) > 0 && retBraces[len(retBraces)-1] == braces {
+							token(TokenTemplateSeqEnd)
+							braces--
+							retBraces = retBraces[0 : len(retBraces)-1]
+							top--
+							cs = stack[top]
+							{
+								stack = stack[:len(stack)-1]
+							}
+							goto _again
 
-					} else {
-						token(TokenCBrace)
-						braces--
+						} else {
+							// We intentionally generate a TokenTemplateSeqEnd here,
+							// even though the user apparently wanted a brace, because
+							// we want to allow the parser to catch the incorrect use
+							// of a ~} to balance a generic opening brace, rather than
+							// a template sequence.
+							token(TokenTemplateSeqEnd)
+							braces--
+						}
 					}
-				}
-			case 70:
-//line scan_tokens.rl:208
-				te = p + 1
-				{
-					// Only consume from the retBraces stack and return if we are at
-					// a suitable brace nesting level, otherwise things will get
-					// confused. (Not entering this branch indicates a syntax error,
-					// which we will catch in the parser.)
-					if len(retBraces) > 0 && retBraces[len(retBraces)-1] == braces {
-						token(TokenTemplateSeqEnd)
-						braces--
-						retBraces = retBraces[0 : len(retBraces)-1]
-						top--
-						cs = stack[top]
+				case 71:
+//line scan_tokens.rl:98
+					te = p + 1
+					{
+						token(TokenOQuote)
 						{
-							stack = stack[:len(stack)-1]
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1510
+							goto _again
 						}
-						goto _again
+					}
+				case 72:
+//line scan_tokens.rl:108
+					te = p + 1
+					{
+						token(TokenOHeredoc)
+						// the token is currently the whole heredoc introducer, like
+						// <<EOT or <<-EOT, followed by a newline. We want to extract
+						// just the "EOT" portion that we'll use as the closing marker.
 
-					} else {
-						// We intentionally generate a TokenTemplateSeqEnd here,
-						// even though the user apparently wanted a brace, because
-						// we want to allow the parser to catch the incorrect use
-						// of a ~} to balance a generic opening brace, rather than
-						// a template sequence.
-						token(TokenTemplateSeqEnd)
-						braces--
+						marker := data[ts+2 : te-1]
+						if marker[0] == '-' {
+							marker = marker[1:]
+						}
+						if marker[len(marker)-1] == '\r' {
+							marker = marker[:len(marker)-1]
+						}
+
+						heredocs = append(heredocs, heredocInProgress{
+							Marker:      marker,
+							StartOfLine: true,
+						})
+
+						{
+							stack = append(stack, 0)
+							stack[top] = cs
+							top++
+							cs = 1524
+							goto _again
+						}
 					}
-				}
-			case 71:
-//line scan_tokens.rl:90
-				te = p + 1
-				{
-					token(TokenOQuote)
+				case 73:
+//line scan_tokens.rl:321
+					te = p + 1
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1510
-						goto _again
+						token(TokenBadUTF8)
 					}
-				}
-			case 72:
-//line scan_tokens.rl:100
-				te = p + 1
-				{
-					token(TokenOHeredoc)
-					// the token is currently the whole heredoc introducer, like
-					// <<EOT or <<-EOT, followed by a newline. We want to extract
-					// just the "EOT" portion that we'll use as the closing marker.
+				case 74:
+//line scan_tokens.rl:322
+					te = p + 1
+					{
+						token(TokenInvalid)
+					}
+				case 75:
+//line scan_tokens.rl:295
+					te = p
+					p--
 
-					marker := data[ts+2 : te-1]
-					if marker[0] == '-' {
-						marker = marker[1:]
+				case 76:
+//line scan_tokens.rl:296
+					te = p
+					p--
+					{
+						token(TokenNumberLit)
 					}
-					if marker[len(marker)-1] == '\r' {
-						marker = marker[:len(marker)-1]
+				case 77:
+//line scan_tokens.rl:297
+					te = p
+					p--
+					{
+						token(TokenIdent)
 					}
-
-					heredocs = append(heredocs, heredocInProgress{
-						Marker:      marker,
-						StartOfLine: true,
-					})
-
+				case 78:
+//line scan_tokens.rl:299
+					te = p
+					p--
 					{
-						stack = append(stack, 0)
-						stack[top] = cs
-						top++
-						cs = 1524
-						goto _again
+						token(TokenComment)
 					}
-				}
-			case 73:
-//line scan_tokens.rl:313
-				te = p + 1
-				{
-					token(TokenBadUTF8)
-				}
-			case 74:
-//line scan_tokens.rl:314
-				te = p + 1
-				{
-					token(TokenInvalid)
-				}
-			case 75:
-//line scan_tokens.rl:287
-				te = p
-				p--
-
-			case 76:
-//line scan_tokens.rl:288
-				te = p
-				p--
-				{
-					token(TokenNumberLit)
-				}
-			case 77:
-//line scan_tokens.rl:289
-				te = p
-				p--
-				{
-					token(TokenIdent)
-				}
-			case 78:
-//line scan_tokens.rl:291
-				te = p
-				p--
-				{
-					token(TokenComment)
-				}
-			case 79:
-//line scan_tokens.rl:303
-				te = p
-				p--
-				{
-					selfToken()
-				}
-			case 80:
-//line scan_tokens.rl:313
-				te = p
-				p--
-				{
-					token(TokenBadUTF8)
-				}
-			case 81:
-//line scan_tokens.rl:314
-				te = p
-				p--
-				{
-					token(TokenInvalid)
-				}
-			case 82:
-//line scan_tokens.rl:288
-				p = (te) - 1
-				{
-					token(TokenNumberLit)
-				}
-			case 83:
-//line scan_tokens.rl:289
-				p = (te) - 1
-				{
-					token(TokenIdent)
-				}
-			case 84:
-//line scan_tokens.rl:303
-				p = (te) - 1
-				{
-					selfToken()
-				}
-			case 85:
-//line scan_tokens.rl:313
-				p = (te) - 1
-				{
-					token(TokenBadUTF8)
-				}
-			case 86:
-//line NONE:1
-				switch act {
-				case 22:
+				case 79:
+//line scan_tokens.rl:311
+					te = p
+					p--
+					{
+						selfToken()
+					}
+				case 80:
+//line scan_tokens.rl:321
+					te = p
+					p--
+					{
+						token(TokenBadUTF8)
+					}
+				case 81:
+//line scan_tokens.rl:322
+					te = p
+					p--
+					{
+						token(TokenInvalid)
+					}
+				case 82:
+//line scan_tokens.rl:296
+					p = (te) - 1
+					{
+						token(TokenNumberLit)
+					}
+				case 83:
+//line scan_tokens.rl:297
+					p = (te) - 1
 					{
-						p = (te) - 1
 						token(TokenIdent)
 					}
-				case 40:
+				case 84:
+//line scan_tokens.rl:311
+					p = (te) - 1
+					{
+						selfToken()
+					}
+				case 85:
+//line scan_tokens.rl:321
+					p = (te) - 1
 					{
-						p = (te) - 1
 						token(TokenBadUTF8)
 					}
-				}
+				case 86:
+//line NONE:1
+					switch act {
+					case 22:
+						{
+							p = (te) - 1
+							token(TokenIdent)
+						}
+					case 40:
+						{
+							p = (te) - 1
+							token(TokenBadUTF8)
+						}
+					}
 
 //line scan_tokens.go:5062
+				}
 			}
-		}
 
-	_again:
-		_acts = int(_hcltok_to_state_actions[cs])
-		_nacts = uint(_hcltok_actions[_acts])
-		_acts++
-		for ; _nacts > 0; _nacts-- {
+		_again:
+			_acts = int(_hcltok_to_state_actions[cs])
+			_nacts = uint(_hcltok_actions[_acts])
 			_acts++
-			switch _hcltok_actions[_acts-1] {
-			case 1:
+			for ; _nacts > 0; _nacts-- {
+				_acts++
+				switch _hcltok_actions[_acts-1] {
+				case 1:
 //line NONE:1
-				ts = 0
+					ts = 0
 
-			case 2:
+				case 2:
 //line NONE:1
-				act = 0
+					act = 0
 
 //line scan_tokens.go:5080
+				}
 			}
-		}
 
-		if cs == 0 {
-			goto _out
-		}
-		p++
-		if p != pe {
-			goto _resume
-		}
-	_test_eof:
-		{
-		}
-		if p == eof {
-			if _hcltok_eof_trans[cs] > 0 {
-				_trans = int(_hcltok_eof_trans[cs] - 1)
-				goto _eof_trans
+			if cs == 0 {
+				goto _out
+			}
+			p++
+			if p != pe {
+				goto _resume
+			}
+		_test_eof:
+			{
+			}
+			if p == eof {
+				if _hcltok_eof_trans[cs] > 0 {
+					_trans = int(_hcltok_eof_trans[cs] - 1)
+					goto _eof_trans
+				}
+			}
+
+		_out:
+			{
 			}
 		}
 
-	_out:
-		{
+//line scan_tokens.rl:387
+
+		// The scanner can only enter its error state when it finds a byte
+		// it cannot match inside a heredoc or a bare template, such as a
+		// carriage return that isn't followed by a newline, because all of
+		// the other machines have catch-all rules. Rather than abandoning
+		// the rest of the input, we emit just that byte as an invalid token
+		// (or as literal text in a bare template, for consistency with the
+		// handling below) and then resume in the same template, so that
+		// the remainder of the input still produces tokens.
+		if cs != hcltok_error || ts >= len(data) {
+			break
 		}
+		if mode == scanTemplate && len(stack) == 0 {
+			f.emitToken(TokenStringLit, ts, ts+1)
+			cs = hcltok_en_bareTemplate
+		} else if len(heredocs) > 0 {
+			f.emitToken(TokenInvalid, ts, ts+1)
+			heredocs[len(heredocs)-1].StartOfLine = false
+			cs = hcltok_en_heredocTemplate
+		} else {
+			break
+		}
+		p = ts + 1
+		ts = p
+		te = p
 	}
 
-//line scan_tokens.rl:375
-
 	// If we fall out here without being in a final state then we've
 	// encountered something that the scanner can't match, which we'll
 	// deal with as an invalid.
@@ -5268,6 +5303,4 @@ func scanTokens(data []byte, filename string, start hcl.Pos, mode scanMode) []To
 	// We always emit a synthetic EOF token at the end, since it gives the
 	// parser position information for an "unexpected EOF" diagnostic.
 	f.emitToken(TokenEOF, len(data), len(data))
-
-	return f.Tokens
 }
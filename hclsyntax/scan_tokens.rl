@@ -18,16 +18,24 @@ import (
 }%%
 
 func scanTokens(data []byte, filename string, start hcl.Pos, mode scanMode) []Token {
+    f := &tokenAccum{}
+    scanTokensAccum(f, data, filename, start, mode)
+    return f.Tokens
+}
+
+// scanTokensAccum is the main implementation of scanTokens, which passes
+// each token to the given accumulator as it is scanned. If the accumulator
+// has an Emit function then the tokens are passed to it rather than being
+// collected, which allows scanning without retaining all of the tokens.
+func scanTokensAccum(f *tokenAccum, data []byte, filename string, start hcl.Pos, mode scanMode) {
     stripData := stripUTF8BOM(data)
     start.Byte += len(data) - len(stripData)
     data = stripData
 
-    f := &tokenAccum{
-        Filename:  filename,
-        Bytes:     data,
-        Pos:       start,
-        StartByte: start.Byte,
-    }
+    f.Filename = filename
+    f.Bytes = data
+    f.Pos = start
+    f.StartByte = start.Byte
 
     %%{
         include UnicodeDerived "unicode_derived.rl";
@@ -371,9 +379,39 @@ func scanTokens(data []byte, filename string, start hcl.Pos, mode scanMode) []To
 
     %%{
         write init nocs;
-        write exec;
     }%%
 
+    for {
+        %%{
+            write exec;
+        }%%
+
+        // The scanner can only enter its error state when it finds a byte
+        // it cannot match inside a heredoc or a bare template, such as a
+        // carriage return that isn't followed by a newline, because all of
+        // the other machines have catch-all rules. Rather than abandoning
+        // the rest of the input, we emit just that byte as an invalid token
+        // (or as literal text in a bare template, for consistency with the
+        // handling below) and then resume in the same template, so that
+        // the remainder of the input still produces tokens.
+        if cs != hcltok_error || ts >= len(data) {
+            break
+        }
+        if mode == scanTemplate && len(stack) == 0 {
+            f.emitToken(TokenStringLit, ts, ts+1)
+            cs = hcltok_en_bareTemplate
+        } else if len(heredocs) > 0 {
+            f.emitToken(TokenInvalid, ts, ts+1)
+            heredocs[len(heredocs)-1].StartOfLine = false
+            cs = hcltok_en_heredocTemplate
+        } else {
+            break
+        }
+        p = ts + 1
+        ts = p
+        te = p
+    }
+
     // If we fall out here without being in a final state then we've
     // encountered something that the scanner can't match, which we'll
     // deal with as an invalid.
@@ -394,6 +432,4 @@ func scanTokens(data []byte, filename string, start hcl.Pos, mode scanMode) []To
     // We always emit a synthetic EOF token at the end, since it gives the
     // parser position information for an "unexpected EOF" diagnostic.
     f.emitToken(TokenEOF, len(data), len(data))
-
-    return f.Tokens
 }
@@ -2498,6 +2498,84 @@ EOF
 			},
 		},
 
+		{
+			"<<EOT\na\rb\nEOT\nc\n", // carriage return without a newline in a heredoc
+			[]Token{
+				{
+					Type:  TokenOHeredoc,
+					Bytes: []byte("<<EOT\n"),
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 0, Line: 1, Column: 1},
+						End:   hcl.Pos{Byte: 6, Line: 2, Column: 1},
+					},
+				},
+				{
+					Type:  TokenStringLit,
+					Bytes: []byte("a"),
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 6, Line: 2, Column: 1},
+						End:   hcl.Pos{Byte: 7, Line: 2, Column: 2},
+					},
+				},
+				{
+					Type:  TokenInvalid,
+					Bytes: []byte("\r"),
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 7, Line: 2, Column: 2},
+						End:   hcl.Pos{Byte: 8, Line: 2, Column: 3},
+					},
+				},
+				{
+					Type:  TokenStringLit,
+					Bytes: []byte("b\n"),
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 8, Line: 2, Column: 3},
+						End:   hcl.Pos{Byte: 10, Line: 3, Column: 1},
+					},
+				},
+				{
+					Type:  TokenCHeredoc,
+					Bytes: []byte("EOT"),
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 10, Line: 3, Column: 1},
+						End:   hcl.Pos{Byte: 13, Line: 3, Column: 4},
+					},
+				},
+				{
+					Type:  TokenNewline,
+					Bytes: []byte("\n"),
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 13, Line: 3, Column: 4},
+						End:   hcl.Pos{Byte: 14, Line: 4, Column: 1},
+					},
+				},
+				{
+					Type:  TokenIdent,
+					Bytes: []byte("c"),
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 14, Line: 4, Column: 1},
+						End:   hcl.Pos{Byte: 15, Line: 4, Column: 2},
+					},
+				},
+				{
+					Type:  TokenNewline,
+					Bytes: []byte("\n"),
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 15, Line: 4, Column: 2},
+						End:   hcl.Pos{Byte: 16, Line: 5, Column: 1},
+					},
+				},
+				{
+					Type:  TokenEOF,
+					Bytes: []byte{},
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 16, Line: 5, Column: 1},
+						End:   hcl.Pos{Byte: 16, Line: 5, Column: 1},
+					},
+				},
+			},
+		},
+
 		// Misc combinations that have come up in bug reports, etc.
 		{
 			"locals {\n  is_percent = percent_sign == \"%\" ? true : false\n}\n",
@@ -2838,6 +2916,43 @@ func TestScanTokens_template(t *testing.T) {
 				},
 			},
 		},
+		{
+			"a\rb", // carriage return without a newline
+			[]Token{
+				{
+					Type:  TokenStringLit,
+					Bytes: []byte("a"),
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 0, Line: 1, Column: 1},
+						End:   hcl.Pos{Byte: 1, Line: 1, Column: 2},
+					},
+				},
+				{
+					Type:  TokenStringLit,
+					Bytes: []byte("\r"),
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 1, Line: 1, Column: 2},
+						End:   hcl.Pos{Byte: 2, Line: 1, Column: 3},
+					},
+				},
+				{
+					Type:  TokenStringLit,
+					Bytes: []byte("b"),
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 2, Line: 1, Column: 3},
+						End:   hcl.Pos{Byte: 3, Line: 1, Column: 4},
+					},
+				},
+				{
+					Type:  TokenEOF,
+					Bytes: []byte{},
+					Range: hcl.Range{
+						Start: hcl.Pos{Byte: 3, Line: 1, Column: 4},
+						End:   hcl.Pos{Byte: 3, Line: 1, Column: 4},
+					},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -5,9 +5,12 @@ package hclsyntax
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // AsHCLBlock returns the block data expressed as a *hcl.Block.
@@ -39,12 +42,60 @@ type Body struct {
 
 	SrcRange hcl.Range
 	EndRange hcl.Range // Final token of the body (zero-length range)
+
+	// deferred is set for a block body whose parsing was deferred by
+	// ParseOptions.LazyBlockBodies. See parseDeferred.
+	deferred *deferredBody
+}
+
+// deferredBody holds what's needed to parse a body whose parsing was
+// deferred, and the diagnostics from doing so.
+type deferredBody struct {
+	once sync.Once
+
+	tokens               Tokens // from the opening brace to the closing brace, then EOF
+	pedanticConditionals bool
+	maxNodes             int
+	annotationPrefix     string
+	typeAnnotations      func(expr hcl.Expression) hcl.Diagnostics
+
+	diags hcl.Diagnostics
+}
+
+// parseDeferred populates the attributes and blocks of the receiver if its
+// parsing was deferred and hasn't happened yet, returning any diagnostics
+// from parsing it. It returns the same diagnostics on every call, so that
+// each caller accessing the body learns of any problems with it.
+func (b *Body) parseDeferred() hcl.Diagnostics {
+	d := b.deferred
+	if d == nil {
+		return nil
+	}
+
+	d.once.Do(func() {
+		parser := &parser{
+			peeker:               newPeeker(d.tokens, false),
+			pedanticConditionals: d.pedanticConditionals,
+			maxNodes:             d.maxNodes,
+			lazyBlockBodies:      true,
+			annotationPrefix:     d.annotationPrefix,
+			typeAnnotations:      d.typeAnnotations,
+		}
+		parser.Read() // the opening brace, which ParseBody expects to be behind it
+		body, diags := parseBodyWithBudget(parser, d.tokens, b.SrcRange.Filename, TokenCBrace)
+		b.Attributes = body.Attributes
+		b.Blocks = body.Blocks
+		d.diags = diags
+		d.tokens = nil
+	})
+	return d.diags
 }
 
 // Assert that *Body implements hcl.Body
 var assertBodyImplBody hcl.Body = &Body{}
 
 func (b *Body) walkChildNodes(w internalWalkFunc) {
+	b.parseDeferred()
 	w(b.Attributes)
 	w(b.Blocks)
 }
@@ -127,6 +178,7 @@ func (b *Body) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Bod
 	attrs := make(hcl.Attributes)
 	var blocks hcl.Blocks
 	var diags hcl.Diagnostics
+	diags = append(diags, b.parseDeferred()...)
 	hiddenAttrs := make(map[string]struct{})
 	hiddenBlocks := make(map[string]struct{})
 
@@ -245,9 +297,29 @@ func (b *Body) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Bod
 	}, remain, diags
 }
 
+// LeftoverBlocks returns the blocks in the receiver whose types were not
+// included in the schema given to the PartialContent call that produced it,
+// in the order they appear in the source. This supports
+// hcl.PartialContentWithLeftovers.
+//
+// For a body that was not produced by PartialContent, the result is all of
+// the blocks in the body.
+func (b *Body) LeftoverBlocks() hcl.Blocks {
+	b.parseDeferred()
+	var ret hcl.Blocks
+	for _, block := range b.Blocks {
+		if _, hidden := b.hiddenBlocks[block.Type]; hidden {
+			continue
+		}
+		ret = append(ret, block.AsHCLBlock())
+	}
+	return ret
+}
+
 func (b *Body) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
 	attrs := make(hcl.Attributes)
 	var diags hcl.Diagnostics
+	diags = append(diags, b.parseDeferred()...)
 
 	if len(b.Blocks) > 0 {
 		example := b.Blocks[0]
@@ -276,6 +348,92 @@ func (b *Body) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
 	return attrs, diags
 }
 
+// ToObjectValue evaluates each of the attributes in the body and returns an
+// object value with one attribute per attribute in the body. Any marks on
+// the individual attribute values are preserved on the corresponding
+// attributes of the result.
+//
+// If ignoreBlocks is true then any nested blocks in the body are ignored.
+// Otherwise, nested blocks produce an error diagnostic in the same way as
+// for JustAttributes. In the case of errors the result is still an object,
+// with cty.DynamicVal for any attributes that could not be evaluated.
+func (b *Body) ToObjectValue(ctx *hcl.EvalContext, ignoreBlocks bool) (cty.Value, hcl.Diagnostics) {
+	var attrs hcl.Attributes
+	var diags hcl.Diagnostics
+	if ignoreBlocks {
+		diags = append(diags, b.parseDeferred()...)
+		attrs = make(hcl.Attributes, len(b.Attributes))
+		for name, attr := range b.Attributes {
+			if _, hidden := b.hiddenAttrs[name]; hidden {
+				continue
+			}
+			attrs[name] = attr.AsHCLAttribute()
+		}
+	} else {
+		attrs, diags = b.JustAttributes()
+	}
+
+	if len(attrs) == 0 {
+		return cty.EmptyObjectVal, diags
+	}
+
+	// We evaluate in a predictable order so that the diagnostics are
+	// consistent between runs.
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	vals := make(map[string]cty.Value, len(attrs))
+	for _, name := range names {
+		val, moreDiags := attrs[name].Expr.Value(ctx)
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			val = cty.DynamicVal
+		}
+		vals[name] = val
+	}
+
+	return cty.ObjectVal(vals), diags
+}
+
+// ExplicitNulls evaluates each of the attributes in the body and returns a
+// map whose keys are the names of the attributes that are present, with
+// each value true if the corresponding attribute evaluated to null.
+//
+// This allows a caller to distinguish an attribute explicitly set to null
+// from one that was omitted altogether, which is not in the result at all.
+// An attribute whose value is unknown is reported as not null, because it
+// is not yet known whether it will be null. Attributes that could not be
+// evaluated due to errors are also omitted from the result. Nested blocks
+// are ignored.
+func (b *Body) ExplicitNulls(ctx *hcl.EvalContext) (map[string]bool, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	diags = append(diags, b.parseDeferred()...)
+	ret := make(map[string]bool, len(b.Attributes))
+
+	names := make([]string, 0, len(b.Attributes))
+	for name := range b.Attributes {
+		if _, hidden := b.hiddenAttrs[name]; hidden {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		val, moreDiags := b.Attributes[name].Expr.Value(ctx)
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			continue
+		}
+		ret[name] = val.IsKnown() && val.IsNull()
+	}
+
+	return ret, diags
+}
+
 func (b *Body) MissingItemRange() hcl.Range {
 	return hcl.Range{
 		Filename: b.SrcRange.Filename,
@@ -317,9 +475,21 @@ type Attribute struct {
 	Name string
 	Expr Expression
 
+	// TypeExpr is the type annotation given between the name and the
+	// equals sign, as in tags: map(string) = {}, if allowed by
+	// ParseOptions.TypeAnnotations. It is nil if there is no annotation.
+	//
+	// A type annotation is not evaluated, and so it is not visited when
+	// walking the attribute and its variables are not reported.
+	TypeExpr Expression
+
 	SrcRange    hcl.Range
 	NameRange   hcl.Range
 	EqualsRange hcl.Range
+
+	// Annotations are the directives extracted from comments preceding the
+	// attribute, if enabled by ParseOptions.AnnotationPrefix.
+	Annotations []Annotation
 }
 
 func (a *Attribute) walkChildNodes(w internalWalkFunc) {
@@ -377,6 +547,10 @@ type Block struct {
 	LabelRanges     []hcl.Range
 	OpenBraceRange  hcl.Range
 	CloseBraceRange hcl.Range
+
+	// Annotations are the directives extracted from comments preceding the
+	// block, if enabled by ParseOptions.AnnotationPrefix.
+	Annotations []Annotation
 }
 
 func (b *Block) walkChildNodes(w internalWalkFunc) {
@@ -45,6 +45,7 @@ func (b *Body) blocksAtPos(pos hcl.Pos, makeList bool) (list []*hcl.Block, inner
 
 Blocks:
 	for current != nil {
+		current.parseDeferred()
 		for _, block := range current.Blocks {
 			wholeRange := hcl.RangeBetween(block.TypeRange, block.CloseBraceRange)
 			if wholeRange.ContainsPos(pos) {
@@ -72,6 +73,7 @@ func (b *Body) outermostBlockAtPos(pos hcl.Pos) *Block {
 	// This is similar to blocksAtPos, but simpler because we know it only
 	// ever needs to search the first level of nested blocks.
 
+	b.parseDeferred()
 	for _, block := range b.Blocks {
 		wholeRange := hcl.RangeBetween(block.TypeRange, block.CloseBraceRange)
 		if wholeRange.ContainsPos(pos) {
@@ -98,6 +100,7 @@ func (b *Body) attributeAtPos(pos hcl.Pos) *Attribute {
 		searchBody = block.Body
 	}
 
+	searchBody.parseDeferred()
 	for _, attr := range searchBody.Attributes {
 		if attr.SrcRange.ContainsPos(pos) {
 			return attr
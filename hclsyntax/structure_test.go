@@ -6,6 +6,7 @@ package hclsyntax
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
@@ -528,3 +529,298 @@ func TestBodyJustAttributes(t *testing.T) {
 		})
 	}
 }
+
+func TestBodyToObjectValue(t *testing.T) {
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"secret": cty.StringVal("hunter2").Mark("sensitive"),
+		},
+	}
+
+	tests := map[string]struct {
+		src          string
+		ignoreBlocks bool
+		want         cty.Value
+		diagCount    int
+	}{
+		"empty": {
+			src:  ``,
+			want: cty.EmptyObjectVal,
+		},
+		"attributes": {
+			src: `
+name  = "web"
+count = 1 + 2
+tags  = { env = "prod" }
+`,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal("web"),
+				"count": cty.NumberIntVal(3),
+				"tags": cty.ObjectVal(map[string]cty.Value{
+					"env": cty.StringVal("prod"),
+				}),
+			}),
+		},
+		"marks preserved": {
+			src: `
+name     = "web"
+password = secret
+`,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name":     cty.StringVal("web"),
+				"password": cty.StringVal("hunter2").Mark("sensitive"),
+			}),
+		},
+		"blocks not allowed": {
+			src: `
+name = "web"
+nested {}
+`,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+			}),
+			diagCount: 1,
+		},
+		"blocks ignored": {
+			src: `
+name = "web"
+nested {}
+`,
+			ignoreBlocks: true,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("web"),
+			}),
+		},
+		"evaluation error": {
+			src: `
+name  = "web"
+other = nope
+`,
+			want: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal("web"),
+				"other": cty.DynamicVal,
+			}),
+			diagCount: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfig([]byte(test.src), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags)
+			}
+
+			got, diags := f.Body.(*Body).ToObjectValue(ctx, test.ignoreBlocks)
+			if len(diags) != test.diagCount {
+				t.Errorf("wrong number of diagnostics %d; want %d", len(diags), test.diagCount)
+				for _, diag := range diags {
+					t.Logf(" - %s", diag.Error())
+				}
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBodyExplicitNulls(t *testing.T) {
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"unknown": cty.UnknownVal(cty.String),
+			"secret":  cty.NullVal(cty.String).Mark("sensitive"),
+		},
+	}
+
+	tests := map[string]struct {
+		src       string
+		want      map[string]bool
+		diagCount int
+	}{
+		"empty": {
+			src:  ``,
+			want: map[string]bool{},
+		},
+		"mixed": {
+			src: `
+a = null
+b = "b"
+c = unknown
+d = secret
+e = true ? null : "e"
+
+block {
+  f = null
+}
+`,
+			want: map[string]bool{
+				"a": true,
+				"b": false,
+				"c": false,
+				"d": true,
+				"e": true,
+			},
+		},
+		"evaluation error": {
+			src: `
+a = null
+b = nope
+`,
+			want: map[string]bool{
+				"a": true,
+			},
+			diagCount: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfig([]byte(test.src), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags)
+			}
+
+			got, diags := f.Body.(*Body).ExplicitNulls(ctx)
+			if len(diags) != test.diagCount {
+				t.Errorf("wrong number of diagnostics %d; want %d", len(diags), test.diagCount)
+				for _, diag := range diags {
+					t.Logf(" - %s", diag.Error())
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPartialContentWithLeftovers(t *testing.T) {
+	parse := func(src string) hcl.Body {
+		t.Helper()
+		f, diags := ParseConfig([]byte(src), "test.hcl", hcl.InitialPos)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected parse diagnostics: %s", diags.Error())
+		}
+		return f.Body
+	}
+	schema := &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "name"},
+		},
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "known"},
+		},
+	}
+
+	tests := map[string]struct {
+		body hcl.Body
+		want []string
+	}{
+		"no leftovers": {
+			parse(`
+name = "a"
+known {}
+`),
+			nil,
+		},
+		"leftovers": {
+			parse(`
+plugin "a" {}
+known {}
+other {}
+plugin "b" "c" {}
+extra = true
+`),
+			[]string{"plugin a", "other", "plugin b c"},
+		},
+		"merged": {
+			hcl.MergeBodies([]hcl.Body{
+				parse("plugin \"a\" {}\nknown {}\n"),
+				parse("other {}\n"),
+			}),
+			[]string{"plugin a", "other"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			content, leftovers, diags := hcl.PartialContentWithLeftovers(test.body, schema)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected diagnostics: %s", diags.Error())
+			}
+			if content == nil {
+				t.Fatalf("content is nil")
+			}
+
+			var got []string
+			for _, block := range leftovers {
+				got = append(got, strings.Join(append([]string{block.Type}, block.Labels...), " "))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong leftover blocks\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUnrecognizedBlockTypes(t *testing.T) {
+	parse := func(src string) hcl.Body {
+		t.Helper()
+		f, diags := ParseConfig([]byte(src), "test.hcl", hcl.InitialPos)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected parse diagnostics: %s", diags.Error())
+		}
+		return f.Body
+	}
+	schema := &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "name"},
+		},
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "known"},
+		},
+	}
+
+	tests := map[string]struct {
+		body hcl.Body
+		want []string
+	}{
+		"none": {
+			parse(`
+name = "a"
+known {}
+`),
+			nil,
+		},
+		"distinct and sorted": {
+			parse(`
+plugin "a" {}
+known {}
+other {}
+plugin "b" "c" {}
+extra = true
+`),
+			[]string{"other", "plugin"},
+		},
+		"merged": {
+			hcl.MergeBodies([]hcl.Body{
+				parse("plugin \"a\" {}\nknown {}\n"),
+				parse("other {}\nplugin \"b\" {}\n"),
+			}),
+			[]string{"other", "plugin"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, diags := hcl.UnrecognizedBlockTypes(test.body, schema)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected diagnostics: %s", diags.Error())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// ValidateTemplateInterpolations evaluates each interpolation sequence in the
+// given template expression and returns an error diagnostic for each one that
+// produces null, or also for each one that produces an empty string if
+// disallowEmpty is set. The diagnostics are anchored at the interpolated
+// expression, so that the caller can see which part of the template is
+// missing a value.
+//
+// The expression may be either a TemplateExpr or a TemplateWrapExpr, as
+// produced for a template consisting only of a single interpolation. For any
+// other expression ValidateTemplateInterpolations returns no diagnostics.
+//
+// Within an if directive only the interpolations of the selected clause are
+// checked, because the other clause does not contribute to the result.
+// Interpolations within for directives are not checked, because there is no
+// single value for them. Interpolations whose values are unknown, or that
+// cannot be evaluated at all, are left for the evaluation of the template
+// itself to deal with, except that any error diagnostics are returned.
+func ValidateTemplateInterpolations(expr Expression, ctx *hcl.EvalContext, disallowEmpty bool) hcl.Diagnostics {
+	switch expr := expr.(type) {
+	case *TemplateExpr:
+		var diags hcl.Diagnostics
+		for _, part := range expr.Parts {
+			diags = append(diags, validateTemplatePart(part, ctx, disallowEmpty)...)
+		}
+		return diags
+	case *TemplateWrapExpr:
+		return validateTemplateInterpolation(expr.Wrapped, ctx, disallowEmpty)
+	default:
+		return nil
+	}
+}
+
+func validateTemplatePart(part Expression, ctx *hcl.EvalContext, disallowEmpty bool) hcl.Diagnostics {
+	switch part := part.(type) {
+	case *LiteralValueExpr:
+		if part.Val.Type() == cty.String && !part.Val.IsNull() {
+			// Literal text between the interpolation sequences.
+			return nil
+		}
+	case *TemplateJoinExpr:
+		return nil
+	case *ConditionalExpr:
+		if !isTemplateIfDirective(part) {
+			break
+		}
+		cond, diags := part.Condition.Value(ctx)
+		if diags.HasErrors() {
+			return diags
+		}
+		cond, _ = cond.Unmark()
+		cond, err := convert.Convert(cond, cty.Bool)
+		if err != nil || !cond.IsKnown() || cond.IsNull() {
+			// The evaluation of the template will report a problem with the
+			// condition, if any.
+			return diags
+		}
+		if cond.True() {
+			return append(diags, ValidateTemplateInterpolations(part.TrueResult, ctx, disallowEmpty)...)
+		}
+		return append(diags, ValidateTemplateInterpolations(part.FalseResult, ctx, disallowEmpty)...)
+	}
+	return validateTemplateInterpolation(part, ctx, disallowEmpty)
+}
+
+// isTemplateIfDirective returns true if the given conditional expression was
+// produced from an if directive in a template, rather than from a conditional
+// expression in an interpolation sequence. Only the range of a directive
+// begins before its condition, because it includes the %{ if introducer.
+func isTemplateIfDirective(expr *ConditionalExpr) bool {
+	_, trueTemplate := expr.TrueResult.(*TemplateExpr)
+	_, falseTemplate := expr.FalseResult.(*TemplateExpr)
+	return trueTemplate && falseTemplate && expr.SrcRange.Start.Byte < expr.Condition.Range().Start.Byte
+}
+
+func validateTemplateInterpolation(expr Expression, ctx *hcl.EvalContext, disallowEmpty bool) hcl.Diagnostics {
+	val, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return diags
+	}
+	val, _ = val.Unmark()
+	if !val.IsKnown() {
+		return diags
+	}
+
+	rng := expr.Range()
+	if val.IsNull() {
+		return append(diags, &hcl.Diagnostic{
+			Severity:    hcl.DiagError,
+			Summary:     "Null value in template",
+			Detail:      "This interpolation produced a null value, which is not allowed here.",
+			Subject:     &rng,
+			Expression:  expr,
+			EvalContext: ctx,
+		})
+	}
+	if !disallowEmpty {
+		return diags
+	}
+	str, err := convert.Convert(val, cty.String)
+	if err != nil || !str.IsKnown() {
+		return diags
+	}
+	if str.AsString() == "" {
+		diags = append(diags, &hcl.Diagnostic{
+			Severity:    hcl.DiagError,
+			Summary:     "Empty string in template",
+			Detail:      "This interpolation produced an empty string, which is not allowed here.",
+			Subject:     &rng,
+			Expression:  expr,
+			EvalContext: ctx,
+		})
+	}
+	return diags
+}
@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestValidateTemplateInterpolations(t *testing.T) {
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"name":    cty.StringVal("web"),
+			"empty":   cty.StringVal(""),
+			"nothing": cty.NullVal(cty.String),
+			"num":     cty.NumberIntVal(0),
+			"unknown": cty.UnknownVal(cty.String),
+			"secret":  cty.StringVal("").Mark("sensitive"),
+			"list":    cty.ListVal([]cty.Value{cty.StringVal("")}),
+		},
+	}
+
+	type diag struct {
+		Summary string
+		Subject string
+	}
+	tests := map[string]struct {
+		src           string
+		disallowEmpty bool
+		want          []diag
+	}{
+		"all present": {
+			`"${name}-${num}"`,
+			true,
+			nil,
+		},
+		"null": {
+			`"${name}-${nothing}"`,
+			false,
+			[]diag{{"Null value in template", "nothing"}},
+		},
+		"empty allowed": {
+			`"${name}-${empty}"`,
+			false,
+			nil,
+		},
+		"empty disallowed": {
+			`"${name}-${empty}-${secret}"`,
+			true,
+			[]diag{
+				{"Empty string in template", "empty"},
+				{"Empty string in template", "secret"},
+			},
+		},
+		"null and empty": {
+			`"${nothing}${empty}"`,
+			true,
+			[]diag{
+				{"Null value in template", "nothing"},
+				{"Empty string in template", "empty"},
+			},
+		},
+		"null literal": {
+			`"x-${null}"`,
+			false,
+			[]diag{{"Null value in template", "null"}},
+		},
+		"unknown": {
+			`"${unknown}-x"`,
+			true,
+			nil,
+		},
+		"single interpolation": {
+			`"${empty}"`,
+			true,
+			[]diag{{"Empty string in template", "empty"}},
+		},
+		"conditional interpolation": {
+			`"x-${num == 0 ? empty : name}"`,
+			true,
+			[]diag{{"Empty string in template", "num == 0 ? empty : name"}},
+		},
+		"if directive selected clause": {
+			`"x-%{ if num == 0 }${empty}%{ else }${nothing}%{ endif }"`,
+			true,
+			[]diag{{"Empty string in template", "empty"}},
+		},
+		"if directive without else": {
+			`"x-%{ if num != 0 }${empty}%{ endif }"`,
+			true,
+			nil,
+		},
+		"for directive": {
+			`"x-%{ for v in list }${v}%{ endfor }"`,
+			true,
+			nil,
+		},
+		"evaluation error": {
+			`"x-${missing}"`,
+			true,
+			[]diag{{"Unknown variable", "missing"}},
+		},
+		"not a template": {
+			`empty`,
+			true,
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := []byte(test.src)
+			expr, diags := ParseExpression(src, "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags)
+			}
+
+			diags = ValidateTemplateInterpolations(expr, ctx, test.disallowEmpty)
+			var got []diag
+			for _, d := range diags {
+				got = append(got, diag{d.Summary, string(d.Subject.SliceBytes(src))})
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("wrong diagnostics\n%s", diff)
+			}
+		})
+	}
+}
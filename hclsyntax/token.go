@@ -126,6 +126,10 @@ type tokenAccum struct {
 	Pos       hcl.Pos
 	Tokens    []Token
 	StartByte int
+
+	// Emit, if set, receives each token as it is produced instead of it
+	// being appended to Tokens.
+	Emit func(Token)
 }
 
 func (f *tokenAccum) emitToken(ty TokenType, startOfs, endOfs int) {
@@ -152,7 +156,7 @@ func (f *tokenAccum) emitToken(ty TokenType, startOfs, endOfs int) {
 
 	f.Pos = end
 
-	f.Tokens = append(f.Tokens, Token{
+	tok := Token{
 		Type:  ty,
 		Bytes: f.Bytes[startOfs:endOfs],
 		Range: hcl.Range{
@@ -160,7 +164,12 @@ func (f *tokenAccum) emitToken(ty TokenType, startOfs, endOfs int) {
 			Start:    start,
 			End:      end,
 		},
-	})
+	}
+	if f.Emit != nil {
+		f.Emit(tok)
+		return
+	}
+	f.Tokens = append(f.Tokens, tok)
 }
 
 type heredocInProgress struct {
@@ -185,138 +194,150 @@ func tokenOpensFlushHeredoc(tok Token) bool {
 // repetition of the same information.
 func checkInvalidTokens(tokens Tokens) hcl.Diagnostics {
 	var diags hcl.Diagnostics
+	var checker invalidTokenChecker
+	for _, tok := range tokens {
+		diags = append(diags, checker.check(tok)...)
+	}
+	return diags
+}
 
-	toldBitwise := 0
-	toldExponent := 0
-	toldBacktick := 0
-	toldApostrophe := 0
-	toldSemicolon := 0
-	toldTabs := 0
-	toldBadUTF8 := 0
+// invalidTokenChecker is the incremental form of checkInvalidTokens, which
+// remembers which problems it has already reported so that it can limit the
+// repetition of the same information across a sequence of calls to check.
+type invalidTokenChecker struct {
+	toldBitwise    int
+	toldExponent   int
+	toldBacktick   int
+	toldApostrophe int
+	toldSemicolon  int
+	toldTabs       int
+	toldBadUTF8    int
+}
 
-	for _, tok := range tokens {
-		tokRange := func() *hcl.Range {
-			r := tok.Range
-			return &r
-		}
+func (c *invalidTokenChecker) check(tok Token) hcl.Diagnostics {
+	var diags hcl.Diagnostics
 
-		switch tok.Type {
-		case TokenBitwiseAnd, TokenBitwiseOr, TokenBitwiseXor, TokenBitwiseNot:
-			if toldBitwise < 4 {
-				var suggestion string
-				switch tok.Type {
-				case TokenBitwiseAnd:
-					suggestion = " Did you mean boolean AND (\"&&\")?"
-				case TokenBitwiseOr:
-					suggestion = " Did you mean boolean OR (\"||\")?"
-				case TokenBitwiseNot:
-					suggestion = " Did you mean boolean NOT (\"!\")?"
-				}
-
-				diags = append(diags, &hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  "Unsupported operator",
-					Detail:   fmt.Sprintf("Bitwise operators are not supported.%s", suggestion),
-					Subject:  tokRange(),
-				})
-				toldBitwise++
-			}
-		case TokenStarStar:
-			if toldExponent < 1 {
-				diags = append(diags, &hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  "Unsupported operator",
-					Detail:   "\"**\" is not a supported operator. Exponentiation is not supported as an operator.",
-					Subject:  tokRange(),
-				})
-
-				toldExponent++
-			}
-		case TokenBacktick:
-			// Only report for alternating (even) backticks, so we won't report both start and ends of the same
-			// backtick-quoted string.
-			if (toldBacktick % 2) == 0 {
-				diags = append(diags, &hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  "Invalid character",
-					Detail:   "The \"`\" character is not valid. To create a multi-line string, use the \"heredoc\" syntax, like \"<<EOT\".",
-					Subject:  tokRange(),
-				})
-			}
-			if toldBacktick <= 2 {
-				toldBacktick++
-			}
-		case TokenApostrophe:
-			if (toldApostrophe % 2) == 0 {
-				newDiag := &hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  "Invalid character",
-					Detail:   "Single quotes are not valid. Use double quotes (\") to enclose strings.",
-					Subject:  tokRange(),
-				}
-				diags = append(diags, newDiag)
-			}
-			if toldApostrophe <= 2 {
-				toldApostrophe++
-			}
-		case TokenSemicolon:
-			if toldSemicolon < 1 {
-				diags = append(diags, &hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  "Invalid character",
-					Detail:   "The \";\" character is not valid. Use newlines to separate arguments and blocks, and commas to separate items in collection values.",
-					Subject:  tokRange(),
-				})
-
-				toldSemicolon++
-			}
-		case TokenTabs:
-			if toldTabs < 1 {
-				diags = append(diags, &hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  "Invalid character",
-					Detail:   "Tab characters may not be used. The recommended indentation style is two spaces per indent.",
-					Subject:  tokRange(),
-				})
-
-				toldTabs++
-			}
-		case TokenBadUTF8:
-			if toldBadUTF8 < 1 {
-				diags = append(diags, &hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  "Invalid character encoding",
-					Detail:   "All input files must be UTF-8 encoded. Ensure that UTF-8 encoding is selected in your editor.",
-					Subject:  tokRange(),
-				})
-
-				toldBadUTF8++
+	tokRange := func() *hcl.Range {
+		r := tok.Range
+		return &r
+	}
+
+	switch tok.Type {
+	case TokenBitwiseAnd, TokenBitwiseOr, TokenBitwiseXor, TokenBitwiseNot:
+		if c.toldBitwise < 4 {
+			var suggestion string
+			switch tok.Type {
+			case TokenBitwiseAnd:
+				suggestion = " Did you mean boolean AND (\"&&\")?"
+			case TokenBitwiseOr:
+				suggestion = " Did you mean boolean OR (\"||\")?"
+			case TokenBitwiseNot:
+				suggestion = " Did you mean boolean NOT (\"!\")?"
 			}
-		case TokenQuotedNewline:
+
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported operator",
+				Detail:   fmt.Sprintf("Bitwise operators are not supported.%s", suggestion),
+				Subject:  tokRange(),
+			})
+			c.toldBitwise++
+		}
+	case TokenStarStar:
+		if c.toldExponent < 1 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported operator",
+				Detail:   "\"**\" is not a supported operator. Exponentiation is not supported as an operator.",
+				Subject:  tokRange(),
+			})
+
+			c.toldExponent++
+		}
+	case TokenBacktick:
+		// Only report for alternating (even) backticks, so we won't report both start and ends of the same
+		// backtick-quoted string.
+		if (c.toldBacktick % 2) == 0 {
 			diags = append(diags, &hcl.Diagnostic{
 				Severity: hcl.DiagError,
-				Summary:  "Invalid multi-line string",
-				Detail:   "Quoted strings may not be split over multiple lines. To produce a multi-line string, either use the \\n escape to represent a newline character or use the \"heredoc\" multi-line template syntax.",
+				Summary:  "Invalid character",
+				Detail:   "The \"`\" character is not valid. To create a multi-line string, use the \"heredoc\" syntax, like \"<<EOT\".",
 				Subject:  tokRange(),
 			})
-		case TokenInvalid:
-			chars := string(tok.Bytes)
-			switch chars {
-			case "“", "”":
-				diags = append(diags, &hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  "Invalid character",
-					Detail:   "\"Curly quotes\" are not valid here. These can sometimes be inadvertently introduced when sharing code via documents or discussion forums. It might help to replace the character with a \"straight quote\".",
-					Subject:  tokRange(),
-				})
-			default:
-				diags = append(diags, &hcl.Diagnostic{
-					Severity: hcl.DiagError,
-					Summary:  "Invalid character",
-					Detail:   "This character is not used within the language.",
-					Subject:  tokRange(),
-				})
+		}
+		if c.toldBacktick <= 2 {
+			c.toldBacktick++
+		}
+	case TokenApostrophe:
+		if (c.toldApostrophe % 2) == 0 {
+			newDiag := &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid character",
+				Detail:   "Single quotes are not valid. Use double quotes (\") to enclose strings.",
+				Subject:  tokRange(),
 			}
+			diags = append(diags, newDiag)
+		}
+		if c.toldApostrophe <= 2 {
+			c.toldApostrophe++
+		}
+	case TokenSemicolon:
+		if c.toldSemicolon < 1 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid character",
+				Detail:   "The \";\" character is not valid. Use newlines to separate arguments and blocks, and commas to separate items in collection values.",
+				Subject:  tokRange(),
+			})
+
+			c.toldSemicolon++
+		}
+	case TokenTabs:
+		if c.toldTabs < 1 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid character",
+				Detail:   "Tab characters may not be used. The recommended indentation style is two spaces per indent.",
+				Subject:  tokRange(),
+			})
+
+			c.toldTabs++
+		}
+	case TokenBadUTF8:
+		if c.toldBadUTF8 < 1 {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid character encoding",
+				Detail:   "All input files must be UTF-8 encoded. Ensure that UTF-8 encoding is selected in your editor.",
+				Subject:  tokRange(),
+			})
+
+			c.toldBadUTF8++
+		}
+	case TokenQuotedNewline:
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid multi-line string",
+			Detail:   "Quoted strings may not be split over multiple lines. To produce a multi-line string, either use the \\n escape to represent a newline character or use the \"heredoc\" multi-line template syntax.",
+			Subject:  tokRange(),
+		})
+	case TokenInvalid:
+		chars := string(tok.Bytes)
+		switch chars {
+		case "“", "”":
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid character",
+				Detail:   "\"Curly quotes\" are not valid here. These can sometimes be inadvertently introduced when sharing code via documents or discussion forums. It might help to replace the character with a \"straight quote\".",
+				Subject:  tokRange(),
+			})
+		default:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid character",
+				Detail:   "This character is not used within the language.",
+				Subject:  tokRange(),
+			})
 		}
 	}
 	return diags
@@ -0,0 +1,51 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// ValidateUniqueBlocks checks that there is at most one block of each of the
+// given types directly in the given body, returning an error diagnostic for
+// each additional block of any of those types.
+//
+// Each diagnostic refers to the header of the duplicate block, with the
+// header of the first block of the same type as its context. This does not
+// recurse into nested blocks, and it works on the raw body so that it can
+// be used without a full schema.
+func ValidateUniqueBlocks(body *Body, typeNames ...string) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	if body == nil {
+		return diags
+	}
+	diags = append(diags, body.parseDeferred()...)
+
+	unique := make(map[string]*Block, len(typeNames))
+	for _, typeName := range typeNames {
+		unique[typeName] = nil
+	}
+
+	for _, block := range body.Blocks {
+		first, isUnique := unique[block.Type]
+		if !isUnique {
+			continue
+		}
+		if first == nil {
+			unique[block.Type] = block
+			continue
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  fmt.Sprintf("Duplicate %s block", block.Type),
+			Detail:   fmt.Sprintf("A %s block was already defined at %s. Only one %s block is allowed.", block.Type, first.DefRange(), block.Type),
+			Subject:  block.DefRange().Ptr(),
+			Context:  first.DefRange().Ptr(),
+		})
+	}
+
+	return diags
+}
@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestValidateUniqueBlocks(t *testing.T) {
+	tests := map[string]struct {
+		src string
+
+		// Each diagnostic is described by the ranges of its subject and
+		// context.
+		want [][2]hcl.Range
+	}{
+		"empty": {
+			``,
+			nil,
+		},
+		"singletons": {
+			`
+backend "s3" {}
+provider "a" {}
+provider "b" {}
+`,
+			nil,
+		},
+		"duplicate": {
+			`
+backend "s3" {}
+backend "gcs" {}
+`,
+			[][2]hcl.Range{
+				{
+					{
+						Start: hcl.Pos{Line: 3, Column: 1, Byte: 17},
+						End:   hcl.Pos{Line: 3, Column: 14, Byte: 30},
+					},
+					{
+						Start: hcl.Pos{Line: 2, Column: 1, Byte: 1},
+						End:   hcl.Pos{Line: 2, Column: 13, Byte: 13},
+					},
+				},
+			},
+		},
+		"several duplicates": {
+			`
+locals {}
+backend "s3" {}
+locals {}
+backend "gcs" {}
+`,
+			[][2]hcl.Range{
+				{
+					{
+						Start: hcl.Pos{Line: 4, Column: 1, Byte: 27},
+						End:   hcl.Pos{Line: 4, Column: 7, Byte: 33},
+					},
+					{
+						Start: hcl.Pos{Line: 2, Column: 1, Byte: 1},
+						End:   hcl.Pos{Line: 2, Column: 7, Byte: 7},
+					},
+				},
+				{
+					{
+						Start: hcl.Pos{Line: 5, Column: 1, Byte: 37},
+						End:   hcl.Pos{Line: 5, Column: 14, Byte: 50},
+					},
+					{
+						Start: hcl.Pos{Line: 3, Column: 1, Byte: 11},
+						End:   hcl.Pos{Line: 3, Column: 13, Byte: 23},
+					},
+				},
+			},
+		},
+		"nested blocks ignored": {
+			`
+backend "s3" {
+  backend "nested" {}
+}
+`,
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfig([]byte(test.src), "test.hcl", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags)
+			}
+
+			diags = ValidateUniqueBlocks(f.Body.(*Body), "backend", "locals")
+			if len(diags) != len(test.want) {
+				t.Fatalf("wrong number of diagnostics %d; want %d\n%s", len(diags), len(test.want), diags)
+			}
+			for i, diag := range diags {
+				if diag.Severity != hcl.DiagError {
+					t.Errorf("diagnostic %d is not an error", i)
+				}
+				wantSubject, wantContext := test.want[i][0], test.want[i][1]
+				wantSubject.Filename = "test.hcl"
+				wantContext.Filename = "test.hcl"
+				if got := *diag.Subject; got != wantSubject {
+					t.Errorf("wrong subject for diagnostic %d\ngot:  %#v\nwant: %#v", i, got, wantSubject)
+				}
+				if got := *diag.Context; got != wantContext {
+					t.Errorf("wrong context for diagnostic %d\ngot:  %#v\nwant: %#v", i, got, wantContext)
+				}
+			}
+		})
+	}
+}
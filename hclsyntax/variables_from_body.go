@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// VariablesFromBody evaluates each of the top-level attributes of the given
+// body and returns their values in a map keyed by attribute name, which is
+// suitable for use as the Variables of a new hcl.EvalContext. This is the
+// usual way to load a file of name = value definitions for use as a scope.
+//
+// Each attribute is evaluated in a child of the given context, in which the
+// attributes of the body are available as variables. An attribute may
+// therefore refer to other attributes in the same body by name, and so the
+// attributes are evaluated in dependency order rather than source order.
+// Attributes that depend on themselves, directly or indirectly, produce an
+// error diagnostic and have the value cty.DynamicVal in the result.
+//
+// The body must contain only attributes. Any blocks are reported as errors,
+// as with JustAttributes.
+func VariablesFromBody(body *Body, ctx *hcl.EvalContext) (map[string]cty.Value, hcl.Diagnostics) {
+	attrs, diags := body.JustAttributes()
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return attrs[names[i]].Range.Start.Byte < attrs[names[j]].Range.Start.Byte
+	})
+
+	vals := make(map[string]cty.Value, len(attrs))
+	evalCtx := ctx.NewChild()
+	evalCtx.Variables = vals
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(attrs))
+	inCycle := make(map[string]bool)
+
+	var visit func(name string, stack []string)
+	visit = func(name string, stack []string) {
+		switch state[name] {
+		case visited:
+			return
+		case visiting:
+			var cycle []string
+			for i, other := range stack {
+				if other == name {
+					cycle = stack[i:]
+					break
+				}
+			}
+			for _, other := range cycle {
+				inCycle[other] = true
+			}
+			attr := attrs[name]
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Variable definition cycle",
+				Detail:   fmt.Sprintf("The value of %q depends on itself: %s.", name, strings.Join(append(cycle, name), " -> ")),
+				Subject:  attr.NameRange.Ptr(),
+				Context:  attr.Range.Ptr(),
+			})
+			return
+		}
+
+		state[name] = visiting
+		stack = append(stack, name)
+		attr := attrs[name]
+		for _, traversal := range attr.Expr.Variables() {
+			if dep := traversal.RootName(); attrs[dep] != nil {
+				visit(dep, stack)
+			}
+		}
+		state[name] = visited
+
+		if inCycle[name] {
+			vals[name] = cty.DynamicVal
+			return
+		}
+		val, valDiags := attr.Expr.Value(evalCtx)
+		diags = append(diags, valDiags...)
+		vals[name] = val
+	}
+
+	for _, name := range names {
+		visit(name, nil)
+	}
+
+	return vals, diags
+}
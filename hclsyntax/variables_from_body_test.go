@@ -0,0 +1,127 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclsyntax
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestVariablesFromBody(t *testing.T) {
+	tests := map[string]struct {
+		src       string
+		want      map[string]cty.Value
+		wantDiags []string
+	}{
+		"independent": {
+			`
+a = "hello"
+b = 2
+`,
+			map[string]cty.Value{
+				"a": cty.StringVal("hello"),
+				"b": cty.NumberIntVal(2),
+			},
+			nil,
+		},
+		"forward and backward references": {
+			`
+c = "${b}!"
+a = "hello"
+b = "${a}, ${who}"
+`,
+			map[string]cty.Value{
+				"a": cty.StringVal("hello"),
+				"b": cty.StringVal("hello, world"),
+				"c": cty.StringVal("hello, world!"),
+			},
+			nil,
+		},
+		"shadows outer variable": {
+			`
+who = "there"
+greeting = "hi ${who}"
+`,
+			map[string]cty.Value{
+				"who":      cty.StringVal("there"),
+				"greeting": cty.StringVal("hi there"),
+			},
+			nil,
+		},
+		"self reference": {
+			`
+a = a + 1
+`,
+			map[string]cty.Value{
+				"a": cty.DynamicVal,
+			},
+			[]string{
+				`test.hcl:2,1-2: Variable definition cycle; The value of "a" depends on itself: a -> a.`,
+			},
+		},
+		"indirect cycle": {
+			`
+a = b
+b = c
+c = a
+d = 1
+`,
+			map[string]cty.Value{
+				"a": cty.DynamicVal,
+				"b": cty.DynamicVal,
+				"c": cty.DynamicVal,
+				"d": cty.NumberIntVal(1),
+			},
+			[]string{
+				`test.hcl:2,1-2: Variable definition cycle; The value of "a" depends on itself: a -> b -> c -> a.`,
+			},
+		},
+		"block": {
+			`
+a = 1
+b {}
+`,
+			map[string]cty.Value{
+				"a": cty.NumberIntVal(1),
+			},
+			[]string{
+				`test.hcl:3,1-2: Unexpected "b" block; Blocks are not allowed here.`,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfig([]byte(test.src), "test.hcl", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags.Error())
+			}
+			ctx := &hcl.EvalContext{
+				Variables: map[string]cty.Value{
+					"who": cty.StringVal("world"),
+				},
+			}
+
+			got, diags := VariablesFromBody(f.Body.(*Body), ctx)
+			var gotDiags []string
+			for _, diag := range diags {
+				gotDiags = append(gotDiags, diag.Error())
+			}
+			if !cmp.Equal(gotDiags, test.wantDiags) {
+				t.Errorf("wrong diagnostics\n%s", cmp.Diff(test.wantDiags, gotDiags))
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d variables; want %d\n%#v", len(got), len(test.want), got)
+			}
+			for name, want := range test.want {
+				if got := got[name]; !got.RawEquals(want) {
+					t.Errorf("wrong value for %q\ngot:  %#v\nwant: %#v", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -6,6 +6,8 @@ package hclwrite
 import (
 	"bytes"
 	"io"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 )
 
 type File struct {
@@ -32,6 +34,130 @@ func (f *File) Body() *Body {
 	return f.body.content.(*Body)
 }
 
+// RemoveAttributeAtPath removes the attribute at the given path, where all
+// but the last element of the path are block type names to descend into and
+// the last element is the name of the attribute to remove. For example,
+// the path ["block", "settings", "timeout"] removes the "timeout" attribute
+// from a "settings" block nested inside a "block" block.
+//
+// The block labels, if any, are not considered when matching blocks. If a
+// body has more than one block of a given type then only the first one is
+// searched, even if the attribute is present in one of the others.
+//
+// Returns true if it removed an attribute, or false if there is no attribute
+// at the given path.
+func (f *File) RemoveAttributeAtPath(path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	body := f.Body()
+	for _, typeName := range path[:len(path)-1] {
+		var found *Block
+		for _, block := range body.Blocks() {
+			if block.Type() == typeName {
+				found = block
+				break
+			}
+		}
+		if found == nil {
+			return false
+		}
+		body = found.Body()
+	}
+
+	return body.RemoveAttribute(path[len(path)-1]) != nil
+}
+
+// RemoveComments removes all of the comments from the file, including both
+// single-line comments using # or // and block comments using /* */,
+// while preserving all of the other content.
+//
+// Comments that occupy whole lines are removed along with their lines, and
+// a blank line that separated such comments from preceding content is also
+// removed so that removing a comment never leaves two consecutive blank
+// lines. Single-line comments at the end of a line that has other content
+// are replaced by a newline, so the following content stays on its own line.
+//
+// Content that merely resembles a comment inside a string literal or
+// heredoc template is not affected.
+func (f *File) RemoveComments() {
+	remove := make(map[*Token]struct{})
+	toks := f.inTree.children.BuildTokens(nil)
+
+	atLineStart := true
+	prevBlank := true // the start of the file behaves as a blank line
+	removedLines := false
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		switch tok.Type {
+		case hclsyntax.TokenComment:
+			singleLine := len(tok.Bytes) > 0 && tok.Bytes[len(tok.Bytes)-1] == '\n'
+			switch {
+			case singleLine && !atLineStart:
+				// The comment also serves as the newline for the content
+				// before it, so we must leave a newline in its place.
+				tok.Type = hclsyntax.TokenNewline
+				tok.Bytes = []byte{'\n'}
+				atLineStart = true
+				prevBlank = false
+				removedLines = false
+			case atLineStart:
+				remove[tok] = struct{}{}
+				if !singleLine && i+1 < len(toks) && toks[i+1].Type == hclsyntax.TokenNewline {
+					// A block comment alone on its line takes the
+					// newline after it too.
+					i++
+					remove[toks[i]] = struct{}{}
+				}
+				removedLines = true
+			default:
+				remove[tok] = struct{}{}
+			}
+		case hclsyntax.TokenNewline:
+			if atLineStart {
+				if removedLines && prevBlank {
+					remove[tok] = struct{}{}
+				}
+				prevBlank = true
+			} else {
+				prevBlank = false
+			}
+			atLineStart = true
+			removedLines = false
+		default:
+			atLineStart = false
+			removedLines = false
+		}
+	}
+
+	if len(remove) == 0 {
+		return
+	}
+
+	filter := func(toks Tokens) Tokens {
+		ret := make(Tokens, 0, len(toks))
+		for _, tok := range toks {
+			if _, removed := remove[tok]; !removed {
+				ret = append(ret, tok)
+			}
+		}
+		return ret
+	}
+	var walk internalWalkFunc
+	walk = func(n *node) {
+		switch c := n.content.(type) {
+		case *comments:
+			c.tokens = filter(c.tokens)
+		case Tokens:
+			n.content = filter(c)
+		default:
+			c.walkChildNodes(walk)
+		}
+	}
+	f.inTree.walkChildNodes(walk)
+}
+
 // WriteTo writes the tokens underlying the receiving file to the given writer.
 //
 // The tokens first have a simple formatting pass applied that adjusts only
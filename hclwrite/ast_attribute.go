@@ -4,7 +4,13 @@
 package hclwrite
 
 import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
 )
 
 type Attribute struct {
@@ -49,3 +55,157 @@ func (a *Attribute) init(name string, expr *Expression) {
 func (a *Attribute) Expr() *Expression {
 	return a.expr.content.(*Expression)
 }
+
+// ToHeredoc rewrites the attribute's expression from a quoted string template
+// into an equivalent heredoc template using the given marker, converting any
+// escape sequences in the literal parts of the template as needed. Any
+// interpolation sequences and template directives are preserved as-is.
+//
+// A heredoc template always ends with a newline, and cannot contain
+// carriage returns or other non-printable characters literally, so the
+// conversion is refused if the string doesn't end with a newline or
+// contains such characters. It is also refused if the expression is not a
+// quoted string template, if the marker is not a valid identifier, or if the
+// marker also appears alone on a line of the string.
+//
+// Returns true if the expression was rewritten, or false if it was left
+// unchanged.
+func (a *Attribute) ToHeredoc(marker string) bool {
+	if !hclsyntax.ValidIdentifier(marker) {
+		return false
+	}
+
+	toks := a.Expr().BuildTokens(nil)
+	if len(toks) < 2 || toks[0].Type != hclsyntax.TokenOQuote || toks[len(toks)-1].Type != hclsyntax.TokenCQuote {
+		return false
+	}
+	lits := templateLiteralTokens(toks[1:len(toks)-1], hclsyntax.TokenQuotedLit)
+	if len(lits) == 0 || lits[len(lits)-1] != toks[len(toks)-2] {
+		// The final part must be literal, so that it can end with the
+		// newline that precedes the closing marker.
+		return false
+	}
+
+	vals := make([]string, len(lits))
+	for i, tok := range lits {
+		val, diags := hclsyntax.ParseStringLiteralToken(tok.asHCLSyntax())
+		if diags.HasErrors() {
+			return false
+		}
+		for _, r := range val {
+			if r == '\r' || (r != '\n' && r != '\t' && !unicode.IsPrint(r)) {
+				return false
+			}
+		}
+		for _, line := range strings.Split(val, "\n") {
+			if strings.TrimSpace(line) == marker {
+				return false
+			}
+		}
+		vals[i] = val
+	}
+	if !strings.HasSuffix(vals[len(vals)-1], "\n") {
+		return false
+	}
+
+	// We've checked everything we need to, so now we can rewrite the tokens
+	// in place, which preserves the rest of the expression's structure.
+	for i, tok := range lits {
+		tok.Type = hclsyntax.TokenStringLit
+		tok.Bytes = escapeHeredocLit(vals[i])
+		tok.SpacesBefore = 0
+	}
+	toks[0].Type = hclsyntax.TokenOHeredoc
+	toks[0].Bytes = []byte("<<" + marker + "\n")
+	end := toks[len(toks)-1]
+	end.Type = hclsyntax.TokenCHeredoc
+	end.Bytes = []byte(marker)
+	end.SpacesBefore = 0
+	return true
+}
+
+// ToQuotedString rewrites the attribute's expression from a heredoc template
+// into an equivalent quoted string template, escaping any characters in the
+// literal parts of the template that cannot appear literally in a quoted
+// string. Any interpolation sequences and template directives are preserved
+// as-is.
+//
+// The conversion is refused if the expression is not a heredoc template. It
+// is also refused for a flush heredoc, introduced with "<<-", that contains
+// interpolation sequences or template directives, because the removal of
+// leading whitespace from such a heredoc cannot be applied to the literal
+// parts in isolation.
+//
+// Returns true if the expression was rewritten, or false if it was left
+// unchanged.
+func (a *Attribute) ToQuotedString() bool {
+	toks := a.Expr().BuildTokens(nil)
+	if len(toks) < 2 || toks[0].Type != hclsyntax.TokenOHeredoc || toks[len(toks)-1].Type != hclsyntax.TokenCHeredoc {
+		return false
+	}
+	lits := templateLiteralTokens(toks[1:len(toks)-1], hclsyntax.TokenStringLit)
+
+	if bytes.HasPrefix(toks[0].Bytes, []byte("<<-")) {
+		if len(lits) != len(toks)-2 {
+			return false
+		}
+
+		// With only literal content, we can let the parser deal with the
+		// leading whitespace and then just render the resulting string.
+		// The closing marker must be followed by a newline to be recognized.
+		src := append(toks.Bytes(), '\n')
+		expr, diags := hclsyntax.ParseExpression(src, "", hcl.InitialPos)
+		if diags.HasErrors() {
+			return false
+		}
+		val, diags := expr.Value(nil)
+		if diags.HasErrors() || !val.IsKnown() || val.IsNull() || val.Type() != cty.String {
+			return false
+		}
+		a.expr = a.expr.ReplaceWith(NewExpressionRaw(TokensForValue(val)))
+		return true
+	}
+
+	vals := make([]string, len(lits))
+	for i, tok := range lits {
+		val, diags := hclsyntax.ParseStringLiteralToken(tok.asHCLSyntax())
+		if diags.HasErrors() {
+			return false
+		}
+		vals[i] = val
+	}
+
+	for i, tok := range lits {
+		tok.Type = hclsyntax.TokenQuotedLit
+		tok.Bytes = escapeQuotedStringLit(vals[i])
+		tok.SpacesBefore = 0
+	}
+	toks[0].Type = hclsyntax.TokenOQuote
+	toks[0].Bytes = []byte{'"'}
+	end := toks[len(toks)-1]
+	end.Type = hclsyntax.TokenCQuote
+	end.Bytes = []byte{'"'}
+	end.SpacesBefore = 0
+	return true
+}
+
+// templateLiteralTokens returns the tokens of the given type from the given
+// template content tokens, excluding any that are nested inside
+// interpolation sequences or template directives.
+func templateLiteralTokens(toks Tokens, litType hclsyntax.TokenType) Tokens {
+	var ret Tokens
+	depth := 0
+	for _, tok := range toks {
+		switch tok.Type {
+		case hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl:
+			depth++
+		case hclsyntax.TokenTemplateSeqEnd:
+			depth--
+		case litType:
+			if depth == 0 {
+				ret = append(ret, tok)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,147 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclwrite
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestAttributeToHeredoc(t *testing.T) {
+	tests := map[string]struct {
+		src    string
+		marker string
+		want   string
+		wantOK bool
+	}{
+		"simple": {
+			"a = \"hello\\nworld\\n\"\n",
+			"EOT",
+			"a = <<EOT\nhello\nworld\nEOT\n",
+			true,
+		},
+		"escapes": {
+			"a = \"say \\\"hi\\\" \\\\ $${x} \\u00e9\\n\"\n",
+			"EOT",
+			"a = <<EOT\nsay \"hi\" \\ $${x} é\nEOT\n",
+			true,
+		},
+		"interpolation": {
+			"a = \"hello ${name}!\\nbye ${title(\"x\\n\")}\\n\"\n",
+			"END",
+			"a = <<END\nhello ${name}!\nbye ${title(\"x\\n\")}\nEND\n",
+			true,
+		},
+		"no trailing newline": {
+			"a = \"hello\"\n",
+			"EOT",
+			"a = \"hello\"\n",
+			false,
+		},
+		"ends with interpolation": {
+			"a = \"hello\\n${name}\"\n",
+			"EOT",
+			"a = \"hello\\n${name}\"\n",
+			false,
+		},
+		"carriage return": {
+			"a = \"hello\\r\\n\"\n",
+			"EOT",
+			"a = \"hello\\r\\n\"\n",
+			false,
+		},
+		"marker conflict": {
+			"a = \"hello\\n  EOT\\n\"\n",
+			"EOT",
+			"a = \"hello\\n  EOT\\n\"\n",
+			false,
+		},
+		"invalid marker": {
+			"a = \"hello\\n\"\n",
+			"not valid",
+			"a = \"hello\\n\"\n",
+			false,
+		},
+		"not a string": {
+			"a = 1\n",
+			"EOT",
+			"a = 1\n",
+			false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfig([]byte(test.src), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected diagnostics: %s", diags.Error())
+			}
+
+			ok := f.Body().GetAttribute("a").ToHeredoc(test.marker)
+			if ok != test.wantOK {
+				t.Errorf("wrong result %t; want %t", ok, test.wantOK)
+			}
+			if got := string(f.Bytes()); got != test.want {
+				t.Errorf("wrong output\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAttributeToQuotedString(t *testing.T) {
+	tests := map[string]struct {
+		src    string
+		want   string
+		wantOK bool
+	}{
+		"simple": {
+			"a = <<EOT\nhello\nworld\nEOT\n",
+			"a = \"hello\\nworld\\n\"\n",
+			true,
+		},
+		"escapes": {
+			"a = <<EOT\nsay \"hi\" \\ $${x}\tok\nEOT\n",
+			"a = \"say \\\"hi\\\" \\\\ $${x}\\tok\\n\"\n",
+			true,
+		},
+		"interpolation": {
+			"a = <<EOT\nhello ${name}!\n%{ if x }yes%{ endif }\nEOT\n",
+			"a = \"hello ${name}!\\n%{if x}yes%{endif}\\n\"\n",
+			true,
+		},
+		"flush": {
+			"a = <<-EOT\n    hello\n      world\n    EOT\n",
+			"a = \"hello\\n  world\\n\"\n",
+			true,
+		},
+		"flush interpolation": {
+			"a = <<-EOT\n  hello ${name}\n  EOT\n",
+			"a = <<-EOT\n  hello ${name}\n  EOT\n",
+			false,
+		},
+		"not a heredoc": {
+			"a = \"hello\"\n",
+			"a = \"hello\"\n",
+			false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfig([]byte(test.src), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected diagnostics: %s", diags.Error())
+			}
+
+			ok := f.Body().GetAttribute("a").ToQuotedString()
+			if ok != test.wantOK {
+				t.Errorf("wrong result %t; want %t", ok, test.wantOK)
+			}
+			if got := string(f.Bytes()); got != test.want {
+				t.Errorf("wrong output\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
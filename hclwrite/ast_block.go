@@ -4,6 +4,9 @@
 package hclwrite
 
 import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/zclconf/go-cty/cty"
 )
@@ -63,6 +66,31 @@ func (b *Block) init(typeName string, labels []string) {
 	})
 }
 
+// Clone returns a deep copy of the receiving block, including its leading
+// comments and all of its nested content, which is not attached to any body.
+// The clone can be modified and then appended to any body using
+// Body.AppendBlock without affecting the receiver.
+//
+// The clone is produced by re-parsing the tokens of the receiver, so it will
+// panic if those tokens are not valid native syntax, which is possible only
+// if invalid tokens were inserted using raw token methods such as
+// Body.SetAttributeRaw.
+func (b *Block) Clone() *Block {
+	src := b.BuildTokens(nil).Bytes()
+	f, diags := parse(src, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		panic(fmt.Sprintf("can't clone block with invalid syntax: %s", diags.Error()))
+	}
+	body := f.Body()
+	blocks := body.Blocks()
+	if len(blocks) != 1 {
+		// Should never happen, since we rendered just one block above.
+		panic(fmt.Sprintf("cloning a block produced %d blocks", len(blocks)))
+	}
+	body.RemoveBlock(blocks[0])
+	return blocks[0]
+}
+
 // Body returns the body that represents the content of the receiving block.
 //
 // Appending to or otherwise modifying this body will make changes to the
@@ -13,6 +13,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestBlockType(t *testing.T) {
@@ -487,3 +488,65 @@ func TestBlockSetLabels(t *testing.T) {
 		})
 	}
 }
+
+func TestBlockClone(t *testing.T) {
+	src := `# The primary server.
+server "primary" {
+  port = 80
+
+  tls {
+    enabled = true # for now
+  }
+}
+`
+	f, diags := ParseConfig([]byte(src), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %s", diags.Error())
+	}
+	body := f.Body()
+	original := body.Blocks()[0]
+
+	clone := original.Clone()
+	clone.SetLabels([]string{"secondary"})
+	clone.Body().SetAttributeValue("port", cty.NumberIntVal(8080))
+	clone.Body().Blocks()[0].Body().RemoveAttribute("enabled")
+	body.AppendNewline()
+	body.AppendBlock(clone)
+
+	got := string(f.Bytes())
+	want := `# The primary server.
+server "primary" {
+  port = 80
+
+  tls {
+    enabled = true # for now
+  }
+}
+
+# The primary server.
+server "secondary" {
+  port = 8080
+
+  tls {
+  }
+}
+`
+	if got != want {
+		t.Errorf("wrong result\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestBlockClone_new(t *testing.T) {
+	original := NewBlock("thing", []string{"a"})
+	original.Body().SetAttributeValue("x", cty.True)
+
+	clone := original.Clone()
+	clone.Body().SetAttributeValue("y", cty.False)
+
+	if got, want := string(Format(original.BuildTokens(nil).Bytes())), "thing \"a\" {\n  x = true\n}\n"; got != want {
+		t.Errorf("original was modified\n%s", cmp.Diff(want, got))
+	}
+	if got, want := string(Format(clone.BuildTokens(nil).Bytes())), "thing \"a\" {\n  x = true\n  y = false\n}\n"; got != want {
+		t.Errorf("wrong clone\n%s", cmp.Diff(want, got))
+	}
+}
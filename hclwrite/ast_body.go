@@ -5,6 +5,7 @@ package hclwrite
 
 import (
 	"reflect"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
@@ -178,6 +179,26 @@ func (b *Body) SetAttributeValue(name string, val cty.Value) *Attribute {
 	return attr
 }
 
+// SetAttributeConditional either replaces the expression of an existing
+// attribute of the given name or adds a new attribute definition to the end
+// of the body, using a conditional expression built from the given
+// condition and result expressions.
+//
+// The given expressions are rendered as tokens from their syntax trees,
+// adding parentheses wherever operator precedence requires them. Native
+// syntax expressions of any kind are supported, while other expressions
+// must be either static traversals or constant values. This method panics
+// if given an expression it cannot render, or one containing an unknown
+// literal value.
+//
+// The return value is the attribute that was either modified in-place or
+// created.
+func (b *Body) SetAttributeConditional(name string, cond, trueExpr, falseExpr hcl.Expression) *Attribute {
+	toks := appendTokensForConditional(cond, trueExpr, falseExpr, nil)
+	format(toks) // fiddle with the SpacesBefore field to get canonical spacing
+	return b.SetAttributeRaw(name, toks)
+}
+
 // SetAttributeTraversal either replaces the expression of an existing attribute
 // of the given name or adds a new attribute definition to the end of the body.
 //
@@ -229,7 +250,7 @@ func (b *Body) AppendNewBlock(typeName string, labels []string) *Block {
 	return block
 }
 
-// AppendNewline appends a newline token to th end of the receiving body,
+// AppendNewline appends a newline token to the end of the receiving body,
 // which generally serves as a separator between different sets of body
 // contents.
 func (b *Body) AppendNewline() {
@@ -240,3 +261,22 @@ func (b *Body) AppendNewline() {
 		},
 	})
 }
+
+// AppendComment appends a standalone comment to the end of the receiving
+// body, using the # style. Each line of the given text becomes a separate
+// comment line, so the text may safely contain newlines.
+func (b *Body) AppendComment(text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var toks Tokens
+	for _, line := range strings.Split(text, "\n") {
+		comment := "#"
+		if line != "" {
+			comment += " " + line
+		}
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenComment,
+			Bytes: []byte(comment + "\n"),
+		})
+	}
+	b.AppendUnstructuredTokens(toks)
+}
@@ -933,6 +933,121 @@ func TestBodySetAttributeRaw(t *testing.T) {
 	}
 }
 
+func TestBodySetAttributeConditional(t *testing.T) {
+	tests := map[string]struct {
+		cond, trueExpr, falseExpr string
+		want                      string
+	}{
+		"simple": {
+			`var.enabled`, `"yes"`, `"no"`,
+			`a = var.enabled ? "yes" : "no"`,
+		},
+		"operators": {
+			`a == 1 && !b`, `x + y * 2`, `(x + y) * 2`,
+			`a = a == 1 && !b ? x + y * 2 : (x + y) * 2`,
+		},
+		"conditional condition": {
+			`a ? b : c`, `d ? e : f`, `g ? h : i`,
+			`a = (a ? b : c) ? d ? e : f : g ? h : i`,
+		},
+		"right-associative grouping": {
+			`true`, `a - (b - c)`, `-(a + b)`,
+			`a = true ? a - (b - c) : -(a + b)`,
+		},
+		"traversals and calls": {
+			`length(var.list[*].id) > 0`, `var.map["key"].value[0]`, `coalesce(var.items...)`,
+			`a = length(var.list[*].id) > 0 ? var.map["key"].value[0] : coalesce(var.items...)`,
+		},
+		"collections": {
+			`c`, `[1, "two", null]`, `{ name = local.name, (local.key) = true, "quoted key" = -1 }`,
+			"a = c ? [1, \"two\", null] : {\n  name         = local.name\n  (local.key)  = true\n  \"quoted key\" = -1\n}",
+		},
+		"for expressions": {
+			`c`, `[for i, v in var.list : upper(v) if i > 0]`, `{for k, v in var.map : v => k...}`,
+			`a = c ? [for i, v in var.list : upper(v) if i > 0] : { for k, v in var.map : v => k... }`,
+		},
+		"templates": {
+			`c`, `"Hello, ${var.name}! $${literal}"`, `"%{for x in var.list}${x}, %{endfor}cost: $${var.cost}"`,
+			`a = c ? "Hello, ${var.name}! $${literal}" : "%{for x in var.list}${x}, %{endfor}cost: $${var.cost}"`,
+		},
+		"template wrap": {
+			`c`, `"${var.name}"`, `"%{if var.x}x%{else}y%{endif}"`,
+			`a = c ? "${var.name}" : "${var.x ? "x" : "y"}"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			parse := func(src string) hcl.Expression {
+				expr, diags := hclsyntax.ParseExpression([]byte(src), "", hcl.InitialPos)
+				if diags.HasErrors() {
+					t.Fatalf("failed to parse %q: %s", src, diags.Error())
+				}
+				return expr
+			}
+			cond, trueExpr, falseExpr := parse(test.cond), parse(test.trueExpr), parse(test.falseExpr)
+
+			f := NewEmptyFile()
+			f.Body().SetAttributeConditional("a", cond, trueExpr, falseExpr)
+			got := strings.TrimSpace(string(f.Bytes()))
+			if got != test.want {
+				t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+
+			// The result must parse back to an equivalent conditional.
+			parsed, diags := hclsyntax.ParseConfig(f.Bytes(), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("result does not parse: %s", diags.Error())
+			}
+			attr := parsed.Body.(*hclsyntax.Body).Attributes["a"]
+			if _, ok := attr.Expr.(*hclsyntax.ConditionalExpr); !ok {
+				t.Errorf("result is %T, not a conditional", attr.Expr)
+			}
+		})
+	}
+}
+
+func TestBodySetAttributeConditional_constructed(t *testing.T) {
+	x := &hclsyntax.ScopeTraversalExpr{
+		Traversal: hcl.Traversal{hcl.TraverseRoot{Name: "x"}},
+	}
+	// A literal dollar sign immediately before an interpolation can't be
+	// written directly, because it would form an escape sequence.
+	trueExpr := &hclsyntax.TemplateExpr{
+		Parts: []hclsyntax.Expression{
+			&hclsyntax.LiteralValueExpr{Val: cty.StringVal("cost: $")},
+			x,
+		},
+	}
+	// Expressions from other syntaxes are rendered as constant values.
+	falseExpr := hcl.StaticExpr(cty.ListVal([]cty.Value{cty.StringVal("a")}), hcl.Range{})
+
+	f := NewEmptyFile()
+	f.Body().SetAttributeConditional("a", x, trueExpr, falseExpr)
+	got := strings.TrimSpace(string(f.Bytes()))
+	want := `a = x ? "cost: ${"$"}${x}" : ["a"]`
+	if got != want {
+		t.Fatalf("wrong result\ngot:  %s\nwant: %s", got, want)
+	}
+
+	parsed, diags := hclsyntax.ParseConfig(f.Bytes(), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("result does not parse: %s", diags.Error())
+	}
+	attr := parsed.Body.(*hclsyntax.Body).Attributes["a"]
+	val, diags := attr.Expr.(*hclsyntax.ConditionalExpr).TrueResult.Value(&hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"x": cty.StringVal("5"),
+		},
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	if got, want := val, cty.StringVal("cost: $5"); !got.RawEquals(want) {
+		t.Errorf("wrong template result %#v; want %#v", got, want)
+	}
+}
+
 func TestBodySetAttributeValueInBlock(t *testing.T) {
 	src := `service "label1" {
   attr1 = "val1"
@@ -1590,3 +1705,54 @@ bar {}
 	}
 
 }
+
+func TestBodyAppendComment(t *testing.T) {
+	tests := map[string]struct {
+		text string
+		want string
+	}{
+		"single line": {
+			"Network settings",
+			"a = 1\n\n# Network settings\nb = 2\n",
+		},
+		"multiple lines": {
+			"First line\n\nThird line",
+			"a = 1\n\n# First line\n#\n# Third line\nb = 2\n",
+		},
+		"windows line endings": {
+			"First\r\nSecond",
+			"a = 1\n\n# First\n# Second\nb = 2\n",
+		},
+		"attempted injection": {
+			"comment\nc = 3",
+			"a = 1\n\n# comment\n# c = 3\nb = 2\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := NewEmptyFile()
+			body := f.Body()
+			body.SetAttributeValue("a", cty.NumberIntVal(1))
+			body.AppendNewline()
+			body.AppendComment(test.text)
+			body.SetAttributeValue("b", cty.NumberIntVal(2))
+
+			got := string(f.Bytes())
+			if got != test.want {
+				t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+			if formatted := string(Format(f.Bytes())); formatted != test.want {
+				t.Errorf("result changed by Format\ngot:\n%s\nwant:\n%s", formatted, test.want)
+			}
+
+			parsed, diags := ParseConfig(f.Bytes(), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("result does not parse: %s", diags.Error())
+			}
+			if got, want := len(parsed.Body().Attributes()), 2; got != want {
+				t.Errorf("result has %d attributes; want %d", got, want)
+			}
+		})
+	}
+}
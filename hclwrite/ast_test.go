@@ -6,6 +6,9 @@ package hclwrite
 import (
 	"fmt"
 	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
 )
 
 type TestTreeNode struct {
@@ -48,3 +51,201 @@ func makeTestTree(n *node) (root TestTreeNode) {
 
 	return root
 }
+
+func TestFileRemoveAttributeAtPath(t *testing.T) {
+	const src = `a = 1
+block "x" {
+  settings {
+    timeout = 5
+    retries = 2
+  }
+}
+block "y" {
+  settings {
+    timeout = 10
+  }
+}
+`
+	tests := map[string]struct {
+		path      []string
+		want      string
+		wantFound bool
+	}{
+		"top-level": {
+			[]string{"a"},
+			`block "x" {
+  settings {
+    timeout = 5
+    retries = 2
+  }
+}
+block "y" {
+  settings {
+    timeout = 10
+  }
+}
+`,
+			true,
+		},
+		"nested, first matching block only": {
+			[]string{"block", "settings", "timeout"},
+			`a = 1
+block "x" {
+  settings {
+    retries = 2
+  }
+}
+block "y" {
+  settings {
+    timeout = 10
+  }
+}
+`,
+			true,
+		},
+		"attribute not in block": {
+			[]string{"block", "timeout"},
+			src,
+			false,
+		},
+		"missing block": {
+			[]string{"other", "timeout"},
+			src,
+			false,
+		},
+		"block rather than attribute": {
+			[]string{"block", "settings"},
+			src,
+			false,
+		},
+		"empty": {
+			nil,
+			src,
+			false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfig([]byte(src), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected diagnostics: %s", diags.Error())
+			}
+
+			found := f.RemoveAttributeAtPath(test.path)
+			if found != test.wantFound {
+				t.Errorf("wrong result %t; want %t", found, test.wantFound)
+			}
+			if got := string(f.Bytes()); got != test.want {
+				t.Errorf("wrong output\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFileRemoveComments(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want string
+	}{
+		"no comments": {
+			"a = 1\n",
+			"a = 1\n",
+		},
+		"header comment": {
+			`# Header comment
+
+a = 1
+`,
+			`a = 1
+`,
+		},
+		"lead comments": {
+			`a = 1
+
+# Comment about b
+// which continues here
+b = 2
+`,
+			`a = 1
+
+b = 2
+`,
+		},
+		"comment between blank lines": {
+			`a = 1
+
+# Section
+
+b = 2
+`,
+			`a = 1
+
+b = 2
+`,
+		},
+		"line comments": {
+			`a = 1 # one
+b = 2 // two
+c = 3 /* three */
+`,
+			`a = 1
+b = 2
+c = 3
+`,
+		},
+		"block comments": {
+			`/* Header
+   spanning lines */
+a = /* inline */ 1
+`,
+			`a = 1
+`,
+		},
+		"nested": {
+			`block "x" { # opening
+  # lead
+  a = [
+    1, # first
+    2,
+  ]
+}
+`,
+			`block "x" {
+  a = [
+    1,
+    2,
+  ]
+}
+`,
+		},
+		"comment-like strings": {
+			`a = "# not a comment"
+b = "// nor this /* or this */"
+c = <<EOT
+# still not a comment
+EOT
+`,
+			`a = "# not a comment"
+b = "// nor this /* or this */"
+c = <<EOT
+# still not a comment
+EOT
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := ParseConfig([]byte(test.src), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected diagnostics: %s", diags.Error())
+			}
+
+			f.RemoveComments()
+			if got := string(f.Bytes()); got != test.want {
+				t.Errorf("wrong output\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
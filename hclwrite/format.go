@@ -4,6 +4,8 @@
 package hclwrite
 
 import (
+	"bytes"
+
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 )
 
@@ -100,6 +102,78 @@ func formatIndent(lines []formatLine) {
 	}
 }
 
+// formatHeredocs indents the content and closing marker of any flush heredocs
+// (those introduced with <<-) whose closing marker is indented less than the
+// line that introduces the heredoc, such as those generated by
+// TokensForValueWithHeredocs and then placed into a nested block. This is
+// used only when requested by FormatOptions.IndentFlushHeredocs, after the
+// other formatting passes.
+//
+// A flush heredoc has the common leading whitespace of its lines removed, so
+// adding the same number of spaces to each line does not change its value.
+// The leading whitespace of a non-flush heredoc is significant, so those are
+// left unchanged, as are flush heredocs that are already indented at least
+// as far as their introducing line.
+func formatHeredocs(lines []formatLine) {
+	for _, line := range lines {
+		if len(line.lead) == 0 {
+			continue
+		}
+		indent := line.lead[0].SpacesBefore
+
+		toks := make(Tokens, 0, len(line.lead)+len(line.assign)+len(line.comment))
+		toks = append(toks, line.lead...)
+		toks = append(toks, line.assign...)
+		toks = append(toks, line.comment...)
+		for i, tok := range toks {
+			if tok.Type == hclsyntax.TokenOHeredoc && bytes.HasPrefix(tok.Bytes, []byte("<<-")) {
+				indentHeredoc(toks[i+1:], indent)
+			}
+		}
+	}
+}
+
+// indentHeredoc adds leading spaces to each line of the heredoc whose
+// content begins with the given tokens so that its closing marker is
+// indented by at least the given number of spaces.
+func indentHeredoc(toks Tokens, indent int) {
+	var starts Tokens
+	startOfLine := true
+	for _, tok := range toks {
+		switch tok.Type {
+		case hclsyntax.TokenOHeredoc:
+			// We don't try to deal with heredocs nested inside the
+			// interpolation sequences of other heredocs.
+			return
+		case hclsyntax.TokenCHeredoc:
+			markerIndent := len(tok.Bytes) - len(bytes.TrimLeft(tok.Bytes, " "))
+			if bytes.HasPrefix(tok.Bytes[markerIndent:], []byte{'\t'}) {
+				// We can't reliably align with indentation using tabs.
+				return
+			}
+			delta := indent - markerIndent
+			if delta <= 0 {
+				return
+			}
+			for _, start := range starts {
+				start.SpacesBefore += delta
+			}
+			tok.SpacesBefore += delta
+			return
+		}
+
+		if startOfLine {
+			// Lines containing only whitespace do not participate in the
+			// removal of leading whitespace, so we must leave them as-is.
+			blank := tok.Type == hclsyntax.TokenStringLit && len(bytes.TrimSpace(tok.Bytes)) == 0 && bytes.HasSuffix(tok.Bytes, []byte{'\n'})
+			if !blank {
+				starts = append(starts, tok)
+			}
+		}
+		startOfLine = tok.Type == hclsyntax.TokenStringLit && bytes.HasSuffix(tok.Bytes, []byte{'\n'})
+	}
+}
+
 func formatSpaces(lines []formatLine) {
 	// placeholder token used when we don't have a token but we don't want
 	// to pass a real "nil" and complicate things with nil pointer checks
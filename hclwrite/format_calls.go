@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclwrite
+
+import (
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// wrapLongCalls rewrites the given already-formatted tokens so that any line
+// wider than maxWidth that contains a single-line function call has the
+// arguments of that call placed one per line, and then formats the result
+// again. This repeats until no more calls can be wrapped, because wrapping
+// an outer call can still leave an inner call on a line that is too long.
+//
+// Unlike format, this inserts new newline tokens and so returns a new
+// sequence of tokens rather than modifying the given tokens in-place.
+func wrapLongCalls(tokens Tokens, maxWidth int) Tokens {
+	for {
+		open, close := longCallToWrap(tokens, maxWidth)
+		if open < 0 {
+			return tokens
+		}
+
+		wrapped := make(Tokens, 0, len(tokens)+8)
+		wrapped = append(wrapped, tokens[:open+1]...)
+		wrapped = append(wrapped, newlineToken())
+		depth := 0
+		for _, tok := range tokens[open+1 : close] {
+			depth += tokenBracketChange(tok)
+			wrapped = append(wrapped, tok)
+			if depth == 0 && tok.Type == hclsyntax.TokenComma {
+				wrapped = append(wrapped, newlineToken())
+			}
+		}
+		wrapped = append(wrapped, newlineToken())
+		wrapped = append(wrapped, tokens[close:]...)
+
+		format(wrapped)
+		tokens = wrapped
+	}
+}
+
+// longCallToWrap finds the first line of the given tokens that is wider
+// than maxWidth and contains a function call that begins and ends on that
+// line, returning the indices of the opening and closing parentheses of the
+// widest such call. It returns -1, -1 if there is no such line.
+func longCallToWrap(tokens Tokens, maxWidth int) (int, int) {
+	lineStart := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && !tokenIsNewline(tokens[i]) && tokens[i].Type != hclsyntax.TokenEOF {
+			continue
+		}
+		line := tokens[lineStart:i]
+		if lineWidth(line) > maxWidth {
+			if open, close := widestCall(line); open >= 0 {
+				return lineStart + open, lineStart + close
+			}
+		}
+		lineStart = i + 1
+	}
+	return -1, -1
+}
+
+// widestCall returns the indices of the opening and closing parentheses of
+// the widest function call with at least one argument within the given
+// tokens, which all belong to a single line. Calls within template
+// interpolation sequences are not considered, and nor are any calls if the
+// line contains a heredoc.
+func widestCall(line Tokens) (int, int) {
+	bestOpen, bestClose, bestWidth := -1, -1, 0
+
+	var opens []int // stack of indices of all open brackets
+	templateDepth := 0
+	for i, tok := range line {
+		switch tok.Type {
+		case hclsyntax.TokenOHeredoc:
+			return -1, -1
+		case hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl:
+			templateDepth++
+		case hclsyntax.TokenTemplateSeqEnd:
+			templateDepth--
+		}
+
+		switch change := tokenBracketChange(tok); {
+		case change > 0:
+			opens = append(opens, i)
+		case change < 0 && len(opens) > 0:
+			open := opens[len(opens)-1]
+			opens = opens[:len(opens)-1]
+			isCall := tok.Type == hclsyntax.TokenCParen && line[open].Type == hclsyntax.TokenOParen && open > 0 && line[open-1].Type == hclsyntax.TokenIdent
+			if !isCall || templateDepth > 0 || i == open+1 {
+				continue
+			}
+			if width := lineWidth(line[open : i+1]); width > bestWidth {
+				bestOpen, bestClose, bestWidth = open, i, width
+			}
+		}
+	}
+
+	return bestOpen, bestClose
+}
+
+// lineWidth returns the number of characters needed to render the given
+// tokens, including their leading spaces.
+func lineWidth(tokens Tokens) int {
+	width := 0
+	for _, tok := range tokens {
+		if tokenIsNewline(tok) {
+			break
+		}
+		width += tok.SpacesBefore + utf8.RuneCount(tok.Bytes)
+	}
+	return width
+}
+
+func newlineToken() *Token {
+	return &Token{
+		Type:  hclsyntax.TokenNewline,
+		Bytes: []byte{'\n'},
+	}
+}
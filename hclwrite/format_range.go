@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclwrite
+
+import (
+	"bytes"
+)
+
+// formatLineSpans returns, for each group of source lines that must be
+// formatted together, the indices of the first and last source lines in
+// that group.
+//
+// Each line produced by linesForFormat is such a group, because it may span
+// several source lines when it contains a heredoc or a multi-line comment.
+// Each run of consecutive lines whose assign or comment cells are aligned
+// with one another is also a group.
+func formatLineSpans(tokens Tokens) [][2]int {
+	tokenLines := make(map[*Token]int, len(tokens))
+	line := 0
+	for _, tok := range tokens {
+		tokenLines[tok] = line
+		line += bytes.Count(tok.Bytes, []byte{'\n'})
+	}
+
+	lines := linesForFormat(tokens)
+	spans := make([][2]int, len(lines))
+	for i, fl := range lines {
+		var toks Tokens
+		toks = append(toks, fl.lead...)
+		toks = append(toks, fl.assign...)
+		toks = append(toks, fl.comment...)
+		if len(toks) == 0 {
+			spans[i] = [2]int{-1, -1}
+			continue
+		}
+		spans[i] = [2]int{tokenLines[toks[0]], tokenLines[toks[len(toks)-1]]}
+	}
+
+	ret := make([][2]int, 0, len(spans))
+	chain := func(cell func(formatLine) Tokens) {
+		chainStart := -1
+		for i := 0; i <= len(lines); i++ {
+			if i < len(lines) && cell(lines[i]) != nil {
+				if chainStart == -1 {
+					chainStart = i
+				}
+				continue
+			}
+			if chainStart != -1 {
+				ret = append(ret, [2]int{spans[chainStart][0], spans[i-1][1]})
+				chainStart = -1
+			}
+		}
+	}
+	chain(func(fl formatLine) Tokens { return fl.assign })
+	chain(func(fl formatLine) Tokens { return fl.comment })
+	for _, span := range spans {
+		if span[0] != -1 {
+			ret = append(ret, span)
+		}
+	}
+	return ret
+}
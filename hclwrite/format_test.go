@@ -5,12 +5,15 @@ package hclwrite
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"reflect"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestFormat(t *testing.T) {
@@ -650,6 +653,220 @@ module "x" {
 
 }
 
+func TestFormatWithOptions_maxCallWidth(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		width int
+		want  string
+	}{
+		"disabled": {
+			`a = lookup(var.something_long, "some_key", "some_default_value")`,
+			0,
+			`a = lookup(var.something_long, "some_key", "some_default_value")`,
+		},
+		"short call unchanged": {
+			`a = f(1,2)`,
+			20,
+			`a = f(1, 2)`,
+		},
+		"long call wrapped": {
+			`a = lookup(var.something_long, "some_key", "some_default_value")`,
+			40,
+			`a = lookup(
+  var.something_long,
+  "some_key",
+  "some_default_value"
+)`,
+		},
+		"nested calls wrapped as needed": {
+			`
+block {
+  a = merge(local.base, { x = 1 }, lookup(var.something_long, "some_key", "default"))
+  b = f(1)
+}
+`,
+			40,
+			`
+block {
+  a = merge(
+    local.base,
+    { x = 1 },
+    lookup(
+      var.something_long,
+      "some_key",
+      "default"
+    )
+  )
+  b = f(1)
+}
+`,
+		},
+		"inline comments preserved": {
+			`a = lookup(var.something_long, /* key */ "some_key", "some_default_value")`,
+			40,
+			`a = lookup(
+  var.something_long,
+  /* key */ "some_key",
+  "some_default_value"
+)`,
+		},
+		"already multi-line": {
+			`
+a = lookup(
+  var.something_long, "some_key",
+  "some_default_value"
+)
+`,
+			20,
+			`
+a = lookup(
+  var.something_long, "some_key",
+  "some_default_value"
+)
+`,
+		},
+		"template interpolation unchanged": {
+			`a = "${join(", ", var.something_long, var.something_else)}"`,
+			20,
+			`a = "${join(", ", var.something_long, var.something_else)}"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := string(FormatWithOptions([]byte(test.input), FormatOptions{
+				MaxCallWidth: test.width,
+			}))
+			if got != test.want {
+				t.Errorf("wrong result\ninput:\n%s\ngot:\n%s\nwant:\n%s", test.input, got, test.want)
+			}
+
+			if _, diags := hclsyntax.ParseConfig([]byte(got), "", hcl.InitialPos); diags.HasErrors() {
+				t.Errorf("result is invalid: %s", diags)
+			}
+		})
+	}
+}
+
+func TestFormatRange(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		sel   string
+		want  string
+	}{
+		"single line": {
+			"a=1\n\nb=2\n\nc=3\n",
+			"b=2",
+			"a=1\n\nb = 2\n\nc=3\n",
+		},
+		"empty range": {
+			"a=1\n\nb=2\n\nc=3\n",
+			"",
+			"a = 1\n\nb=2\n\nc=3\n",
+		},
+		"part of a line": {
+			"a=1\n\nb=[1,2]\n\nc=3\n",
+			"2",
+			"a=1\n\nb = [1, 2]\n\nc=3\n",
+		},
+		"several lines": {
+			"a=1\n\nb=2\n\nc=3\n",
+			"b=2\n\nc",
+			"a=1\n\nb = 2\n\nc = 3\n",
+		},
+		"indented by context": {
+			"block {\na=1\n\nb=2\n}\n",
+			"b=2",
+			"block {\na=1\n\n  b = 2\n}\n",
+		},
+		"alignment group extended": {
+			"x=1\n\nfoo=1\nlongername=2\n\ny=2\n",
+			"foo",
+			"x=1\n\nfoo        = 1\nlongername = 2\n\ny=2\n",
+		},
+		"comment alignment group extended": {
+			"a=1 # one\nb = 2 # two\n",
+			"two",
+			"a = 1 # one\nb = 2 # two\n",
+		},
+		"heredoc extended": {
+			"block {\nb=<<-EOT\nhello\nEOT\n\nc=1\n}\n",
+			"hello",
+			"block {\n  b = <<-EOT\nhello\nEOT\n\nc=1\n}\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			start := strings.Index(test.input, test.sel)
+			if start < 0 {
+				t.Fatalf("input does not contain %q", test.sel)
+			}
+			got, err := FormatRange([]byte(test.input), start, start+len(test.sel))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("wrong result\ninput:\n%s\ngot:\n%s\nwant:\n%s", test.input, got, test.want)
+			}
+		})
+	}
+
+	t.Run("invalid range", func(t *testing.T) {
+		src := []byte("a = 1\n")
+		for _, rng := range [][2]int{{-1, 2}, {3, 2}, {0, len(src) + 1}} {
+			if _, err := FormatRange(src, rng[0], rng[1]); err == nil {
+				t.Errorf("no error for range %d to %d", rng[0], rng[1])
+			}
+		}
+	})
+}
+
+func TestFormatGeneratedHeredoc(t *testing.T) {
+	val := cty.StringVal("hello\n  world\n\n")
+
+	f := NewEmptyFile()
+	inner := f.Body().AppendNewBlock("outer", nil).Body().AppendNewBlock("inner", nil).Body()
+	inner.SetAttributeRaw("greeting", TokensForValueWithHeredocs(val))
+
+	opts := FormatOptions{
+		IndentFlushHeredocs: true,
+	}
+	got := string(FormatWithOptions(f.Bytes(), opts))
+	want := `outer {
+  inner {
+    greeting = <<-EOT
+      hello
+        world
+
+    EOT
+  }
+}
+`
+	if got != want {
+		t.Fatalf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Formatting must not change the value of the heredoc.
+	parsed, diags := hclsyntax.ParseConfig([]byte(got), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("formatted result is invalid: %s", diags)
+	}
+	block := parsed.Body.(*hclsyntax.Body).Blocks[0].Body.Blocks[0]
+	gotVal, diags := block.Body.Attributes["greeting"].Expr.Value(nil)
+	if diags.HasErrors() {
+		t.Fatalf("formatted result is invalid: %s", diags)
+	}
+	if !gotVal.RawEquals(val) {
+		t.Errorf("wrong value\ngot:  %#v\nwant: %#v", gotVal, val)
+	}
+
+	// Formatting again should make no further changes.
+	if again := string(FormatWithOptions([]byte(got), opts)); again != got {
+		t.Errorf("formatting is not idempotent\ngot:\n%s\nwant:\n%s", again, got)
+	}
+}
+
 func TestLinesForFormat(t *testing.T) {
 	tests := []struct {
 		tokens Tokens
@@ -5,6 +5,7 @@ package hclwrite
 
 import (
 	"fmt"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 
@@ -19,13 +20,41 @@ import (
 // This function only supports types that are used by HCL. In particular, it
 // does not support capsule types and will panic if given one.
 //
+// The attributes of objects and the elements of maps are written in lexical
+// order of their keys, so the result is the same each time for equal values.
+//
 // It is not possible to express an unknown value in source code, so this
 // function will panic if the given value is unknown or contains any unknown
 // values. A caller can call the value's IsWhollyKnown method to verify that
 // no unknown values are present before calling TokensForValue.
 func TokensForValue(val cty.Value) Tokens {
-	toks := appendTokensForValue(val, nil)
+	toks := appendTokensForValue(val, nil, false)
+	format(toks) // fiddle with the SpacesBefore field to get canonical spacing
+	return toks
+}
+
+// TokensForValueWithHeredocs is a variant of TokensForValue that renders
+// strings spanning multiple lines and ending with a newline as flush
+// heredocs, using the <<- introducer, rather than as quoted strings with
+// escaped newlines.
+//
+// Strings that cannot be represented exactly as a flush heredoc, such as
+// those where every line begins with whitespace or those containing
+// carriage returns or other non-printable characters, are still rendered as
+// quoted strings.
+//
+// The closing marker of a heredoc must be followed by a newline, so the
+// caller must not place any other tokens after the result on the same line.
+// Using the result as the value of an attribute, such as with
+// Body.SetAttributeRaw, meets that requirement. Each heredoc is aligned with
+// the attribute it belongs to within the result, but the result doesn't know
+// how deeply it will eventually be nested, so FormatWithOptions with
+// FormatOptions.IndentFlushHeredocs can be used to align the heredocs again
+// after placing the result in a file.
+func TokensForValueWithHeredocs(val cty.Value) Tokens {
+	toks := appendTokensForValue(val, nil, true)
 	format(toks) // fiddle with the SpacesBefore field to get canonical spacing
+	formatHeredocs(linesForFormat(toks))
 	return toks
 }
 
@@ -182,7 +211,7 @@ func TokensForFunctionCall(funcName string, args ...Tokens) Tokens {
 	return toks
 }
 
-func appendTokensForValue(val cty.Value, toks Tokens) Tokens {
+func appendTokensForValue(val cty.Value, toks Tokens, heredocs bool) Tokens {
 	switch {
 
 	case !val.IsKnown():
@@ -215,8 +244,12 @@ func appendTokensForValue(val cty.Value, toks Tokens) Tokens {
 		})
 
 	case val.Type() == cty.String:
-		// TODO: If it's a multi-line string ending in a newline, format
-		// it as a HEREDOC instead.
+		if heredocs {
+			if heredocToks := tokensForHeredoc(val.AsString()); heredocToks != nil {
+				toks = append(toks, heredocToks...)
+				break
+			}
+		}
 		src := escapeQuotedStringLit(val.AsString())
 		toks = append(toks, &Token{
 			Type:  hclsyntax.TokenOQuote,
@@ -248,7 +281,14 @@ func appendTokensForValue(val cty.Value, toks Tokens) Tokens {
 				})
 			}
 			_, eVal := it.Element()
-			toks = appendTokensForValue(eVal, toks)
+			toks = appendTokensForValue(eVal, toks, heredocs)
+			if toks[len(toks)-1].Type == hclsyntax.TokenCHeredoc {
+				// A heredoc closing marker must be alone on its line.
+				toks = append(toks, &Token{
+					Type:  hclsyntax.TokenNewline,
+					Bytes: []byte{'\n'},
+				})
+			}
 			i++
 		}
 
@@ -278,13 +318,13 @@ func appendTokensForValue(val cty.Value, toks Tokens) Tokens {
 					Bytes: []byte(eKey.AsString()),
 				})
 			} else {
-				toks = appendTokensForValue(eKey, toks)
+				toks = appendTokensForValue(eKey, toks, false)
 			}
 			toks = append(toks, &Token{
 				Type:  hclsyntax.TokenEqual,
 				Bytes: []byte{'='},
 			})
-			toks = appendTokensForValue(eVal, toks)
+			toks = appendTokensForValue(eVal, toks, heredocs)
 			toks = append(toks, &Token{
 				Type:  hclsyntax.TokenNewline,
 				Bytes: []byte{'\n'},
@@ -335,7 +375,7 @@ func appendTokensForTraversalStep(step hcl.Traverser, toks Tokens) Tokens {
 			Type:  hclsyntax.TokenOBrack,
 			Bytes: []byte{'['},
 		})
-		toks = appendTokensForValue(ts.Key, toks)
+		toks = appendTokensForValue(ts.Key, toks, false)
 		toks = append(toks, &Token{
 			Type:  hclsyntax.TokenCBrack,
 			Bytes: []byte{']'},
@@ -347,6 +387,102 @@ func appendTokensForTraversalStep(step hcl.Traverser, toks Tokens) Tokens {
 	return toks
 }
 
+// tokensForHeredoc returns tokens representing the given string as a flush
+// heredoc, or nil if the string is not suitable for representation as a
+// heredoc.
+//
+// Each line of content is indented by two spaces relative to the closing
+// marker. Because a flush heredoc has the common leading whitespace of its
+// lines removed, that indentation is not part of the resulting value
+// provided that at least one line of the string does not already begin with
+// whitespace.
+func tokensForHeredoc(s string) Tokens {
+	if !strings.HasSuffix(s, "\n") {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	lines = lines[:len(lines)-1] // SplitAfter produces an empty string after the final newline
+	if len(lines) < 2 {
+		return nil
+	}
+
+	flush := false
+	for _, line := range lines {
+		for _, r := range line {
+			if r == '\r' || (r != '\n' && r != '\t' && !unicode.IsPrint(r)) {
+				return nil
+			}
+		}
+		if r, _ := utf8.DecodeRuneInString(line); r != '\n' && !unicode.IsSpace(r) {
+			flush = true
+		}
+	}
+	if !flush {
+		return nil
+	}
+
+	marker := heredocMarker(lines)
+	toks := Tokens{
+		{
+			Type:  hclsyntax.TokenOHeredoc,
+			Bytes: []byte("<<-" + marker + "\n"),
+		},
+	}
+	for _, line := range lines {
+		var src []byte
+		if strings.TrimSpace(line) != "" {
+			// Lines containing only whitespace do not participate in the
+			// removal of leading whitespace from a flush heredoc, so we
+			// must leave those unindented to preserve them exactly.
+			src = append(src, "  "...)
+		}
+		src = append(src, escapeHeredocLit(line)...)
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenStringLit,
+			Bytes: src,
+		})
+	}
+	toks = append(toks, &Token{
+		Type:  hclsyntax.TokenCHeredoc,
+		Bytes: []byte(marker),
+	})
+	return toks
+}
+
+// heredocMarker returns a heredoc marker that does not conflict with any of
+// the given lines of content.
+func heredocMarker(lines []string) string {
+	marker := "EOT"
+	for i := 1; ; i++ {
+		conflict := false
+		for _, line := range lines {
+			if strings.TrimSpace(line) == marker {
+				conflict = true
+				break
+			}
+		}
+		if !conflict {
+			return marker
+		}
+		marker = fmt.Sprintf("EOT%d", i)
+	}
+}
+
+// escapeHeredocLit escapes the template introducers in the given string so
+// that it can be used literally in the content of a heredoc. Backslash
+// escapes are not recognized in heredocs, so nothing else needs escaping.
+func escapeHeredocLit(s string) []byte {
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		buf = append(buf, s[i])
+		if (s[i] == '$' || s[i] == '%') && i+1 < len(s) && s[i+1] == '{' {
+			// Double up our template introducer symbol to escape it.
+			buf = append(buf, s[i])
+		}
+	}
+	return buf
+}
+
 func escapeQuotedStringLit(s string) []byte {
 	if len(s) == 0 {
 		return nil
@@ -0,0 +1,479 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hclwrite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Precedence levels for the purpose of deciding where parentheses are needed
+// when generating tokens for an expression tree. Higher levels bind more
+// tightly.
+const (
+	precConditional = iota
+	precOr
+	precAnd
+	precEquality
+	precComparison
+	precAdditive
+	precMultiplicative
+	precUnary
+	precPrimary
+)
+
+var binaryOpTokens = map[*hclsyntax.Operation]struct {
+	typ  hclsyntax.TokenType
+	src  string
+	prec int
+}{
+	hclsyntax.OpLogicalOr:          {hclsyntax.TokenOr, "||", precOr},
+	hclsyntax.OpLogicalAnd:         {hclsyntax.TokenAnd, "&&", precAnd},
+	hclsyntax.OpEqual:              {hclsyntax.TokenEqualOp, "==", precEquality},
+	hclsyntax.OpNotEqual:           {hclsyntax.TokenNotEqual, "!=", precEquality},
+	hclsyntax.OpGreaterThan:        {hclsyntax.TokenGreaterThan, ">", precComparison},
+	hclsyntax.OpGreaterThanOrEqual: {hclsyntax.TokenGreaterThanEq, ">=", precComparison},
+	hclsyntax.OpLessThan:           {hclsyntax.TokenLessThan, "<", precComparison},
+	hclsyntax.OpLessThanOrEqual:    {hclsyntax.TokenLessThanEq, "<=", precComparison},
+	hclsyntax.OpAdd:                {hclsyntax.TokenPlus, "+", precAdditive},
+	hclsyntax.OpSubtract:           {hclsyntax.TokenMinus, "-", precAdditive},
+	hclsyntax.OpMultiply:           {hclsyntax.TokenStar, "*", precMultiplicative},
+	hclsyntax.OpDivide:             {hclsyntax.TokenSlash, "/", precMultiplicative},
+	hclsyntax.OpModulo:             {hclsyntax.TokenPercent, "%", precMultiplicative},
+}
+
+// exprPrecedence returns the precedence level of the given expression, as
+// it would be rendered by appendTokensForExpr.
+func exprPrecedence(expr hcl.Expression) int {
+	switch e := expr.(type) {
+	case *hclsyntax.ConditionalExpr:
+		return precConditional
+	case *hclsyntax.BinaryOpExpr:
+		if op, ok := binaryOpTokens[e.Op]; ok {
+			return op.prec
+		}
+	case *hclsyntax.UnaryOpExpr:
+		return precUnary
+	case *hclsyntax.LiteralValueExpr:
+		// Negative numbers are rendered with a leading minus sign, and so
+		// behave like a unary operation.
+		if v := e.Val; v.IsKnown() && !v.IsNull() && v.Type() == cty.Number && v.LessThan(cty.Zero).True() {
+			return precUnary
+		}
+	}
+	return precPrimary
+}
+
+// appendTokensForExpr appends tokens representing the given expression,
+// adding parentheses around it if its precedence is lower than minPrec.
+//
+// Native syntax expressions are rendered by walking their syntax tree. Any
+// other expression is rendered using its static traversal or, if it has no
+// variables, its constant value. This panics if given an expression that
+// cannot be rendered in either of those ways.
+func appendTokensForExpr(expr hcl.Expression, minPrec int, toks Tokens) Tokens {
+	if exprPrecedence(expr) < minPrec {
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenOParen,
+			Bytes: []byte{'('},
+		})
+		toks = appendTokensForExpr(expr, precConditional, toks)
+		return append(toks, &Token{
+			Type:  hclsyntax.TokenCParen,
+			Bytes: []byte{')'},
+		})
+	}
+
+	switch e := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		return appendTokensForValue(e.Val, toks, false)
+
+	case *hclsyntax.ScopeTraversalExpr:
+		return appendTokensForTraversal(e.Traversal, toks)
+
+	case *hclsyntax.RelativeTraversalExpr:
+		toks = appendTokensForExpr(e.Source, precPrimary, toks)
+		return appendTokensForTraversal(e.Traversal, toks)
+
+	case *hclsyntax.IndexExpr:
+		toks = appendTokensForExpr(e.Collection, precPrimary, toks)
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenOBrack,
+			Bytes: []byte{'['},
+		})
+		toks = appendTokensForExpr(e.Key, precConditional, toks)
+		return append(toks, &Token{
+			Type:  hclsyntax.TokenCBrack,
+			Bytes: []byte{']'},
+		})
+
+	case *hclsyntax.SplatExpr:
+		toks = appendTokensForExpr(e.Source, precPrimary, toks)
+		toks = append(toks,
+			&Token{
+				Type:  hclsyntax.TokenOBrack,
+				Bytes: []byte{'['},
+			},
+			&Token{
+				Type:  hclsyntax.TokenStar,
+				Bytes: []byte{'*'},
+			},
+			&Token{
+				Type:  hclsyntax.TokenCBrack,
+				Bytes: []byte{']'},
+			},
+		)
+		// The splat item symbol at the root of Each renders as nothing, so
+		// that the remaining traversal follows directly from the [*].
+		return appendTokensForExpr(e.Each, precPrimary, toks)
+
+	case *hclsyntax.AnonSymbolExpr:
+		return toks
+
+	case *hclsyntax.ParenthesesExpr:
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenOParen,
+			Bytes: []byte{'('},
+		})
+		toks = appendTokensForExpr(e.Expression, precConditional, toks)
+		return append(toks, &Token{
+			Type:  hclsyntax.TokenCParen,
+			Bytes: []byte{')'},
+		})
+
+	case *hclsyntax.FunctionCallExpr:
+		toks = append(toks, newIdentToken(e.Name), &Token{
+			Type:  hclsyntax.TokenOParen,
+			Bytes: []byte{'('},
+		})
+		for i, arg := range e.Args {
+			if i > 0 {
+				toks = append(toks, &Token{
+					Type:  hclsyntax.TokenComma,
+					Bytes: []byte{','},
+				})
+			}
+			toks = appendTokensForExpr(arg, precConditional, toks)
+		}
+		if e.ExpandFinal {
+			toks = append(toks, &Token{
+				Type:  hclsyntax.TokenEllipsis,
+				Bytes: []byte("..."),
+			})
+		}
+		return append(toks, &Token{
+			Type:  hclsyntax.TokenCParen,
+			Bytes: []byte{')'},
+		})
+
+	case *hclsyntax.ConditionalExpr:
+		return appendTokensForConditional(e.Condition, e.TrueResult, e.FalseResult, toks)
+
+	case *hclsyntax.BinaryOpExpr:
+		op, ok := binaryOpTokens[e.Op]
+		if !ok {
+			break
+		}
+		// Binary operators are left-associative, so the right operand
+		// needs parentheses even at the same level of precedence.
+		toks = appendTokensForExpr(e.LHS, op.prec, toks)
+		toks = append(toks, &Token{
+			Type:  op.typ,
+			Bytes: []byte(op.src),
+		})
+		return appendTokensForExpr(e.RHS, op.prec+1, toks)
+
+	case *hclsyntax.UnaryOpExpr:
+		switch e.Op {
+		case hclsyntax.OpLogicalNot:
+			toks = append(toks, &Token{
+				Type:  hclsyntax.TokenBang,
+				Bytes: []byte{'!'},
+			})
+		case hclsyntax.OpNegate:
+			toks = append(toks, &Token{
+				Type:  hclsyntax.TokenMinus,
+				Bytes: []byte{'-'},
+			})
+		default:
+			panic(fmt.Sprintf("cannot produce tokens for unary operation %#v", e.Op))
+		}
+		return appendTokensForExpr(e.Val, precUnary, toks)
+
+	case *hclsyntax.TupleConsExpr:
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenOBrack,
+			Bytes: []byte{'['},
+		})
+		for i, elem := range e.Exprs {
+			if i > 0 {
+				toks = append(toks, &Token{
+					Type:  hclsyntax.TokenComma,
+					Bytes: []byte{','},
+				})
+			}
+			toks = appendTokensForExpr(elem, precConditional, toks)
+		}
+		return append(toks, &Token{
+			Type:  hclsyntax.TokenCBrack,
+			Bytes: []byte{']'},
+		})
+
+	case *hclsyntax.ObjectConsExpr:
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenOBrace,
+			Bytes: []byte{'{'},
+		})
+		if len(e.Items) > 0 {
+			toks = append(toks, &Token{
+				Type:  hclsyntax.TokenNewline,
+				Bytes: []byte{'\n'},
+			})
+		}
+		for _, item := range e.Items {
+			toks = appendTokensForObjectKey(item.KeyExpr, toks)
+			toks = append(toks, &Token{
+				Type:  hclsyntax.TokenEqual,
+				Bytes: []byte{'='},
+			})
+			toks = appendTokensForExpr(item.ValueExpr, precConditional, toks)
+			toks = append(toks, &Token{
+				Type:  hclsyntax.TokenNewline,
+				Bytes: []byte{'\n'},
+			})
+		}
+		return append(toks, &Token{
+			Type:  hclsyntax.TokenCBrace,
+			Bytes: []byte{'}'},
+		})
+
+	case *hclsyntax.ForExpr:
+		return appendTokensForFor(e, toks)
+
+	case *hclsyntax.TemplateExpr:
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenOQuote,
+			Bytes: []byte{'"'},
+		})
+		toks = appendTokensForTemplateParts(e.Parts, toks)
+		return append(toks, &Token{
+			Type:  hclsyntax.TokenCQuote,
+			Bytes: []byte{'"'},
+		})
+
+	case *hclsyntax.TemplateWrapExpr:
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenOQuote,
+			Bytes: []byte{'"'},
+		})
+		toks = appendTokensForTemplateParts([]hclsyntax.Expression{e.Wrapped}, toks)
+		return append(toks, &Token{
+			Type:  hclsyntax.TokenCQuote,
+			Bytes: []byte{'"'},
+		})
+
+	case *hclsyntax.TemplateJoinExpr:
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenOQuote,
+			Bytes: []byte{'"'},
+		})
+		toks = appendTokensForTemplateParts([]hclsyntax.Expression{e}, toks)
+		return append(toks, &Token{
+			Type:  hclsyntax.TokenCQuote,
+			Bytes: []byte{'"'},
+		})
+	}
+
+	if traversal, diags := hcl.AbsTraversalForExpr(expr); !diags.HasErrors() {
+		return appendTokensForTraversal(traversal, toks)
+	}
+	if len(expr.Variables()) == 0 {
+		if val, diags := expr.Value(nil); !diags.HasErrors() && val.IsWhollyKnown() {
+			return appendTokensForValue(val, toks, false)
+		}
+	}
+	panic(fmt.Sprintf("cannot produce tokens for expression of type %T", expr))
+}
+
+// appendTokensForConditional appends tokens for a conditional expression with
+// the given operands.
+func appendTokensForConditional(cond, trueExpr, falseExpr hcl.Expression, toks Tokens) Tokens {
+	toks = appendTokensForExpr(cond, precOr, toks)
+	toks = append(toks, &Token{
+		Type:  hclsyntax.TokenQuestion,
+		Bytes: []byte{'?'},
+	})
+	toks = appendTokensForExpr(trueExpr, precConditional, toks)
+	toks = append(toks, &Token{
+		Type:  hclsyntax.TokenColon,
+		Bytes: []byte{':'},
+	})
+	return appendTokensForExpr(falseExpr, precConditional, toks)
+}
+
+// appendTokensForObjectKey appends tokens for the key of an object
+// constructor item, which is interpreted as a literal attribute name if it
+// is a single identifier.
+func appendTokensForObjectKey(expr hcl.Expression, toks Tokens) Tokens {
+	forceNonLiteral := false
+	if key, ok := expr.(*hclsyntax.ObjectConsKeyExpr); ok {
+		expr = key.Wrapped
+		forceNonLiteral = key.ForceNonLiteral
+	}
+
+	if traversal, diags := hcl.AbsTraversalForExpr(expr); !diags.HasErrors() && !(forceNonLiteral && len(traversal) == 1) {
+		if len(traversal) == 1 {
+			return appendTokensForTraversal(traversal, toks)
+		}
+		// A longer traversal would be misinterpreted as a literal name
+		// followed by garbage, so it must be in parentheses.
+		forceNonLiteral = true
+	}
+	if _, isParens := expr.(*hclsyntax.ParenthesesExpr); forceNonLiteral && !isParens {
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenOParen,
+			Bytes: []byte{'('},
+		})
+		toks = appendTokensForExpr(expr, precConditional, toks)
+		return append(toks, &Token{
+			Type:  hclsyntax.TokenCParen,
+			Bytes: []byte{')'},
+		})
+	}
+	return appendTokensForExpr(expr, precConditional, toks)
+}
+
+// appendTokensForFor appends tokens for a tuple or object for expression.
+func appendTokensForFor(e *hclsyntax.ForExpr, toks Tokens) Tokens {
+	open, close := hclsyntax.TokenOBrack, hclsyntax.TokenCBrack
+	openSrc, closeSrc := "[", "]"
+	if e.KeyExpr != nil {
+		open, close = hclsyntax.TokenOBrace, hclsyntax.TokenCBrace
+		openSrc, closeSrc = "{", "}"
+	}
+
+	toks = append(toks, &Token{
+		Type:  open,
+		Bytes: []byte(openSrc),
+	})
+	toks = appendTokensForForIntro(e, toks)
+	toks = append(toks, &Token{
+		Type:  hclsyntax.TokenColon,
+		Bytes: []byte{':'},
+	})
+	if e.KeyExpr != nil {
+		toks = appendTokensForExpr(e.KeyExpr, precConditional, toks)
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenFatArrow,
+			Bytes: []byte("=>"),
+		})
+	}
+	toks = appendTokensForExpr(e.ValExpr, precConditional, toks)
+	if e.Group {
+		toks = append(toks, &Token{
+			Type:  hclsyntax.TokenEllipsis,
+			Bytes: []byte("..."),
+		})
+	}
+	if e.CondExpr != nil {
+		toks = append(toks, newIdentToken("if"))
+		toks = appendTokensForExpr(e.CondExpr, precConditional, toks)
+	}
+	return append(toks, &Token{
+		Type:  close,
+		Bytes: []byte(closeSrc),
+	})
+}
+
+// appendTokensForForIntro appends the "for k, v in coll" portion of a for
+// expression or template directive.
+func appendTokensForForIntro(e *hclsyntax.ForExpr, toks Tokens) Tokens {
+	toks = append(toks, newIdentToken("for"))
+	if e.KeyVar != "" {
+		toks = append(toks, newIdentToken(e.KeyVar), &Token{
+			Type:  hclsyntax.TokenComma,
+			Bytes: []byte{','},
+		})
+	}
+	toks = append(toks, newIdentToken(e.ValVar), newIdentToken("in"))
+	return appendTokensForExpr(e.CollExpr, precConditional, toks)
+}
+
+// appendTokensForTemplateParts appends tokens for the given template parts,
+// as they would appear between the quotes of a quoted template.
+func appendTokensForTemplateParts(parts []hclsyntax.Expression, toks Tokens) Tokens {
+	for i, part := range parts {
+		if lit, ok := part.(*hclsyntax.LiteralValueExpr); ok && lit.Val.Type() == cty.String && lit.Val.IsKnown() && !lit.Val.IsNull() {
+			s := lit.Val.AsString()
+			// A literal $ or % immediately before an interpolation or
+			// directive would combine with it to form an escape sequence,
+			// so we must render it as an interpolation of its own instead.
+			var introducer string
+			if i+1 < len(parts) && (strings.HasSuffix(s, "$") || strings.HasSuffix(s, "%")) {
+				if _, nextLit := parts[i+1].(*hclsyntax.LiteralValueExpr); !nextLit {
+					introducer = s[len(s)-1:]
+					s = s[:len(s)-1]
+				}
+			}
+			if src := escapeQuotedStringLit(s); len(src) > 0 {
+				toks = append(toks, &Token{
+					Type:  hclsyntax.TokenQuotedLit,
+					Bytes: src,
+				})
+			}
+			if introducer != "" {
+				toks = appendTokensForTemplateInterp(&hclsyntax.LiteralValueExpr{Val: cty.StringVal(introducer)}, toks)
+			}
+			continue
+		}
+
+		if join, ok := part.(*hclsyntax.TemplateJoinExpr); ok {
+			if forExpr, ok := join.Tuple.(*hclsyntax.ForExpr); ok && forExpr.KeyExpr == nil && forExpr.CondExpr == nil {
+				if body, ok := forExpr.ValExpr.(*hclsyntax.TemplateExpr); ok {
+					toks = append(toks, &Token{
+						Type:  hclsyntax.TokenTemplateControl,
+						Bytes: []byte("%{"),
+					})
+					toks = appendTokensForForIntro(forExpr, toks)
+					toks = append(toks, &Token{
+						Type:  hclsyntax.TokenTemplateSeqEnd,
+						Bytes: []byte{'}'},
+					})
+					toks = appendTokensForTemplateParts(body.Parts, toks)
+					toks = append(toks,
+						&Token{
+							Type:  hclsyntax.TokenTemplateControl,
+							Bytes: []byte("%{"),
+						},
+						newIdentToken("endfor"),
+						&Token{
+							Type:  hclsyntax.TokenTemplateSeqEnd,
+							Bytes: []byte{'}'},
+						},
+					)
+					continue
+				}
+			}
+		}
+
+		toks = appendTokensForTemplateInterp(part, toks)
+	}
+	return toks
+}
+
+func appendTokensForTemplateInterp(expr hcl.Expression, toks Tokens) Tokens {
+	toks = append(toks, &Token{
+		Type:  hclsyntax.TokenTemplateInterp,
+		Bytes: []byte("${"),
+	})
+	toks = appendTokensForExpr(expr, precConditional, toks)
+	return append(toks, &Token{
+		Type:  hclsyntax.TokenTemplateSeqEnd,
+		Bytes: []byte{'}'},
+	})
+}
@@ -499,6 +499,129 @@ func TestTokensForValue(t *testing.T) {
 	}
 }
 
+func TestTokensForValue_sortedKeys(t *testing.T) {
+	keys := []string{"zebra", "alpha", "Bravo", "10", "9", "with-dash", "a b", "mike", "_x", "delta"}
+	attrs := make(map[string]cty.Value, len(keys))
+	for _, key := range keys {
+		attrs[key] = cty.StringVal(key)
+	}
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+
+	for name, val := range map[string]cty.Value{
+		"object": cty.ObjectVal(attrs),
+		"map":    cty.MapVal(attrs),
+	} {
+		t.Run(name, func(t *testing.T) {
+			want := string(TokensForValue(val).Bytes())
+			for i := 0; i < 20; i++ {
+				// Rebuilding the value from the Go map each time gives the
+				// map's random iteration order a chance to show through.
+				again := cty.ObjectVal(attrs)
+				if val.Type().IsMapType() {
+					again = cty.MapVal(attrs)
+				}
+				if got := string(TokensForValue(again).Bytes()); got != want {
+					t.Fatalf("output changed between runs\nfirst:\n%s\nlater:\n%s", want, got)
+				}
+			}
+
+			file, diags := hclsyntax.ParseConfig([]byte("a = "+want), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("result is invalid: %s", diags.Error())
+			}
+			items := file.Body.(*hclsyntax.Body).Attributes["a"].Expr.(*hclsyntax.ObjectConsExpr).Items
+			var gotKeys []string
+			for _, item := range items {
+				key, diags := item.KeyExpr.Value(nil)
+				if diags.HasErrors() {
+					t.Fatalf("invalid key: %s", diags.Error())
+				}
+				gotKeys = append(gotKeys, key.AsString())
+			}
+			if diff := cmp.Diff(sorted, gotKeys); diff != "" {
+				t.Errorf("keys not in lexical order\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTokensForValueWithHeredocs(t *testing.T) {
+	tests := map[string]struct {
+		Val  cty.Value
+		Want string
+	}{
+		"single line": {
+			cty.StringVal("hello\n"),
+			`"hello\n"`,
+		},
+		"no trailing newline": {
+			cty.StringVal("hello\nworld"),
+			`"hello\nworld"`,
+		},
+		"multi-line": {
+			cty.StringVal("hello\n  world\n"),
+			"<<-EOT\n  hello\n    world\nEOT",
+		},
+		"blank lines": {
+			cty.StringVal("hello\n\n  \nworld\n"),
+			"<<-EOT\n  hello\n\n  \n  world\nEOT",
+		},
+		"template sequences": {
+			cty.StringVal("${hello}\n%{world}\n"),
+			"<<-EOT\n  $${hello}\n  %%{world}\nEOT",
+		},
+		"marker conflict": {
+			cty.StringVal("hello\nEOT\n"),
+			"<<-EOT1\n  hello\n  EOT\nEOT1",
+		},
+		"all lines indented": {
+			cty.StringVal("  hello\n  world\n"),
+			`"  hello\n  world\n"`,
+		},
+		"carriage returns": {
+			cty.StringVal("hello\r\nworld\r\n"),
+			`"hello\r\nworld\r\n"`,
+		},
+		"object": {
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal("hello\nworld\n"),
+			}),
+			"{\n  a = <<-EOT\n    hello\n    world\n  EOT\n}",
+		},
+		"tuple": {
+			cty.TupleVal([]cty.Value{
+				cty.StringVal("hello\nworld\n"),
+				cty.StringVal("foo"),
+			}),
+			"[<<-EOT\n  hello\n  world\nEOT\n, \"foo\"]",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := string(TokensForValueWithHeredocs(test.Val).Bytes())
+			if got != test.Want {
+				t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, test.Want)
+			}
+
+			// The result must also be valid and produce the same value. A
+			// heredoc closing marker must be followed by a newline.
+			expr, diags := hclsyntax.ParseExpression([]byte(got+"\n"), "", hcl.InitialPos)
+			if diags.HasErrors() {
+				t.Fatalf("result is invalid: %s", diags)
+			}
+			val, diags := expr.Value(nil)
+			if diags.HasErrors() {
+				t.Fatalf("result is invalid: %s", diags)
+			}
+			if !val.RawEquals(test.Val) {
+				t.Errorf("wrong value\ngot:  %#v\nwant: %#v", val, test.Val)
+			}
+		})
+	}
+}
+
 func TestTokensForTraversal(t *testing.T) {
 	tests := []struct {
 		Val  hcl.Traversal
@@ -5,6 +5,7 @@ package hclwrite
 
 import (
 	"bytes"
+	"fmt"
 
 	"github.com/hashicorp/hcl/v2"
 )
@@ -45,3 +46,108 @@ func Format(src []byte) []byte {
 	tokens.WriteTo(buf)
 	return buf.Bytes()
 }
+
+// FormatOptions contains optional settings that modify the behavior of
+// FormatWithOptions. The zero value of FormatOptions selects the same
+// behavior as Format.
+type FormatOptions struct {
+	// MaxCallWidth, if greater than zero, is the maximum number of
+	// characters allowed on a line containing a function call before the
+	// arguments of that call are placed on separate lines, one argument per
+	// line with the closing parenthesis on a line of its own.
+	//
+	// Only calls that are written entirely on one line are wrapped, and
+	// calls within template interpolation sequences are left unchanged.
+	MaxCallWidth int
+
+	// IndentFlushHeredocs indents the content and closing marker of each
+	// flush heredoc, introduced with <<-, whose closing marker is indented
+	// less than the line that introduces it, so that the closing marker
+	// aligns with that line. This suits heredocs generated by
+	// TokensForValueWithHeredocs, which can't know how deeply they will be
+	// nested. The value of such a heredoc is unchanged, because a flush
+	// heredoc has the common leading whitespace of its lines removed.
+	IndentFlushHeredocs bool
+}
+
+// FormatWithOptions is a variant of Format which accepts additional options
+// to modify the resulting layout.
+//
+// Some options may insert newlines in addition to adjusting the existing
+// whitespace, but the result always has the same meaning as the input.
+func FormatWithOptions(src []byte, opts FormatOptions) []byte {
+	tokens := lexConfig(src)
+	format(tokens)
+	if opts.IndentFlushHeredocs {
+		formatHeredocs(linesForFormat(tokens))
+	}
+	if opts.MaxCallWidth > 0 {
+		tokens = wrapLongCalls(tokens, opts.MaxCallWidth)
+	}
+	buf := &bytes.Buffer{}
+	tokens.WriteTo(buf)
+	return buf.Bytes()
+}
+
+// FormatRange is a variant of Format which changes only the lines that
+// overlap the given range of byte offsets into src, leaving all other lines
+// byte-for-byte identical to the input. This is intended for editor
+// integrations that format only a region that has just been edited.
+//
+// The lines are formatted in the context of the whole file, so they get
+// the same indentation they would get from Format. If the range includes
+// only some of a group of lines that are formatted together, such as a
+// heredoc or a set of attributes whose equals signs are aligned, then the
+// range is extended to include the whole group so that the group remains
+// consistently formatted.
+//
+// An empty range, where start equals end, selects the line containing
+// start. FormatRange returns an error if the range is not within src.
+func FormatRange(src []byte, start, end int) ([]byte, error) {
+	if start < 0 || end < start || end > len(src) {
+		return nil, fmt.Errorf("invalid range %d to %d for source of %d bytes", start, end, len(src))
+	}
+
+	tokens := lexConfig(src)
+	format(tokens)
+	buf := &bytes.Buffer{}
+	tokens.WriteTo(buf)
+
+	// Formatting changes only spaces within each line, so the formatted
+	// result has the same lines as the source.
+	srcLines := bytes.SplitAfter(src, []byte{'\n'})
+	fmtLines := bytes.SplitAfter(buf.Bytes(), []byte{'\n'})
+	if len(srcLines) != len(fmtLines) {
+		return nil, fmt.Errorf("formatting changed the number of lines from %d to %d", len(srcLines), len(fmtLines))
+	}
+
+	first := bytes.Count(src[:start], []byte{'\n'})
+	last := first
+	if end > start {
+		last = bytes.Count(src[:end-1], []byte{'\n'})
+	}
+	spans := formatLineSpans(tokens)
+	for changed := true; changed; {
+		changed = false
+		for _, span := range spans {
+			if span[0] <= last && span[1] >= first && (span[0] < first || span[1] > last) {
+				if span[0] < first {
+					first = span[0]
+				}
+				if span[1] > last {
+					last = span[1]
+				}
+				changed = true
+			}
+		}
+	}
+
+	ret := make([]byte, 0, len(buf.Bytes()))
+	for i, line := range srcLines {
+		if i >= first && i <= last {
+			line = fmtLines[i]
+		}
+		ret = append(ret, line...)
+	}
+	return ret, nil
+}
@@ -1532,3 +1532,50 @@ func TestExpressionValue_Diags(t *testing.T) {
 	}
 
 }
+
+func TestUnrecognizedBlockTypes(t *testing.T) {
+	schema := &hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "name"},
+		},
+		Blocks: []hcl.BlockHeaderSchema{
+			{Type: "known"},
+		},
+	}
+
+	tests := map[string]struct {
+		src  string
+		want []string
+	}{
+		"none": {
+			`{"name": "a", "known": {}}`,
+			nil,
+		},
+		"leftovers": {
+			`{"plugin": {"a": {}}, "known": {}, "other": [{}, {}], "//": "comment"}`,
+			[]string{"other", "plugin"},
+		},
+		"undeclared attribute": {
+			// JSON can't distinguish an undeclared attribute from a block.
+			`{"name": "a", "extra": true}`,
+			[]string{"extra"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, diags := Parse([]byte(test.src), "test.json")
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse diagnostics: %s", diags.Error())
+			}
+
+			got, diags := hcl.UnrecognizedBlockTypes(file.Body, schema)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected diagnostics: %s", diags.Error())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
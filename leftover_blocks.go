@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import "sort"
+
+// PartialContentWithLeftovers is like the PartialContent method of the given
+// body, but instead of returning a body representing the remaining content
+// it returns the blocks whose types are not included in the given schema.
+//
+// This is intended for extensible configuration systems, where a host
+// application decodes the blocks it knows about and then dispatches any
+// other blocks to plugins, without needing to know their types in advance.
+//
+// Enumerating the leftover blocks requires cooperation from the body
+// implementation, via a method LeftoverBlocks() Blocks on the remaining body
+// returned from PartialContent. The native syntax bodies implement this, as
+// do bodies produced by MergeBodies as long as all of the bodies they merge
+// do. Other bodies, such as those from the JSON syntax, cannot distinguish
+// leftover blocks from leftover attributes, and so contribute no blocks to
+// the result.
+func PartialContentWithLeftovers(body Body, schema *BodySchema) (*BodyContent, Blocks, Diagnostics) {
+	content, remain, diags := body.PartialContent(schema)
+	return content, leftoverBlocks(remain), diags
+}
+
+func leftoverBlocks(remain Body) Blocks {
+	type withLeftoverBlocks interface {
+		LeftoverBlocks() Blocks
+	}
+
+	switch remain := remain.(type) {
+	case mergedBodies:
+		var ret Blocks
+		for _, body := range remain {
+			ret = append(ret, leftoverBlocks(body)...)
+		}
+		return ret
+	case withLeftoverBlocks:
+		return remain.LeftoverBlocks()
+	default:
+		return nil
+	}
+}
+
+// UnrecognizedBlockTypes returns the distinct names of the block types that
+// appear in the given body but are not declared in the given schema, in
+// lexical order.
+//
+// This allows a host application to decide which plugins to load before
+// decoding a body, based on the block types it would otherwise leave
+// unrecognized.
+//
+// The body is decoded with PartialContent, and the returned diagnostics are
+// those that produces. The native syntax bodies, and bodies produced by
+// MergeBodies, report exactly the leftover block types as described for
+// PartialContentWithLeftovers. Other bodies, such as those from the JSON
+// syntax, cannot distinguish leftover blocks from leftover attributes, and so
+// for those bodies the name of every property not covered by the schema is
+// returned, since any of them might be a block.
+func UnrecognizedBlockTypes(body Body, schema *BodySchema) ([]string, Diagnostics) {
+	_, remain, diags := body.PartialContent(schema)
+
+	seen := make(map[string]struct{})
+	collectUnrecognizedBlockTypes(remain, seen)
+	if len(seen) == 0 {
+		return nil, diags
+	}
+
+	ret := make([]string, 0, len(seen))
+	for name := range seen {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret, diags
+}
+
+func collectUnrecognizedBlockTypes(remain Body, seen map[string]struct{}) {
+	type withLeftoverBlocks interface {
+		LeftoverBlocks() Blocks
+	}
+
+	switch remain := remain.(type) {
+	case nil:
+		return
+	case mergedBodies:
+		for _, body := range remain {
+			collectUnrecognizedBlockTypes(body, seen)
+		}
+	case withLeftoverBlocks:
+		for _, block := range remain.LeftoverBlocks() {
+			seen[block.Type] = struct{}{}
+		}
+	default:
+		// The diagnostics are not interesting here, because they either
+		// repeat problems already reported by PartialContent or complain
+		// about repeated names that are valid for blocks.
+		attrs, _ := remain.JustAttributes()
+		for name := range attrs {
+			seen[name] = struct{}{}
+		}
+	}
+}
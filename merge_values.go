@@ -0,0 +1,193 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// MergeOpts contains options that modify the behavior of DeepMerge.
+type MergeOpts struct {
+	// AppendLists causes sequences in the overlay to be appended to the
+	// corresponding sequences in the base, rather than replacing them.
+	AppendLists bool
+}
+
+// DeepMerge merges the given overlay value into the given base value,
+// returning the result. This is the value-level analogue of MergeBodies.
+//
+// Objects and maps are merged recursively: the result has all of the
+// attributes or keys of both values, and where both have the same attribute
+// or key the two corresponding values are merged in turn. The result of
+// merging two maps is a map if all of the resulting elements have the same
+// type, or an object otherwise; the result of merging an object with
+// anything else is an object.
+//
+// Lists, sets, and tuples from the overlay replace the corresponding values
+// in the base, unless opts.AppendLists is set. In that case the elements of
+// the overlay are appended to the elements of the base, producing a list when
+// appending two lists of the same type, a set containing the union of the
+// elements when combining two sets of the same type, or otherwise a tuple.
+//
+// For any other value, including null values, the value from the overlay
+// takes precedence over the base value.
+//
+// If one of the values is an object or map and the other is a non-null value
+// of any other kind, or if one is a sequence and the other is a non-null
+// primitive value, DeepMerge returns a cty.PathError whose path and message
+// indicate where in the structure the mismatch occurred. If either of two
+// values that would otherwise be merged is unknown then their merged result
+// is cty.DynamicVal, because the attributes or elements are not yet known.
+// Any marks on either value are applied to the result.
+func DeepMerge(base, overlay cty.Value, opts MergeOpts) (cty.Value, error) {
+	return deepMerge(nil, base, overlay, opts)
+}
+
+func deepMerge(path cty.Path, base, overlay cty.Value, opts MergeOpts) (cty.Value, error) {
+	baseKind, overlayKind := mergeKindOf(base), mergeKindOf(overlay)
+	if baseKind == mergeKindOther || overlayKind == mergeKindOther {
+		return overlay, nil
+	}
+	if baseKind != overlayKind {
+		return cty.DynamicVal, path.NewErrorf(
+			"%scannot merge %s with %s",
+			mergePathPrefix(path), base.Type().FriendlyName(), overlay.Type().FriendlyName(),
+		)
+	}
+	if baseKind == mergeKindPrimitive || (baseKind == mergeKindSequence && !opts.AppendLists) {
+		return overlay, nil
+	}
+
+	if !base.IsKnown() || !overlay.IsKnown() {
+		return cty.DynamicVal.WithSameMarks(base, overlay), nil
+	}
+	base, baseMarks := base.Unmark()
+	overlay, overlayMarks := overlay.Unmark()
+
+	var ret cty.Value
+	var err error
+	switch baseKind {
+	case mergeKindObject:
+		ret, err = deepMergeObjects(path, base, overlay, opts)
+	case mergeKindSequence:
+		ret = appendSequences(base, overlay)
+	}
+	if err != nil {
+		return cty.DynamicVal, err
+	}
+	return ret.WithMarks(baseMarks, overlayMarks), nil
+}
+
+func deepMergeObjects(path cty.Path, base, overlay cty.Value, opts MergeOpts) (cty.Value, error) {
+	vals := base.AsValueMap()
+	if vals == nil {
+		vals = make(map[string]cty.Value)
+	}
+	for it := overlay.ElementIterator(); it.Next(); {
+		k, overlayVal := it.Element()
+		key := k.AsString()
+		baseVal, exists := vals[key]
+		if !exists {
+			vals[key] = overlayVal
+			continue
+		}
+
+		var keyPath cty.Path
+		if overlay.Type().IsObjectType() {
+			keyPath = path.GetAttr(key)
+		} else {
+			keyPath = path.Index(k)
+		}
+		merged, err := deepMerge(keyPath, baseVal, overlayVal, opts)
+		if err != nil {
+			return cty.DynamicVal, err
+		}
+		vals[key] = merged
+	}
+
+	if base.Type().IsMapType() && overlay.Type().IsMapType() {
+		if len(vals) == 0 {
+			return cty.MapValEmpty(base.Type().ElementType()), nil
+		}
+		var ety cty.Type
+		consistent := true
+		for _, val := range vals {
+			if ety == cty.NilType {
+				ety = val.Type()
+			} else if !val.Type().Equals(ety) {
+				consistent = false
+				break
+			}
+		}
+		if consistent {
+			return cty.MapVal(vals), nil
+		}
+	}
+	return cty.ObjectVal(vals), nil
+}
+
+func appendSequences(base, overlay cty.Value) cty.Value {
+	baseTy, overlayTy := base.Type(), overlay.Type()
+	vals := append(base.AsValueSlice(), overlay.AsValueSlice()...)
+	switch {
+	case baseTy.IsListType() && overlayTy.Equals(baseTy):
+		if len(vals) == 0 {
+			return cty.ListValEmpty(baseTy.ElementType())
+		}
+		return cty.ListVal(vals)
+	case baseTy.IsSetType() && overlayTy.Equals(baseTy):
+		if len(vals) == 0 {
+			return cty.SetValEmpty(baseTy.ElementType())
+		}
+		return cty.SetVal(vals)
+	default:
+		return cty.TupleVal(vals)
+	}
+}
+
+type mergeKind int
+
+const (
+	// mergeKindOther is for null values and values of unknown type, which
+	// can be merged with values of any other kind.
+	mergeKindOther mergeKind = iota
+	mergeKindPrimitive
+	mergeKindSequence
+	mergeKindObject
+)
+
+func mergeKindOf(val cty.Value) mergeKind {
+	ty := val.Type()
+	switch {
+	case val.IsNull() || ty == cty.DynamicPseudoType:
+		return mergeKindOther
+	case ty.IsObjectType() || ty.IsMapType():
+		return mergeKindObject
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		return mergeKindSequence
+	default:
+		return mergeKindPrimitive
+	}
+}
+
+// mergePathPrefix returns a prefix for an error message describing the given
+// path using attribute and index syntax, or an empty string for the root.
+func mergePathPrefix(path cty.Path) string {
+	if len(path) == 0 {
+		return ""
+	}
+	var buf strings.Builder
+	for _, step := range path {
+		switch step := step.(type) {
+		case cty.GetAttrStep:
+			buf.WriteString("." + step.Name)
+		case cty.IndexStep:
+			buf.WriteString(fmt.Sprintf("[%q]", step.Key.AsString()))
+		}
+	}
+	return fmt.Sprintf("at %s: ", buf.String())
+}
@@ -0,0 +1,200 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestDeepMerge(t *testing.T) {
+	tests := map[string]struct {
+		base    cty.Value
+		overlay cty.Value
+		opts    MergeOpts
+		want    cty.Value
+		wantErr string
+	}{
+		"scalar": {
+			cty.StringVal("base"),
+			cty.StringVal("overlay"),
+			MergeOpts{},
+			cty.StringVal("overlay"),
+			``,
+		},
+		"null overlay": {
+			cty.StringVal("base"),
+			cty.NullVal(cty.String),
+			MergeOpts{},
+			cty.NullVal(cty.String),
+			``,
+		},
+		"nested objects": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("base"),
+				"settings": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.NumberIntVal(1),
+					"b": cty.NumberIntVal(2),
+				}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"settings": cty.ObjectVal(map[string]cty.Value{
+					"b": cty.NumberIntVal(3),
+					"c": cty.NumberIntVal(4),
+				}),
+				"extra": cty.True,
+			}),
+			MergeOpts{},
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("base"),
+				"settings": cty.ObjectVal(map[string]cty.Value{
+					"a": cty.NumberIntVal(1),
+					"b": cty.NumberIntVal(3),
+					"c": cty.NumberIntVal(4),
+				}),
+				"extra": cty.True,
+			}),
+			``,
+		},
+		"maps": {
+			cty.MapVal(map[string]cty.Value{
+				"a": cty.StringVal("base a"),
+				"b": cty.StringVal("base b"),
+			}),
+			cty.MapVal(map[string]cty.Value{
+				"b": cty.StringVal("overlay b"),
+			}),
+			MergeOpts{},
+			cty.MapVal(map[string]cty.Value{
+				"a": cty.StringVal("base a"),
+				"b": cty.StringVal("overlay b"),
+			}),
+			``,
+		},
+		"maps with inconsistent results": {
+			cty.MapVal(map[string]cty.Value{
+				"a": cty.StringVal("base a"),
+			}),
+			cty.MapVal(map[string]cty.Value{
+				"b": cty.True,
+			}),
+			MergeOpts{},
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal("base a"),
+				"b": cty.True,
+			}),
+			``,
+		},
+		"lists replaced": {
+			cty.ObjectVal(map[string]cty.Value{
+				"list": cty.ListVal([]cty.Value{cty.StringVal("a")}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"list": cty.ListVal([]cty.Value{cty.StringVal("b")}),
+			}),
+			MergeOpts{},
+			cty.ObjectVal(map[string]cty.Value{
+				"list": cty.ListVal([]cty.Value{cty.StringVal("b")}),
+			}),
+			``,
+		},
+		"lists appended": {
+			cty.ObjectVal(map[string]cty.Value{
+				"list": cty.ListVal([]cty.Value{cty.StringVal("a")}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"list": cty.ListVal([]cty.Value{cty.StringVal("b")}),
+			}),
+			MergeOpts{AppendLists: true},
+			cty.ObjectVal(map[string]cty.Value{
+				"list": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+			}),
+			``,
+		},
+		"sets appended": {
+			cty.SetVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+			cty.SetVal([]cty.Value{cty.StringVal("b"), cty.StringVal("c")}),
+			MergeOpts{AppendLists: true},
+			cty.SetVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b"), cty.StringVal("c")}),
+			``,
+		},
+		"mixed sequences appended": {
+			cty.ListVal([]cty.Value{cty.StringVal("a")}),
+			cty.TupleVal([]cty.Value{cty.True}),
+			MergeOpts{AppendLists: true},
+			cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.True}),
+			``,
+		},
+		"marks": {
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal("a"),
+			}).Mark("base"),
+			cty.ObjectVal(map[string]cty.Value{
+				"b": cty.StringVal("b"),
+			}).Mark("overlay"),
+			MergeOpts{},
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal("a"),
+				"b": cty.StringVal("b"),
+			}).WithMarks(cty.NewValueMarks("base", "overlay")),
+			``,
+		},
+		"unknown": {
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.StringVal("a"),
+			}),
+			cty.UnknownVal(cty.Object(map[string]cty.Type{
+				"b": cty.String,
+			})),
+			MergeOpts{},
+			cty.DynamicVal,
+			``,
+		},
+		"type mismatch": {
+			cty.ObjectVal(map[string]cty.Value{
+				"settings": cty.ObjectVal(map[string]cty.Value{
+					"tags": cty.MapVal(map[string]cty.Value{
+						"env": cty.ListValEmpty(cty.String),
+					}),
+				}),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"settings": cty.ObjectVal(map[string]cty.Value{
+					"tags": cty.MapVal(map[string]cty.Value{
+						"env": cty.StringVal("prod"),
+					}),
+				}),
+			}),
+			MergeOpts{},
+			cty.DynamicVal,
+			`at .settings.tags["env"]: cannot merge list of string with string`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := DeepMerge(test.base, test.overlay, test.opts)
+
+			if test.wantErr != "" {
+				if err == nil {
+					t.Fatalf("unexpected success\nwant error: %s", test.wantErr)
+				}
+				if got := err.Error(); got != test.wantErr {
+					t.Fatalf("wrong error\ngot:  %s\nwant: %s", got, test.wantErr)
+				}
+				if _, ok := err.(cty.PathError); !ok {
+					t.Errorf("error is %T, not cty.PathError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
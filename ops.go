@@ -87,6 +87,12 @@ func Index(collection, key cty.Value, srcRange *Range) (cty.Value, Diagnostics)
 		has, _ := collection.HasIndex(key).Unmark()
 		if !has.IsKnown() {
 			if ty.IsTupleType() {
+				// If all of the elements have the same type then we know
+				// the result type even though we don't know which element
+				// will be selected.
+				if ety, ok := uniformType(ty.TupleElementTypes()); ok {
+					return cty.UnknownVal(ety).WithSameMarks(collection), nil
+				}
 				return cty.DynamicVal.WithSameMarks(collection), nil
 			} else {
 				return cty.UnknownVal(ty.ElementType()).WithSameMarks(collection), nil
@@ -195,9 +201,6 @@ func Index(collection, key cty.Value, srcRange *Range) (cty.Value, Diagnostics)
 				},
 			}
 		}
-		if !collection.IsKnown() {
-			return cty.DynamicVal.WithSameMarks(collection), nil
-		}
 		if !key.IsKnown() {
 			return cty.DynamicVal.WithSameMarks(collection), nil
 		}
@@ -225,6 +228,10 @@ func Index(collection, key cty.Value, srcRange *Range) (cty.Value, Diagnostics)
 			}
 		}
 
+		if !collection.IsKnown() {
+			return cty.UnknownVal(ty.AttributeType(attrName)).WithSameMarks(collection), nil
+		}
+
 		return collection.GetAttr(attrName), nil
 
 	case ty.IsSetType():
@@ -250,6 +257,20 @@ func Index(collection, key cty.Value, srcRange *Range) (cty.Value, Diagnostics)
 
 }
 
+// uniformType returns the type shared by all of the given types, or false
+// if there are no types or if they are not all equal.
+func uniformType(tys []cty.Type) (cty.Type, bool) {
+	if len(tys) == 0 {
+		return cty.NilType, false
+	}
+	for _, ty := range tys[1:] {
+		if !ty.Equals(tys[0]) {
+			return cty.NilType, false
+		}
+	}
+	return tys[0], true
+}
+
 // GetAttr is a helper function that performs the same operation as the
 // attribute access in the HCL expression language. That is, the result is the
 // same as it would be for obj.attr in a configuration expression.
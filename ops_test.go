@@ -476,6 +476,41 @@ func TestIndex(t *testing.T) {
 			want: cty.DynamicVal,
 			err:  "Invalid index",
 		},
+		"unknown list": {
+			coll: cty.UnknownVal(cty.List(cty.String)),
+			key:  cty.NumberIntVal(0),
+			want: cty.UnknownVal(cty.String),
+		},
+		"unknown tuple with unknown key and uniform element types": {
+			coll: cty.UnknownVal(cty.Tuple([]cty.Type{cty.String, cty.String})),
+			key:  cty.UnknownVal(cty.Number),
+			want: cty.UnknownVal(cty.String),
+		},
+		"unknown key into tuple with uniform element types": {
+			coll: cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+			key:  cty.UnknownVal(cty.Number),
+			want: cty.UnknownVal(cty.String),
+		},
+		"unknown key into tuple with mixed element types": {
+			coll: cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.True}),
+			key:  cty.UnknownVal(cty.Number),
+			want: cty.DynamicVal,
+		},
+		"unknown object": {
+			coll: cty.UnknownVal(cty.Object(map[string]cty.Type{
+				"foo": cty.String,
+			})).Mark("marked"),
+			key:  cty.StringVal("foo"),
+			want: cty.UnknownVal(cty.String).Mark("marked"),
+		},
+		"unknown object missing attribute": {
+			coll: cty.UnknownVal(cty.Object(map[string]cty.Type{
+				"foo": cty.String,
+			})),
+			key:  cty.StringVal("bar"),
+			want: cty.DynamicVal,
+			err:  "Invalid index",
+		},
 	}
 
 	for name, tc := range tests {
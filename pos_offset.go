@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"github.com/apparentlymart/go-textseg/v15/textseg"
+)
+
+// OffsetToPos returns the position of the given byte offset within the given
+// source buffer, counting lines and columns in the same way as the native
+// syntax scanner does: columns count grapheme clusters, and only LF and CRLF
+// sequences start a new line.
+//
+// If the offset falls part way through a character, including between the
+// CR and LF of a CRLF sequence, the result is the position of the start of
+// that character, and so its Byte field is less than the given offset.
+// Offsets beyond either end of the buffer are clamped to the nearest end.
+func OffsetToPos(src []byte, offset int) Pos {
+	pos := InitialPos
+	if offset <= 0 {
+		return pos
+	}
+	for pos.Byte < len(src) {
+		advance, seq, _ := textseg.ScanGraphemeClusters(src[pos.Byte:], true)
+		if pos.Byte+advance > offset {
+			break
+		}
+		pos = nextPos(pos, advance, seq)
+	}
+	return pos
+}
+
+// PosToOffset returns the byte offset within the given source buffer of the
+// given line and column, counted in the same way as for OffsetToPos. The
+// Byte field of the given position is ignored.
+//
+// If the column is beyond the end of its line then the result is the offset
+// of the newline sequence that ends the line, and if the line is beyond the
+// end of the buffer then the result is the length of the buffer. Lines and
+// columns less than one are treated as one.
+func PosToOffset(src []byte, pos Pos) int {
+	cur := InitialPos
+	for cur.Byte < len(src) {
+		if cur.Line > pos.Line || (cur.Line == pos.Line && cur.Column >= pos.Column) {
+			break
+		}
+		advance, seq, _ := textseg.ScanGraphemeClusters(src[cur.Byte:], true)
+		next := nextPos(cur, advance, seq)
+		if next.Line > cur.Line && cur.Line >= pos.Line {
+			// The requested column is beyond the end of this line.
+			break
+		}
+		cur = next
+	}
+	return cur.Byte
+}
+
+// nextPos returns the position after advancing over the given grapheme
+// cluster, which is the given number of bytes long.
+func nextPos(pos Pos, advance int, seq []byte) Pos {
+	pos.Byte += advance
+	if (len(seq) == 1 && seq[0] == '\n') || (len(seq) == 2 && seq[0] == '\r' && seq[1] == '\n') {
+		pos.Line++
+		pos.Column = 1
+	} else {
+		pos.Column++
+	}
+	return pos
+}
@@ -0,0 +1,136 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"testing"
+)
+
+func TestOffsetToPos(t *testing.T) {
+	// "e\u0301" is a latin letter with a combining diacritic, which counts
+	// as a single column.
+	src := []byte("ab\ncd\r\ne\u0301f\rg\n")
+
+	tests := map[string]struct {
+		offset int
+		want   Pos
+	}{
+		"start": {
+			0,
+			Pos{Line: 1, Column: 1, Byte: 0},
+		},
+		"first line": {
+			1,
+			Pos{Line: 1, Column: 2, Byte: 1},
+		},
+		"newline": {
+			2,
+			Pos{Line: 1, Column: 3, Byte: 2},
+		},
+		"after LF": {
+			3,
+			Pos{Line: 2, Column: 1, Byte: 3},
+		},
+		"CR of CRLF": {
+			5,
+			Pos{Line: 2, Column: 3, Byte: 5},
+		},
+		"part way through CRLF": {
+			6,
+			Pos{Line: 2, Column: 3, Byte: 5},
+		},
+		"after CRLF": {
+			7,
+			Pos{Line: 3, Column: 1, Byte: 7},
+		},
+		"part way through combining sequence": {
+			8,
+			Pos{Line: 3, Column: 1, Byte: 7},
+		},
+		"after combining sequence": {
+			10,
+			Pos{Line: 3, Column: 2, Byte: 10},
+		},
+		"after lone CR": {
+			12,
+			Pos{Line: 3, Column: 4, Byte: 12},
+		},
+		"end": {
+			14,
+			Pos{Line: 4, Column: 1, Byte: 14},
+		},
+		"negative": {
+			-1,
+			Pos{Line: 1, Column: 1, Byte: 0},
+		},
+		"beyond end": {
+			100,
+			Pos{Line: 4, Column: 1, Byte: 14},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := OffsetToPos(src, test.offset)
+			if got != test.want {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPosToOffset(t *testing.T) {
+	src := []byte("ab\ncd\r\ne\u0301f\rg\n")
+
+	tests := map[string]struct {
+		pos  Pos
+		want int
+	}{
+		"start": {
+			Pos{Line: 1, Column: 1},
+			0,
+		},
+		"first line": {
+			Pos{Line: 1, Column: 2},
+			1,
+		},
+		"second line": {
+			Pos{Line: 2, Column: 2},
+			4,
+		},
+		"after combining sequence": {
+			Pos{Line: 3, Column: 2},
+			10,
+		},
+		"after lone CR": {
+			Pos{Line: 3, Column: 4},
+			12,
+		},
+		"beyond end of line": {
+			Pos{Line: 2, Column: 10},
+			5,
+		},
+		"beyond end of buffer": {
+			Pos{Line: 10, Column: 1},
+			14,
+		},
+		"byte ignored": {
+			Pos{Line: 2, Column: 1, Byte: 100},
+			3,
+		},
+		"zero": {
+			Pos{},
+			0,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := PosToOffset(src, test.pos)
+			if got != test.want {
+				t.Errorf("wrong result %d; want %d", got, test.want)
+			}
+		})
+	}
+}
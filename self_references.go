@@ -0,0 +1,35 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ValidateSelfReferences statically checks that each reference to the
+// variable "self" in the given expression describes a valid path through a
+// value of the given type, returning error diagnostics describing any that
+// do not.
+//
+// This is intended for validating expressions ahead of evaluation, when the
+// type of "self" is known but its value is not. Only the type is checked, so
+// for example an index into a list is accepted regardless of the list's
+// eventual length. Any traversals whose root name is not "self" are ignored.
+func ValidateSelfReferences(expr Expression, selfType cty.Type) Diagnostics {
+	var diags Diagnostics
+	if expr == nil {
+		return diags
+	}
+
+	selfVal := cty.UnknownVal(selfType)
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != "self" {
+			continue
+		}
+		_, moreDiags := traversal.SimpleSplit().Rel.TraverseRel(selfVal)
+		diags = append(diags, moreDiags...)
+	}
+
+	return diags
+}
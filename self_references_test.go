@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+type selfReferencesExpr struct {
+	staticExpr
+	vars []Traversal
+}
+
+func (e selfReferencesExpr) Variables() []Traversal {
+	return e.vars
+}
+
+func TestValidateSelfReferences(t *testing.T) {
+	selfType := cty.Object(map[string]cty.Type{
+		"name": cty.String,
+		"tags": cty.Map(cty.String),
+		"ports": cty.List(cty.Object(map[string]cty.Type{
+			"number": cty.Number,
+		})),
+		"extra": cty.DynamicPseudoType,
+	})
+	rng := func(n int) Range {
+		return Range{
+			Filename: "test.hcl",
+			Start:    Pos{Line: n, Column: 1, Byte: 0},
+			End:      Pos{Line: n, Column: 2, Byte: 1},
+		}
+	}
+
+	tests := map[string]struct {
+		vars []Traversal
+		want []Range
+	}{
+		"no references": {
+			nil,
+			nil,
+		},
+		"valid references": {
+			[]Traversal{
+				{TraverseRoot{Name: "self"}, TraverseAttr{Name: "name", SrcRange: rng(1)}},
+				{TraverseRoot{Name: "self"}, TraverseAttr{Name: "tags", SrcRange: rng(2)}, TraverseAttr{Name: "anything", SrcRange: rng(3)}},
+				{TraverseRoot{Name: "self"}, TraverseAttr{Name: "ports", SrcRange: rng(4)}, TraverseIndex{Key: cty.NumberIntVal(0), SrcRange: rng(5)}, TraverseAttr{Name: "number", SrcRange: rng(6)}},
+				{TraverseRoot{Name: "self"}, TraverseAttr{Name: "extra", SrcRange: rng(7)}, TraverseAttr{Name: "whatever", SrcRange: rng(8)}},
+				{TraverseRoot{Name: "self"}},
+			},
+			nil,
+		},
+		"unknown attributes": {
+			[]Traversal{
+				{TraverseRoot{Name: "self"}, TraverseAttr{Name: "nam", SrcRange: rng(1)}},
+				{TraverseRoot{Name: "self"}, TraverseAttr{Name: "ports", SrcRange: rng(2)}, TraverseIndex{Key: cty.NumberIntVal(0), SrcRange: rng(3)}, TraverseAttr{Name: "num", SrcRange: rng(4)}},
+			},
+			[]Range{rng(1), rng(4)},
+		},
+		"other roots": {
+			[]Traversal{
+				{TraverseRoot{Name: "var"}, TraverseAttr{Name: "nonexist", SrcRange: rng(1)}},
+			},
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			diags := ValidateSelfReferences(selfReferencesExpr{vars: test.vars}, selfType)
+			if len(diags) != len(test.want) {
+				t.Fatalf("wrong number of diagnostics %d; want %d\n%s", len(diags), len(test.want), diags)
+			}
+			for i, diag := range diags {
+				if got, want := diag.Summary, "Unsupported attribute"; got != want {
+					t.Errorf("wrong summary for diagnostic %d %q; want %q", i, got, want)
+				}
+				if got, want := *diag.Subject, test.want[i]; got != want {
+					t.Errorf("wrong subject for diagnostic %d\ngot:  %#v\nwant: %#v", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -152,3 +152,18 @@ func (els Blocks) ByType() map[string]Blocks {
 	}
 	return ret
 }
+
+// LabelsValue returns the block's labels as a cty tuple of strings, which is
+// convenient for use as a key when indexing blocks by their labels.
+//
+// A block with no labels produces an empty tuple.
+func (b *Block) LabelsValue() cty.Value {
+	if len(b.Labels) == 0 {
+		return cty.EmptyTupleVal
+	}
+	vals := make([]cty.Value, len(b.Labels))
+	for i, label := range b.Labels {
+		vals[i] = cty.StringVal(label)
+	}
+	return cty.TupleVal(vals)
+}
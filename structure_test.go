@@ -0,0 +1,43 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestBlockLabelsValue(t *testing.T) {
+	tests := map[string]struct {
+		Labels []string
+		Want   cty.Value
+	}{
+		"no labels": {
+			nil,
+			cty.EmptyTupleVal,
+		},
+		"one label": {
+			[]string{"foo"},
+			cty.TupleVal([]cty.Value{cty.StringVal("foo")}),
+		},
+		"two labels": {
+			[]string{"foo", "bar"},
+			cty.TupleVal([]cty.Value{cty.StringVal("foo"), cty.StringVal("bar")}),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			block := &Block{
+				Type:   "test",
+				Labels: test.Labels,
+			}
+			got := block.LabelsValue()
+			if !got.RawEquals(test.Want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.Want)
+			}
+		})
+	}
+}
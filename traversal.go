@@ -86,6 +86,20 @@ func (t Traversal) TraverseAbs(ctx *EvalContext) (cty.Value, Diagnostics) {
 		thisCtx = thisCtx.parent
 	}
 
+	// Only once we've checked all of the static tables do we consult any
+	// resolvers, and the presence of a resolver allows variables even if
+	// there are no static tables.
+	for thisCtx = ctx; thisCtx != nil; thisCtx = thisCtx.parent {
+		if thisCtx.VariableResolver == nil {
+			continue
+		}
+		hasNonNil = true
+		val, diags, exists := thisCtx.VariableResolver(t)
+		if exists {
+			return val, diags
+		}
+	}
+
 	if !hasNonNil {
 		return cty.DynamicVal, Diagnostics{
 			{
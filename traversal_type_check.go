@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// TraversalTypeCheck checks that the given absolute traversal is valid for
+// the declared type of its root variable, without needing a value for that
+// variable, and returns the type of the traversal's result. This allows
+// applications to validate references, or to offer type-aware completion,
+// before the values they refer to are available.
+//
+// The type of each root variable is given in rootTypes. Each step of the
+// traversal is checked in the same way as it would be when traversing a
+// value of that type, so for example an attribute must exist in an object
+// type, an index into a list or tuple must be a valid whole number in range
+// for a tuple, and an index into a map must be a string. Any problems are
+// reported against the source range of the step where they occur.
+//
+// If there are errors then the returned type is cty.DynamicPseudoType. The
+// result is also cty.DynamicPseudoType if any part of the traversal passes
+// through a value of that type, since nothing more is known about the result.
+func TraversalTypeCheck(traversal Traversal, rootTypes map[string]cty.Type) (cty.Type, Diagnostics) {
+	if len(traversal) == 0 {
+		return cty.DynamicPseudoType, nil
+	}
+
+	root, ok := traversal[0].(TraverseRoot)
+	if !ok {
+		return cty.DynamicPseudoType, Diagnostics{
+			{
+				Severity: DiagError,
+				Summary:  "Relative traversal",
+				Detail:   "Only absolute traversals, starting with a variable name, can be type-checked.",
+				Subject:  traversal.SourceRange().Ptr(),
+			},
+		}
+	}
+	ty, ok := rootTypes[root.Name]
+	if !ok {
+		return cty.DynamicPseudoType, Diagnostics{
+			{
+				Severity: DiagError,
+				Summary:  "Unknown variable",
+				Detail:   fmt.Sprintf("There is no variable named %q.", root.Name),
+				Subject:  root.SrcRange.Ptr(),
+			},
+		}
+	}
+
+	for _, step := range traversal[1:] {
+		// We traverse a placeholder value that has only the information
+		// implied by the type, so that the usual traversal rules and their
+		// error messages apply.
+		placeholder := typeCheckPlaceholder(ty)
+		var result cty.Value
+		var diags Diagnostics
+		switch step := step.(type) {
+		case TraverseAttr:
+			result, diags = GetAttr(placeholder, step.Name, &step.SrcRange)
+		case TraverseIndex:
+			result, diags = Index(placeholder, step.Key, &step.SrcRange)
+		default:
+			rng := step.SourceRange()
+			return cty.DynamicPseudoType, Diagnostics{
+				{
+					Severity: DiagError,
+					Summary:  "Unsupported traversal step",
+					Detail:   "Only attribute access and index steps can be type-checked.",
+					Subject:  &rng,
+				},
+			}
+		}
+		if diags.HasErrors() {
+			return cty.DynamicPseudoType, diags
+		}
+		ty = result.Type()
+	}
+
+	return ty, nil
+}
+
+// typeCheckPlaceholder returns an unknown value of the given type, except
+// that a tuple is known so that its length is available, with unknown
+// elements.
+func typeCheckPlaceholder(ty cty.Type) cty.Value {
+	if !ty.IsTupleType() {
+		return cty.UnknownVal(ty)
+	}
+	etys := ty.TupleElementTypes()
+	if len(etys) == 0 {
+		return cty.EmptyTupleVal
+	}
+	elems := make([]cty.Value, len(etys))
+	for i, ety := range etys {
+		elems[i] = cty.UnknownVal(ety)
+	}
+	return cty.TupleVal(elems)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcl
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestTraversalTypeCheck(t *testing.T) {
+	rootTypes := map[string]cty.Type{
+		"var": cty.Object(map[string]cty.Type{
+			"foo": cty.Object(map[string]cty.Type{
+				"bar": cty.List(cty.String),
+			}),
+			"tags":  cty.Map(cty.Number),
+			"pair":  cty.Tuple([]cty.Type{cty.String, cty.Bool}),
+			"ids":   cty.Set(cty.String),
+			"extra": cty.DynamicPseudoType,
+		}),
+	}
+	rng := func(name string) Range {
+		return Range{Filename: name}
+	}
+	root := TraverseRoot{Name: "var", SrcRange: rng("root")}
+
+	tests := map[string]struct {
+		Traversal   Traversal
+		Want        cty.Type
+		WantSummary string
+		WantSubject string
+	}{
+		"root only": {
+			Traversal{root},
+			rootTypes["var"],
+			"",
+			"",
+		},
+		"nested attributes and index": {
+			Traversal{
+				root,
+				TraverseAttr{Name: "foo", SrcRange: rng("foo")},
+				TraverseAttr{Name: "bar", SrcRange: rng("bar")},
+				TraverseIndex{Key: cty.NumberIntVal(0), SrcRange: rng("index")},
+			},
+			cty.String,
+			"",
+			"",
+		},
+		"unknown attribute": {
+			Traversal{
+				root,
+				TraverseAttr{Name: "foo", SrcRange: rng("foo")},
+				TraverseAttr{Name: "baz", SrcRange: rng("baz")},
+				TraverseIndex{Key: cty.NumberIntVal(0), SrcRange: rng("index")},
+			},
+			cty.DynamicPseudoType,
+			"Unsupported attribute",
+			"baz",
+		},
+		"list with string key": {
+			Traversal{
+				root,
+				TraverseAttr{Name: "foo", SrcRange: rng("foo")},
+				TraverseAttr{Name: "bar", SrcRange: rng("bar")},
+				TraverseIndex{Key: cty.StringVal("a"), SrcRange: rng("index")},
+			},
+			cty.DynamicPseudoType,
+			"Invalid index",
+			"index",
+		},
+		"map with string key": {
+			Traversal{
+				root,
+				TraverseAttr{Name: "tags", SrcRange: rng("tags")},
+				TraverseIndex{Key: cty.StringVal("a"), SrcRange: rng("index")},
+			},
+			cty.Number,
+			"",
+			"",
+		},
+		"map with attribute syntax": {
+			Traversal{
+				root,
+				TraverseAttr{Name: "tags", SrcRange: rng("tags")},
+				TraverseAttr{Name: "a", SrcRange: rng("a")},
+			},
+			cty.Number,
+			"",
+			"",
+		},
+		"tuple element": {
+			Traversal{
+				root,
+				TraverseAttr{Name: "pair", SrcRange: rng("pair")},
+				TraverseIndex{Key: cty.NumberIntVal(1), SrcRange: rng("index")},
+			},
+			cty.Bool,
+			"",
+			"",
+		},
+		"tuple index out of range": {
+			Traversal{
+				root,
+				TraverseAttr{Name: "pair", SrcRange: rng("pair")},
+				TraverseIndex{Key: cty.NumberIntVal(2), SrcRange: rng("index")},
+			},
+			cty.DynamicPseudoType,
+			"Invalid index",
+			"index",
+		},
+		"set index": {
+			Traversal{
+				root,
+				TraverseAttr{Name: "ids", SrcRange: rng("ids")},
+				TraverseIndex{Key: cty.NumberIntVal(0), SrcRange: rng("index")},
+			},
+			cty.DynamicPseudoType,
+			"Invalid index",
+			"index",
+		},
+		"through dynamic type": {
+			Traversal{
+				root,
+				TraverseAttr{Name: "extra", SrcRange: rng("extra")},
+				TraverseAttr{Name: "anything", SrcRange: rng("anything")},
+			},
+			cty.DynamicPseudoType,
+			"",
+			"",
+		},
+		"unknown root": {
+			Traversal{
+				TraverseRoot{Name: "local", SrcRange: rng("local")},
+			},
+			cty.DynamicPseudoType,
+			"Unknown variable",
+			"local",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, diags := TraversalTypeCheck(test.Traversal, rootTypes)
+			if !got.Equals(test.Want) {
+				t.Errorf("wrong type %#v; want %#v", got, test.Want)
+			}
+			if test.WantSummary == "" {
+				if len(diags) != 0 {
+					t.Errorf("unexpected diagnostics: %s", diags.Error())
+				}
+				return
+			}
+			if len(diags) != 1 {
+				t.Fatalf("got %d diagnostics; want 1\n%s", len(diags), diags.Error())
+			}
+			if got := diags[0].Summary; got != test.WantSummary {
+				t.Errorf("wrong summary %q; want %q", got, test.WantSummary)
+			}
+			if got := diags[0].Subject.Filename; got != test.WantSubject {
+				t.Errorf("wrong subject %q; want %q", got, test.WantSubject)
+			}
+		})
+	}
+}